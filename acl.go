@@ -0,0 +1,481 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Zyxel switches filter traffic with classifiers, which match frames, and
+// policies, which act on the frames of one or more classifiers:
+//
+//	classifier web vlan 10 ethernet-type ip ip-protocol tcp destination-socket 80 destination-ip 10.0.0.5 mask-bits 32
+//	policy web classifier web forward-action discard-the-packet
+//
+// Firmware differs in the order of the arguments, in "mask-bits" versus a
+// CIDR suffix and in whether "inactive" or "active" is written out.
+
+// ACLRule is a classifier and the action of the policies using it.
+type ACLRule struct {
+	Name      string `json:"name"`
+	Active    bool   `json:"active"`
+	VLAN      int    `json:"vlan,omitempty"`
+	InPort    string `json:"in_port,omitempty"`
+	SrcMAC    string `json:"src_mac,omitempty"`
+	DstMAC    string `json:"dst_mac,omitempty"`
+	EtherType string `json:"ether_type,omitempty"`
+	Protocol  string `json:"protocol,omitempty"`
+	SrcIP     string `json:"src_ip,omitempty"`
+	DstIP     string `json:"dst_ip,omitempty"`
+	SrcPort   int    `json:"src_port,omitempty"`
+	DstPort   int    `json:"dst_port,omitempty"`
+	// Other holds the match arguments not parsed above, as written.
+	Other []string `json:"other,omitempty"`
+	// Policies name the policies using the classifier; Action is
+	// "permit", "deny" or the forward action of the switch, and empty
+	// when no policy uses it.
+	Policies []string `json:"policies"`
+	Action   string   `json:"action,omitempty"`
+}
+
+// match describes what r matches, e.g. "vlan 10, tcp 10.0.0.0/24 -> any:22".
+func (r ACLRule) match() string {
+	var parts []string
+	if r.VLAN != 0 {
+		parts = append(parts, fmt.Sprintf("vlan %d", r.VLAN))
+	}
+	if r.InPort != "" {
+		parts = append(parts, "in port "+r.InPort)
+	}
+	if r.SrcMAC != "" || r.DstMAC != "" {
+		parts = append(parts, cmp.Or(r.SrcMAC, "any")+" -> "+cmp.Or(r.DstMAC, "any"))
+	}
+	if r.EtherType != "" && r.EtherType != "ip" {
+		parts = append(parts, "ether-type "+r.EtherType)
+	}
+	if r.Protocol != "" || r.SrcIP != "" || r.DstIP != "" {
+		ends := func(ip string, port int) string {
+			s := cmp.Or(ip, "any")
+			if port != 0 {
+				s += ":" + strconv.Itoa(port)
+			}
+			return s
+		}
+		parts = append(parts, strings.TrimSpace(r.Protocol+" "+ends(r.SrcIP, r.SrcPort)+" -> "+ends(r.DstIP, r.DstPort)))
+	}
+	parts = append(parts, r.Other...)
+	if len(parts) == 0 {
+		return "any"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// aclAction names a policy's forward action.
+func aclAction(forward string) string {
+	switch forward {
+	case "discard-the-packet", "discard", "drop":
+		return "deny"
+	case "no-change", "forward":
+		return "permit"
+	}
+	return forward
+}
+
+// parseACL reads the classifiers and policies from the global commands of
+// a running-config, in the order of the classifiers.
+func parseACL(rc *RunningConfig) []ACLRule {
+	var rules []ACLRule
+	byName := make(map[string]int)
+	for _, c := range rc.Global {
+		f := strings.Fields(c)
+		if len(f) < 2 || f[0] != "classifier" {
+			continue
+		}
+		r := ACLRule{Name: f[1], Active: true, Policies: []string{}}
+		for i := 2; i < len(f); i++ {
+			key, value := f[i], ""
+			if i+1 < len(f) {
+				value = f[i+1]
+			}
+			switch key {
+			case "active":
+				continue
+			case "inactive":
+				r.Active = false
+				continue
+			case "vlan":
+				r.VLAN = atoiOr(value, 0)
+			case "source-port":
+				r.InPort = value
+			case "source-mac":
+				r.SrcMAC = value
+			case "destination-mac":
+				r.DstMAC = value
+			case "ethernet-type":
+				r.EtherType = value
+			case "ip-protocol":
+				r.Protocol = value
+			case "source-socket":
+				r.SrcPort = atoiOr(value, 0)
+			case "destination-socket":
+				r.DstPort = atoiOr(value, 0)
+			case "source-ip", "destination-ip":
+				ip := value
+				if i+3 < len(f) && f[i+2] == "mask-bits" {
+					ip += "/" + f[i+3]
+					i += 2
+				}
+				if key == "source-ip" {
+					r.SrcIP = ip
+				} else {
+					r.DstIP = ip
+				}
+			default:
+				if i+1 < len(f) {
+					r.Other = append(r.Other, key+" "+value)
+				} else {
+					r.Other = append(r.Other, key)
+				}
+			}
+			i++
+		}
+		byName[r.Name] = len(rules)
+		rules = append(rules, r)
+	}
+
+	for _, c := range rc.Global {
+		f := strings.Fields(c)
+		if len(f) < 4 || f[0] != "policy" {
+			continue
+		}
+		var classifiers []string
+		action := ""
+		for i := 2; i+1 < len(f); i++ {
+			switch f[i] {
+			case "classifier":
+				classifiers = strings.Split(f[i+1], ",")
+			case "forward-action":
+				action = aclAction(f[i+1])
+			}
+		}
+		for _, name := range classifiers {
+			if i, ok := byName[name]; ok {
+				rules[i].Policies = append(rules[i].Policies, f[1])
+				if action != "" {
+					rules[i].Action = action
+				}
+			}
+		}
+	}
+	return rules
+}
+
+var aclName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// aclSpec is a simple rule to add: a classifier and a policy of the same
+// name that permits or denies what it matches.
+type aclSpec struct {
+	name, action     string
+	vlan             int
+	inPort           string
+	srcMAC, dstMAC   string
+	protocol         string
+	srcIP, dstIP     string
+	srcPort, dstPort int
+	// cidr writes prefixes as "10.0.0.0/24", for models that take them so.
+	cidr bool
+}
+
+// ipArgs writes an IP address or CIDR prefix as "<ip> mask-bits <n>", or
+// as "<ip>/<n>" when cidr is set.
+func ipArgs(key, s string, cidr bool) ([]string, error) {
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil || ip.To4() == nil {
+			return nil, fmt.Errorf("invalid IPv4 address %q", s)
+		}
+		s += "/32"
+	}
+	ip, prefix, err := net.ParseCIDR(s)
+	if err != nil || ip.To4() == nil {
+		return nil, fmt.Errorf("invalid IPv4 prefix %q", s)
+	}
+	bits, _ := prefix.Mask.Size()
+	if cidr {
+		return []string{key, fmt.Sprintf("%s/%d", prefix.IP, bits)}, nil
+	}
+	return []string{key, prefix.IP.String(), "mask-bits", strconv.Itoa(bits)}, nil
+}
+
+// commands returns the classifier and policy commands for sp.
+func (sp aclSpec) commands() ([]string, error) {
+	if !aclName.MatchString(sp.name) {
+		return nil, fmt.Errorf("invalid rule name %q: use up to 32 letters, digits, - and _", sp.name)
+	}
+	forward := map[string]string{"permit": "no-change", "deny": "discard-the-packet"}[sp.action]
+	if forward == "" {
+		return nil, fmt.Errorf("action must be permit or deny, not %q", sp.action)
+	}
+	l3 := sp.protocol != "" || sp.srcIP != "" || sp.dstIP != ""
+	if (sp.srcPort != 0 || sp.dstPort != 0) && sp.protocol != "tcp" && sp.protocol != "udp" {
+		return nil, fmt.Errorf("--src-port and --dst-port need --proto tcp or udp")
+	}
+
+	c := []string{"classifier", sp.name}
+	if sp.vlan != 0 {
+		c = append(c, "vlan", strconv.Itoa(sp.vlan))
+	}
+	if sp.inPort != "" {
+		c = append(c, "source-port", sp.inPort)
+	}
+	for _, m := range []struct{ key, mac string }{{"source-mac", sp.srcMAC}, {"destination-mac", sp.dstMAC}} {
+		if m.mac == "" {
+			continue
+		}
+		mac, err := normalizeMAC(m.mac)
+		if err != nil {
+			return nil, err
+		}
+		c = append(c, m.key, mac)
+	}
+	if l3 {
+		c = append(c, "ethernet-type", "ip")
+	}
+	if sp.protocol != "" {
+		c = append(c, "ip-protocol", sp.protocol)
+		if sp.srcPort != 0 {
+			c = append(c, "source-socket", strconv.Itoa(sp.srcPort))
+		}
+		if sp.dstPort != 0 {
+			c = append(c, "destination-socket", strconv.Itoa(sp.dstPort))
+		}
+	}
+	for _, ip := range []struct{ key, addr string }{{"source-ip", sp.srcIP}, {"destination-ip", sp.dstIP}} {
+		if ip.addr == "" {
+			continue
+		}
+		args, err := ipArgs(ip.key, ip.addr, sp.cidr)
+		if err != nil {
+			return nil, err
+		}
+		c = append(c, args...)
+	}
+	if len(c) == 2 {
+		return nil, fmt.Errorf("rule %s matches everything; give at least one match", sp.name)
+	}
+	return []string{
+		strings.Join(c, " "),
+		fmt.Sprintf("policy %s classifier %s forward-action %s", sp.name, sp.name, forward),
+	}, nil
+}
+
+var aclCommandList = []subcommand{
+	{"show", "Show the classifiers and the action of their policies", runACLShow,
+		"a table: Name, Action, Active, Match, Policies; with --format json an array of rules with their parsed fields", nil},
+	{"add", "Add a rule that permits or denies matching traffic", runACLAdd, "nothing; status goes to stderr", nil},
+	{"remove", "Remove a rule and the policies using it", runACLRemove, "nothing; status goes to stderr", nil},
+}
+
+func runACL(fs *flag.FlagSet) func() {
+	return func() {
+		args := fs.Args()
+		if len(args) == 0 {
+			fmt.Println("Usage: zyxel acl <command> [flags]")
+			fmt.Println()
+			fmt.Println("Commands:")
+			for _, c := range aclCommandList {
+				fmt.Printf("  %-10s %s\n", c.name, c.summary)
+			}
+			os.Exit(1)
+		}
+		for _, c := range aclCommandList {
+			if c.name == args[0] {
+				c.invoke("acl "+c.name, args[1:])
+				return
+			}
+		}
+		fatal("Unknown acl command %q", args[0])
+	}
+}
+
+func runACLShow(fs *flag.FlagSet) func() {
+	cf := addConnFlags(fs)
+	format := fs.String("format", "text", tr("Output format: text or json"))
+	return func() {
+		if *format != "text" && *format != "json" {
+			fatal("--format must be text or json, not %q", *format)
+		}
+		_, s := cf.connect()
+		defer s.Close()
+		rc, err := runningConfig(s)
+		if err != nil {
+			fatal("%v", err)
+		}
+		rules := parseACL(rc)
+		if *format == "json" {
+			if rules == nil {
+				rules = []ACLRule{}
+			}
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(rules); err != nil {
+				fatal("%v", err)
+			}
+			return
+		}
+		fmt.Printf("%-16s %-8s %-7s %-40s %s\n", "Name", "Action", "Active", "Match", "Policies")
+		for _, r := range rules {
+			fmt.Printf("%-16s %-8s %-7s %-40s %s\n", r.Name, cmp.Or(r.Action, "-"), map[bool]string{true: "yes", false: "no"}[r.Active],
+				r.match(), cmp.Or(strings.Join(r.Policies, ","), "-"))
+		}
+	}
+}
+
+func runACLAdd(fs *flag.FlagSet) func() {
+	cf := addConnFlags(fs)
+	var sp aclSpec
+	fs.StringVar(&sp.action, "action", "deny", tr("permit or deny"))
+	fs.IntVar(&sp.vlan, "vlan", 0, tr("Match frames in this VLAN"))
+	inPort := fs.String("in-port", "", tr("Match frames received on this switch `port`"))
+	fs.StringVar(&sp.srcMAC, "src-mac", "", tr("Match this source MAC address"))
+	fs.StringVar(&sp.dstMAC, "dst-mac", "", tr("Match this destination MAC address"))
+	fs.StringVar(&sp.protocol, "proto", "", tr("Match this IP protocol: tcp, udp, icmp or a number"))
+	fs.StringVar(&sp.srcIP, "src-ip", "", tr("Match this source IPv4 address or prefix, e.g. 10.0.0.0/24"))
+	fs.StringVar(&sp.dstIP, "dst-ip", "", tr("Match this destination IPv4 address or prefix"))
+	fs.IntVar(&sp.srcPort, "src-port", 0, tr("Match this TCP or UDP source port"))
+	fs.IntVar(&sp.dstPort, "dst-port", 0, tr("Match this TCP or UDP destination port"))
+	save := fs.Bool("save", false, tr("Write memory after the change is verified"))
+	dryRun := addDryRunFlag(fs)
+	return func() {
+		if fs.NArg() != 1 {
+			fatal("Usage: zyxel acl add <name> --action permit|deny [match flags]")
+		}
+		sp.name = fs.Arg(0)
+		_, s := cf.connect()
+		defer s.Close()
+		s.dryRun = *dryRun
+		sp.cidr = s.model.cidr
+		if *inPort != "" {
+			list, err := s.portList(*inPort)
+			if err != nil {
+				fatal("%v", err)
+			}
+			if strings.ContainsAny(list, ",-") {
+				fatal("--in-port must be a single port")
+			}
+			sp.inPort = list
+		}
+		commands, err := sp.commands()
+		if err != nil {
+			fatal("%v", err)
+		}
+		rc, err := runningConfig(s)
+		if err != nil {
+			fatal("%v", err)
+		}
+		for _, r := range parseACL(rc) {
+			if r.Name == sp.name {
+				fatal("Rule %s already exists; remove it first", sp.name)
+			}
+		}
+		if err := s.Configure(commands, io.Discard); err != nil {
+			fatal("%v", err)
+		}
+		if s.dryRun {
+			if *save {
+				saveConfig(s)
+			}
+			return
+		}
+
+		if rc, err = runningConfig(s); err != nil {
+			fatal("%v", err)
+		}
+		added := false
+		for _, r := range parseACL(rc) {
+			if r.Name == sp.name && r.Action == sp.action {
+				added = true
+				fmt.Fprintf(os.Stderr, "Rule %s: %s %s\n", r.Name, r.Action, r.match())
+			}
+		}
+		if !added {
+			fatal("Rule %s is not in the running-config; the switch may not support these matches", sp.name)
+		}
+		if *save {
+			saveConfig(s)
+		}
+	}
+}
+
+func runACLRemove(fs *flag.FlagSet) func() {
+	cf := addConnFlags(fs)
+	save := fs.Bool("save", false, tr("Write memory after the change is verified"))
+	dryRun := addDryRunFlag(fs)
+	return func() {
+		if fs.NArg() != 1 {
+			fatal("Usage: zyxel acl remove <name>")
+		}
+		name := fs.Arg(0)
+		_, s := cf.connect()
+		defer s.Close()
+		s.dryRun = *dryRun
+		rc, err := runningConfig(s)
+		if err != nil {
+			fatal("%v", err)
+		}
+		rules := parseACL(rc)
+		var commands []string
+		for _, r := range rules {
+			if r.Name != name {
+				continue
+			}
+			// A classifier in use by a policy cannot be removed, and a
+			// policy shared with other classifiers would take them along.
+			policies := append([]string(nil), r.Policies...)
+			sort.Strings(policies)
+			for _, p := range policies {
+				for _, other := range rules {
+					if other.Name != name && slices.Contains(other.Policies, p) {
+						fatal("Policy %s also uses classifier %s; change it by hand", p, other.Name)
+					}
+				}
+				commands = append(commands, "no policy "+p)
+			}
+			commands = append(commands, "no classifier "+name)
+		}
+		if commands == nil {
+			fatal("No rule named %s", name)
+		}
+		if err := s.Configure(commands, io.Discard); err != nil {
+			fatal("%v", err)
+		}
+		if s.dryRun {
+			if *save {
+				saveConfig(s)
+			}
+			return
+		}
+
+		if rc, err = runningConfig(s); err != nil {
+			fatal("%v", err)
+		}
+		for _, r := range parseACL(rc) {
+			if r.Name == name {
+				fatal("Rule %s is still in the running-config", name)
+			}
+		}
+		fmt.Fprintf(os.Stderr, "Rule %s removed\n", name)
+		if *save {
+			saveConfig(s)
+		}
+	}
+}
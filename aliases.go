@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+)
+
+// loadAliases returns the command aliases of the config file, such as
+// "macs: show mac address-table". Without a config file there are none.
+func loadAliases() (map[string]string, error) {
+	file, _, err := loadConfigFile()
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return file.Aliases, nil
+}
+
+// expandAliases replaces the first word of each command that names an
+// alias with what it stands for, keeping the words after it, so with
+// "macs: show mac address-table", "macs vlan 10" becomes "show mac
+// address-table vlan 10". Aliases do not expand within aliases.
+func expandAliases(commands []string, aliases map[string]string) []string {
+	if len(aliases) == 0 {
+		return commands
+	}
+	expanded := make([]string, len(commands))
+	for i, c := range commands {
+		word, rest, _ := strings.Cut(strings.TrimSpace(c), " ")
+		if a, ok := aliases[word]; ok {
+			c = strings.TrimSpace(a + " " + rest)
+		}
+		expanded[i] = c
+	}
+	return expanded
+}
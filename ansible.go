@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ansibleInventory is an Ansible dynamic inventory document: one group per
+// tag plus "all", and the connection variables of each host under _meta,
+// so Ansible does not call back with --host for each of them.
+type ansibleInventory map[string]any
+
+type ansibleGroup struct {
+	Hosts []string `json:"hosts"`
+}
+
+var ansibleGroupChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// ansibleGroupName turns a tag into a valid Ansible group name.
+func ansibleGroupName(tag string) string {
+	name := ansibleGroupChars.ReplaceAllString(tag, "_")
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+// ansibleHostVars are the variables of h. Passwords are left out; Ansible
+// gets them from its own vault or --ask-pass.
+func ansibleHostVars(h Host) map[string]string {
+	vars := map[string]string{"ansible_host": h.Address}
+	if h.Port != "" {
+		vars["ansible_port"] = h.Port
+	}
+	if h.User != "" {
+		vars["ansible_user"] = h.User
+	}
+	if h.Transport != "" {
+		vars["zyxel_transport"] = h.Transport
+	}
+	if h.Uplinks != "" {
+		vars["zyxel_uplinks"] = h.Uplinks
+	}
+	return vars
+}
+
+func buildAnsibleInventory(hosts []Host) ansibleInventory {
+	all := ansibleGroup{Hosts: []string{}}
+	groups := make(map[string]*ansibleGroup)
+	hostvars := make(map[string]map[string]string)
+	for _, h := range hosts {
+		all.Hosts = append(all.Hosts, h.Name)
+		hostvars[h.Name] = ansibleHostVars(h)
+		for _, t := range h.Tags {
+			name := ansibleGroupName(t)
+			if groups[name] == nil {
+				groups[name] = &ansibleGroup{}
+			}
+			groups[name].Hosts = append(groups[name].Hosts, h.Name)
+		}
+	}
+
+	doc := ansibleInventory{
+		"all":   all,
+		"_meta": map[string]any{"hostvars": hostvars},
+	}
+	for name, g := range groups {
+		if name != "all" && name != "_meta" {
+			doc[name] = g
+		}
+	}
+	return doc
+}
+
+func runInventory(fs *flag.FlagSet) func() {
+	ff := addFleetFlags(fs)
+	format := fs.String("format", "text", tr("Output format: text or ansible"))
+	// Ansible runs a dynamic inventory script with --list, or --host to
+	// ask for the variables of one host.
+	fs.Bool("list", true, tr("List the inventory (what Ansible asks a dynamic inventory for)"))
+	host := fs.String("host", "", tr("Print the Ansible variables of one `host` only"))
+	return func() {
+		if *format != "text" && *format != "ansible" {
+			fatal("--format must be text or ansible, not %q", *format)
+		}
+		_, hosts := ff.load()
+
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		switch {
+		case *host != "":
+			for _, h := range hosts {
+				if h.Name == *host {
+					if err := enc.Encode(ansibleHostVars(h)); err != nil {
+						fatal("%v", err)
+					}
+					return
+				}
+			}
+			fatal("No inventory host named %s", *host)
+		case *format == "ansible":
+			if err := enc.Encode(buildAnsibleInventory(hosts)); err != nil {
+				fatal("%v", err)
+			}
+		default:
+			fmt.Printf("%-20s %-20s %s\n", "Name", "Address", "Tags")
+			for _, h := range hosts {
+				fmt.Printf("%-20s %-20s %s\n", h.Name, h.Address, strings.Join(h.Tags, ","))
+			}
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+// ARPEntry is one row of the ARP table.
+type ARPEntry struct {
+	IP   string `json:"ip"`
+	MAC  string `json:"mac"`
+	VLAN int    `json:"vlan"`
+	Type string `json:"type"`
+}
+
+// parseARP parses "show ip arp". Some firmware leaves out the Index
+// column, so the table then starts at the IP address.
+func parseARP(output string) []ARPEntry {
+	rows := parseTable(output, "Index")
+	if rows == nil {
+		rows = parseTable(output, "IP")
+	}
+	var entries []ARPEntry
+	for _, row := range rows {
+		mac, err := normalizeMAC(firstOf(row, "MAC Address", "MAC"))
+		if err != nil {
+			continue
+		}
+		entries = append(entries, ARPEntry{
+			IP:   firstOf(row, "IP Address", "IP"),
+			MAC:  mac,
+			VLAN: atoiOr(firstOf(row, "VLAN", "VID"), 0),
+			Type: row["Type"],
+		})
+	}
+	return entries
+}
+
+func arpTable(s *Session) ([]ARPEntry, error) {
+	out, err := s.Output(s.command("arp"))
+	if err != nil {
+		return nil, err
+	}
+	return parseARP(out), nil
+}
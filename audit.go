@@ -0,0 +1,149 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// finding is one problem reported by an audit.
+type finding struct {
+	Host    string
+	Message string
+}
+
+// findingsOutput is the stdout format shared by all audit checks.
+const findingsOutput = "one \"<host>: <problem>\" line per finding, or \"OK\"; connection errors go to stderr"
+
+var auditChecks = []subcommand{
+	{"stp", "Compare spanning-tree mode, priorities and root with the inventory", runAuditSTP, findingsOutput, nil},
+	{"mtu", "Check frame sizes match on both ends of every link", runAuditMTU, findingsOutput, nil},
+	{"vlan", "Check VLANs are carried on both ends of every link", runAuditVLAN, findingsOutput, nil},
+	{"mac", "Find duplicate and flapping MAC addresses", runAuditMAC, findingsOutput, nil},
+	{"ports", "Compare connected devices with an expected mapping", runAuditPorts, findingsOutput, nil},
+	{"errors", "Find ports whose CRC, collision or drop counters grew since the last run", runAuditErrors, findingsOutput, nil},
+	{"protection", "Find access ports without storm control or loop guard", runAuditProtection, findingsOutput, nil},
+	{"snmp", "Find default SNMP communities, shared engine IDs and switches without SNMPv3", runAuditSNMP, findingsOutput, nil},
+}
+
+func runAudit(fs *flag.FlagSet) func() {
+	return func() {
+		args := fs.Args()
+		if len(args) == 0 {
+			fmt.Println("Usage: zyxel audit <check> [flags]")
+			fmt.Println()
+			fmt.Println("Checks:")
+			for _, c := range auditChecks {
+				fmt.Printf("  %-10s %s\n", c.name, c.summary)
+			}
+			os.Exit(1)
+		}
+		for _, c := range auditChecks {
+			if c.name == args[0] {
+				c.invoke("audit "+c.name, args[1:])
+				return
+			}
+		}
+		fatal("Unknown audit check %q", args[0])
+	}
+}
+
+// reportFindings prints findings and connection errors and exits non-zero
+// if there were any.
+func reportFindings[T any](results []fleetResult[T], findings []finding) {
+	failed := false
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", r.Host.Name, r.Err)
+			failed = true
+		}
+	}
+	for _, f := range findings {
+		fmt.Printf("%s: %s\n", f.Host, f.Message)
+	}
+	if failed || len(findings) > 0 {
+		os.Exit(1)
+	}
+	fmt.Println("OK")
+}
+
+func runAuditSTP(fs *flag.FlagSet) func() {
+	ff := addFleetFlags(fs)
+	return func() {
+		inv, hosts := ff.load()
+		results := runFleet(hosts, ff, func(h Host, s *Session) (STPStatus, error) {
+			return stpStatus(s)
+		})
+
+		findings := auditSTP(inv.STP, results)
+		reportFindings(results, findings)
+	}
+}
+
+// auditSTP checks the collected status of every host against the fleet
+// intent and per-host priorities.
+func auditSTP(intent STPIntent, results []fleetResult[STPStatus]) []finding {
+	var findings []finding
+	add := func(host, format string, args ...any) {
+		findings = append(findings, finding{host, fmt.Sprintf(format, args...)})
+	}
+
+	var root *STPStatus
+	if intent.Root != "" {
+		found := false
+		for i, r := range results {
+			if r.Host.Name == intent.Root {
+				found = true
+				if r.Err == nil {
+					root = &results[i].Value
+				}
+			}
+		}
+		if !found {
+			add(intent.Root, "intended root bridge is not among the audited hosts")
+		}
+	}
+
+	for _, r := range results {
+		if r.Err != nil {
+			continue
+		}
+		st := r.Value
+		name := r.Host.Name
+
+		mode := intent.Mode
+		if r.Host.STP.Mode != "" {
+			mode = r.Host.STP.Mode
+		}
+		if mode != "" && !strings.EqualFold(st.Mode, mode) {
+			add(name, "spanning-tree mode is %q, expected %q", st.Mode, mode)
+		}
+
+		if want := r.Host.STP.Priority; want != 0 && st.Priority != want {
+			add(name, "bridge priority is %d, expected %d", st.Priority, want)
+		}
+
+		if intent.Root == "" {
+			continue
+		}
+		if name == intent.Root {
+			if !st.IsRoot {
+				add(name, "is not the root bridge (root is %s)", st.RootID)
+			}
+			continue
+		}
+		if st.IsRoot {
+			add(name, "is acting as root bridge instead of %s", intent.Root)
+		}
+		if root != nil {
+			if root.BridgeID != "" && st.RootID != "" && st.RootID != root.BridgeID {
+				add(name, "sees root %s, expected %s (%s)", st.RootID, root.BridgeID, intent.Root)
+			}
+			if root.Priority != 0 && st.Priority != 0 && st.Priority <= root.Priority {
+				add(name, "bridge priority %d is not higher than root's %d and may take over as root", st.Priority, root.Priority)
+			}
+		}
+	}
+	return findings
+}
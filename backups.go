@@ -0,0 +1,483 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Backups are kept as plain files, one directory per host under
+// <state dir>/backups, named by the UTC time they were taken.
+
+const backupLayout = "20060102T150405Z"
+
+// backup is one stored configuration.
+type backup struct {
+	Host string
+	Time time.Time
+	Path string
+}
+
+// ID is how a backup is referred to on the command line.
+func (b backup) ID() string {
+	return b.Time.UTC().Format(backupLayout)
+}
+
+func backupRoot() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "backups"), nil
+}
+
+// saveBackup stores config for host as taken at t. It reports false when a
+// backup with that time already exists.
+func saveBackup(host string, t time.Time, config string) (bool, error) {
+	root, err := backupRoot()
+	if err != nil {
+		return false, err
+	}
+	return storeConfig(root, host, t, config)
+}
+
+// storeConfig writes config for host as taken at t under root, reporting
+// false when one with that time already exists.
+func storeConfig(root, host string, t time.Time, config string) (bool, error) {
+	dir := filepath.Join(root, safeName(host))
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return false, err
+	}
+	path := filepath.Join(dir, t.UTC().Format(backupLayout)+".cfg")
+	if _, err := os.Stat(path); err == nil {
+		return false, nil
+	}
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		return false, err
+	}
+	return true, os.Chtimes(path, t, t)
+}
+
+// safeName makes host usable as a directory name.
+func safeName(host string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' {
+			return '_'
+		}
+		return r
+	}, host)
+}
+
+// listBackups returns the backups of host, or of every host when host is
+// "", oldest first.
+func listBackups(host string) ([]backup, error) {
+	root, err := backupRoot()
+	if err != nil {
+		return nil, err
+	}
+	return storedConfigs(root, host)
+}
+
+// storedConfigs lists the configurations stored under root like
+// listBackups.
+func storedConfigs(root, host string) ([]backup, error) {
+	hosts := []string{safeName(host)}
+	if host == "" {
+		entries, err := os.ReadDir(root)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		hosts = nil
+		for _, e := range entries {
+			if e.IsDir() {
+				hosts = append(hosts, e.Name())
+			}
+		}
+	}
+
+	var backups []backup
+	for _, h := range hosts {
+		files, err := os.ReadDir(filepath.Join(root, h))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		for _, f := range files {
+			id, ok := strings.CutSuffix(f.Name(), ".cfg")
+			if !ok {
+				continue
+			}
+			t, err := time.Parse(backupLayout, id)
+			if err != nil {
+				continue
+			}
+			backups = append(backups, backup{h, t, filepath.Join(root, h, f.Name())})
+		}
+	}
+	sort.SliceStable(backups, func(i, j int) bool {
+		if backups[i].Host != backups[j].Host {
+			return backups[i].Host < backups[j].Host
+		}
+		return backups[i].Time.Before(backups[j].Time)
+	})
+	return backups, nil
+}
+
+var backupCommands = []subcommand{
+	{"take", "Back up the running-config of the inventory switches", runBackupsTake,
+		"one line per switch: \"<host>: saved <id>\"", nil},
+	{"list", "List stored backups", runBackupsList,
+		"one line per backup: host, id (UTC time) and size", nil},
+	{"diff", "Show what changed between two backups of a switch", runBackupsDiff,
+		"\"@@\" separated hunks of \"- \"/\"+ \"/\"  \" prefixed config lines", nil},
+	{"grep", "Search the stored configurations", runBackupsGrep,
+		"one \"<host> <id>: <line>\" line per match", nil},
+	{"import", "Import an Oxidized or RANCID config archive", runBackupsImport,
+		"one line per host: number of backups imported", nil},
+}
+
+func runBackups(fs *flag.FlagSet) func() {
+	return func() {
+		args := fs.Args()
+		if len(args) == 0 {
+			fmt.Println("Usage: zyxel backups <command> [flags]")
+			fmt.Println()
+			fmt.Println("Commands:")
+			for _, c := range backupCommands {
+				fmt.Printf("  %-10s %s\n", c.name, c.summary)
+			}
+			os.Exit(1)
+		}
+		for _, c := range backupCommands {
+			if c.name == args[0] {
+				c.invoke("backups "+c.name, args[1:])
+				return
+			}
+		}
+		fatal("Unknown backups command %q", args[0])
+	}
+}
+
+func runBackupsTake(fs *flag.FlagSet) func() {
+	ff := addFleetFlags(fs)
+	listen := fs.String("tftp-listen", "", tr("Have the switches upload their configuration to a built-in TFTP server at this `address` or interface"))
+	return func() {
+		_, hosts := ff.load()
+		var t *tftpServer
+		if *listen != "" {
+			var err error
+			if t, err = startTFTP(*listen); err != nil {
+				fatal("%v", err)
+			}
+			defer t.Close()
+		}
+		now := time.Now()
+		type taken struct {
+			config string
+			snap   snapshot
+		}
+		results := runFleet(hosts, ff, func(h Host, s *Session) (taken, error) {
+			var tk taken
+			var err error
+			if t == nil {
+				tk.config, err = s.Output(s.command("running-config"))
+			} else {
+				tk.config, err = uploadConfig(s, t, safeName(h.Name)+".cfg")
+			}
+			if err == nil && historyEnabled() {
+				tk.snap, err = readSnapshot(h, s, snapshot{})
+			}
+			return tk, err
+		})
+
+		failed := false
+		snaps := make(map[string]snapshot)
+		for _, r := range results {
+			if r.Err == nil && strings.TrimSpace(r.Value.config) == "" {
+				r.Err = fmt.Errorf("empty running-config")
+			}
+			if r.Err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", r.Host.Name, r.Err)
+				failed = true
+				continue
+			}
+			if _, err := saveBackup(r.Host.Name, now, r.Value.config); err != nil {
+				fatal("%v", err)
+			}
+			snaps[r.Host.Name] = r.Value.snap
+			fmt.Printf("%s: saved %s\n", r.Host.Name, now.UTC().Format(backupLayout))
+		}
+		if historyEnabled() {
+			storeSnapshots(now, snaps)
+		}
+		if failed {
+			os.Exit(1)
+		}
+	}
+}
+
+// uploadConfig has the switch copy its running-config to the TFTP server
+// t as name and returns the file, byte for byte as the switch wrote it.
+func uploadConfig(s *Session, t *tftpServer, name string) (string, error) {
+	upload := t.expect(name)
+	out, err := s.Output(fmt.Sprintf("copy running-config tftp %s %s", t.IP, name))
+	if err != nil {
+		return "", err
+	}
+	if looksLikeError(out) {
+		return "", fmt.Errorf("upload failed: %s", strings.TrimSpace(out))
+	}
+	select {
+	case u := <-upload:
+		return string(u.Data), u.Err
+	case <-time.After(time.Minute):
+		return "", fmt.Errorf("the switch did not upload its configuration to %s", t.IP)
+	}
+}
+
+func runBackupsList(fs *flag.FlagSet) func() {
+	return func() {
+		backups, err := listBackups(fs.Arg(0))
+		if err != nil {
+			fatal("%v", err)
+		}
+		for _, b := range backups {
+			size := int64(0)
+			if st, err := os.Stat(b.Path); err == nil {
+				size = st.Size()
+			}
+			fmt.Printf("%-20s %s %8d\n", b.Host, b.ID(), size)
+		}
+	}
+}
+
+// findBackup returns the backup of host with the given id; "" selects the
+// latest and "-1", "-2", ... the ones before it.
+func findBackup(backups []backup, id string) (backup, error) {
+	if id == "" {
+		id = "0"
+	}
+	if n, err := strconv.Atoi(id); err == nil && n <= 0 {
+		if i := len(backups) - 1 + n; i >= 0 {
+			return backups[i], nil
+		}
+		return backup{}, fmt.Errorf("only %d backups stored", len(backups))
+	}
+	for _, b := range backups {
+		if b.ID() == id {
+			return b, nil
+		}
+	}
+	return backup{}, fmt.Errorf("no backup %s", id)
+}
+
+func runBackupsDiff(fs *flag.FlagSet) func() {
+	context := fs.Int("context", 3, tr("Unchanged lines shown around each change"))
+	return func() {
+		if fs.NArg() < 1 || fs.NArg() > 3 {
+			fatal("Usage: zyxel backups diff <host> [from-id [to-id]]")
+		}
+		backups, err := listBackups(fs.Arg(0))
+		if err != nil {
+			fatal("%v", err)
+		}
+		from, to := "-1", "0"
+		if fs.NArg() >= 2 {
+			from = fs.Arg(1)
+		}
+		if fs.NArg() == 3 {
+			to = fs.Arg(2)
+		}
+		a, err := findBackup(backups, from)
+		if err != nil {
+			fatal("%s: %v", fs.Arg(0), err)
+		}
+		b, err := findBackup(backups, to)
+		if err != nil {
+			fatal("%s: %v", fs.Arg(0), err)
+		}
+
+		old, err := os.ReadFile(a.Path)
+		if err != nil {
+			fatal("%v", err)
+		}
+		cur, err := os.ReadFile(b.Path)
+		if err != nil {
+			fatal("%v", err)
+		}
+		d := lineDiff(string(old), string(cur))
+		if !diffChanged(d) {
+			fmt.Fprintf(os.Stderr, "No changes between %s and %s\n", a.ID(), b.ID())
+			return
+		}
+		fmt.Printf("--- %s %s\n+++ %s %s\n", a.Host, a.ID(), b.Host, b.ID())
+		writeDiff(os.Stdout, d, *context)
+	}
+}
+
+func runBackupsGrep(fs *flag.FlagSet) func() {
+	all := fs.Bool("all", false, tr("Search every stored backup, not just the latest of each host"))
+	host := fs.String("host", "", tr("Only search backups of this host"))
+	return func() {
+		if fs.NArg() != 1 {
+			fatal("Usage: zyxel backups grep <regex> [--all] [--host name]")
+		}
+		re, err := regexp.Compile(fs.Arg(0))
+		if err != nil {
+			fatal("%v", err)
+		}
+		backups, err := listBackups(*host)
+		if err != nil {
+			fatal("%v", err)
+		}
+
+		for i, b := range backups {
+			if !*all && i+1 < len(backups) && backups[i+1].Host == b.Host {
+				continue
+			}
+			data, err := os.ReadFile(b.Path)
+			if err != nil {
+				fatal("%v", err)
+			}
+			for _, line := range splitLines(string(data)) {
+				if re.MatchString(line) {
+					fmt.Printf("%s %s: %s\n", b.Host, b.ID(), line)
+				}
+			}
+		}
+	}
+}
+
+func runBackupsImport(fs *flag.FlagSet) func() {
+	from := fs.String("from-oxidized", "", tr("Oxidized or RANCID archive: a git repository or a directory of config files"))
+	return func() {
+		if *from == "" {
+			fatal("Usage: zyxel backups import --from-oxidized <repo>")
+		}
+
+		var counts map[string]int
+		var err error
+		if _, statErr := os.Stat(filepath.Join(*from, ".git")); statErr == nil {
+			counts, err = importGitArchive(*from)
+		} else {
+			counts, err = importDirArchive(*from)
+		}
+		if err != nil {
+			fatal("%v", err)
+		}
+		if len(counts) == 0 {
+			fatal("No configurations found in %s", *from)
+		}
+		for _, h := range sortedStringKeys(counts) {
+			fmt.Printf("%s: %d backups imported\n", h, counts[h])
+		}
+	}
+}
+
+// archiveHost maps a file in an archive to a host name. Oxidized names
+// files after the node, optionally inside a group directory; RANCID keeps
+// them in <group>/configs/<host>.
+func archiveHost(path string) (string, bool) {
+	name := filepath.Base(path)
+	if strings.HasPrefix(name, ".") || slices.Contains([]string{"router.db", "README", "README.md"}, name) {
+		return "", false
+	}
+	return name, true
+}
+
+// countImport counts a backup of host; hosts whose backups all existed
+// already are listed with 0.
+func countImport(counts map[string]int, host string, added bool) {
+	if added {
+		counts[host]++
+	} else if _, ok := counts[host]; !ok {
+		counts[host] = 0
+	}
+}
+
+// importGitArchive imports every version of every file in the history of
+// a git repository, dated by commit time.
+func importGitArchive(repo string) (map[string]int, error) {
+	out, err := exec.Command("git", "-C", repo, "log", "--reverse", "--format=commit %H %ct",
+		"--name-only", "--diff-filter=AM").Output()
+	if err != nil {
+		return nil, fmt.Errorf("git log in %s: %w", repo, err)
+	}
+
+	counts := make(map[string]int)
+	var hash string
+	var when time.Time
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		line := sc.Text()
+		if rest, ok := strings.CutPrefix(line, "commit "); ok {
+			fields := strings.Fields(rest)
+			if len(fields) != 2 {
+				continue
+			}
+			secs, _ := strconv.ParseInt(fields[1], 10, 64)
+			hash, when = fields[0], time.Unix(secs, 0)
+			continue
+		}
+		host, ok := archiveHost(line)
+		if line == "" || !ok {
+			continue
+		}
+		content, err := exec.Command("git", "-C", repo, "show", hash+":"+line).Output()
+		if err != nil {
+			return nil, fmt.Errorf("git show %s:%s: %w", hash, line, err)
+		}
+		added, err := saveBackup(host, when, string(content))
+		if err != nil {
+			return nil, err
+		}
+		countImport(counts, host, added)
+	}
+	return counts, sc.Err()
+}
+
+// importDirArchive imports the files of a plain directory, dated by their
+// modification time.
+func importDirArchive(dir string) (map[string]int, error) {
+	counts := make(map[string]int)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if strings.HasPrefix(d.Name(), ".") && path != dir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		host, ok := archiveHost(path)
+		if !ok {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		added, err := saveBackup(host, info.ModTime(), string(content))
+		countImport(counts, host, added)
+		return err
+	})
+	return counts, err
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// CablePair is the test result of one wire pair of a port.
+type CablePair struct {
+	Port string `json:"port"`
+	Pair string `json:"pair"`
+	// Status is e.g. "Ok", "Open", "Short" or "Impedance mismatch".
+	Status string `json:"status"`
+	// LengthM is the cable length and FaultM the distance to a fault in
+	// metres; -1 when the switch does not tell.
+	LengthM int `json:"length_m"`
+	FaultM  int `json:"fault_m"`
+}
+
+// OK reports whether the pair tested fine.
+func (p CablePair) OK() bool {
+	s := strings.ToLower(p.Status)
+	return s == "ok" || s == "normal" || s == "pass"
+}
+
+// cablePortLine and cablePairLine match the "Port 5" and "Pair A: Open,
+// length 3m" lines of firmware that prints the results per port rather
+// than as a table.
+var (
+	cablePortLine = regexp.MustCompile(`(?i)^\s*port\s+(\S+?):?\s*$`)
+	cablePairLine = regexp.MustCompile(`(?i)^\s*pair\s*(\w)\s*:\s*([^,]+?)\s*(?:,\s*(?:cable\s+)?length\s*:?\s*(\d+)\s*m?)?(?:,\s*(?:distance\s+to\s+)?fault\s*:?\s*(\d+)\s*m?)?\s*$`)
+)
+
+// meters parses a length column such as "12", "12m" or "N/A".
+func meters(s string) int {
+	return atoiOr(strings.TrimSuffix(strings.TrimSpace(s), "m"), -1)
+}
+
+// parseCableDiag parses the output of "cable-diagnostics": a table with a
+// row per pair where only the first pair of a port names it, or "Port n"
+// lines each followed by "Pair A: status" lines.
+func parseCableDiag(output string) []CablePair {
+	var pairs []CablePair
+	port := ""
+	for _, row := range parseTable(output, "Port") {
+		if p := row["Port"]; p != "" {
+			port = p
+		}
+		status := firstOf(row, "Pair status", "Pair Status", "Status", "Result")
+		if port == "" || status == "" {
+			continue
+		}
+		pairs = append(pairs, CablePair{
+			Port:    port,
+			Pair:    firstOf(row, "Channel", "Pair"),
+			Status:  status,
+			LengthM: meters(firstOf(row, "Cable length", "Cable Length", "Length", "Length(m)")),
+			FaultM:  meters(firstOf(row, "Distance to fault", "Distance to Fault", "Fault Distance")),
+		})
+	}
+	if len(pairs) > 0 {
+		return pairs
+	}
+
+	port = ""
+	for _, line := range strings.Split(strings.ReplaceAll(output, "\r", ""), "\n") {
+		if m := cablePortLine.FindStringSubmatch(line); m != nil {
+			port = m[1]
+			continue
+		}
+		if m := cablePairLine.FindStringSubmatch(line); m != nil && port != "" {
+			pairs = append(pairs, CablePair{port, strings.ToUpper(m[1]), m[2], meters(m[3]), meters(m[4])})
+		}
+	}
+	return pairs
+}
+
+// cableDiagRunning matches the answer of firmware that runs the test in
+// the background.
+var cableDiagRunning = regexp.MustCompile(`(?i)in progress|testing|please wait`)
+
+// cableDiag runs the cable test on ports, a port list in the notation of
+// the switch, and repeats the command until the results are in or the
+// timeout passes.
+func cableDiag(s *Session, ports string, timeout time.Duration) ([]CablePair, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		out, err := s.Output(s.command("cable-diagnostics") + " " + ports)
+		if err != nil {
+			return nil, err
+		}
+		if pairs := parseCableDiag(out); len(pairs) > 0 {
+			return pairs, nil
+		}
+		if !cableDiagRunning.MatchString(out) {
+			return nil, fmt.Errorf("no cable diagnostics in output: %q", strings.TrimSpace(out))
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("cable diagnostics did not finish within %s", timeout)
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+func runCableDiag(fs *flag.FlagSet) func() {
+	cf := addConnFlags(fs)
+	allowUplink := fs.Bool("allow-uplink", false, tr("Test ports even if they look like uplinks"))
+	format := fs.String("format", "text", tr("Output format: text or json"))
+	timeout := fs.Duration("timeout", time.Minute, tr("How long to wait for the test to finish"))
+	return func() {
+		if fs.NArg() != 1 {
+			fatal("Usage: zyxel cable-diag <ports>")
+		}
+		if *format != "text" && *format != "json" {
+			fatal("--format must be text or json, not %q", *format)
+		}
+		ports, err := ParsePortList(fs.Arg(0))
+		if err != nil {
+			fatal("%v", err)
+		}
+		_, s := cf.connect()
+		defer s.Close()
+		// The test takes the link down for a moment.
+		if err := guardUplinks(s, ports, *allowUplink); err != nil {
+			fatal("%v", err)
+		}
+		list, err := s.portList(fs.Arg(0))
+		if err != nil {
+			fatal("%v", err)
+		}
+		pairs, err := cableDiag(s, list, *timeout)
+		if err != nil {
+			fatal("%v", err)
+		}
+
+		if *format == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(pairs); err != nil {
+				fatal("%v", err)
+			}
+			return
+		}
+		length := func(m int) string {
+			if m < 0 {
+				return "-"
+			}
+			return fmt.Sprintf("%d m", m)
+		}
+		fmt.Printf("%-8s %-4s %-20s %8s %8s\n", "Port", "Pair", "Status", "Length", "Fault")
+		for _, p := range pairs {
+			fault := "-"
+			if !p.OK() {
+				fault = length(p.FaultM)
+			}
+			fmt.Printf("%-8s %-4s %-20s %8s %8s\n", p.Port, p.Pair, p.Status, length(p.LengthM), fault)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+type collectData struct {
+	macs []macSighting
+	ips  []ipSighting
+	snap snapshot
+}
+
+// runCollect records the MAC, ARP and DHCP snooping tables of the fleet in
+// the state directory. Run it regularly (e.g. from cron) to build history.
+func runCollect(fs *flag.FlagSet) func() {
+	ff := addFleetFlags(fs)
+	return func() {
+		_, hosts := ff.load()
+		now := time.Now()
+		results := runFleet(hosts, ff, func(h Host, s *Session) (collectData, error) {
+			return collectClients(h, s, now)
+		})
+
+		failed := false
+		snaps := make(map[string]snapshot)
+		for _, r := range results {
+			if r.Err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", r.Host.Name, r.Err)
+				failed = true
+				continue
+			}
+			snaps[r.Host.Name] = r.Value.snap
+			if err := recordMACSightings(r.Value.macs); err != nil {
+				fatal("%v", err)
+			}
+			if err := recordIPSightings(r.Value.ips); err != nil {
+				fatal("%v", err)
+			}
+			fmt.Printf("%s: %d MACs, %d IP bindings\n", r.Host.Name, len(r.Value.macs), len(r.Value.ips))
+		}
+		if historyEnabled() {
+			storeSnapshots(now, snaps)
+		}
+		if failed {
+			os.Exit(1)
+		}
+	}
+}
+
+func collectClients(h Host, s *Session, now time.Time) (collectData, error) {
+	entries, err := macTable(s)
+	if err != nil {
+		return collectData{}, err
+	}
+	neighbors, err := lldpNeighbors(s)
+	if err != nil {
+		return collectData{}, err
+	}
+	d := collectData{macs: edgeSightings(h, macData{entries, neighbors}, now), snap: snapshot{MACs: nonNil(entries), Uplinks: uplinkNames(h, neighbors)}}
+	if historyEnabled() {
+		if d.snap, err = readSnapshot(h, s, d.snap); err != nil {
+			return collectData{}, err
+		}
+	}
+
+	arp, err := arpTable(s)
+	if err != nil {
+		return collectData{}, err
+	}
+	for _, e := range arp {
+		d.ips = append(d.ips, ipSighting{now, h.Name, e.MAC, e.IP, "arp"})
+	}
+
+	bindings, err := dhcpBindings(s)
+	if err != nil {
+		return collectData{}, err
+	}
+	for _, b := range bindings {
+		d.ips = append(d.ips, ipSighting{now, h.Name, b.MAC, b.IP, "dhcp-snooping"})
+	}
+	return d, nil
+}
+
+// presence is a stretch of time a MAC was seen in one place.
+type presence struct {
+	Host  string
+	Port  string
+	VLAN  int
+	First time.Time
+	Last  time.Time
+}
+
+func runClient(fs *flag.FlagSet) func() {
+	timeline := fs.Bool("timeline", false, tr("Show every place the MAC was seen, not just the last one"))
+	since := fs.Duration("since", 30*24*time.Hour, tr("How far back to look in the history"))
+	return func() {
+		if fs.NArg() != 1 {
+			fatal("Usage: zyxel client <mac> [--timeline] [--since 720h]")
+		}
+		mac, err := normalizeMAC(fs.Arg(0))
+		if err != nil {
+			fatal("%v", err)
+		}
+
+		from := time.Now().Add(-*since)
+		all, err := loadMACSightings(from)
+		if err != nil {
+			fatal("%v", err)
+		}
+		ips, err := loadIPSightings(from)
+		if err != nil {
+			fatal("%v", err)
+		}
+
+		// The newest collection per host tells whether a MAC has since gone.
+		lastRun := make(map[string]time.Time)
+		var sightings []macSighting
+		for _, s := range all {
+			if s.Time.After(lastRun[s.Host]) {
+				lastRun[s.Host] = s.Time
+			}
+			if s.MAC == mac {
+				sightings = append(sightings, s)
+			}
+		}
+		if len(sightings) == 0 {
+			fatal("MAC %s not seen in the last %s (run 'zyxel collect' to record history)", mac, *since)
+		}
+		sort.SliceStable(sightings, func(i, j int) bool { return sightings[i].Time.Before(sightings[j].Time) })
+
+		var stays []presence
+		for _, s := range sightings {
+			if n := len(stays); n > 0 && stays[n-1].Host == s.Host && stays[n-1].Port == s.Port && stays[n-1].VLAN == s.VLAN {
+				stays[n-1].Last = s.Time
+				continue
+			}
+			stays = append(stays, presence{s.Host, s.Port, s.VLAN, s.Time, s.Time})
+		}
+
+		fmt.Printf("MAC %s\n", mac)
+		var lastIP *ipSighting
+		for i := range ips {
+			if ips[i].MAC == mac && (lastIP == nil || !ips[i].Time.Before(lastIP.Time)) {
+				lastIP = &ips[i]
+			}
+		}
+		if lastIP != nil {
+			fmt.Printf("Last IP: %s (%s on %s, %s)\n", lastIP.IP, lastIP.Source, lastIP.Host, lastIP.Time.Format(timeLayout))
+		}
+
+		if !*timeline {
+			stays = stays[len(stays)-1:]
+		}
+		for i, st := range stays {
+			until := st.Last.Format(timeLayout)
+			if i == len(stays)-1 && !lastRun[st.Host].After(st.Last) {
+				until = "present"
+			}
+			fmt.Printf("%s - %-16s %s port %s VLAN %d\n", st.First.Format(timeLayout), until, st.Host, st.Port, st.VLAN)
+		}
+	}
+}
+
+const timeLayout = "2006-01-02 15:04"
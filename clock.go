@@ -0,0 +1,353 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Time is synchronized with "timesync server <address>" and "timesync
+// ntp" (the other protocols being daytime and time), and the time zone is
+// "time timezone <hhmm>", e.g. 200 for UTC+02:00 or -530 for UTC-05:30.
+// A "time daylight-saving-time" setting moves the clock an hour ahead in
+// summer.
+
+// clockConfig is the time synchronization in a running-config.
+type clockConfig struct {
+	Protocol string `json:"protocol"`
+	Server   string `json:"server"`
+	// Offset is the time zone in minutes east of UTC.
+	Offset int  `json:"utc_offset_minutes"`
+	DST    bool `json:"daylight_saving"`
+}
+
+func parseClockConfig(rc *RunningConfig) clockConfig {
+	var cc clockConfig
+	for _, c := range rc.Global {
+		f := strings.Fields(c)
+		switch {
+		case len(f) == 3 && f[0] == "timesync" && f[1] == "server":
+			cc.Server = f[2]
+		case len(f) == 2 && f[0] == "timesync":
+			cc.Protocol = f[1]
+		case len(f) == 3 && f[0] == "time" && f[1] == "timezone":
+			hhmm := atoiOr(f[2], 0)
+			cc.Offset = hhmm/100*60 + hhmm%100
+		case len(f) >= 2 && f[0] == "time" && f[1] == "daylight-saving-time":
+			cc.DST = true
+		}
+	}
+	return cc
+}
+
+// formatOffset writes a time zone as e.g. "UTC+02:00".
+func formatOffset(minutes int) string {
+	sign := "+"
+	if minutes < 0 {
+		sign, minutes = "-", -minutes
+	}
+	return fmt.Sprintf("UTC%s%02d:%02d", sign, minutes/60, minutes%60)
+}
+
+var offsetPattern = regexp.MustCompile(`^([+-]?)(\d{1,2})(?::?(\d{2}))?$`)
+
+// parseOffset parses a time zone such as "+02:00", "+0200", "UTC+2" or
+// "-5:30" into minutes east of UTC.
+func parseOffset(s string) (int, error) {
+	v := strings.TrimPrefix(strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s)), "UTC"), "GMT")
+	if v == "" {
+		return 0, nil
+	}
+	m := offsetPattern.FindStringSubmatch(v)
+	if m == nil {
+		return 0, fmt.Errorf("invalid time zone %q; use e.g. +02:00", s)
+	}
+	hours, _ := strconv.Atoi(m[2])
+	mins := 0
+	if m[3] != "" {
+		mins, _ = strconv.Atoi(m[3])
+	}
+	if hours > 14 || mins >= 60 {
+		return 0, fmt.Errorf("invalid time zone %q; use e.g. +02:00", s)
+	}
+	offset := hours*60 + mins
+	if m[1] == "-" {
+		offset = -offset
+	}
+	return offset, nil
+}
+
+// timezoneCommand sets the time zone to offset minutes east of UTC.
+func timezoneCommand(offset int) string {
+	sign := ""
+	if offset < 0 {
+		sign, offset = "-", -offset
+	}
+	return fmt.Sprintf("time timezone %s%d", sign, offset/60*100+offset%60)
+}
+
+var (
+	clockTime   = regexp.MustCompile(`\b(\d{1,2}):(\d{2}):(\d{2})\b`)
+	clockISO    = regexp.MustCompile(`\b(\d{4})-(\d{1,2})-(\d{1,2})\b`)
+	clockUSDate = regexp.MustCompile(`\b(\d{1,2})/(\d{1,2})/(\d{4})\b`)
+)
+
+// parseShowTime parses "show time", "Current Time 12:34:56" and "Current
+// Date 2026-10-14" (or 10/14/2026) lines, into the wall clock time of the
+// switch, returned as if it were UTC.
+func parseShowTime(output string) (time.Time, error) {
+	var clock, date []string
+	for _, line := range strings.Split(strings.ReplaceAll(output, "\r", ""), "\n") {
+		if m := clockTime.FindStringSubmatch(line); m != nil && clock == nil {
+			clock = m[1:]
+		}
+		if m := clockISO.FindStringSubmatch(line); m != nil && date == nil {
+			date = m[1:]
+		} else if m := clockUSDate.FindStringSubmatch(line); m != nil && date == nil {
+			date = []string{m[3], m[1], m[2]}
+		}
+	}
+	if clock == nil || date == nil {
+		return time.Time{}, fmt.Errorf("no date and time in %q output", "show time")
+	}
+	return time.Date(atoiOr(date[0], 0), time.Month(atoiOr(date[1], 0)), atoiOr(date[2], 0),
+		atoiOr(clock[0], 0), atoiOr(clock[1], 0), atoiOr(clock[2], 0), 0, time.UTC), nil
+}
+
+// clockStatus is the time synchronization of a switch and how far its
+// clock is off.
+type clockStatus struct {
+	clockConfig
+	Timezone string        `json:"timezone"`
+	Time     time.Time     `json:"time"`
+	Drift    time.Duration `json:"drift_ns"`
+}
+
+// readClock reads the configuration and the clock of a switch. The drift
+// is the switch's time minus ours, with the time zone taken out; with
+// daylight saving time set, the hour closer to ours is assumed to apply.
+func readClock(s *Session) (clockStatus, error) {
+	rc, err := runningConfig(s)
+	if err != nil {
+		return clockStatus{}, err
+	}
+	cc := parseClockConfig(rc)
+	now := time.Now()
+	out, err := s.Output(s.command("clock"))
+	if err != nil {
+		return clockStatus{}, err
+	}
+	wall, err := parseShowTime(out)
+	if err != nil {
+		return clockStatus{}, err
+	}
+	st := clockStatus{clockConfig: cc, Timezone: formatOffset(cc.Offset)}
+	st.Time = wall.Add(-time.Duration(cc.Offset) * time.Minute)
+	st.Drift = st.Time.Sub(now).Truncate(time.Second)
+	if cc.DST {
+		if summer := st.Drift - time.Hour; summer.Abs() < st.Drift.Abs() {
+			st.Time, st.Drift = st.Time.Add(-time.Hour), summer
+		}
+	}
+	return st, nil
+}
+
+// clockProblems lists what is wrong with st: no NTP, another server or
+// time zone than wanted, or a clock more than maxDrift off.
+func clockProblems(st clockStatus, server string, offset *int, maxDrift time.Duration) []string {
+	var problems []string
+	switch {
+	case st.Protocol != "ntp":
+		problems = append(problems, "not synchronized by NTP")
+	case st.Server == "":
+		problems = append(problems, "no NTP server")
+	case server != "" && st.Server != server:
+		problems = append(problems, fmt.Sprintf("NTP server is %s, expected %s", st.Server, server))
+	}
+	if offset != nil && st.Offset != *offset {
+		problems = append(problems, fmt.Sprintf("time zone is %s, expected %s", st.Timezone, formatOffset(*offset)))
+	}
+	if maxDrift > 0 && st.Drift.Abs() > maxDrift {
+		problems = append(problems, fmt.Sprintf("clock is %s off", st.Drift))
+	}
+	return problems
+}
+
+var timeCommandList = []subcommand{
+	{"status", "Show the NTP server, time zone and clock drift of a switch or the inventory", runTimeStatus,
+		"a table: Host, Protocol, Server, Timezone, Drift, Problems; with --format json an array of objects with the same fields; exit code 1 when a switch has a problem", nil},
+	{"set", "Set the NTP server and time zone of a switch or the inventory", runTimeSet, "nothing; status goes to stderr", nil},
+}
+
+func runTime(fs *flag.FlagSet) func() {
+	return func() {
+		args := fs.Args()
+		if len(args) == 0 {
+			fmt.Println("Usage: zyxel time <command> [flags]")
+			fmt.Println()
+			fmt.Println("Commands:")
+			for _, c := range timeCommandList {
+				fmt.Printf("  %-10s %s\n", c.name, c.summary)
+			}
+			os.Exit(1)
+		}
+		for _, c := range timeCommandList {
+			if c.name == args[0] {
+				c.invoke("time "+c.name, args[1:])
+				return
+			}
+		}
+		fatal("Unknown time command %q", args[0])
+	}
+}
+
+func runTimeStatus(fs *flag.FlagSet) func() {
+	fleet := fs.Bool("fleet", false, tr("Check every inventory switch instead of one"))
+	server := fs.String("server", "", tr("NTP server every switch should use"))
+	timezone := fs.String("timezone", "", tr("Time zone every switch should have, e.g. +02:00"))
+	maxDrift := fs.Duration("max-drift", 10*time.Second, tr("How far a clock may be off; 0 turns the check off"))
+	format := fs.String("format", "text", tr("Output format: text or json"))
+	cf := addConnFlags(fs)
+	ff := addFleetFlags(fs)
+	return func() {
+		if *format != "text" && *format != "json" {
+			fatal("--format must be text or json, not %q", *format)
+		}
+		var offset *int
+		if *timezone != "" {
+			o, err := parseOffset(*timezone)
+			if err != nil {
+				fatal("%v", err)
+			}
+			offset = &o
+		}
+		results := hostOrFleet(*fleet, cf, ff, func(h Host, s *Session) (clockStatus, error) {
+			return readClock(s)
+		})
+
+		type row struct {
+			Host string `json:"host"`
+			clockStatus
+			Problems []string `json:"problems"`
+			Error    string   `json:"error,omitempty"`
+		}
+		rows := []row{}
+		failed := false
+		for _, r := range results {
+			if r.Err != nil {
+				rows = append(rows, row{Host: r.Host.Name, Problems: []string{}, Error: r.Err.Error()})
+				failed = true
+				continue
+			}
+			problems := clockProblems(r.Value, *server, offset, *maxDrift)
+			if len(problems) > 0 {
+				failed = true
+			}
+			rows = append(rows, row{r.Host.Name, r.Value, append([]string{}, problems...), ""})
+		}
+
+		if *format == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(rows); err != nil {
+				fatal("%v", err)
+			}
+		} else {
+			fmt.Printf("%-20s %-9s %-16s %-10s %-8s %s\n", "Host", "Protocol", "Server", "Timezone", "Drift", "Problems")
+			for _, r := range rows {
+				if r.Error != "" {
+					fmt.Printf("%-20s %s\n", r.Host, r.Error)
+					continue
+				}
+				fmt.Printf("%-20s %-9s %-16s %-10s %-8s %s\n", r.Host, cmp.Or(r.Protocol, "-"), cmp.Or(r.Server, "-"), r.Timezone, r.Drift,
+					strings.Join(r.Problems, "; "))
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+	}
+}
+
+func runTimeSet(fs *flag.FlagSet) func() {
+	fleet := fs.Bool("fleet", false, tr("Set every inventory switch instead of one"))
+	server := fs.String("server", "", tr("NTP server `address`"))
+	timezone := fs.String("timezone", "", tr("Time zone, e.g. +02:00"))
+	save := fs.Bool("save", false, tr("Write memory after the change is verified"))
+	dryRun := addDryRunFlag(fs)
+	cf := addConnFlags(fs)
+	ff := addFleetFlags(fs)
+	return func() {
+		if *server == "" && *timezone == "" {
+			fatal("Usage: zyxel time set [--server <address>] [--timezone <offset>] [--fleet]")
+		}
+		var commands []string
+		if *server != "" {
+			commands = append(commands, "timesync server "+*server, "timesync ntp")
+		}
+		offset := 0
+		if *timezone != "" {
+			var err error
+			if offset, err = parseOffset(*timezone); err != nil {
+				fatal("%v", err)
+			}
+			commands = append(commands, timezoneCommand(offset))
+		}
+		if *dryRun {
+			// Keep the printed command sequences of the switches apart.
+			ff.parallel = 1
+		}
+
+		results := hostOrFleet(*fleet, cf, ff, func(h Host, s *Session) (clockConfig, error) {
+			s.dryRun = *dryRun
+			if err := s.Configure(commands, io.Discard); err != nil {
+				return clockConfig{}, err
+			}
+			if s.dryRun {
+				if *save {
+					_, err := s.Save()
+					return clockConfig{}, err
+				}
+				return clockConfig{}, nil
+			}
+			rc, err := runningConfig(s)
+			if err != nil {
+				return clockConfig{}, err
+			}
+			cc := parseClockConfig(rc)
+			if *server != "" && (cc.Server != *server || cc.Protocol != "ntp") {
+				return cc, fmt.Errorf("NTP server %s is not in the running-config", *server)
+			}
+			if *timezone != "" && cc.Offset != offset {
+				return cc, fmt.Errorf("time zone is %s in the running-config, not %s", formatOffset(cc.Offset), formatOffset(offset))
+			}
+			if *save {
+				if _, err := s.Save(); err != nil {
+					return cc, fmt.Errorf("configuration NOT saved: %w", err)
+				}
+			}
+			return cc, nil
+		})
+
+		failed := false
+		for _, r := range results {
+			switch {
+			case r.Err != nil:
+				fmt.Fprintf(os.Stderr, "%s: %v\n", r.Host.Name, r.Err)
+				failed = true
+			case !*dryRun:
+				fmt.Fprintf(os.Stderr, "%s: NTP server %s, time zone %s\n", r.Host.Name, cmp.Or(r.Value.Server, "-"), formatOffset(r.Value.Offset))
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,423 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// commandTree is the help tree of the privileged prompt, as "?" lists it
+// word by word, learned once per model and firmware by "zyxel
+// learn-commands" and kept under <state dir>/commands. It completes and
+// checks -c commands without asking the switch.
+type commandTree struct {
+	Model    string       `json:"model"`
+	Firmware string       `json:"firmware"`
+	Learned  time.Time    `json:"learned"`
+	Depth    int          `json:"depth"`
+	Root     *commandNode `json:"root"`
+	// EndMarked is set when the switch offers "<cr>" where a command may
+	// end; without it, incomplete commands cannot be told apart.
+	EndMarked bool `json:"end_marked,omitempty"`
+}
+
+// commandNode is a word and what may follow it. Placeholders such as
+// "<1-4094>" or "A.B.C.D" stand for any value and are not explored.
+type commandNode struct {
+	Help string `json:"help,omitempty"`
+	// End is set when the words up to here form a whole command.
+	End bool `json:"end,omitempty"`
+	// Explored is set when "?" was asked after the word, so an empty
+	// Next means nothing may follow it.
+	Explored bool                    `json:"explored,omitempty"`
+	Next     map[string]*commandNode `json:"next,omitempty"`
+}
+
+// helpLine matches a line of "?" output: a word, such as "show" or
+// "<1-4094>", and its help text.
+var helpLine = regexp.MustCompile(`^\s*(<[^>]+>|[\w.:/|-]+)(?:\s+(\S.*?))?\s*$`)
+
+// valueWord reports whether word stands for a value rather than being
+// typed as is: "<port-list>", "<1-4094>", "A.B.C.D", "HH:HH:HH:HH:HH:HH".
+func valueWord(word string) bool {
+	return strings.HasPrefix(word, "<") || (strings.ToUpper(word) == word && strings.ContainsAny(word, ".:") && strings.ContainsAny(word, "ABCDH"))
+}
+
+// valueRange matches a numeric placeholder such as "<1-4094>".
+var valueRange = regexp.MustCompile(`^<(\d+)-(\d+)>$`)
+
+// accepts reports whether the placeholder word takes value.
+func accepts(word, value string) bool {
+	m := valueRange.FindStringSubmatch(word)
+	if m == nil {
+		return true
+	}
+	n, err := strconv.Atoi(value)
+	return err == nil && n >= atoiOr(m[1], 0) && n <= atoiOr(m[2], 0)
+}
+
+// help asks the switch what may follow words by typing them and "?". The
+// switch lists the choices and types words again after the prompt; they
+// are erased with backspaces, so nothing is ever run.
+func (s *Session) help(ctx context.Context, words string) ([]helpEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	typed := strings.TrimSpace(words + " ?")
+	fmt.Fprint(s.stdin, typed)
+	toolMetrics.commands.Add(1)
+
+	limit := cmp.Or(s.commandTimeout, defaultCommandTimeout)
+	timeout := time.NewTimer(limit)
+	defer timeout.Stop()
+	var output, tail string
+	// idle ends the wait when the switch echoes nothing for the erasing.
+	var idle <-chan time.Time
+	erased := false
+	for {
+		select {
+		case chunk, ok := <-s.out.data:
+			if !ok {
+				return nil, errorf("connection closed: %w", s.out.closed())
+			}
+			timeout.Reset(limit)
+			tail += chunk
+			if !erased {
+				output += chunk
+			}
+			if !s.noPager && pagerPrompt.MatchString(sanitizeLine(tailLine(tail))) {
+				fmt.Fprint(s.stdin, " ")
+				tail = ""
+				continue
+			}
+			line := promptLine(tail)
+			if !erased && s.prompt.MatchString(strings.TrimSpace(strings.TrimSuffix(line, strings.TrimSpace(words)))) && strings.Contains(output, "\n") {
+				fmt.Fprint(s.stdin, strings.Repeat("\b", len(typed)))
+				erased, tail = true, line
+				idle = time.After(promptIdle)
+				continue
+			}
+			if erased && s.prompt.MatchString(line) {
+				s.lastPrompt = line
+				return parseHelp(output, typed), nil
+			}
+		case <-idle:
+			if line := promptLine(tail); s.prompt.MatchString(line) {
+				s.lastPrompt = line
+				return parseHelp(output, typed), nil
+			}
+		case <-timeout.C:
+			toolMetrics.timeouts.Add(1)
+			return nil, errorf("no prompt after %s without output, last line %q", limit, promptLine(tail))
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+type helpEntry struct {
+	word, help string
+}
+
+// parseHelp picks the entries out of the output of "?": the lines after
+// the echo of typed, up to the prompt the switch types them again after.
+// Lines indented deeper than the first entry continue its help text.
+func parseHelp(output, typed string) []helpEntry {
+	lines := strings.Split(strings.ReplaceAll(output, "\r", ""), "\n")
+	if len(lines) < 2 {
+		return nil
+	}
+	var entries []helpEntry
+	indent := -1
+	for _, l := range lines[1 : len(lines)-1] {
+		l = cleanLine(l)
+		if strings.HasPrefix(strings.TrimSpace(l), "%") || strings.Contains(l, typed) {
+			continue
+		}
+		m := helpLine.FindStringSubmatch(l)
+		if m == nil {
+			continue
+		}
+		n := len(l) - len(strings.TrimLeft(l, " "))
+		if indent < 0 {
+			indent = n
+		}
+		if n > indent {
+			continue
+		}
+		entries = append(entries, helpEntry{m[1], m[2]})
+	}
+	return entries
+}
+
+// learnCommandTree asks "?" after every word sequence up to depth words
+// long, printing progress to stderr.
+func learnCommandTree(s *Session, depth int) (*commandTree, error) {
+	t := &commandTree{Depth: depth, Learned: time.Now().UTC(), Root: &commandNode{}}
+	type pending struct {
+		words []string
+		node  *commandNode
+	}
+	queue := []pending{{nil, t.Root}}
+	asked := 0
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		entries, err := s.help(context.Background(), strings.Join(p.words, " "))
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", strings.Join(append(p.words, "?"), " "), err)
+		}
+		if asked++; asked%50 == 0 {
+			fmt.Fprintf(os.Stderr, tr("%d words asked about, %d to go")+"\n", asked, len(queue))
+		}
+		p.node.Explored = true
+		for _, e := range entries {
+			if e.word == "<cr>" {
+				p.node.End, t.EndMarked = true, true
+				continue
+			}
+			if p.node.Next == nil {
+				p.node.Next = make(map[string]*commandNode)
+			}
+			n := &commandNode{Help: e.help}
+			p.node.Next[e.word] = n
+			if !valueWord(e.word) && len(p.words)+1 < depth {
+				queue = append(queue, pending{append(slices.Clip(p.words), e.word), n})
+			}
+		}
+	}
+	return t, nil
+}
+
+// check reports an error when command is not one the tree knows. Words
+// may be abbreviated as far as they stay unambiguous, as the CLI allows;
+// past a placeholder or an unexplored word anything goes.
+func (t *commandTree) check(command string) error {
+	if strings.Contains(command, "?") {
+		return nil
+	}
+	node := t.Root
+	for _, w := range strings.Fields(command) {
+		if !node.Explored {
+			return nil
+		}
+		next, ok := node.lookup(w)
+		if !ok {
+			var words []string
+			for word := range node.Next {
+				words = append(words, word)
+			}
+			sort.Strings(words)
+			return errorf("%q: the switch knows no %q there, only: %s", command, w, strings.Join(words, " "))
+		}
+		node = next
+	}
+	if t.EndMarked && node.Explored && !node.End && len(node.Next) > 0 && node != t.Root {
+		return errorf("%q is incomplete", command)
+	}
+	return nil
+}
+
+// lookup finds the child of n that word stands for: the word itself, the
+// only word it abbreviates, or a placeholder taking it.
+func (n *commandNode) lookup(word string) (*commandNode, bool) {
+	if next, ok := n.Next[word]; ok {
+		return next, true
+	}
+	var match *commandNode
+	matches := 0
+	for w, next := range n.Next {
+		if !valueWord(w) && strings.HasPrefix(w, word) {
+			match, matches = next, matches+1
+		}
+	}
+	if matches == 1 {
+		return match, true
+	}
+	for w, next := range n.Next {
+		if valueWord(w) && accepts(w, word) {
+			return next, true
+		}
+	}
+	return nil, false
+}
+
+// complete returns the commands that partial may be completed to: the
+// words typed so far followed by each word that may come next.
+func (t *commandTree) complete(partial string) []string {
+	words := strings.Fields(partial)
+	last := ""
+	if len(words) > 0 && !strings.HasSuffix(partial, " ") {
+		last, words = words[len(words)-1], words[:len(words)-1]
+	}
+	node := t.Root
+	for _, w := range words {
+		next, ok := node.lookup(w)
+		if !ok {
+			return nil
+		}
+		node = next
+	}
+	prefix := strings.Join(words, " ")
+	if prefix != "" {
+		prefix += " "
+	}
+	var candidates []string
+	for w, next := range node.Next {
+		if valueWord(w) || !strings.HasPrefix(w, last) {
+			continue
+		}
+		candidates = append(candidates, prefix+w+"\t"+next.Help)
+	}
+	sort.Strings(candidates)
+	return candidates
+}
+
+func commandTreeDir() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "commands"), nil
+}
+
+// commandTreeFile is the name the tree of a model and firmware is kept
+// under, e.g. "GS1920-24HPv2_V4.80_ABMH.2_.json".
+func commandTreeFile(model, firmware string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' {
+			return r
+		}
+		return '_'
+	}, model+"_"+firmware) + ".json"
+}
+
+// commandTreeHosts maps a switch address to the file of its tree, so the
+// tree is found without connecting.
+const commandTreeHosts = "hosts.json"
+
+func saveCommandTree(t *commandTree) error {
+	dir, err := commandTreeDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, commandTreeFile(t.Model, t.Firmware)), data, 0o644)
+}
+
+// indexCommandTree records that host runs model and firmware.
+func indexCommandTree(host, model, firmware string) error {
+	dir, err := commandTreeDir()
+	if err != nil {
+		return err
+	}
+	hosts := make(map[string]string)
+	if data, err := os.ReadFile(filepath.Join(dir, commandTreeHosts)); err == nil {
+		json.Unmarshal(data, &hosts)
+	}
+	hosts[host] = commandTreeFile(model, firmware)
+	data, err := json.MarshalIndent(hosts, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, commandTreeHosts), data, 0o644)
+}
+
+// loadCommandTree returns the tree learned for host, or nil when there is
+// none.
+func loadCommandTree(host string) *commandTree {
+	dir, err := commandTreeDir()
+	if err != nil || host == "" {
+		return nil
+	}
+	hosts := make(map[string]string)
+	data, err := os.ReadFile(filepath.Join(dir, commandTreeHosts))
+	if err != nil || json.Unmarshal(data, &hosts) != nil || hosts[host] == "" {
+		return nil
+	}
+	return loadCommandTreeFile(filepath.Join(dir, hosts[host]))
+}
+
+func loadCommandTreeFile(path string) *commandTree {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var t commandTree
+	if json.Unmarshal(data, &t) != nil || t.Root == nil {
+		return nil
+	}
+	return &t
+}
+
+func runLearnCommands(fs *flag.FlagSet) func() {
+	cf := addConnFlags(fs)
+	depth := fs.Int("depth", 3, tr("Ask about command lines of up to this many words"))
+	refresh := fs.Bool("refresh", false, tr("Ask the switch again although its model and firmware are known"))
+	return func() {
+		if *depth < 1 {
+			fatal("--depth must be at least 1")
+		}
+		cfg, s := cf.connect()
+		defer s.Close()
+		model, firmware := s.Model()
+		if firmware == "" {
+			si, err := systemInfo(s)
+			if err != nil {
+				fatal("%v", err)
+			}
+			model, firmware = si.Model, si.Firmware
+		}
+
+		dir, err := commandTreeDir()
+		if err != nil {
+			fatal("%v", err)
+		}
+		if t := loadCommandTreeFile(filepath.Join(dir, commandTreeFile(model, firmware))); t != nil && !*refresh && t.Depth >= *depth {
+			if err := indexCommandTree(cfg.Host, model, firmware); err != nil {
+				fatal("%v", err)
+			}
+			fmt.Fprintf(os.Stderr, tr("%s: using the commands learned from %s %s on %s")+"\n", cfg.Host, model, firmware, t.Learned.Local().Format(time.DateOnly))
+			return
+		}
+
+		start := time.Now()
+		t, err := learnCommandTree(s, *depth)
+		if err != nil {
+			fatal("%v", err)
+		}
+		t.Model, t.Firmware = model, firmware
+		if err := saveCommandTree(t); err != nil {
+			fatal("%v", err)
+		}
+		if err := indexCommandTree(cfg.Host, model, firmware); err != nil {
+			fatal("%v", err)
+		}
+		fmt.Fprintf(os.Stderr, tr("%s: learned %d words of %s %s in %s")+"\n", cfg.Host, t.Root.count(), model, firmware, time.Since(start).Round(time.Second))
+	}
+}
+
+// count returns the number of words below n.
+func (n *commandNode) count() int {
+	c := len(n.Next)
+	for _, next := range n.Next {
+		c += next.count()
+	}
+	return c
+}
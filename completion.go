@@ -0,0 +1,281 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// completion walks subcommands, so it is registered here rather than in
+// the subcommands literal to avoid an initialization cycle.
+func init() {
+	subcommands = append(subcommands, subcommand{"completion", "Print a bash, zsh or fish completion script", runCompletion,
+		"the completion script; with --complete the candidates for the last word, one per line with a tab before the description", nil})
+}
+
+// The scripts ask "zyxel completion --complete" for the candidates, so
+// they need no update when subcommands, flags, profiles or the inventory
+// change. Nothing printed falls back to completing file names.
+const bashCompletion = `# bash completion for zyxel; load with: source <(zyxel completion bash)
+_zyxel() {
+    local IFS=$'\n'
+    COMPREPLY=($(zyxel completion --complete -- "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null | cut -f1))
+}
+complete -o default -F _zyxel zyxel
+`
+
+const zshCompletion = `#compdef zyxel
+# zsh completion for zyxel; load with: source <(zyxel completion zsh)
+_zyxel() {
+    local -a lines items
+    local line
+    lines=("${(@f)$(zyxel completion --complete -- "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    for line in $lines; do
+        [[ -z $line ]] && continue
+        if [[ $line == *$'\t'* ]]; then
+            items+=("${${line%%$'\t'*}//:/\\:}:${line#*$'\t'}")
+        else
+            items+=("${line//:/\\:}")
+        fi
+    done
+    if (( ${#items} )); then
+        _describe zyxel items
+    else
+        _files
+    fi
+}
+compdef _zyxel zyxel
+`
+
+const fishCompletion = `# fish completion for zyxel; load with: zyxel completion fish | source
+function __zyxel_complete
+    set -l out (zyxel completion --complete -- (commandline -opc)[2..-1] (commandline -ct) 2>/dev/null)
+    if test (count $out) -gt 0
+        printf '%s\n' $out
+    else
+        __fish_complete_path (commandline -ct)
+    end
+end
+complete -c zyxel -f -a '(__zyxel_complete)'
+`
+
+func runCompletion(fs *flag.FlagSet) func() {
+	complete := fs.Bool("complete", false, tr("Print the candidates for the last of the words after --, for the scripts"))
+	return func() {
+		if *complete {
+			for _, c := range completions(fs.Args()) {
+				fmt.Println(c)
+			}
+			return
+		}
+		if fs.NArg() != 1 {
+			fatal("Usage: zyxel completion bash|zsh|fish")
+		}
+		switch fs.Arg(0) {
+		case "bash":
+			os.Stdout.WriteString(bashCompletion)
+		case "zsh":
+			os.Stdout.WriteString(zshCompletion)
+		case "fish":
+			os.Stdout.WriteString(fishCompletion)
+		default:
+			fatal("Unknown shell %q; use bash, zsh or fish", fs.Arg(0))
+		}
+	}
+}
+
+// completions returns the candidates for the last of words, the command
+// line after "zyxel", each with a tab and a description where there is
+// one. Earlier words choose the subcommand whose flags and nested
+// subcommands are offered.
+func completions(words []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	cur, prev := words[len(words)-1], words[:len(words)-1]
+
+	fs := completionFlags(runCommands)
+	cmds := subcommands
+	for i := 0; i < len(prev); i++ {
+		w := prev[i]
+		if strings.HasPrefix(w, "-") && w != "-" {
+			if i == 0 {
+				// -c mode: subcommands come first or not at all.
+				cmds = nil
+			}
+			name, _, hasValue := strings.Cut(strings.TrimLeft(w, "-"), "=")
+			if f := fs.Lookup(name); f != nil && !hasValue && !isBoolFlag(f) {
+				i++
+			}
+			continue
+		}
+		n := slices.IndexFunc(cmds, func(sc subcommand) bool { return sc.name == w })
+		if n < 0 {
+			cmds = nil
+			continue
+		}
+		fs = completionFlags(cmds[n].run)
+		cmds = cmds[n].checks
+	}
+
+	var candidates []string
+	if last := len(prev) - 1; last >= 0 && strings.HasPrefix(prev[last], "-") {
+		if f := fs.Lookup(strings.TrimLeft(prev[last], "-")); f != nil && !isBoolFlag(f) {
+			return matching(flagValues(f.Name, words), cur)
+		}
+	}
+	if name, value, ok := strings.Cut(cur, "="); ok && strings.HasPrefix(cur, "-") {
+		for _, v := range matching(flagValues(strings.TrimLeft(name, "-"), words), value) {
+			candidates = append(candidates, name+"="+v)
+		}
+		return candidates
+	}
+	if strings.HasPrefix(cur, "-") {
+		fs.VisitAll(func(f *flag.Flag) {
+			dash := "--"
+			if len(f.Name) == 1 {
+				dash = "-"
+			}
+			_, usage := flag.UnquoteUsage(f)
+			candidates = append(candidates, dash+f.Name+"\t"+usage)
+		})
+		candidates = append(candidates,
+			"--plain\t"+tr("ASCII-only output without colors or animations"),
+			"--lang\t"+tr("Language of messages, en or et (default: from LANG)"))
+		return matching(candidates, cur)
+	}
+	for _, sc := range cmds {
+		candidates = append(candidates, sc.name+"\t"+tr(sc.summary))
+	}
+	return matching(candidates, cur)
+}
+
+// completionFlags returns the flags run defines.
+func completionFlags(run func(fs *flag.FlagSet) func()) *flag.FlagSet {
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	run(fs)
+	return fs
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// flagValues returns the values known for the flag name: profile names,
+// inventory hosts and tags, the transports, and for -c and --verify the
+// aliases and the switch commands learned with learn-commands.
+func flagValues(name string, words []string) []string {
+	switch name {
+	case "c", "verify":
+		// A quote opening the command stays in front of each candidate.
+		cur := words[len(words)-1]
+		partial := strings.TrimLeft(cur, `'"`)
+		var values []string
+		aliases, _ := loadAliases()
+		for name, a := range aliases {
+			values = append(values, name+"\t"+a)
+		}
+		sort.Strings(values)
+		if t := loadCommandTree(completionHost(words)); t != nil {
+			values = append(values, t.complete(partial)...)
+		}
+		for i, v := range values {
+			values[i] = cur[:len(cur)-len(partial)] + v
+		}
+		return values
+	case "profile":
+		profiles, _, _ := loadProfiles()
+		var values []string
+		for name, p := range profiles {
+			values = append(values, name+"\t"+p.Host)
+		}
+		sort.Strings(values)
+		return values
+	case "host", "tag":
+		inv, err := loadInventory(completionInventory(words))
+		if err != nil {
+			return nil
+		}
+		var values []string
+		for _, h := range inv.Hosts {
+			if name == "host" {
+				values = append(values, h.Address+"\t"+h.Name)
+				continue
+			}
+			for _, t := range h.Tags {
+				if !slices.Contains(values, t) {
+					values = append(values, t)
+				}
+			}
+		}
+		return values
+	case "transport":
+		return []string{"ssh", "telnet", "http", "https"}
+	}
+	return nil
+}
+
+// completionHost returns the switch the words connect to, from --host,
+// --profile or the environment.
+func completionHost(words []string) string {
+	host, profile := "", os.Getenv("ZYXEL_PROFILE")
+	for i, w := range words[:len(words)-1] {
+		if !strings.HasPrefix(w, "-") {
+			continue
+		}
+		name, value, ok := strings.Cut(strings.TrimLeft(w, "-"), "=")
+		if !ok && i+1 < len(words)-1 {
+			value = words[i+1]
+		}
+		switch name {
+		case "host":
+			host = value
+		case "profile":
+			profile = value
+		}
+	}
+	if host != "" {
+		return host
+	}
+	if profile != "" {
+		if p, err := loadProfile(profile); err == nil && p.Host != "" {
+			return p.Host
+		}
+	}
+	return envConfig().Host
+}
+
+// completionInventory returns the inventory file named by --inventory in
+// words, or the one fleet subcommands default to.
+func completionInventory(words []string) string {
+	for i, w := range words {
+		name, value, ok := strings.Cut(strings.TrimLeft(w, "-"), "=")
+		switch {
+		case name == "inventory" && ok:
+			return value
+		case w == "--inventory" || w == "-inventory":
+			if i+1 < len(words)-1 {
+				return words[i+1]
+			}
+		}
+	}
+	if path := os.Getenv("ZYXEL_INVENTORY"); path != "" {
+		return path
+	}
+	return defaultInventory
+}
+
+// matching keeps the candidates starting with prefix.
+func matching(candidates []string, prefix string) []string {
+	var out []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, prefix) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// inConfigMode reports whether the switch is at a configuration prompt such
+// as "sw1(config)#" or "sw1(config-vlan)#".
+func (s *Session) inConfigMode() bool {
+	return strings.Contains(s.lastPrompt, "(config")
+}
+
+// Configure enters configuration mode, runs commands and returns to the
+// privileged prompt, checking the prompt at each transition. The output of
+// each command is written to w. It stops at the first command the switch
+// rejects or that fails, and leaves configuration mode then too. The first
+// change of a session is preceded by a checkpoint of the running-config,
+// for zyxel rollback.
+func (s *Session) Configure(commands []string, w io.Writer) error {
+	return s.configure(commands, func(_, out string) { io.WriteString(w, out) })
+}
+
+// configure is Configure with each command's output passed to step.
+func (s *Session) configure(commands []string, step func(command, output string)) error {
+	if s.dryRun {
+		printDryRun(commands)
+		return nil
+	}
+	if err := s.checkpoint(); err != nil {
+		return err
+	}
+	if _, err := s.Output("configure"); err != nil {
+		s.leaveConfigMode()
+		return err
+	}
+	if !s.inConfigMode() {
+		return errorf("failed to enter configuration mode (prompt is %q)", s.lastPrompt)
+	}
+
+	// modes gets back to where the commands are after a reconnect:
+	// configuration mode, and the sub-mode ("interface", "vlan") the last
+	// command that entered one.
+	modes := []string{"configure"}
+	var cmdErr error
+	for i, c := range commands {
+		var out string
+		err := s.retry(c, modes, func() (err error) {
+			out, err = s.Output(c)
+			return err
+		})
+		step(c, out)
+		if err != nil {
+			// The switch is left as it was found even if the
+			// command failed; the error is about the command.
+			s.leaveConfigMode()
+			if i > 0 {
+				return errorf("%w; %d of %d commands were applied, not from %q on", err, i, len(commands), c)
+			}
+			return err
+		}
+		if looksLikeError(out) {
+			cmdErr = errorf("switch rejected %q: %s", c, strings.TrimSpace(out))
+			break
+		}
+		switch f := strings.Fields(c); {
+		case !strings.Contains(s.lastPrompt, "(config-"):
+			modes = modes[:1]
+		case len(modes) == 1 || len(f) > 0 && (f[0] == "vlan" || f[0] == "interface"):
+			modes = []string{"configure", c}
+		}
+	}
+
+	if err := s.leaveConfigMode(); err != nil {
+		return err
+	}
+	return cmdErr
+}
+
+// leaveConfigMode leaves any sub-mode ("interface", "vlan") and
+// configuration mode. A new connection starts outside of it.
+func (s *Session) leaveConfigMode() error {
+	for i := 0; i < 3 && s.inConfigMode(); i++ {
+		_, err := s.Output("exit")
+		if s.canReconnect(err) {
+			err = s.reconnect(nil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if s.inConfigMode() {
+		return errorf("failed to leave configuration mode (prompt is %q)", s.lastPrompt)
+	}
+	return nil
+}
+
+// addDryRunFlag defines --dry-run for subcommands that change the
+// configuration.
+func addDryRunFlag(fs *flag.FlagSet) *bool {
+	return fs.Bool("dry-run", false, tr("Print the configuration commands instead of sending them"))
+}
+
+// printDryRun prints the session configure would have with the switch:
+// entering configuration mode, commands, and an "exit" for each mode left
+// open, counting "interface" and "vlan" blocks as sub-modes.
+func printDryRun(commands []string) {
+	fmt.Println("configure")
+	depth := 1
+	for _, c := range commands {
+		fmt.Println(c)
+		f := strings.Fields(c)
+		switch {
+		case c == "exit":
+			depth--
+		case depth == 1 && len(f) >= 2 && (f[0] == "interface" || f[0] == "vlan"):
+			depth++
+		}
+	}
+	for ; depth > 0; depth-- {
+		fmt.Println("exit")
+	}
+}
+
+var (
+	saveConfirm = regexp.MustCompile(`(?i)(\(y/n\)|\[y/n\]|overwrite.*\?|continue\?)\s*$`)
+	saveOK      = regexp.MustCompile(`(?i)(\[ok\]|success|saved|save ok|done|complete)`)
+)
+
+// Save writes the running configuration to the startup configuration,
+// confirming the overwrite if the switch asks. It returns the switch's
+// confirmation message, or an error if it reported a failure or did not
+// confirm the save.
+//
+// Switches sometimes drop the connection while saving; the save command
+// is then sent again on a new one.
+func (s *Session) Save() (string, error) {
+	if s.dryRun {
+		fmt.Println(s.command("save"))
+		return "dry run, nothing saved", nil
+	}
+	var msg string
+	err := s.retry(s.command("save"), nil, func() (err error) {
+		msg, err = s.save()
+		return err
+	})
+	return msg, err
+}
+
+func (s *Session) save() (string, error) {
+	// Held throughout, as by Run, so that nothing else is sent between
+	// the command and its confirmation.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	toolMetrics.commands.Add(1)
+	fmt.Fprintf(s.stdin, "%s\n", s.command("save"))
+
+	line, out, err := s.waitForLocked(context.Background(), func(line string) bool {
+		return saveConfirm.MatchString(line) || s.prompt.MatchString(line)
+	}, 60*time.Second)
+	if err != nil {
+		return "", errorf("write memory: %w", err)
+	}
+	if saveConfirm.MatchString(line) {
+		fmt.Fprintf(s.stdin, "y\n")
+		var more string
+		if line, more, err = s.waitForLocked(context.Background(), s.prompt.MatchString, 60*time.Second); err != nil {
+			return "", errorf("write memory: %w", err)
+		}
+		out += more
+	}
+	s.lastPrompt = line
+
+	var lines []string
+	for _, l := range strings.Split(out, "\n") {
+		if l = cleanLine(l); strings.TrimSpace(l) != "" {
+			lines = append(lines, strings.TrimSpace(l))
+		}
+	}
+	msg := strings.Join(lines, "\n")
+	if looksLikeError(msg) {
+		return "", errorf("write memory failed: %s", msg)
+	}
+	for _, l := range lines {
+		if saveOK.MatchString(l) {
+			return l, nil
+		}
+	}
+	// Some firmware saves silently, but so does one that ignored the
+	// command; only the startup-config can tell.
+	return "", errorf("write memory: the switch did not confirm the save (output %q); check the startup-config", msg)
+}
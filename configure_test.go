@@ -0,0 +1,31 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestConfigureLeavesConfigModeOnError(t *testing.T) {
+	s, stray := fakeSwitch(t, []step{
+		{expect: "configure\n", send: []string{"configure\r\nsw1(config)#"}},
+		{expect: "vlan 10\n", send: []string{"vlan 10\r\nsw1(config-vlan)#"}},
+		// The switch hangs on the command.
+		{expect: "name users\n", send: []string{"name users\r\n"}},
+		{expect: "exit\n", send: []string{"\r\nsw1(config-vlan)#exit\r\nsw1(config)#"}},
+		{expect: "exit\n", send: []string{"exit\r\nsw1#"}},
+	}, false)
+	s.checkpointed = true
+	s.commandTimeout = 3 * promptIdle
+
+	err := s.Configure([]string{"vlan 10", "name users", "fixed 1-4"}, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), `1 of 3 commands were applied, not from "name users" on`) {
+		t.Errorf("Configure error = %v, want the command that timed out", err)
+	}
+	if s.lastPrompt != "sw1#" {
+		t.Errorf("lastPrompt = %q, want %q", s.lastPrompt, "sw1#")
+	}
+	if rest := stray(); rest != "" {
+		t.Errorf("the session also sent %q", rest)
+	}
+}
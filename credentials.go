@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zalando/go-keyring"
+)
+
+// keychainService is the service name credentials are stored under in the
+// OS keychain (macOS Keychain, Windows Credential Manager, libsecret).
+const keychainService = "zyxel"
+
+type storedCredentials struct {
+	User     string `json:"user"`
+	Password string `json:"password"`
+}
+
+// keychainLookup fills in a missing password, and user, from the keychain
+// entry for cfg.Host. An entry for a different user is ignored.
+func keychainLookup(cfg *Config) {
+	if cfg.Password != "" || cfg.Host == "" {
+		return
+	}
+	secret, err := keyring.Get(keychainService, cfg.Host)
+	if err != nil {
+		return
+	}
+	var c storedCredentials
+	if json.Unmarshal([]byte(secret), &c) != nil {
+		return
+	}
+	if cfg.User != "" && cfg.User != c.User {
+		return
+	}
+	cfg.User, cfg.Password = c.User, c.Password
+}
+
+// vaultCache holds secrets already read from Vault, by path, so a fleet
+// run reads a shared secret once.
+var vaultCache = struct {
+	sync.Mutex
+	secrets map[string]map[string]any
+}{secrets: make(map[string]map[string]any)}
+
+// vaultLookup fills in missing credentials from HashiCorp Vault when
+// ZYXEL_VAULT_ADDR and ZYXEL_VAULT_PATH are set. The path is the API path
+// of the secret, e.g. "secret/data/switches/{host}"; {host} expands to the
+// switch address. The secret's "user" and "password" keys are used (KV v1
+// and v2). The token comes from ZYXEL_VAULT_TOKEN or VAULT_TOKEN.
+func vaultLookup(cfg *Config) error {
+	addr, path := os.Getenv("ZYXEL_VAULT_ADDR"), os.Getenv("ZYXEL_VAULT_PATH")
+	if addr == "" || path == "" || cfg.Password != "" {
+		return nil
+	}
+	path = strings.Trim(strings.ReplaceAll(path, "{host}", cfg.Host), "/")
+
+	vaultCache.Lock()
+	defer vaultCache.Unlock()
+	data, ok := vaultCache.secrets[path]
+	if !ok {
+		var err error
+		if data, err = vaultRead(addr, path); err != nil {
+			return err
+		}
+		vaultCache.secrets[path] = data
+	}
+
+	if user, ok := data["user"].(string); ok && cfg.User == "" {
+		cfg.User = user
+	}
+	pw, ok := data["password"].(string)
+	if !ok {
+		return fmt.Errorf("vault secret %s has no password key", path)
+	}
+	cfg.Password = pw
+	return nil
+}
+
+func vaultRead(addr, path string) (map[string]any, error) {
+	token := os.Getenv("ZYXEL_VAULT_TOKEN")
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+	req, err := http.NewRequest("GET", strings.TrimRight(addr, "/")+"/v1/"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", token)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("vault: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault: reading %s: %s", path, resp.Status)
+	}
+
+	var body struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("vault: %w", err)
+	}
+	// KV version 2 nests the secret in data.data.
+	if inner, ok := body.Data["data"].(map[string]any); ok {
+		return inner, nil
+	}
+	return body.Data, nil
+}
+
+func runLogin(fs *flag.FlagSet) func() {
+	user := fs.String("user", "", tr("Login user (default: ZYXEL_USER, or asked)"))
+	del := fs.Bool("delete", false, tr("Remove the stored credentials instead"))
+	return func() {
+		if fs.NArg() != 1 {
+			fatal("Usage: zyxel login <host> [--user name] [--delete]")
+		}
+		host := fs.Arg(0)
+
+		if *del {
+			if err := keyring.Delete(keychainService, host); err != nil && !errors.Is(err, keyring.ErrNotFound) {
+				fatal("%v", err)
+			}
+			fmt.Fprintf(os.Stderr, "Removed credentials for %s\n", host)
+			return
+		}
+
+		if *user == "" {
+			*user = envConfig().User
+		}
+		if *user == "" {
+			fmt.Fprint(os.Stderr, tr("User: "))
+			line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+			*user = strings.TrimSpace(line)
+		}
+		if *user == "" {
+			fatal("a user is required")
+		}
+
+		pw, err := readPassword(fmt.Sprintf(tr("Password for %s@%s: "), *user, host))
+		if err != nil {
+			fatal("%v", err)
+		}
+		if pw == "" {
+			fatal("zyxel login needs a terminal to read the password")
+		}
+
+		secret, _ := json.Marshal(storedCredentials{*user, pw})
+		if err := keyring.Set(keychainService, host, string(secret)); err != nil {
+			fatal("failed to store credentials: %v", err)
+		}
+		fmt.Fprintf(os.Stderr, "Stored credentials for %s@%s in the keychain\n", *user, host)
+	}
+}
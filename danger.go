@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// dangerousCommands are commands that reboot the switch, wipe files or
+// configuration, or can cut off the management connection, with why.
+var dangerousCommands = []struct {
+	re     *regexp.Regexp
+	reason string
+}{
+	{regexp.MustCompile(`^(reload|reboot)\b`), "reboots the switch"},
+	{regexp.MustCompile(`^(erase|delete|format)\b`), "deletes files or configuration"},
+	{regexp.MustCompile(`^(boot|restore|reset)\b`), "changes what the switch starts with"},
+	{regexp.MustCompile(`^(no\s+)?ip\s+(address|default-gateway)\b`), "changes the management address"},
+	{regexp.MustCompile(`^(no\s+)?(admin-inband-vid|inband-default|management-vlan)\b`), "changes the management VLAN"},
+}
+
+// dangerous lists the commands that match dangerousCommands, each with
+// the reason.
+func dangerous(commands []string) []string {
+	var hits []string
+	for _, c := range commands {
+		lc := strings.ToLower(strings.TrimSpace(c))
+		for _, d := range dangerousCommands {
+			if d.re.MatchString(lc) {
+				hits = append(hits, fmt.Sprintf("%s (%s)", strings.TrimSpace(c), tr(d.reason)))
+				break
+			}
+		}
+	}
+	return hits
+}
+
+// confirmDangerous asks before running dangerous commands on host, or
+// refuses them without a terminal, unless yes is set. It exits when the
+// commands are not to be run.
+func confirmDangerous(commands []string, host string, yes bool) {
+	hits := dangerous(commands)
+	if len(hits) == 0 || yes {
+		return
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		fatal("refusing to run %s without --yes", strings.Join(hits, "; "))
+	}
+	fmt.Fprintln(os.Stderr, tr("These commands can disrupt the switch:"))
+	for _, h := range hits {
+		fmt.Fprintf(os.Stderr, "  %s\n", h)
+	}
+	fmt.Fprintf(os.Stderr, tr("Run them on %s? [y/N] "), host)
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if a := strings.ToLower(strings.TrimSpace(line)); a != "y" && a != "yes" {
+		fatal("not run")
+	}
+}
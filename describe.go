@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"strings"
+)
+
+// describe walks subcommands, so it is registered here rather than in
+// the subcommands literal to avoid an initialization cycle.
+func init() {
+	subcommands = append(subcommands, subcommand{"describe", "Print subcommands, flags and exit codes as JSON", runDescribe,
+		"JSON object with name, usage, output, flags, global_flags, environment, subcommands and exit_codes", nil})
+}
+
+// manifest is what "zyxel describe --json" prints, for wrappers that drive
+// the tool without scraping help text.
+type manifest struct {
+	Name        string            `json:"name"`
+	Usage       string            `json:"usage"`
+	Output      string            `json:"output"`
+	Flags       []flagInfo        `json:"flags"`
+	GlobalFlags []flagInfo        `json:"global_flags"`
+	Environment map[string]string `json:"environment"`
+	Subcommands []commandInfo     `json:"subcommands"`
+	ExitCodes   map[string]string `json:"exit_codes"`
+}
+
+type commandInfo struct {
+	Name        string        `json:"name"`
+	Summary     string        `json:"summary"`
+	Flags       []flagInfo    `json:"flags"`
+	Output      string        `json:"output,omitempty"`
+	Subcommands []commandInfo `json:"subcommands,omitempty"`
+}
+
+type flagInfo struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	Default    string `json:"default,omitempty"`
+	Repeatable bool   `json:"repeatable,omitempty"`
+	Usage      string `json:"usage"`
+}
+
+// flagInfos lists the flags that run defines.
+func flagInfos(run func(fs *flag.FlagSet) func()) []flagInfo {
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	run(fs)
+
+	flags := []flagInfo{}
+	fs.VisitAll(func(f *flag.Flag) {
+		typ, usage := flag.UnquoteUsage(f)
+		info := flagInfo{Name: "--" + f.Name, Type: typ, Default: f.DefValue, Usage: usage}
+		if _, ok := f.Value.(*stringList); ok {
+			info.Type, info.Default, info.Repeatable = "string", "", true
+		}
+		if typ == "" {
+			info.Type = "bool"
+		}
+		if len(f.Name) == 1 {
+			info.Name = "-" + f.Name
+		}
+		flags = append(flags, info)
+	})
+	return flags
+}
+
+func describeCommands(cmds []subcommand) []commandInfo {
+	var infos []commandInfo
+	for _, sc := range cmds {
+		infos = append(infos, commandInfo{
+			Name:        sc.name,
+			Summary:     sc.summary,
+			Flags:       flagInfos(sc.run),
+			Output:      sc.output,
+			Subcommands: describeCommands(sc.checks),
+		})
+	}
+	return infos
+}
+
+func runDescribe(fs *flag.FlagSet) func() {
+	fs.Bool("json", true, tr("Print the manifest as JSON (the only format)"))
+	return func() {
+		env := make(map[string]string)
+		for _, e := range environment {
+			name, _, _ := strings.Cut(e[0], ",")
+			env[name] = e[1]
+		}
+
+		m := manifest{
+			Name:   "zyxel",
+			Usage:  "zyxel [flags] -c '<command>' [-c ...] | zyxel <subcommand> [flags] [args]",
+			Output: "output of the -c commands as printed by the switch, with pager prompts and escapes removed",
+			Flags:  flagInfos(runCommands),
+			GlobalFlags: []flagInfo{
+				{Name: "--plain", Type: "bool", Default: "false", Usage: "ASCII-only output without colors or animations (also ZYXEL_PLAIN)"},
+				{Name: "--lang", Type: "string", Usage: "Language of messages, en or et (default: from LANG)"},
+			},
+			Environment: env,
+			Subcommands: describeCommands(subcommands),
+			ExitCodes: map[string]string{
+				"0": "success",
+				"1": "error, an audit found problems, or a health check warns",
+				"2": "invalid flags, or a health check failed",
+				"3": "health or a --format nagios check could not check the switch",
+			},
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(m); err != nil {
+			fatal("%v", err)
+		}
+	}
+}
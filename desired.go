@@ -0,0 +1,380 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"golang.org/x/term"
+	"gopkg.in/yaml.v3"
+)
+
+// DesiredState is the declared configuration of one switch. Only what it
+// mentions is managed: VLANs it does not list are left alone, and so are
+// ports it does not list.
+type DesiredState struct {
+	VLANs []DesiredVLAN `yaml:"vlans"`
+	// Ports maps a port list such as "5-8" to the settings of those ports.
+	Ports map[string]DesiredPort `yaml:"ports"`
+}
+
+type DesiredVLAN struct {
+	ID   int    `yaml:"id"`
+	Name string `yaml:"name"`
+}
+
+// DesiredPort is the declared state of a port. Unset fields are left as
+// they are.
+type DesiredPort struct {
+	Description *string `yaml:"description"`
+	Enabled     *bool   `yaml:"enabled"`
+	// Mode is "access", an untagged member of VLAN, or "trunk", a tagged
+	// member of VLANs and untagged in Native if set. The port is removed
+	// from every other VLAN.
+	Mode   string `yaml:"mode"`
+	VLAN   int    `yaml:"vlan"`
+	VLANs  []int  `yaml:"vlans"`
+	Native int    `yaml:"native"`
+}
+
+func loadDesiredState(path string) (*DesiredState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read desired state: %w", err)
+	}
+	var ds DesiredState
+	if err := yaml.Unmarshal(data, &ds); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for list, p := range ds.Ports {
+		switch {
+		case p.Mode == "access" && p.VLAN == 0:
+			return nil, fmt.Errorf("%s: ports %s: access mode needs vlan", path, list)
+		case p.Mode == "trunk" && len(p.VLANs) == 0 && p.Native == 0:
+			return nil, fmt.Errorf("%s: ports %s: trunk mode needs vlans or native", path, list)
+		case p.Mode != "" && p.Mode != "access" && p.Mode != "trunk":
+			return nil, fmt.Errorf("%s: ports %s: unknown mode %q (want access or trunk)", path, list, p.Mode)
+		}
+	}
+	return &ds, nil
+}
+
+// planStep is one change of a plan with the commands that make it.
+type planStep struct {
+	Summary  string
+	Commands []string
+}
+
+// vlanRole is how a port belongs to a VLAN.
+type vlanRole int
+
+const (
+	roleNone vlanRole = iota
+	roleTagged
+	roleUntagged
+)
+
+// portGroups collects ports by a key and lists them per key in dialect d,
+// so one command can change all ports that need the same change.
+type portGroups[K comparable] map[K][]Port
+
+func (g portGroups[K]) add(k K, p Port) { g[k] = append(g[k], p) }
+
+func (g portGroups[K]) list(k K, d Dialect) string {
+	s, _ := FormatPortList(g[k], d)
+	return s
+}
+
+// planState computes the steps that take a switch from its running-config
+// rc and VLAN table live to the desired state. Ports are compared in
+// dialect d. An empty plan means the switch already matches.
+func planState(ds *DesiredState, rc *RunningConfig, live map[int]VLAN, d Dialect, cmd func(operation string) string) ([]planStep, error) {
+	var plan []planStep
+	names := rc.vlans()
+
+	known := make(map[int]bool)
+	for id := range live {
+		known[id] = true
+	}
+	for _, v := range ds.VLANs {
+		known[v.ID] = true
+		_, exists := live[v.ID]
+		switch {
+		case !exists:
+			step := planStep{Summary: fmt.Sprintf("+ create VLAN %d", v.ID), Commands: []string{fmt.Sprintf("%s %d", cmd("vlan-config"), v.ID)}}
+			if v.Name != "" {
+				step.Summary += fmt.Sprintf(" %q", v.Name)
+				step.Commands = append(step.Commands, nameCommand(cmd("vlan-name"), v.Name))
+			}
+			step.Commands = append(step.Commands, "exit")
+			plan = append(plan, step)
+		case v.Name != "" && names[v.ID].Name != v.Name:
+			plan = append(plan, planStep{
+				Summary:  fmt.Sprintf("~ rename VLAN %d %q -> %q", v.ID, names[v.ID].Name, v.Name),
+				Commands: []string{fmt.Sprintf("%s %d", cmd("vlan-config"), v.ID), nameCommand(cmd("vlan-name"), v.Name), "exit"},
+			})
+		}
+	}
+
+	// Expand the port lists; where lists overlap the later one in sorted
+	// order wins, so the plan is the same on every run.
+	want := make(map[Port]DesiredPort)
+	lists := make([]string, 0, len(ds.Ports))
+	for list := range ds.Ports {
+		lists = append(lists, list)
+	}
+	sort.Strings(lists)
+	for _, list := range lists {
+		ports, err := ParsePortList(list)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range ports {
+			np, err := p.normalize(d)
+			if err != nil {
+				return nil, err
+			}
+			want[np] = ds.Ports[list]
+		}
+	}
+	ports := make([]Port, 0, len(want))
+	for p := range want {
+		ports = append(ports, p)
+	}
+	sort.Slice(ports, func(i, j int) bool { return ports[i].less(ports[j]) })
+
+	// Membership, one block per VLAN that changes.
+	tag, untag, remove := portGroups[int]{}, portGroups[int]{}, portGroups[int]{}
+	pvid := portGroups[int]{}
+	for _, p := range ports {
+		w := want[p]
+		if w.Mode == "" {
+			continue
+		}
+		roles := make(map[int]vlanRole)
+		native := w.VLAN
+		if w.Mode == "trunk" {
+			native = w.Native
+			for _, id := range w.VLANs {
+				roles[id] = roleTagged
+			}
+		}
+		if native != 0 {
+			roles[native] = roleUntagged
+		}
+		for id := range roles {
+			if !known[id] {
+				return nil, fmt.Errorf("port %s wants VLAN %d, which is neither on the switch nor declared", p, id)
+			}
+		}
+
+		for _, id := range slices.Sorted(maps.Keys(known)) {
+			cur := roleNone
+			if v := live[id]; v.isUntagged(p) {
+				cur = roleUntagged
+			} else if v.isMember(p) {
+				cur = roleTagged
+			}
+			if roles[id] == cur {
+				continue
+			}
+			switch roles[id] {
+			case roleTagged:
+				tag.add(id, p)
+			case roleUntagged:
+				untag.add(id, p)
+			default:
+				remove.add(id, p)
+			}
+		}
+		if native != 0 && atoiOr(setting(rc.Ports[p], cmd("pvid")), 1) != native {
+			pvid.add(native, p)
+		}
+	}
+	changed := make(map[int]bool)
+	for _, g := range []portGroups[int]{tag, untag, remove} {
+		for id := range g {
+			changed[id] = true
+		}
+	}
+	for _, id := range slices.Sorted(maps.Keys(changed)) {
+		var parts []string
+		for _, c := range []struct {
+			verb string
+			g    portGroups[int]
+		}{{"tag", tag}, {"untag", untag}, {"remove", remove}} {
+			if len(c.g[id]) > 0 {
+				parts = append(parts, c.verb+" "+c.g.list(id, d))
+			}
+		}
+		plan = append(plan, planStep{
+			Summary:  fmt.Sprintf("~ VLAN %d: %s", id, strings.Join(parts, ", ")),
+			Commands: vlanMemberCommands(cmd, id, tag.list(id, d), untag.list(id, d), remove.list(id, d)),
+		})
+	}
+	for _, id := range slices.Sorted(maps.Keys(pvid)) {
+		list := pvid.list(id, d)
+		plan = append(plan, planStep{
+			Summary:  fmt.Sprintf("~ port %s: PVID %d", list, id),
+			Commands: []string{cmd("port-config") + " " + list, fmt.Sprintf("%s %d", cmd("pvid"), id), "exit"},
+		})
+	}
+
+	// Descriptions and admin state, grouped by the wanted value.
+	descriptions, enable := portGroups[string]{}, portGroups[bool]{}
+	for _, p := range ports {
+		w := want[p]
+		if w.Description != nil && portName(cmd("port-name"), rc.Ports[p]) != *w.Description {
+			descriptions.add(*w.Description, p)
+		}
+		if w.Enabled != nil && slices.Contains(rc.Ports[p], cmd("port-inactive")) == *w.Enabled {
+			enable.add(*w.Enabled, p)
+		}
+	}
+	for _, text := range slices.Sorted(maps.Keys(descriptions)) {
+		list := descriptions.list(text, d)
+		plan = append(plan, planStep{
+			Summary:  fmt.Sprintf("~ port %s: description %q", list, text),
+			Commands: []string{cmd("port-config") + " " + list, nameCommand(cmd("port-name"), text), "exit"},
+		})
+	}
+	for _, on := range []bool{true, false} {
+		if len(enable[on]) == 0 {
+			continue
+		}
+		list := enable.list(on, d)
+		step := planStep{Summary: "~ port " + list + ": enable", Commands: []string{cmd("port-config") + " " + list, "no " + cmd("port-inactive"), "exit"}}
+		if !on {
+			step = planStep{Summary: "~ port " + list + ": disable", Commands: []string{cmd("port-config") + " " + list, cmd("port-inactive"), "exit"}}
+		}
+		plan = append(plan, step)
+	}
+	return plan, nil
+}
+
+// planCommands flattens a plan into the commands to run.
+func planCommands(plan []planStep) []string {
+	var commands []string
+	for _, step := range plan {
+		commands = append(commands, step.Commands...)
+	}
+	return commands
+}
+
+func writePlan(plan []planStep) {
+	for _, step := range plan {
+		fmt.Println(step.Summary)
+		for _, c := range step.Commands {
+			fmt.Printf("    %s\n", c)
+		}
+	}
+	fmt.Printf("\nPlan: %d changes, %d commands\n", len(plan), len(planCommands(plan)))
+}
+
+// currentPlan reads the switch and plans the way to ds, exiting on
+// failure.
+func currentPlan(s *Session, ds *DesiredState) []planStep {
+	if err := s.checkConfigSyntax(); err != nil {
+		fatal("%v", err)
+	}
+	d, err := s.portDialect()
+	if err != nil {
+		fatal("%v", err)
+	}
+	rc, err := runningConfig(s)
+	if err != nil {
+		fatal("%v", err)
+	}
+	live, err := showVLAN(s)
+	if err != nil {
+		fatal("%v", err)
+	}
+	plan, err := planState(ds, rc, live, d, s.command)
+	if err != nil {
+		fatal("%v", err)
+	}
+	return plan
+}
+
+// desiredArgs loads the desired state named by the only argument.
+func desiredArgs(fs *flag.FlagSet, name string) *DesiredState {
+	if fs.NArg() != 1 {
+		fatal("Usage: zyxel %s <state.yaml>", name)
+	}
+	ds, err := loadDesiredState(fs.Arg(0))
+	if err != nil {
+		fatal("%v", err)
+	}
+	return ds
+}
+
+func runPlan(fs *flag.FlagSet) func() {
+	cf := addConnFlags(fs)
+	return func() {
+		ds := desiredArgs(fs, "plan")
+		_, s := cf.connect()
+		defer s.Close()
+
+		plan := currentPlan(s, ds)
+		if len(plan) == 0 {
+			fmt.Println("No changes; the switch matches the desired state")
+			return
+		}
+		writePlan(plan)
+	}
+}
+
+func runConverge(fs *flag.FlagSet) func() {
+	save := fs.Bool("save", false, tr("Write memory after converging"))
+	yes := fs.Bool("yes", false, tr("Apply the plan without asking"))
+	runbookPath := fs.String("runbook", "", tr("Write a Markdown runbook of the change to `file` ({host} expands)"))
+	dryRun := addDryRunFlag(fs)
+	cf := addConnFlags(fs)
+	return func() {
+		ds := desiredArgs(fs, "converge")
+		if !*yes && !*dryRun && !term.IsTerminal(int(os.Stdin.Fd())) {
+			fatal("stdin is not a terminal; review with zyxel plan and pass --yes to apply")
+		}
+		cfg, s := cf.connect()
+		defer s.Close()
+
+		plan := currentPlan(s, ds)
+		if len(plan) == 0 {
+			fmt.Println("No changes; the switch matches the desired state")
+			return
+		}
+		writePlan(plan)
+		commands := planCommands(plan)
+		if *dryRun {
+			fmt.Println()
+			s.dryRun = true
+			applyChange(s, cfg, commands, nil, *save, *runbookPath, os.Stdout)
+			return
+		}
+		if !*yes {
+			confirmApply(len(commands), cfg.Host)
+		}
+		// Saved only once the switch is seen to have converged, so a
+		// partial change does not reach the startup-config.
+		applyChange(s, cfg, commands, nil, false, *runbookPath, os.Stdout)
+
+		if left := currentPlan(s, ds); len(left) > 0 {
+			fmt.Fprintln(os.Stderr, "The switch still differs from the desired state:")
+			for _, step := range left {
+				fmt.Fprintln(os.Stderr, step.Summary)
+			}
+			if *save {
+				fmt.Fprintln(os.Stderr, "Configuration NOT saved")
+			}
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Converged: %d changes applied\n", len(plan))
+		if *save {
+			saveConfig(s)
+		}
+	}
+}
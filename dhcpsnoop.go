@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// DHCPBinding is one entry of the DHCP snooping binding table.
+type DHCPBinding struct {
+	MAC string `json:"mac"`
+	IP  string `json:"ip"`
+	// Lease is the remaining lease time in seconds.
+	Lease int    `json:"lease"`
+	VLAN  int    `json:"vlan"`
+	Port  string `json:"port"`
+}
+
+// parseDHCPBindings parses "show dhcp snooping binding". The table starts
+// with the MAC address on most firmware and with the IP address on some.
+func parseDHCPBindings(output string) []DHCPBinding {
+	rows := parseTable(output, "Mac")
+	if rows == nil {
+		rows = parseTable(output, "IP")
+	}
+	var bindings []DHCPBinding
+	for _, row := range rows {
+		mac, err := normalizeMAC(firstOf(row, "MacAddress", "MAC Address", "Mac Address"))
+		if err != nil {
+			continue
+		}
+		bindings = append(bindings, DHCPBinding{
+			MAC:   mac,
+			IP:    firstOf(row, "IpAddress", "IP Address", "Ip Address"),
+			Lease: atoiOr(firstOf(row, "Lease(sec)", "Lease"), 0),
+			VLAN:  atoiOr(firstOf(row, "VLAN", "VID"), 0),
+			Port:  firstOf(row, "Port", "Interface"),
+		})
+	}
+	return bindings
+}
+
+func dhcpBindings(s *Session) ([]DHCPBinding, error) {
+	out, err := s.Output(s.command("dhcp-snooping"))
+	if err != nil {
+		return nil, err
+	}
+	return parseDHCPBindings(out), nil
+}
+
+// hostBinding is a binding with the switch it came from.
+type hostBinding struct {
+	Host string `json:"host"`
+	DHCPBinding
+}
+
+func runDHCPBindings(fs *flag.FlagSet) func() {
+	fleet := fs.Bool("fleet", false, tr("Read the bindings of every inventory switch instead of one"))
+	format := fs.String("format", "csv", tr("Output format: csv or json"))
+	cf := addConnFlags(fs)
+	ff := addFleetFlags(fs)
+	return func() {
+		if *format != "csv" && *format != "json" {
+			fatal("--format must be csv or json, not %q", *format)
+		}
+		read := func(h Host, s *Session) ([]DHCPBinding, error) {
+			return dhcpBindings(s)
+		}
+		var results []fleetResult[[]DHCPBinding]
+		if *fleet {
+			_, hosts := ff.load()
+			results = runFleet(hosts, ff, read)
+		} else {
+			cfg, s := cf.connect()
+			h := Host{Name: cfg.Host, Address: cfg.Host}
+			b, err := read(h, s)
+			s.Close()
+			results = []fleetResult[[]DHCPBinding]{{h, b, err}}
+		}
+
+		rows := []hostBinding{}
+		failed := false
+		for _, r := range results {
+			if r.Err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", r.Host.Name, r.Err)
+				failed = true
+				continue
+			}
+			for _, b := range r.Value {
+				rows = append(rows, hostBinding{r.Host.Name, b})
+			}
+		}
+
+		if *format == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(rows); err != nil {
+				fatal("%v", err)
+			}
+		} else {
+			w := csv.NewWriter(os.Stdout)
+			w.Write([]string{"host", "mac", "ip", "lease", "vlan", "port"})
+			for _, r := range rows {
+				w.Write([]string{r.Host, r.MAC, r.IP, strconv.Itoa(r.Lease), strconv.Itoa(r.VLAN), r.Port})
+			}
+			w.Flush()
+			if err := w.Error(); err != nil {
+				fatal("%v", err)
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+	}
+}
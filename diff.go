@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// diffLine is one line of a line diff: ' ' unchanged, '-' only in the old
+// text, '+' only in the new one.
+type diffLine struct {
+	Op   byte
+	Text string
+}
+
+// lineDiff compares two texts line by line using their longest common
+// subsequence. Configs are a few thousand lines at most, so the quadratic
+// table is fine.
+func lineDiff(old, new string) []diffLine {
+	a, b := splitLines(old), splitLines(new)
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var out []diffLine
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, diffLine{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, diffLine{'-', a[i]})
+			i++
+		default:
+			out = append(out, diffLine{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		out = append(out, diffLine{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		out = append(out, diffLine{'+', b[j]})
+	}
+	return out
+}
+
+func splitLines(s string) []string {
+	s = strings.TrimRight(strings.ReplaceAll(s, "\r", ""), "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// diffChanged reports whether d has any added or removed lines.
+func diffChanged(d []diffLine) bool {
+	for _, l := range d {
+		if l.Op != ' ' {
+			return true
+		}
+	}
+	return false
+}
+
+// writeDiff prints the changed lines of d with context lines of
+// surrounding unchanged text, separating hunks with "@@".
+func writeDiff(w io.Writer, d []diffLine, context int) {
+	keep := make([]bool, len(d))
+	for i, l := range d {
+		if l.Op == ' ' {
+			continue
+		}
+		for k := max(0, i-context); k <= min(len(d)-1, i+context); k++ {
+			keep[k] = true
+		}
+	}
+	gap := false
+	for i, l := range d {
+		if !keep[i] {
+			gap = true
+			continue
+		}
+		if gap || i == 0 {
+			fmt.Fprintln(w, "@@")
+			gap = false
+		}
+		fmt.Fprintf(w, "%c %s\n", l.Op, l.Text)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// zyxelEnterprise is the sysObjectID prefix of Zyxel devices.
+const zyxelEnterprise = ".1.3.6.1.4.1.890."
+
+// maxDiscover bounds the size of the subnet discover probes.
+const maxDiscover = 4096
+
+// discovered is what the probes learned about one address.
+type discovered struct {
+	Address string
+	// SSH is the banner of the SSH server, e.g. "SSH-2.0-OpenSSH_7.5".
+	SSH   string
+	Name  string
+	Model string
+	Zyxel bool
+}
+
+// sshBanner reads the identification line an SSH server sends first.
+func sshBanner(addr string, timeout time.Duration) string {
+	c, err := net.DialTimeout("tcp", net.JoinHostPort(addr, "22"), timeout)
+	if err != nil {
+		return ""
+	}
+	defer c.Close()
+	c.SetReadDeadline(time.Now().Add(timeout))
+	line, _ := bufio.NewReader(c).ReadString('\n')
+	if !strings.HasPrefix(line, "SSH-") {
+		return ""
+	}
+	return strings.TrimSpace(line)
+}
+
+// probe asks addr for its SSH banner and SNMP system group. Zyxel devices
+// are told by their sysObjectID, or by "Zyxel" in sysDescr; the model is
+// the first word of sysDescr, which is what Zyxel switches put there.
+func probe(addr string, timeout time.Duration) discovered {
+	d := discovered{Address: addr, SSH: sshBanner(addr, timeout)}
+	g, err := dialSNMP(addr)
+	if err != nil {
+		return d
+	}
+	defer g.Conn.Close()
+	g.Timeout, g.Retries = timeout, 0
+	info, err := snmpSysInfo(g)
+	if err != nil {
+		return d
+	}
+	d.Name = info.Name
+	if f := strings.Fields(info.Descr); len(f) > 0 {
+		d.Model = f[0]
+	}
+	d.Zyxel = strings.HasPrefix(info.ObjectID+".", zyxelEnterprise) || strings.Contains(strings.ToLower(info.Descr), "zyxel")
+	return d
+}
+
+// subnetAddrs lists the host addresses of prefix, without the network and
+// broadcast addresses of IPv4 subnets larger than /31.
+func subnetAddrs(prefix netip.Prefix) ([]string, error) {
+	prefix = prefix.Masked()
+	bits := prefix.Addr().BitLen() - prefix.Bits()
+	if bits > 12 {
+		return nil, fmt.Errorf("%s is too large; discover probes at most %d addresses", prefix, maxDiscover)
+	}
+	var addrs []string
+	for a := prefix.Addr(); prefix.Contains(a); a = a.Next() {
+		addrs = append(addrs, a.String())
+	}
+	if prefix.Addr().Is4() && bits > 1 {
+		addrs = addrs[1 : len(addrs)-1]
+	}
+	return addrs, nil
+}
+
+// addToInventory appends the found switches to the hosts of the inventory
+// at path, creating it if needed, and returns how many were new. The file
+// is edited as a YAML document, so its comments stay.
+func addToInventory(path string, found []discovered, tag string) (int, error) {
+	var doc yaml.Node
+	data, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		data = []byte("hosts: []\n")
+	case err != nil:
+		return 0, errorf("failed to read inventory: %w", err)
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return 0, errorf("failed to parse inventory %s: %w", path, err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return 0, fmt.Errorf("inventory %s is not a YAML mapping", path)
+	}
+	root := doc.Content[0]
+	var hosts *yaml.Node
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == "hosts" {
+			hosts = root.Content[i+1]
+		}
+	}
+	if hosts == nil {
+		hosts = &yaml.Node{Kind: yaml.SequenceNode}
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "hosts"}, hosts)
+	}
+	hosts.Style = 0
+
+	var inv Inventory
+	if err := doc.Decode(&inv); err != nil {
+		return 0, errorf("failed to parse inventory %s: %w", path, err)
+	}
+	added := 0
+	for _, d := range found {
+		if slices.ContainsFunc(inv.Hosts, func(h Host) bool { return h.Address == d.Address }) {
+			continue
+		}
+		h := Host{Name: d.Name, Address: d.Address}
+		if h.Name == "" {
+			h.Name = d.Address
+		}
+		if tag != "" {
+			h.Tags = []string{tag}
+		}
+		var n yaml.Node
+		if err := n.Encode(struct {
+			Name    string   `yaml:"name"`
+			Address string   `yaml:"host"`
+			Tags    []string `yaml:"tags,omitempty,flow"`
+		}{h.Name, h.Address, h.Tags}); err != nil {
+			return 0, err
+		}
+		hosts.Content = append(hosts.Content, &n)
+		added++
+	}
+
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return 0, err
+	}
+	return added, os.WriteFile(path, b.Bytes(), 0o644)
+}
+
+func runDiscover(fs *flag.FlagSet) func() {
+	parallel := fs.Int("parallel", 64, tr("Number of addresses to probe at once"))
+	timeout := fs.Duration("timeout", time.Second, tr("How long to wait for each probe"))
+	all := fs.Bool("all", false, tr("List every address that answered, not just Zyxel devices"))
+	write := fs.String("write", "", tr("Add the switches found to this inventory `file`"))
+	tag := fs.String("tag", "", tr("With --write: tag the added hosts"))
+	return func() {
+		if fs.NArg() != 1 {
+			fatal("Usage: zyxel discover <subnet>")
+		}
+		prefix, err := netip.ParsePrefix(fs.Arg(0))
+		if err != nil {
+			fatal("%v", err)
+		}
+		addrs, err := subnetAddrs(prefix)
+		if err != nil {
+			fatal("%v", err)
+		}
+
+		results := make([]discovered, len(addrs))
+		sem := make(chan struct{}, max(*parallel, 1))
+		var wg sync.WaitGroup
+		for i, a := range addrs {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				results[i] = probe(a, *timeout)
+			}()
+		}
+		wg.Wait()
+
+		var found []discovered
+		fmt.Printf("%-15s %-20s %-16s %s\n", "Address", "Name", "Model", "SSH")
+		for _, d := range results {
+			if d.Zyxel {
+				found = append(found, d)
+			}
+			if d.Zyxel || *all && (d.SSH != "" || d.Model != "") {
+				fmt.Println(strings.TrimRight(fmt.Sprintf("%-15s %-20s %-16s %s", d.Address, d.Name, d.Model, d.SSH), " "))
+			}
+		}
+		fmt.Fprintf(os.Stderr, "%d Zyxel devices in %s\n", len(found), prefix.Masked())
+
+		if *write != "" {
+			added, err := addToInventory(*write, found, *tag)
+			if err != nil {
+				fatal("%v", err)
+			}
+			fmt.Fprintf(os.Stderr, "Added %d hosts to %s\n", added, *write)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// AuthPort is the 802.1X state of one port.
+type AuthPort struct {
+	Port string `json:"port"`
+	// Enabled is whether port authentication is on.
+	Enabled bool `json:"enabled"`
+	// Status is e.g. "Authorized" or "Unauthorized".
+	Status     string `json:"status"`
+	Supplicant string `json:"supplicant_mac,omitempty"`
+	// Method is how the supplicant was authenticated, e.g. "EAP-TLS" or
+	// "MAB" (MAC authentication bypass).
+	Method string `json:"method,omitempty"`
+	VLAN   int    `json:"vlan,omitempty"`
+}
+
+// Authorized reports whether the port lets traffic through.
+func (a AuthPort) Authorized() bool {
+	s := strings.ToLower(a.Status)
+	return strings.HasPrefix(s, "authorized") || s == "authenticated"
+}
+
+var authPortHeader = regexp.MustCompile(`(?i)^\s*port\s*:?\s*([\d/]+)\s*:?\s*$`)
+
+// enabledValue parses the on/off values of the switch.
+func enabledValue(v string) bool {
+	switch strings.ToLower(v) {
+	case "enable", "enabled", "on", "yes", "active":
+		return true
+	}
+	return false
+}
+
+// parseAuthPorts parses "show port-access-authenticator": a table with a
+// row per port, or a "Port n" block per port with "Key : value" lines.
+func parseAuthPorts(output string) []AuthPort {
+	var ports []AuthPort
+	for _, row := range parseTable(output, "Port") {
+		if _, err := parsePort(row["Port"]); err != nil {
+			continue
+		}
+		// Ports without a session show "-".
+		for k, v := range row {
+			if v == "-" {
+				row[k] = ""
+			}
+		}
+		mac, _ := normalizeMAC(firstOf(row, "Supplicant MAC", "Supplicant", "MAC Address", "MAC"))
+		ports = append(ports, AuthPort{
+			Port:       row["Port"],
+			Enabled:    enabledValue(firstOf(row, "Port Authentication", "Authentication", "Active", "Enabled", "Admin")),
+			Status:     firstOf(row, "Status", "Auth Status", "Port Status", "State"),
+			Supplicant: mac,
+			Method:     firstOf(row, "Method", "Auth Method", "Type"),
+			VLAN:       atoiOr(firstOf(row, "VLAN", "VID", "Assigned VLAN"), 0),
+		})
+	}
+	if len(ports) > 0 {
+		return ports
+	}
+
+	var cur *AuthPort
+	for _, line := range strings.Split(strings.ReplaceAll(output, "\r", ""), "\n") {
+		if m := authPortHeader.FindStringSubmatch(line); m != nil {
+			ports = append(ports, AuthPort{Port: m[1]})
+			cur = &ports[len(ports)-1]
+			continue
+		}
+		key, value, ok := splitKeyValue(line)
+		if cur == nil || !ok || value == "" {
+			continue
+		}
+		lk := strings.ToLower(key)
+		switch {
+		case strings.Contains(lk, "supplicant") || strings.Contains(lk, "mac"):
+			if mac, err := normalizeMAC(value); err == nil {
+				cur.Supplicant = mac
+			}
+		case strings.Contains(lk, "method") || strings.Contains(lk, "type"):
+			cur.Method = value
+		case strings.Contains(lk, "vlan"):
+			cur.VLAN = atoiOr(value, 0)
+		case strings.Contains(lk, "status") || strings.Contains(lk, "state"):
+			cur.Status = value
+		case strings.Contains(lk, "authentication") || lk == "active" || lk == "enabled":
+			cur.Enabled = enabledValue(value)
+		}
+	}
+	return ports
+}
+
+func authPorts(s *Session) ([]AuthPort, error) {
+	out, err := s.Output(s.command("dot1x"))
+	if err != nil {
+		return nil, err
+	}
+	if looksLikeError(out) {
+		return nil, fmt.Errorf("show port-access-authenticator: %s", strings.TrimSpace(out))
+	}
+	return parseAuthPorts(out), nil
+}
+
+// dot1xRow is one port in the 802.1X report.
+type dot1xRow struct {
+	Host string `json:"host"`
+	AuthPort
+	Uplink  bool   `json:"uplink"`
+	Problem string `json:"problem,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// dot1xData is what the 802.1X report reads from a switch.
+type dot1xData struct {
+	ports   []AuthPort
+	uplinks map[Port]bool
+}
+
+func runReportDot1x(fs *flag.FlagSet) func() {
+	ff := addFleetFlags(fs)
+	format := fs.String("format", "csv", tr("Output format: csv or json"))
+	require := fs.Bool("require", false, tr("Report access ports without 802.1X as problems"))
+	return func() {
+		if *format != "csv" && *format != "json" {
+			fatal("--format must be csv or json, not %q", *format)
+		}
+		_, hosts := ff.load()
+		results := runFleet(hosts, ff, func(h Host, s *Session) (dot1xData, error) {
+			ports, err := authPorts(s)
+			if err != nil {
+				return dot1xData{}, err
+			}
+			neighbors, err := lldpNeighbors(s)
+			if err != nil {
+				return dot1xData{}, err
+			}
+			return dot1xData{ports, switchPorts(h, neighbors)}, nil
+		})
+
+		rows := []dot1xRow{}
+		failed := false
+		for _, r := range results {
+			if r.Err != nil {
+				rows = append(rows, dot1xRow{Host: r.Host.Name, Error: r.Err.Error()})
+				failed = true
+				continue
+			}
+			for _, a := range r.Value.ports {
+				row := dot1xRow{Host: r.Host.Name, AuthPort: a}
+				if p, err := parsePort(a.Port); err == nil {
+					row.Uplink = r.Value.uplinks[p]
+				}
+				switch {
+				case row.Uplink:
+				case *require && !a.Enabled:
+					row.Problem = "802.1X is off on an access port"
+				case a.Enabled && a.Supplicant != "" && !a.Authorized():
+					row.Problem = fmt.Sprintf("supplicant %s is %s", a.Supplicant, strings.ToLower(a.Status))
+				}
+				if row.Problem != "" {
+					failed = true
+				}
+				rows = append(rows, row)
+			}
+		}
+
+		if *format == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(rows); err != nil {
+				fatal("%v", err)
+			}
+		} else {
+			w := csv.NewWriter(os.Stdout)
+			w.Write([]string{"host", "port", "enabled", "status", "supplicant_mac", "method", "vlan", "uplink", "problem", "error"})
+			for _, r := range rows {
+				vlan := ""
+				if r.VLAN > 0 {
+					vlan = strconv.Itoa(r.VLAN)
+				}
+				w.Write([]string{r.Host, r.Port, strconv.FormatBool(r.Enabled), r.Status, r.Supplicant, r.Method, vlan,
+					strconv.FormatBool(r.Uplink), r.Problem, r.Error})
+			}
+			w.Flush()
+			if err := w.Error(); err != nil {
+				fatal("%v", err)
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+	}
+}
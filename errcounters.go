@@ -0,0 +1,180 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// counterSample is the counters of a switch's ports at one run of "audit
+// errors", kept in the state directory to compare the next run with.
+type counterSample struct {
+	Time  time.Time                    `json:"time"`
+	Host  string                       `json:"host"`
+	Ports map[string]map[string]uint64 `json:"ports"`
+}
+
+// errorClasses name the error counters that audit errors watches, by the
+// words in their names; models differ in which they show.
+var errorClasses = []struct {
+	name, label string
+	words       []string
+}{
+	{"crc", "CRC errors", []string{"crc", "fcs"}},
+	{"collisions", "collisions", []string{"collision"}},
+	{"drops", "dropped packets", []string{"drop", "discard"}},
+}
+
+// errorClass returns the index in errorClasses of the counter
+// "section/name", or -1.
+func errorClass(key string) int {
+	key = strings.ToLower(key)
+	for i, c := range errorClasses {
+		for _, w := range c.words {
+			if strings.Contains(key, w) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// errorIncrease is how much the counters of one class grew on a port.
+type errorIncrease struct {
+	Port string
+	// Label names the class, e.g. "CRC errors".
+	Label string
+	Count uint64
+	// Counters are the increments of the counters in the class.
+	Counters map[string]uint64
+}
+
+// errorIncreases compares two samples of a switch and returns the classes
+// that grew by at least their limit on a port; a limit of 0 is off.
+func errorIncreases(before, after map[string]map[string]uint64, limits map[string]uint64) []errorIncrease {
+	var found []errorIncrease
+	for _, port := range sortedStringKeys(after) {
+		prev, ok := before[port]
+		if !ok {
+			continue
+		}
+		byClass := make(map[int]*errorIncrease)
+		for k, v := range after[port] {
+			class := errorClass(k)
+			if class < 0 {
+				continue
+			}
+			old, ok := prev[k]
+			if !ok {
+				continue
+			}
+			if d := counterDelta(old, v); d > 0 {
+				inc := byClass[class]
+				if inc == nil {
+					inc = &errorIncrease{Port: port, Label: errorClasses[class].label, Counters: map[string]uint64{}}
+					byClass[class] = inc
+				}
+				inc.Count += d
+				inc.Counters[k] = d
+			}
+		}
+		for i, c := range errorClasses {
+			if inc := byClass[i]; inc != nil && limits[c.name] > 0 && inc.Count >= limits[c.name] {
+				found = append(found, *inc)
+			}
+		}
+	}
+	return found
+}
+
+func (inc errorIncrease) message(since time.Time) string {
+	var parts []string
+	for _, k := range sortedStringKeys(inc.Counters) {
+		parts = append(parts, fmt.Sprintf("%s +%d", k, inc.Counters[k]))
+	}
+	return fmt.Sprintf("port %s counted %d %s since %s (%s)", inc.Port, inc.Count, inc.Label,
+		since.Local().Format(timeLayout), strings.Join(parts, ", "))
+}
+
+func runAuditErrors(fs *flag.FlagSet) func() {
+	ff := addFleetFlags(fs)
+	crc := fs.Uint64("crc", 1, tr("CRC errors since the last run that count as a problem (0 is off)"))
+	collisions := fs.Uint64("collisions", 10, tr("Collisions since the last run that count as a problem (0 is off)"))
+	drops := fs.Uint64("drops", 100, tr("Dropped packets since the last run that count as a problem (0 is off)"))
+	notify := fs.Bool("notify", false, tr("Post the problems to the inventory's errors webhooks"))
+	var urls []string
+	fs.Func("webhook", tr("Post the problems to this `url` (repeatable)"), func(s string) error {
+		urls = append(urls, s)
+		return nil
+	})
+	return func() {
+		inv, hosts := ff.load()
+		var webhooks []Webhook
+		if *notify {
+			webhooks = inv.Webhooks
+		}
+		for _, u := range urls {
+			webhooks = append(webhooks, Webhook{URL: u})
+		}
+		limits := map[string]uint64{"crc": *crc, "collisions": *collisions, "drops": *drops}
+
+		results := runFleet(hosts, ff, func(h Host, s *Session) ([]Interface, error) {
+			return interfaces(s, "*")
+		})
+		now := time.Now()
+		// The file holds the last sample of each host.
+		earlier, err := loadState("counters.jsonl", func(counterSample) bool { return true })
+		if err != nil {
+			fatal("%v", err)
+		}
+		last := make(map[string]counterSample)
+		for _, s := range earlier {
+			last[s.Host] = s
+		}
+
+		var findings []finding
+		var samples []counterSample
+		for _, r := range results {
+			if r.Err != nil {
+				continue
+			}
+			sample := counterSample{now, r.Host.Name, make(map[string]map[string]uint64)}
+			for _, i := range r.Value {
+				sample.Ports[i.Port] = i.Counters
+			}
+			samples = append(samples, sample)
+			prev, ok := last[r.Host.Name]
+			if !ok {
+				continue
+			}
+			for _, inc := range errorIncreases(prev.Ports, sample.Ports, limits) {
+				msg := inc.message(prev.Time)
+				findings = append(findings, finding{r.Host.Name, msg})
+				ev := event{Time: now, Type: eventErrors, Host: r.Host.Name, Port: inc.Port, Message: r.Host.Name + " " + msg, Counters: inc.Counters}
+				for _, w := range webhooks {
+					if !w.wants(eventErrors) {
+						continue
+					}
+					if err := postWebhook(w, ev); err != nil {
+						fmt.Fprintf(os.Stderr, "Warning: webhook: %v\n", err)
+					}
+				}
+			}
+		}
+		// Keep only the newest sample of each host, so that the file does
+		// not grow with every run; hosts that failed keep their old one.
+		for _, s := range samples {
+			last[s.Host] = s
+		}
+		kept := make([]counterSample, 0, len(last))
+		for _, h := range sortedStringKeys(last) {
+			kept = append(kept, last[h])
+		}
+		if err := writeState("counters.jsonl", kept); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		reportFindings(results, findings)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// runExec runs each command in an SSH exec request of its own, without a
+// PTY or shell, for firmware that executes commands directly. Nothing is
+// echoed, there is no prompt to find and no pager, and no terminal width
+// wraps the lines.
+func runExec(cfg Config, commands []string, w io.Writer, raw bool) error {
+	if cfg.Transport != "" && cfg.Transport != "ssh" {
+		return errorf("--no-pty needs the ssh transport")
+	}
+	pc := &passwordChange{host: cfg.Host, old: cfg.Password, new: cfg.NewPassword}
+	client, err := sshClient(context.Background(), cfg, pc)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	// Port lists are rewritten as in a session, with the notation set by
+	// ZYXEL_PORT_DIALECT, learned by an earlier run, or read from the
+	// running-config in an exec request of its own.
+	dialect := func() (Dialect, error) {
+		if cfg.PortDialect != "" && cfg.PortDialect != "auto" {
+			return parseDialect(cfg.PortDialect)
+		}
+		if d, ok := learnedPortDialect(cfg.Host, cfg.Model); ok {
+			return d, nil
+		}
+		command, ok := cfg.Commands["running-config"]
+		if !ok {
+			command = defaultCommands["running-config"]
+		}
+		out, err := execCommand(client, command)
+		if err != nil {
+			return DialectFlat, errorf("failed to learn the port notation: %w", err)
+		}
+		d := dialectOf(parseRunningConfig(string(out)))
+		learnPortDialect(cfg.Host, cfg.Model, d)
+		return d, nil
+	}
+
+	for _, c := range commands {
+		c, err := expandPortList(c, dialect)
+		if err != nil {
+			return err
+		}
+		out, err := execCommand(client, c)
+
+		if raw {
+			w.Write(out)
+		} else {
+			for _, line := range strings.Split(string(out), "\n") {
+				if line = cleanLine(line); line != "" {
+					if plain {
+						line = plainText(line)
+					}
+					fmt.Fprintln(w, line)
+				}
+			}
+		}
+
+		var exitErr *ssh.ExitError
+		if errors.As(err, &exitErr) {
+			return errorf("%q exited with status %d", c, exitErr.ExitStatus())
+		}
+		if err != nil {
+			return errorf("%q: %w", c, err)
+		}
+	}
+	return nil
+}
+
+// execCommand runs command in an exec request of its own and returns its
+// output.
+func execCommand(client *ssh.Client, command string) ([]byte, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, errorf("failed to create SSH session: %w", err)
+	}
+	defer session.Close()
+	toolMetrics.commands.Add(1)
+	return session.CombinedOutput(command)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// exporterPort is the switch data behind one port's metrics.
+type exporterPort struct {
+	Name   string
+	Up     bool
+	Errors uint64
+	// RxBytes and TxBytes are totals from SNMP; CLI polls only have rates.
+	RxBytes, TxBytes uint64
+	RxKBps, TxKBps   float64
+}
+
+// pollCLI reads port status over a CLI session.
+func pollCLI(s *Session) ([]exporterPort, error) {
+	ifaces, err := interfaces(s, "*")
+	if err != nil {
+		return nil, err
+	}
+	ports := make([]exporterPort, 0, len(ifaces))
+	for _, i := range ifaces {
+		ports = append(ports, exporterPort{
+			Name:   i.Port,
+			Up:     i.LinkUp(),
+			Errors: i.Counters["Port Info/Errors"],
+			RxKBps: i.RxKBps,
+			TxKBps: i.TxKBps,
+		})
+	}
+	return ports, nil
+}
+
+// pollSNMP reads port status and counters over SNMP.
+func pollSNMP(h Host) ([]exporterPort, error) {
+	g, err := dialSNMP(h.Address)
+	if err != nil {
+		return nil, err
+	}
+	defer g.Conn.Close()
+
+	snmpPorts, err := snmpPorts(g)
+	if err != nil {
+		return nil, err
+	}
+	ports := make([]exporterPort, 0, len(snmpPorts))
+	for _, p := range snmpPorts {
+		ports = append(ports, exporterPort{
+			Name:    p.label(),
+			Up:      p.OperUp,
+			Errors:  p.Counters["ifInErrors"] + p.Counters["ifOutErrors"],
+			RxBytes: p.Counters["ifHCInOctets"],
+			TxBytes: p.Counters["ifHCOutOctets"],
+		})
+	}
+	return ports, nil
+}
+
+// scrapeContext ends when the scraper hangs up or, when Prometheus says
+// how long it waits, shortly before that, so the switches that did answer
+// still make it into the reply.
+func scrapeContext(r *http.Request) (context.Context, context.CancelFunc) {
+	secs, err := strconv.ParseFloat(r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"), 64)
+	if err != nil || secs <= 1 {
+		return context.WithCancel(r.Context())
+	}
+	return context.WithTimeout(r.Context(), time.Duration((secs-0.5)*float64(time.Second)))
+}
+
+func runExporter(fs *flag.FlagSet) func() {
+	ff := addFleetFlags(fs)
+	listen := fs.String("listen", ":9798", tr("Address to serve /metrics on"))
+	source := fs.String("source", "cli", tr("Where switch data comes from: cli or snmp"))
+	threshold := fs.Int("breaker-failures", 3, tr("Consecutive failures after which a host is no longer polled"))
+	cooldown := fs.Duration("breaker-cooldown", 5*time.Minute, tr("How long a failing host is skipped before it is tried again"))
+	return func() {
+		if *source != "cli" && *source != "snmp" {
+			fatal("--source must be cli or snmp, not %q", *source)
+		}
+		_, hosts := ff.load()
+		b := newBreaker(*threshold, *cooldown)
+		var names []string
+		for _, h := range hosts {
+			names = append(names, h.Name)
+		}
+
+		http.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := scrapeContext(r)
+			defer cancel()
+			m := newMetricWriter()
+
+			var polled []Host
+			for _, h := range hosts {
+				if b.allow(h.Name) {
+					polled = append(polled, h)
+				} else {
+					m.metric("zyxel_up", "gauge", "Whether the last poll of the switch succeeded.", 0, "host", h.Name)
+				}
+			}
+
+			var results []fleetResult[[]exporterPort]
+			start := time.Now()
+			if *source == "snmp" {
+				results = make([]fleetResult[[]exporterPort], len(polled))
+				for i, h := range polled {
+					results[i].Host = h
+					results[i].Value, results[i].Err = pollSNMP(h)
+				}
+			} else {
+				results = runFleetContext(ctx, polled, ff, func(h Host, s *Session) ([]exporterPort, error) {
+					return pollCLI(s)
+				})
+			}
+
+			for _, res := range results {
+				b.record(res.Host.Name, res.Err)
+				up := 1.0
+				if res.Err != nil {
+					up = 0
+					fmt.Fprintf(os.Stderr, "%s: %v\n", res.Host.Name, res.Err)
+				}
+				m.metric("zyxel_up", "gauge", "Whether the last poll of the switch succeeded.", up, "host", res.Host.Name)
+				for _, p := range res.Value {
+					portUp := 0.0
+					if p.Up {
+						portUp = 1
+					}
+					m.metric("zyxel_port_up", "gauge", "Whether the port has link.", portUp, "host", res.Host.Name, "port", p.Name)
+					m.metric("zyxel_port_errors_total", "counter", "Errors counted on the port.", float64(p.Errors), "host", res.Host.Name, "port", p.Name)
+					if *source == "snmp" {
+						m.metric("zyxel_port_receive_bytes_total", "counter", "Bytes received on the port.", float64(p.RxBytes), "host", res.Host.Name, "port", p.Name)
+						m.metric("zyxel_port_transmit_bytes_total", "counter", "Bytes sent on the port.", float64(p.TxBytes), "host", res.Host.Name, "port", p.Name)
+					} else {
+						m.metric("zyxel_port_receive_kbytes_per_second", "gauge", "Receive rate reported by the switch.", p.RxKBps, "host", res.Host.Name, "port", p.Name)
+						m.metric("zyxel_port_transmit_kbytes_per_second", "gauge", "Transmit rate reported by the switch.", p.TxKBps, "host", res.Host.Name, "port", p.Name)
+					}
+				}
+			}
+			m.metric("zyxel_scrape_duration_seconds", "gauge", "Time spent polling the switches.", time.Since(start).Seconds())
+			writeToolMetrics(m, b, names)
+
+			w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+			m.writeTo(w)
+		})
+
+		fmt.Fprintf(os.Stderr, "Serving metrics on %s/metrics\n", *listen)
+		if err := http.ListenAndServe(*listen, nil); err != nil {
+			fatal("%v", err)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// record is a parsed record cut down to the fields --fields asked for, in
+// the order asked for. It encodes as a JSON object with its keys in that
+// order and renders as a table row.
+type record struct {
+	names  []string
+	values []any
+}
+
+func (r record) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, name := range r.names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(r.values[i])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(v)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// selectFields keeps the named fields of the result of a parser: of each
+// record of a list, or of a single record. Names match field names and
+// the keys of counter maps such as "Error Packet/RX CRC", ignoring case;
+// a record without one of them leaves it out. found reports the names
+// some record had, and have lists the fields there are.
+func selectFields(data any, fields []string, found map[string]bool) (out any, have []string) {
+	rv := indirect(reflect.ValueOf(data))
+	if !rv.IsValid() {
+		return data, nil
+	}
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return pickFields(rv, fields, found, &have), have
+	}
+	list := make([]any, rv.Len())
+	for i := range list {
+		list[i] = pickFields(indirect(rv.Index(i)), fields, found, &have)
+	}
+	return list, have
+}
+
+func pickFields(rv reflect.Value, fields []string, found map[string]bool, have *[]string) record {
+	names, values := recordFields(rv)
+	for _, n := range fieldNames(names, values) {
+		if !slices.Contains(*have, n) {
+			*have = append(*have, n)
+		}
+	}
+	var r record
+	for _, f := range fields {
+		if i := indexFold(names, f); i >= 0 {
+			r.names = append(r.names, names[i])
+			r.values = append(r.values, valueOf(values[i]))
+			found[f] = true
+		} else if k, v, ok := counter(values, f); ok {
+			r.names = append(r.names, k)
+			r.values = append(r.values, v)
+			found[f] = true
+		}
+	}
+	return r
+}
+
+// counter finds the key of a counter map among values, ignoring case.
+func counter(values []reflect.Value, name string) (string, any, bool) {
+	for _, v := range values {
+		v = indirect(v)
+		if v.Kind() != reflect.Map || !isNumber(v.Type().Elem().Kind()) {
+			continue
+		}
+		keys := sortedMapKeys(v)
+		if i := indexFold(keys, name); i >= 0 {
+			return keys[i], v.MapIndex(reflect.ValueOf(keys[i])).Interface(), true
+		}
+	}
+	return "", nil, false
+}
+
+// fieldNames lists the fields of a record and the keys of its counter
+// maps.
+func fieldNames(names []string, values []reflect.Value) []string {
+	var out []string
+	for i, name := range names {
+		v := indirect(values[i])
+		if v.Kind() == reflect.Map && isNumber(v.Type().Elem().Kind()) {
+			out = append(out, sortedMapKeys(v)...)
+			continue
+		}
+		out = append(out, name)
+	}
+	return out
+}
+
+func indexFold(names []string, name string) int {
+	for i, n := range names {
+		if strings.EqualFold(n, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+func valueOf(v reflect.Value) any {
+	if !v.IsValid() || !v.CanInterface() {
+		return nil
+	}
+	return v.Interface()
+}
+
+// grepWriter passes on the lines written to it that match (--grep).
+type grepWriter struct {
+	w       io.Writer
+	match   *regexp.Regexp
+	partial []byte
+}
+
+func (g *grepWriter) Write(p []byte) (int, error) {
+	g.partial = append(g.partial, p...)
+	for {
+		i := bytes.IndexByte(g.partial, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := g.partial[:i+1]
+		g.partial = g.partial[i+1:]
+		if g.match.Match(bytes.TrimRight(line, "\r\n")) {
+			if _, err := g.w.Write(line); err != nil {
+				return len(p), err
+			}
+		}
+	}
+}
+
+// flush passes on a last line without a newline if it matches.
+func (g *grepWriter) flush() {
+	if len(g.partial) > 0 && g.match.Match(g.partial) {
+		g.w.Write(g.partial)
+	}
+	g.partial = nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+func runFindMAC(fs *flag.FlagSet) func() {
+	ff := addFleetFlags(fs)
+	all := fs.Bool("all", false, tr("Also list uplinks and ports facing other switches"))
+	return func() {
+		if fs.NArg() != 1 {
+			fatal("Usage: zyxel find-mac <mac> [--all]")
+		}
+		mac, err := normalizeMAC(fs.Arg(0))
+		if err != nil {
+			fatal("%v", err)
+		}
+
+		_, hosts := ff.load()
+		results := runFleet(hosts, ff, func(h Host, s *Session) (macData, error) {
+			entries, err := macTable(s)
+			if err != nil {
+				return macData{}, err
+			}
+			neighbors, err := lldpNeighbors(s)
+			return macData{entries, neighbors}, err
+		})
+
+		now := time.Now()
+		found, transit := 0, 0
+		for _, r := range results {
+			if r.Err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", r.Host.Name, r.Err)
+				continue
+			}
+			edge := make(map[string]bool)
+			for _, s := range edgeSightings(r.Host, r.Value, now) {
+				if s.MAC == mac {
+					edge[s.Port] = true
+					found++
+					fmt.Printf("%s port %s VLAN %d\n", r.Host.Name, s.Port, s.VLAN)
+				}
+			}
+			for _, e := range r.Value.entries {
+				if _, err := parsePort(e.Port); err != nil || e.MAC != mac || edge[e.Port] {
+					continue
+				}
+				transit++
+				if *all {
+					fmt.Printf("%s port %s VLAN %d (uplink)\n", r.Host.Name, e.Port, e.VLAN)
+				}
+			}
+		}
+
+		if found == 0 {
+			if transit == 0 {
+				fatal("%s not found on any switch", mac)
+			}
+			if !*all {
+				fatal("%s only seen on uplinks (%d entries); --all lists them", mac, transit)
+			}
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// SystemInfo is what "show system-information" reports about a switch.
+type SystemInfo struct {
+	Name      string `json:"name"`
+	Model     string `json:"model"`
+	Serial    string `json:"serial"`
+	Firmware  string `json:"firmware"`
+	Uptime    string `json:"uptime"`
+	BootImage string `json:"boot_image"`
+}
+
+// parseSystemInfo parses the "Key : value" lines of "show
+// system-information". The firmware line also carries the build date
+// ("V4.50(AAZJ.5) | 06/26/2020"), which is dropped.
+func parseSystemInfo(output string) SystemInfo {
+	var si SystemInfo
+	for _, line := range strings.Split(strings.ReplaceAll(output, "\r", ""), "\n") {
+		key, value, ok := splitKeyValue(line)
+		if !ok || value == "" {
+			continue
+		}
+		lk := strings.ToLower(key)
+		switch {
+		case strings.Contains(lk, "model"):
+			si.Model = value
+		case lk == "system name":
+			si.Name = value
+		case strings.Contains(lk, "serial"):
+			si.Serial = value
+		case strings.Contains(lk, "f/w version") || strings.Contains(lk, "firmware") || strings.Contains(lk, "zynos version"):
+			si.Firmware = strings.TrimSpace(strings.Split(value, "|")[0])
+		case strings.Contains(lk, "up time") || strings.Contains(lk, "uptime"):
+			si.Uptime = value
+		case strings.Contains(lk, "current boot image") || lk == "boot image":
+			si.BootImage = value
+		}
+	}
+	return si
+}
+
+func systemInfo(s *Session) (SystemInfo, error) {
+	out, err := s.Output(s.command("system-information"))
+	if err != nil {
+		return SystemInfo{}, err
+	}
+	si := parseSystemInfo(out)
+	if si.Firmware == "" && si.Model == "" {
+		return si, fmt.Errorf("no firmware version in %q output", s.command("system-information"))
+	}
+	return si, nil
+}
+
+var reloadConfirm = regexp.MustCompile(`(?i)(\(y/n\)|\[y/n\]|sure.*\?|continue\?)\s*$`)
+
+// reload reboots the switch, confirming if it asks. The session is of no
+// use afterwards.
+func (s *Session) reload() error {
+	fmt.Fprintf(s.stdin, "reload\n")
+	line, out, err := s.waitFor(context.Background(), func(line string) bool {
+		return reloadConfirm.MatchString(line) || s.prompt.MatchString(line)
+	}, 30*time.Second)
+	if err != nil {
+		// The switch went down without asking.
+		return nil
+	}
+	if !reloadConfirm.MatchString(line) {
+		return fmt.Errorf("switch did not reload: %s", strings.TrimSpace(out))
+	}
+	fmt.Fprintf(s.stdin, "y\n")
+	return nil
+}
+
+// linksUp returns the ports that have link.
+func linksUp(s *Session) ([]string, error) {
+	ifaces, err := interfaces(s, "*")
+	if err != nil {
+		return nil, err
+	}
+	var up []string
+	for _, i := range ifaces {
+		if i.LinkUp() {
+			up = append(up, i.Port)
+		}
+	}
+	return up, nil
+}
+
+// upgrade is one firmware upgrade as given on the command line.
+type upgrade struct {
+	image           string
+	server          string
+	version         string
+	wait            time.Duration
+	transferTimeout time.Duration
+	dryRun          bool
+}
+
+// run upgrades the switch name at cfg: it has the switch fetch the image by
+// TFTP, reboots it and waits for it to come back with a new firmware
+// version and with link on every port that had link before.
+func (u upgrade) run(name string, cfg Config) error {
+	s, err := Dial(cfg)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	before, err := systemInfo(s)
+	if err != nil {
+		return err
+	}
+	if u.version != "" && strings.Contains(before.Firmware, u.version) {
+		fmt.Fprintf(os.Stderr, "%s: already runs %s, skipped\n", name, before.Firmware)
+		return nil
+	}
+	up, err := linksUp(s)
+	if err != nil {
+		return err
+	}
+
+	transfer := fmt.Sprintf("copy tftp flash %s %s", u.server, u.image)
+	if u.dryRun {
+		fmt.Println(transfer)
+		fmt.Println("reload")
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "%s: running %s, loading %s from %s\n", name, before.Firmware, u.image, u.server)
+	s.commandTimeout = u.transferTimeout
+	out, err := s.Output(transfer)
+	if err != nil {
+		return fmt.Errorf("transfer failed: %w", err)
+	}
+	if looksLikeError(out) {
+		return fmt.Errorf("transfer failed: %s", strings.TrimSpace(out))
+	}
+	if err := s.reload(); err != nil {
+		return err
+	}
+	s.Close()
+	fmt.Fprintf(os.Stderr, "%s: rebooting\n", name)
+
+	// Give the switch time to go down before polling for it.
+	deadline := time.Now().Add(u.wait)
+	time.Sleep(min(30*time.Second, u.wait))
+	for {
+		if s, err = Dial(cfg); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("switch did not come back within %s: %w", u.wait, err)
+		}
+		time.Sleep(10 * time.Second)
+	}
+	defer s.Close()
+
+	after, err := systemInfo(s)
+	if err != nil {
+		return err
+	}
+	switch {
+	case u.version != "" && !strings.Contains(after.Firmware, u.version):
+		return fmt.Errorf("switch runs %s after the upgrade, not %s", after.Firmware, u.version)
+	case u.version == "" && after.Firmware == before.Firmware:
+		return fmt.Errorf("switch still runs %s after the upgrade", after.Firmware)
+	}
+
+	// Links take a while to come up after the boot.
+	for {
+		now, err := linksUp(s)
+		if err != nil {
+			return err
+		}
+		var down []string
+		for _, p := range up {
+			if !slices.Contains(now, p) {
+				down = append(down, p)
+			}
+		}
+		if len(down) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("ports %s had link before the upgrade and are still down", strings.Join(down, ","))
+		}
+		time.Sleep(10 * time.Second)
+	}
+	fmt.Fprintf(os.Stderr, "%s: upgraded %s -> %s\n", name, before.Firmware, after.Firmware)
+	return nil
+}
+
+var firmwareCommands = []subcommand{
+	{"upgrade", "Load a firmware image, reboot and check the switch comes back on it", runFirmwareUpgrade,
+		"nothing; progress goes to stderr", nil},
+}
+
+func runFirmware(fs *flag.FlagSet) func() {
+	return func() {
+		args := fs.Args()
+		if len(args) == 0 {
+			fmt.Println("Usage: zyxel firmware <command> [flags]")
+			fmt.Println()
+			fmt.Println("Commands:")
+			for _, c := range firmwareCommands {
+				fmt.Printf("  %-10s %s\n", c.name, c.summary)
+			}
+			os.Exit(1)
+		}
+		for _, c := range firmwareCommands {
+			if c.name == args[0] {
+				c.invoke("firmware "+c.name, args[1:])
+				return
+			}
+		}
+		fatal("Unknown firmware command %q", args[0])
+	}
+}
+
+func runFirmwareUpgrade(fs *flag.FlagSet) func() {
+	var u upgrade
+	fs.StringVar(&u.image, "image", "", tr("Firmware image `file` on the TFTP server, or a local file with --tftp-listen"))
+	fs.StringVar(&u.server, "tftp-server", "", tr("TFTP server the switch loads the image from"))
+	listen := fs.String("tftp-listen", "", tr("Serve the image from a built-in TFTP server at this `address` or interface instead"))
+	fs.StringVar(&u.version, "version", "", tr("Firmware version expected after the upgrade, e.g. V4.80(ABMH.2); switches already on it are skipped"))
+	fs.DurationVar(&u.wait, "wait", 10*time.Minute, tr("How long a switch may take to come back with its links up"))
+	fs.DurationVar(&u.transferTimeout, "transfer-timeout", 10*time.Minute, tr("How long loading the image may take"))
+	rolling := fs.Bool("rolling", false, tr("Upgrade the inventory switches one at a time, stopping at the first that fails"))
+	yes := fs.Bool("yes", false, tr("Upgrade without asking"))
+	dryRun := addDryRunFlag(fs)
+	cf := addConnFlags(fs)
+	ff := addFleetFlags(fs)
+	return func() {
+		if u.image == "" || (u.server == "") == (*listen == "") {
+			fatal("Usage: zyxel firmware upgrade --image <file> --tftp-server <address> | --tftp-listen <address> [--rolling]")
+		}
+		u.dryRun = *dryRun
+		if *listen != "" {
+			if _, err := os.Stat(u.image); err != nil {
+				fatal("%v", err)
+			}
+			path := u.image
+			u.image = filepath.Base(path)
+			if *dryRun {
+				addr, err := tftpListenAddr(*listen)
+				if err != nil {
+					fatal("%v", err)
+				}
+				u.server = addr.IP.String()
+			} else {
+				t, err := startTFTP(*listen)
+				if err != nil {
+					fatal("%v", err)
+				}
+				defer t.Close()
+				t.offer(u.image, path)
+				u.server = t.IP
+			}
+		}
+
+		var names []string
+		var cfgs []Config
+		if *rolling {
+			_, hosts := ff.load()
+			var errs []error
+			var err error
+			if cfgs, errs, err = hostConfigs(hosts); err != nil {
+				fatal("%v", err)
+			}
+			for i, h := range hosts {
+				if errs[i] != nil {
+					fatal("%s: %v", h.Name, errs[i])
+				}
+				names = append(names, h.Name)
+			}
+		} else {
+			cfg := cf.config()
+			cfgs, names = []Config{cfg}, []string{cfg.Host}
+		}
+
+		if !*yes && !*dryRun {
+			if !term.IsTerminal(int(os.Stdin.Fd())) {
+				fatal("stdin is not a terminal; pass --yes to upgrade")
+			}
+			fmt.Fprintf(os.Stderr, tr("Upgrade and reboot %s? [y/N] "), strings.Join(names, ", "))
+			line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+			if a := strings.ToLower(strings.TrimSpace(line)); a != "y" && a != "yes" {
+				fatal("not upgraded")
+			}
+		}
+
+		for i, cfg := range cfgs {
+			err := cfg.validate()
+			if err == nil {
+				err = u.run(names[i], cfg)
+			}
+			if err != nil {
+				if left := len(cfgs) - i - 1; left > 0 {
+					fatal("%s: %v; stopping, %d switches not upgraded", names[i], err, left)
+				}
+				fatal("%s: %v", names[i], err)
+			}
+		}
+	}
+}
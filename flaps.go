@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// linkChange is a port going up or down.
+type linkChange struct {
+	Time time.Time
+	Host string
+	Port string
+	Up   bool
+}
+
+// loadLinkChanges returns the link changes between the interface snapshots
+// of the fleet since the given time, with the last snapshot of each host
+// before it as the baseline. Flaps between two polls are not seen.
+func loadLinkChanges(db *sql.DB, since time.Time) ([]linkChange, error) {
+	from := since.UTC().Format(time.RFC3339)
+	rows, err := db.Query(`SELECT time, host, data FROM snapshots s
+		WHERE kind = 'interfaces' AND (time >= ? OR time = (
+			SELECT MAX(time) FROM snapshots WHERE host = s.host AND kind = s.kind AND time < ?))
+		ORDER BY time, id`, from, from)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	last := make(map[string]map[string]bool)
+	var changes []linkChange
+	for rows.Next() {
+		var ts, host, data string
+		if err := rows.Scan(&ts, &host, &data); err != nil {
+			return nil, err
+		}
+		var ports map[string]struct {
+			Link string `json:"link"`
+		}
+		if json.Unmarshal([]byte(data), &ports) != nil {
+			continue
+		}
+		t, _ := time.Parse(time.RFC3339, ts)
+		prev := last[host]
+		cur := make(map[string]bool)
+		for _, port := range sortedStringKeys(ports) {
+			up := Interface{Link: ports[port].Link}.LinkUp()
+			cur[port] = up
+			if was, ok := prev[port]; ok && was != up && !t.Before(since) {
+				changes = append(changes, linkChange{t, host, port, up})
+			}
+		}
+		last[host] = cur
+	}
+	return changes, rows.Err()
+}
+
+// syslogLink matches the link messages of the switches, e.g. "Port 5 link
+// down", "port 1/5 link up(1000M/F)" or "Link Down on port 5".
+var syslogLink = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\bport\s+([\w/:.-]+?)[,:]?\s+(?:link\s+)?(?:is\s+)?(up|down)\b`),
+	regexp.MustCompile(`(?i)\blink\s+(?:is\s+)?(up|down)\b.*?\bport\s+([\w/:.-]+)`),
+}
+
+// parseSyslogLink returns the port and state of a link message.
+func parseSyslogLink(msg string) (port string, up, ok bool) {
+	if m := syslogLink[0].FindStringSubmatch(msg); m != nil {
+		return m[1], strings.EqualFold(m[2], "up"), true
+	}
+	if m := syslogLink[1].FindStringSubmatch(msg); m != nil {
+		return m[2], strings.EqualFold(m[1], "up"), true
+	}
+	return "", false, false
+}
+
+// readSyslogLinkChanges returns the link messages since the given time in
+// a log written by zyxel syslogd. Senders outside the inventory are named
+// by their hostname or address.
+func readSyslogLinkChanges(path string, since time.Time) ([]linkChange, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var changes []linkChange
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		var r syslogRecord
+		if json.Unmarshal(sc.Bytes(), &r) != nil || r.Time.Before(since) {
+			continue
+		}
+		port, up, ok := parseSyslogLink(r.Message)
+		if !ok {
+			continue
+		}
+		host := r.Switch
+		if host == "" {
+			host = r.Hostname
+		}
+		if host == "" {
+			host = r.Address
+		}
+		changes = append(changes, linkChange{r.Time, host, port, up})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return changes, nil
+}
+
+// portFlaps counts the link changes of one port.
+type portFlaps struct {
+	Host    string
+	Port    string
+	Changes int
+	Downs   int
+	Last    time.Time
+}
+
+// countFlaps returns the ports with at least min link changes, most
+// changes first.
+func countFlaps(changes []linkChange, min int) []portFlaps {
+	byPort := make(map[string]*portFlaps)
+	for _, c := range changes {
+		key := c.Host + "/" + c.Port
+		f := byPort[key]
+		if f == nil {
+			f = &portFlaps{Host: c.Host, Port: c.Port}
+			byPort[key] = f
+		}
+		f.Changes++
+		if !c.Up {
+			f.Downs++
+		}
+		if c.Time.After(f.Last) {
+			f.Last = c.Time
+		}
+	}
+	var flaps []portFlaps
+	for _, f := range byPort {
+		if f.Changes >= min {
+			flaps = append(flaps, *f)
+		}
+	}
+	sort.Slice(flaps, func(i, j int) bool {
+		a, b := flaps[i], flaps[j]
+		if a.Changes != b.Changes {
+			return a.Changes > b.Changes
+		}
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		pa, errA := parsePort(a.Port)
+		pb, errB := parsePort(b.Port)
+		if errA == nil && errB == nil {
+			return pa.less(pb)
+		}
+		return a.Port < b.Port
+	})
+	return flaps
+}
+
+func runFlaps(fs *flag.FlagSet) func() {
+	since := fs.Duration("since", 24*time.Hour, tr("How far back to look for link changes"))
+	min := fs.Int("min", 4, tr("Link changes (up or down) that make a port flapping"))
+	var logs []string
+	fs.Func("syslog", tr("Count the link messages in this zyxel syslogd `log` instead of the snapshot history (repeatable)"), func(s string) error {
+		logs = append(logs, s)
+		return nil
+	})
+	return func() {
+		if *min < 1 {
+			fatal("--min must be positive")
+		}
+		from := time.Now().Add(-*since)
+
+		var changes []linkChange
+		if len(logs) > 0 {
+			for _, path := range logs {
+				c, err := readSyslogLinkChanges(path, from)
+				if err != nil {
+					fatal("%v", err)
+				}
+				changes = append(changes, c...)
+			}
+		} else {
+			db, err := openHistory()
+			if err != nil {
+				fatal("%v", err)
+			}
+			if db == nil {
+				fatal("No history: set ZYXEL_HISTORY_DB to record snapshots in collect, monitor and backups take")
+			}
+			defer db.Close()
+			if changes, err = loadLinkChanges(db, from); err != nil {
+				fatal("%v", err)
+			}
+		}
+
+		flaps := countFlaps(changes, *min)
+		if len(flaps) == 0 {
+			fmt.Println("No flapping ports")
+			return
+		}
+		fmt.Printf("%-20s %-8s %7s %5s  %s\n", "Host", "Port", "Changes", "Downs", "Last change")
+		for _, f := range flaps {
+			fmt.Printf("%-20s %-8s %7d %5d  %s\n", f.Host, f.Port, f.Changes, f.Downs, f.Last.Local().Format(timeLayout))
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// healthStatus is the outcome of a health check. The values are the
+// Nagios plugin exit codes.
+type healthStatus int
+
+const (
+	healthOK healthStatus = iota
+	healthWarn
+	healthFail
+	healthUnknown
+)
+
+func (h healthStatus) String() string {
+	return [...]string{"ok", "warn", "fail", "unknown"}[h]
+}
+
+// perfValue is one measurement behind a check, with its thresholds; nil
+// thresholds are not set.
+type perfValue struct {
+	Label      string
+	Value      float64
+	Unit       string
+	Warn, Crit *float64
+}
+
+// healthCheck is the state of one part of the switch.
+type healthCheck struct {
+	Name   string
+	Status healthStatus
+	Detail string
+	Perf   []perfValue
+}
+
+// healthLimits are the thresholds of the health checks.
+type healthLimits struct {
+	cpuWarn, cpuCrit float64
+	memWarn, memCrit float64
+	// tempMargin is how close to the switch's own threshold a temperature
+	// may get before it warns.
+	tempMargin float64
+}
+
+// level rates value against warn and crit; a nil threshold is never
+// reached.
+func level(value float64, warn, crit *float64) healthStatus {
+	switch {
+	case crit != nil && value >= *crit:
+		return healthFail
+	case warn != nil && value >= *warn:
+		return healthWarn
+	}
+	return healthOK
+}
+
+var (
+	percent       = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*%`)
+	oneMinutePcnt = regexp.MustCompile(`(?i)(?:1 min|one minute|60 sec)\w*\s*[:=]?\s*(\d+(?:\.\d+)?)\s*%`)
+)
+
+// parseCPU returns the CPU load from "show cpu-utilization": the one
+// minute average when the switch gives one, otherwise the first
+// percentage.
+func parseCPU(output string) (float64, bool) {
+	m := oneMinutePcnt.FindStringSubmatch(output)
+	if m == nil {
+		m = percent.FindStringSubmatch(output)
+	}
+	if m == nil {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(m[1], 64)
+	return n, err == nil
+}
+
+// parseMemory returns the memory in use, in percent, from "show memory":
+// a usage or utilization line, or else the used and total amounts.
+func parseMemory(output string) (float64, bool) {
+	var total, used, free float64
+	for _, line := range strings.Split(strings.ReplaceAll(output, "\r", ""), "\n") {
+		key, value, ok := splitKeyValue(line)
+		if !ok {
+			continue
+		}
+		lk := strings.ToLower(key)
+		if m := percent.FindStringSubmatch(value); m != nil && (strings.Contains(lk, "usage") || strings.Contains(lk, "utilization")) {
+			n, err := strconv.ParseFloat(m[1], 64)
+			return n, err == nil
+		}
+		f := strings.Fields(value)
+		if len(f) == 0 {
+			continue
+		}
+		n, err := strconv.ParseFloat(f[0], 64)
+		if err != nil {
+			continue
+		}
+		switch {
+		case strings.Contains(lk, "total"):
+			total = n
+		case strings.Contains(lk, "used"):
+			used = n
+		case strings.Contains(lk, "free"):
+			free = n
+		}
+	}
+	if used == 0 && free > 0 {
+		used = total - free
+	}
+	if total <= 0 {
+		return 0, false
+	}
+	return used / total * 100, true
+}
+
+// sensor is one row of "show hardware-monitor".
+type sensor struct {
+	Name      string
+	Current   float64
+	Threshold float64
+	Status    string
+}
+
+// parseHardwareMonitor parses "show hardware-monitor C" into its sections
+// (temperature, fan, voltage and, on models with them, power supplies)
+// keyed by the lower-case first word of the section header.
+func parseHardwareMonitor(output string) map[string][]sensor {
+	sections := make(map[string][]sensor)
+	section := ""
+	var columns []string
+	for _, line := range strings.Split(strings.ReplaceAll(output, "\r", ""), "\n") {
+		f := strings.Fields(line)
+		switch {
+		case len(f) == 0:
+			section = ""
+			continue
+		case isRule(line):
+			continue
+		case strings.EqualFold(f[len(f)-1], "status"):
+			section = strings.ToLower(f[0])
+			for _, k := range []string{"temperature", "fan", "voltage", "power", "psu"} {
+				if strings.HasPrefix(section, k) {
+					section = k
+				}
+			}
+			columns = f
+			continue
+		case section == "":
+			continue
+		}
+		s := sensor{Name: f[0], Status: f[len(f)-1]}
+		if len(f) > 2 {
+			s.Current, _ = strconv.ParseFloat(f[1], 64)
+		}
+		if len(f) == len(columns) {
+			for i, c := range columns {
+				if strings.EqualFold(c, "threshold") {
+					s.Threshold, _ = strconv.ParseFloat(f[i], 64)
+				}
+			}
+		}
+		sections[section] = append(sections[section], s)
+	}
+	return sections
+}
+
+// sensorCheck rates one hardware-monitor section: any sensor the switch
+// does not report as normal fails it. Temperatures within the margin of
+// their threshold warn.
+func sensorCheck(name, unit string, sensors []sensor, margin float64) healthCheck {
+	c := healthCheck{Name: name}
+	var parts []string
+	for _, s := range sensors {
+		st := healthOK
+		if l := strings.ToLower(s.Status); l != "normal" && l != "ok" && l != "good" {
+			st = healthFail
+		} else if unit == "C" && s.Threshold > 0 && s.Current >= s.Threshold-margin {
+			st = healthWarn
+		}
+		c.Status = max(c.Status, st)
+		part := s.Name
+		if unit != "" {
+			part += fmt.Sprintf(" %g %s", s.Current, unit)
+		}
+		switch st {
+		case healthWarn:
+			part += fmt.Sprintf(" (threshold %g)", s.Threshold)
+		case healthFail:
+			part += " " + s.Status
+		}
+		parts = append(parts, part)
+		// Only temperature thresholds are upper limits; a fan's is its
+		// minimum speed.
+		pv := perfValue{Label: strings.ToLower(name + "_" + s.Name), Value: s.Current}
+		if unit == "C" && s.Threshold > 0 {
+			warn := s.Threshold - margin
+			pv.Warn, pv.Crit = &warn, &s.Threshold
+		}
+		c.Perf = append(c.Perf, pv)
+	}
+	c.Detail = strings.Join(parts, ", ")
+	return c
+}
+
+// checkHealth reads CPU, memory, the hardware monitor and the trunks.
+// Parts the switch does not report are left out.
+func checkHealth(s *Session, lim healthLimits) ([]healthCheck, error) {
+	var checks []healthCheck
+	out, err := s.Output(s.command("cpu"))
+	if err != nil {
+		return nil, err
+	}
+	if cpu, ok := parseCPU(out); ok && !looksLikeError(out) {
+		checks = append(checks, healthCheck{
+			Name:   "CPU",
+			Status: level(cpu, &lim.cpuWarn, &lim.cpuCrit),
+			Detail: fmt.Sprintf("%g%%", cpu),
+			Perf:   []perfValue{{"cpu", cpu, "%", &lim.cpuWarn, &lim.cpuCrit}},
+		})
+	}
+
+	if out, err = s.Output(s.command("memory")); err != nil {
+		return nil, err
+	}
+	if mem, ok := parseMemory(out); ok && !looksLikeError(out) {
+		mem = math.Round(mem*10) / 10
+		checks = append(checks, healthCheck{
+			Name:   "Memory",
+			Status: level(mem, &lim.memWarn, &lim.memCrit),
+			Detail: fmt.Sprintf("%.0f%% used", mem),
+			Perf:   []perfValue{{"memory", mem, "%", &lim.memWarn, &lim.memCrit}},
+		})
+	}
+
+	if out, err = s.Output(s.command("hardware-monitor")); err != nil {
+		return nil, err
+	}
+	// Not looksLikeError: a failed sensor is reported as "Error". Output
+	// without sensor tables parses to nothing.
+	hw := parseHardwareMonitor(out)
+	for _, sec := range []struct{ key, name, unit string }{
+		{"temperature", "Temperature", "C"},
+		{"fan", "Fan", "RPM"},
+		{"voltage", "Voltage", "V"},
+		{"power", "PSU", ""},
+		{"psu", "PSU", ""},
+	} {
+		if len(hw[sec.key]) > 0 {
+			checks = append(checks, sensorCheck(sec.name, sec.unit, hw[sec.key], lim.tempMargin))
+		}
+	}
+
+	trunks, err := trunkHealthStates(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(trunks) > 0 {
+		checks = append(checks, trunkCheck(trunks))
+	}
+	if len(checks) == 0 {
+		return nil, fmt.Errorf("the switch reported none of CPU, memory or hardware monitor")
+	}
+	return checks, nil
+}
+
+// healthPoints are the checks as zyxel_health points: the status code
+// and the check's measurements.
+func healthPoints(host string, checks []healthCheck, t time.Time) []influxPoint {
+	points := make([]influxPoint, 0, len(checks))
+	for _, c := range checks {
+		fields := map[string]any{"status": int64(c.Status)}
+		for _, p := range c.Perf {
+			fields[influxKey(p.Label)] = p.Value
+		}
+		points = append(points, influxPoint{
+			Measurement: "zyxel_health",
+			Tags:        [][2]string{{"host", host}, {"check", strings.ToLower(c.Name)}},
+			Fields:      fields,
+			Time:        t,
+		})
+	}
+	return points
+}
+
+func runHealth(fs *flag.FlagSet) func() {
+	var lim healthLimits
+	fs.Float64Var(&lim.cpuWarn, "cpu-warn", 80, tr("CPU load in percent that warns"))
+	fs.Float64Var(&lim.cpuCrit, "cpu-crit", 95, tr("CPU load in percent that fails"))
+	fs.Float64Var(&lim.memWarn, "mem-warn", 80, tr("Memory use in percent that warns"))
+	fs.Float64Var(&lim.memCrit, "mem-crit", 95, tr("Memory use in percent that fails"))
+	fs.Float64Var(&lim.tempMargin, "temp-margin", 10, tr("Warn when a temperature is this many degrees below the switch's threshold"))
+	format := fs.String("format", "text", tr("Output format: text, nagios or influx"))
+	cf := addConnFlags(fs)
+	return func() {
+		if *format != "text" && *format != "nagios" && *format != "influx" {
+			fatal("--format must be text, nagios or influx, not %q", *format)
+		}
+		// A check that cannot run is UNKNOWN, whether for the settings or
+		// the connection.
+		cfg, err := cf.load()
+		checks, err := func() ([]healthCheck, error) {
+			if err != nil {
+				return nil, err
+			}
+			s, err := Dial(cfg)
+			if err != nil {
+				return nil, err
+			}
+			defer s.Close()
+			return checkHealth(s, lim)
+		}()
+
+		if *format == "nagios" {
+			if err != nil {
+				nagiosUnknown("ZYXEL HEALTH", err)
+			}
+			os.Exit(int(printNagios("ZYXEL HEALTH", fmt.Sprintf("%d checks OK", len(checks)), checks)))
+		}
+		if err != nil && *format == "influx" {
+			fatal("%v", err)
+		}
+		if err != nil {
+			fmt.Printf("Health: UNKNOWN: %v\n", err)
+			os.Exit(int(healthUnknown))
+		}
+		if *format == "influx" {
+			if err := writeInflux(healthPoints(cfg.Host, checks, time.Now())); err != nil {
+				fatal("%v", err)
+			}
+			return
+		}
+		for _, c := range checks {
+			fmt.Printf("%-12s %-5s %s\n", c.Name, c.Status, c.Detail)
+		}
+		worst := worstStatus(checks)
+		fmt.Printf("Health: %s\n", map[healthStatus]string{healthOK: "PASS", healthWarn: "WARN", healthFail: "FAIL"}[worst])
+		os.Exit(int(worst))
+	}
+}
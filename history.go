@@ -0,0 +1,309 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// The snapshot history is an SQLite database, ZYXEL_HISTORY_DB, where
+// collect, monitor and backups take keep what they read from the switches.
+// Each row is one kind of state (interfaces, macs, system or uplinks) of
+// one host at one time, as JSON, so it can also be queried with
+// json_extract.
+const historySchema = `
+CREATE TABLE IF NOT EXISTS snapshots (
+	id   INTEGER PRIMARY KEY,
+	time TEXT NOT NULL,
+	host TEXT NOT NULL,
+	kind TEXT NOT NULL,
+	data TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS snapshots_host_kind_time ON snapshots (host, kind, time);
+`
+
+// historyKinds are the kinds of state a snapshot holds.
+var historyKinds = []string{"interfaces", "macs", "system", "uplinks"}
+
+// openHistory opens the snapshot database, creating it if needed. It
+// returns nil when ZYXEL_HISTORY_DB is not set.
+func openHistory() (*sql.DB, error) {
+	path := os.Getenv("ZYXEL_HISTORY_DB")
+	if path == "" {
+		return nil, nil
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(historySchema); err != nil {
+		db.Close()
+		return nil, errorf("failed to open history %s: %w", path, err)
+	}
+	return db, nil
+}
+
+// snapshot is the state read from one switch; nil parts were not read.
+type snapshot struct {
+	Interfaces []Interface
+	MACs       []MACEntry
+	System     *SystemInfo
+	// Uplinks are the ports facing other switches, whose MAC addresses
+	// are not where the devices are.
+	Uplinks []string
+}
+
+// readSnapshot reads the parts of a snapshot that are not set yet. Empty
+// tables are kept as read, so a MAC address that left shows as gone.
+func readSnapshot(h Host, s *Session, snap snapshot) (snapshot, error) {
+	var err error
+	if snap.Interfaces == nil {
+		if snap.Interfaces, err = interfaces(s, "*"); err != nil {
+			return snap, err
+		}
+		snap.Interfaces = nonNil(snap.Interfaces)
+	}
+	if snap.MACs == nil {
+		if snap.MACs, err = macTable(s); err != nil {
+			return snap, err
+		}
+		snap.MACs = nonNil(snap.MACs)
+	}
+	if snap.System == nil {
+		info, err := systemInfo(s)
+		if err != nil {
+			return snap, err
+		}
+		snap.System = &info
+	}
+	if snap.Uplinks == nil {
+		neighbors, err := lldpNeighbors(s)
+		if err != nil {
+			return snap, err
+		}
+		snap.Uplinks = uplinkNames(h, neighbors)
+	}
+	return snap, nil
+}
+
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
+
+// uplinkNames returns the ports of h facing other switches, sorted.
+func uplinkNames(h Host, neighbors []LLDPNeighbor) []string {
+	var ports []Port
+	for p := range switchPorts(h, neighbors) {
+		ports = append(ports, p)
+	}
+	sort.Slice(ports, func(i, j int) bool { return ports[i].less(ports[j]) })
+	names := []string{}
+	for _, p := range ports {
+		names = append(names, p.String())
+	}
+	return names
+}
+
+// kinds returns the stored form of each part: interfaces by port, MAC
+// addresses by MAC and VLAN with the port as value, and the system
+// information and the uplinks.
+func (snap snapshot) kinds() map[string]any {
+	m := make(map[string]any)
+	if snap.Interfaces != nil {
+		ports := make(map[string]any)
+		for _, i := range snap.Interfaces {
+			ports[i.Port] = map[string]any{
+				"link":     i.Link,
+				"status":   i.Status,
+				"lacp":     i.LACP,
+				"counters": i.Counters,
+			}
+		}
+		m["interfaces"] = ports
+	}
+	if snap.MACs != nil {
+		macs := make(map[string]map[string]string)
+		for _, e := range snap.MACs {
+			if macs[e.MAC] == nil {
+				macs[e.MAC] = make(map[string]string)
+			}
+			macs[e.MAC][strconv.Itoa(e.VLAN)] = e.Port
+		}
+		m["macs"] = macs
+	}
+	if i := snap.System; i != nil {
+		m["system"] = map[string]string{
+			"name":       i.Name,
+			"model":      i.Model,
+			"serial":     i.Serial,
+			"firmware":   i.Firmware,
+			"uptime":     i.Uptime,
+			"boot_image": i.BootImage,
+		}
+	}
+	if snap.Uplinks != nil {
+		m["uplinks"] = snap.Uplinks
+	}
+	return m
+}
+
+// saveSnapshot stores the parts of snap read from host at t.
+func saveSnapshot(db *sql.DB, host string, t time.Time, snap snapshot) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for kind, data := range snap.kinds() {
+		b, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("INSERT INTO snapshots (time, host, kind, data) VALUES (?, ?, ?, ?)",
+			t.UTC().Format(time.RFC3339), host, kind, string(b)); err != nil {
+			return errorf("failed to save snapshot: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// storeSnapshots saves the snapshots of a fleet run when the history is
+// enabled. Failing to is a warning; the run itself succeeded.
+func storeSnapshots(t time.Time, snaps map[string]snapshot) {
+	db, err := openHistory()
+	if err == nil && db != nil {
+		defer db.Close()
+		for host, snap := range snaps {
+			if err = saveSnapshot(db, host, t, snap); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+}
+
+// historyEnabled reports whether runs should read and store snapshots.
+func historyEnabled() bool {
+	return os.Getenv("ZYXEL_HISTORY_DB") != ""
+}
+
+// flatten turns nested JSON values into dotted paths, e.g.
+// "5.counters.RX Packet/Unicast".
+func flatten(prefix string, v any, out map[string]string) {
+	switch x := v.(type) {
+	case map[string]any:
+		for k, v := range x {
+			flatten(prefix+"."+k, v, out)
+		}
+	case nil:
+	default:
+		out[prefix] = fmt.Sprint(x)
+	}
+}
+
+// historyChange is a value that changed between two snapshots.
+type historyChange struct {
+	Time  time.Time
+	Path  string
+	Value string
+}
+
+// loadHistory returns how the values under query ("kind" or
+// "kind.path", e.g. "interfaces.5.link") changed for host since the
+// given time. The first snapshot lists every value; values that are
+// missing from a later one change to "(gone)".
+func loadHistory(db *sql.DB, host, query string, since time.Time) ([]historyChange, int, error) {
+	kind, _, _ := strings.Cut(query, ".")
+	rows, err := db.Query("SELECT time, data FROM snapshots WHERE host = ? AND kind = ? AND time >= ? ORDER BY time, id",
+		host, kind, since.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	match := func(path string) bool {
+		return path == query || strings.HasPrefix(path, query+".")
+	}
+	var changes []historyChange
+	var prev map[string]string
+	n := 0
+	for rows.Next() {
+		var ts, data string
+		if err := rows.Scan(&ts, &data); err != nil {
+			return nil, 0, err
+		}
+		t, _ := time.Parse(time.RFC3339, ts)
+		var v any
+		if err := json.Unmarshal([]byte(data), &v); err != nil {
+			continue
+		}
+		cur := make(map[string]string)
+		flatten(kind, v, cur)
+		n++
+		for _, p := range sortedStringKeys(cur) {
+			if old, ok := prev[p]; match(p) && (!ok || old != cur[p]) {
+				changes = append(changes, historyChange{t, p, cur[p]})
+			}
+		}
+		for _, p := range sortedStringKeys(prev) {
+			if _, ok := cur[p]; match(p) && !ok {
+				changes = append(changes, historyChange{t, p, "(gone)"})
+			}
+		}
+		prev = cur
+	}
+	return changes, n, rows.Err()
+}
+
+func runHistory(fs *flag.FlagSet) func() {
+	since := fs.Duration("since", 30*24*time.Hour, tr("How far back to look"))
+	return func() {
+		if fs.NArg() != 2 {
+			fatal("Usage: zyxel history <host> <interfaces|macs|system|uplinks>[.<path>]")
+		}
+		host, query := fs.Arg(0), fs.Arg(1)
+		kind, rest, _ := strings.Cut(query, ".")
+		if !slices.Contains(historyKinds, kind) {
+			fatal("Unknown history %q; use interfaces, macs, system or uplinks", kind)
+		}
+		// MAC addresses may be given in any notation.
+		if kind == "macs" && rest != "" {
+			if mac, err := normalizeMAC(rest); err == nil {
+				query = kind + "." + mac
+			}
+		}
+
+		db, err := openHistory()
+		if err != nil {
+			fatal("%v", err)
+		}
+		if db == nil {
+			fatal("No history: set ZYXEL_HISTORY_DB to record snapshots in collect, monitor and backups take")
+		}
+		defer db.Close()
+		changes, n, err := loadHistory(db, host, query, time.Now().Add(-*since))
+		if err != nil {
+			fatal("%v", err)
+		}
+		if n == 0 {
+			fatal("No %s snapshots of %s in the last %s", kind, host, *since)
+		}
+		for _, c := range changes {
+			fmt.Printf("%s  %s  %s\n", c.Time.Local().Format(timeLayout), c.Path, c.Value)
+		}
+	}
+}
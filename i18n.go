@@ -0,0 +1,552 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// lang is the language of help text and messages, "en" or "et".
+var lang = "en"
+
+// translations maps an English message or format string to its
+// translation. Untranslated messages are shown in English.
+var translations = map[string]map[string]string{
+	"et": {
+		"Error: ":                "Viga: ",
+		"Usage:":                 "Kasutus:",
+		"Examples:":              "Näited:",
+		"Subcommands:":           "Alamkäsud:",
+		"Environment variables:": "Keskkonnamuutujad:",
+
+		"show available commands": "näita saadaolevaid käske",
+		"untouched output":        "töötlemata väljund",
+
+		"List ports detected as uplinks":                         "Näita üleslinkideks tuvastatud porte",
+		"Check the inventory for configuration drift":            "Kontrolli inventuuri seadistuste kõrvalekaldeid",
+		"Record MAC, ARP and DHCP snooping tables of the fleet":  "Salvesta kõigi kommutaatorite MAC-, ARP- ja DHCP snooping tabelid",
+		"Show where a MAC address has been seen":                 "Näita, kus MAC-aadressi on nähtud",
+		"Take, compare, search and import configuration backups": "Tee, võrdle, otsi ja impordi seadistuste varukoopiaid",
+		"Unknown backups command %q":                             "Tundmatu varukoopia käsk %q",
+		"Store credentials for a switch in the OS keychain":      "Salvesta kommutaatori kasutajaandmed OS-i võtmehoidjasse",
+		"User: ": "Kasutaja: ",
+		"Serve switch and tool metrics for Prometheus":                      "Jaga kommutaatorite ja tööriista mõõdikuid Prometheusele",
+		"Receive syslog from the switches and log it as JSON":               "Võta kommutaatoritelt syslog vastu ja logi see JSON-ina",
+		"Watch for link, PoE and MAC changes and post them to webhooks":     "Jälgi lingi, PoE ja MAC-i muutusi ning saada need veebihaakidele",
+		"Report MAC addresses that moved to another port":                   "Teata MAC-aadressidest, mis liikusid teise porti",
+		"List ports whose link went up and down repeatedly":                 "Näita porte, mille link käis korduvalt üles ja alla",
+		"Show how interfaces, MAC table and system information changed":     "Näita, kuidas liidesed, MAC-tabel ja süsteemiteave on muutunud",
+		"Run a guided troubleshooting playbook":                             "Käivita juhendatud veaotsingu käsiraamat",
+		"Show system information and port counters over SNMP":               "Näita süsteemi infot ja pordiloendureid SNMP kaudu",
+		"Find the switch port a MAC address is learned on":                  "Leia kommutaatori port, kus MAC-aadress on õpitud",
+		"Export the LLDP topology of the inventory as Graphviz DOT or JSON": "Ekspordi inventuuri LLDP topoloogia Graphviz DOT või JSON kujul",
+		"List the inventory switches, also as an Ansible dynamic inventory": "Näita inventuuri kommutaatoreid, ka Ansible dünaamilise inventuurina",
+		"Find Zyxel switches in a subnet over SSH and SNMP":                 "Leia alamvõrgust Zyxeli kommutaatorid SSH ja SNMP kaudu",
+		"Show per-port packet rates and error deltas over an interval":      "Näita portide pakettide kiirust ja vigade kasvu teatud aja jooksul",
+		"Show PoE power usage and switch or power-cycle PoE ports":          "Näita PoE võimsuse kasutust ning lülita või taaskäivita PoE porte",
+		"Mirror ports to a monitor port for packet captures":                "Peegelda porte pakettide püüdmiseks monitorporti",
+		"Show and set storm control and loop guard on ports":                "Näita ja sea portide tormikontrolli ning silmusekaitset",
+		"Show, add and remove classifier and policy rules":                  "Näita, lisa ja eemalda klassifikaatori- ja poliitikareegleid",
+		"List, add and remove switch accounts and change passwords":         "Näita, lisa ja eemalda kommutaatori kontosid ning muuda paroole",
+		"Check and set the NTP server and time zone of the switches":        "Kontrolli ja sea kommutaatorite NTP-serverit ning ajavööndit",
+		"Set up the SNMPv3 user of ZYXEL_SNMP_* on switches":                "Seadista kommutaatoritel ZYXEL_SNMP_* SNMPv3 kasutaja",
+		"Run the steps of a YAML runbook on inventory switches":             "Käivita YAML-tegevuskava sammud inventuuri kommutaatoritel",
+		"Run a Starlark script against switches":                            "Käivita kommutaatoritel Starlarki skript",
+		"List the DHCP snooping bindings of a switch or the inventory":      "Näita kommutaatori või inventuuri DHCP snooping sidumisi",
+		"Show link aggregation groups and the state of their members":       "Näita lingiagregeerimise gruppe ja nende liikmete olekut",
+		"Show the IGMP snooping groups and their member ports":              "Näita IGMP snooping gruppe ja nende liikmesporte",
+		"Find the switch port of an IP address from the ARP and MAC tables": "Leia IP-aadressi kommutaatoriport ARP- ja MAC-tabelitest",
+		"Show the spanning-tree bridge, root and port roles and states":     "Näita toesepuu silda, juurt ning portide rolle ja olekuid",
+		"Show SFP module diagnostics and warn on bad readings":              "Näita SFP-moodulite diagnostikat ja hoiata halbade näitude korral",
+		"Test the cables on ports and show each pair's status and length":   "Testi portide kaableid ja näita iga paari olekut ning pikkust",
+		"Create and delete VLANs and change their port membership":          "Loo ja kustuta VLAN-e ning muuda nende portide kuuluvust",
+		"Enable, disable or describe ports":                                 "Luba, keela või kirjelda porte",
+		"Render a configuration template with variables from a YAML file":   "Koosta seadistus mallist ja YAML-faili muutujatest",
+		"Render a configuration template and apply it to a switch":          "Koosta seadistus mallist ja rakenda see kommutaatoril",
+		"Show the changes that would bring a switch to a declared state":    "Näita muudatusi, mis viiksid kommutaatori kirjeldatud olekusse",
+		"Apply the changes that bring a switch to a declared state":         "Rakenda muudatused, mis viivad kommutaatori kirjeldatud olekusse",
+		"Restore the checkpoint taken before a change":                      "Taasta enne muudatust tehtud kontrollpunkt",
+		"Upgrade switch firmware, one switch or the inventory in turn":      "Uuenda püsivara ühel kommutaatoril või kordamööda kogu inventuuril",
+		"Report on the inventory switches":                                  "Aruanded inventuuri kommutaatorite kohta",
+		"Check CPU, memory, temperature, fans, power supplies and trunks":   "Kontrolli protsessorit, mälu, temperatuuri, ventilaatoreid, toiteplokke ja agregeeritud linke",
+		"Unknown port command %q":                                           "Tundmatu pordi käsk %q",
+		"Unknown vlan command %q":                                           "Tundmatu VLAN-i käsk %q",
+		"Unknown poe command %q":                                            "Tundmatu PoE käsk %q",
+		"Unknown firmware command %q":                                       "Tundmatu püsivara käsk %q",
+		"Unknown report command %q":                                         "Tundmatu aruande käsk %q",
+		"Print subcommands, flags and exit codes as JSON":                   "Väljasta alamkäsud, lipud ja väljumiskoodid JSON-ina",
+		"Serve a recorded session over Telnet as a mock switch":             "Jäljenda Telnetis salvestatud seansiga kommutaatorit",
+		"Run a simulated GS1920 over SSH for development and CI":            "Käivita SSH kaudu simuleeritud GS1920 arenduseks ja CI jaoks",
+		"Print a bash, zsh or fish completion script":                       "Väljasta bashi, zsh-i või fishi lõpetusskript",
+		"Learn the switch's commands from its ? help":                       "Õpi kommutaatori käsud selle ? abist",
+
+		"Switch IP address (required)": "Kommutaatori IP-aadress (kohustuslik)",
+		"SSH username (required)":      "SSH kasutajanimi (kohustuslik)",
+		"SSH password (required)":      "SSH parool (kohustuslik)",
+		"ssh, telnet, http or https (default: ssh; http/https are for GS1900 web management)":                             "ssh, telnet, http või https (vaikimisi: ssh; http/https on GS1900 veebihalduse jaoks)",
+		"Switch address (default: ZYXEL_HOST)":                                                                            "Kommutaatori aadress (vaikimisi: ZYXEL_HOST)",
+		"Login user (default: ZYXEL_USER)":                                                                                "Kasutajanimi (vaikimisi: ZYXEL_USER)",
+		"Read the password from `file` (default: ZYXEL_PASSWORD)":                                                         "Loe parool failist `file` (vaikimisi: ZYXEL_PASSWORD)",
+		"Port to connect to (default: ZYXEL_PORT or the transport's port)":                                                "Ühenduse port (vaikimisi: ZYXEL_PORT või ühenduse tüübi port)",
+		"Connection type: ssh, telnet, http or https (default: ZYXEL_TRANSPORT or ssh)":                                   "Ühenduse tüüp: ssh, telnet, http või https (vaikimisi: ZYXEL_TRANSPORT või ssh)",
+		"unknown transport %q (want ssh, telnet, http or https)":                                                          "tundmatu ühenduse tüüp %q (lubatud ssh, telnet, http või https)",
+		"unknown auth method %q (want password or key)":                                                                   "tundmatu autentimisviis %q (lubatud password või key)",
+		"key authentication needs the ssh transport":                                                                      "võtmega autentimine vajab ssh ühendust",
+		"key authentication needs ZYXEL_KEY_FILE":                                                                         "võtmega autentimine vajab ZYXEL_KEY_FILE väärtust",
+		"failed to read SSH key: %w":                                                                                      "SSH võtme lugemine ebaõnnestus: %w",
+		"failed to parse SSH key %s: %w":                                                                                  "SSH võtme %s parsimine ebaõnnestus: %w",
+		"Use the connection settings of this profile from the config file":                                                "Kasuta seadistusfaili selle profiili ühenduse seadeid",
+		"Terminal width announced to the switch (default: ZYXEL_TERM_WIDTH or 200)":                                       "Kommutaatorile teatatav terminali laius (vaikimisi: ZYXEL_TERM_WIDTH või 200)",
+		"Terminal height announced to the switch (default: ZYXEL_TERM_HEIGHT or 80)":                                      "Kommutaatorile teatatav terminali kõrgus (vaikimisi: ZYXEL_TERM_HEIGHT või 80)",
+		"Rejoin output lines the switch wrapped at this many columns (default: ZYXEL_WRAP_WIDTH)":                         "Ühenda väljundi read, mille kommutaator sellel veerul murdis (vaikimisi: ZYXEL_WRAP_WIDTH)",
+		"the %s transport has no CLI; only -c with show running-config, show vlan or show interfaces status is supported": "ühendusel %s puudub käsurida; toetatud on ainult -c käsuga show running-config, show vlan või show interfaces status",
+		"--configure, --save, --runbook, --watch and --neighbors need a CLI transport":                                    "--configure, --save, --runbook, --watch ja --neighbors vajavad käsurea ühendust",
+		"stdin is not a terminal; review with zyxel render and pass --yes to apply":                                       "sisend pole terminal; vaata üle käsuga zyxel render ja rakenda lipuga --yes",
+		"stdin is not a terminal; review with zyxel plan and pass --yes to apply":                                         "sisend pole terminal; vaata üle käsuga zyxel plan ja rakenda lipuga --yes",
+		"stdin is not a terminal; review with --dry-run and pass --yes to roll back":                                      "sisend pole terminal; vaata üle lipuga --dry-run ja taasta lipuga --yes",
+		"Usage: zyxel history <host> <interfaces|macs|system|uplinks>[.<path>]":                                           "Kasutus: zyxel history <host> <interfaces|macs|system|uplinks>[.<tee>]",
+		"SQLite file where collect, monitor and backups take record snapshots for history":                                "SQLite fail, kuhu collect, monitor ja backups take salvestavad ajaloo jaoks hetktõmmiseid",
+		"No history: set ZYXEL_HISTORY_DB to record snapshots in collect, monitor and backups take":                       "Ajalugu pole: määra ZYXEL_HISTORY_DB, et collect, monitor ja backups take salvestaksid hetktõmmiseid",
+		"InfluxDB write URL that --format influx sends to instead of stdout, with ZYXEL_INFLUX_TOKEN":                     "InfluxDB kirjutamise URL, kuhu --format influx saadab väljundi stdout-i asemel, koos ZYXEL_INFLUX_TOKEN-iga",
+		"Usage: zyxel firmware upgrade --image <file> --tftp-server <address> | --tftp-listen <address> [--rolling]":      "Kasutus: zyxel firmware upgrade --image <fail> --tftp-server <aadress> | --tftp-listen <aadress> [--rolling]",
+		"Password for %s@%s: ":            "Kasutaja %s@%s parool: ",
+		"Apply %d commands to %s? [y/N] ": "Kas rakendada %d käsku seadmele %s? [y/N] ",
+		"Run them on %s? [y/N] ":          "Kas käivitada need seadmes %s? [y/N] ",
+		"Upgrade and reboot %s? [y/N] ":   "Kas uuendada ja taaskäivitada %s? [y/N] ",
+		"New password: ":                  "Uus parool: ",
+		"Retype new password: ":           "Korda uut parooli: ",
+		"password for %s has expired (switch: %q); set ZYXEL_NEW_PASSWORD to change it at login": "seadme %s parool on aegunud (kommutaator: %q); sisselogimisel muutmiseks määra ZYXEL_NEW_PASSWORD",
+		"switch rejected the new password for %s (last prompt %q)":                               "kommutaator ei võtnud %s uut parooli vastu (viimane viip %q)",
+		"Password for %s was changed at login as the switch required; update ZYXEL_PASSWORD\n":   "Kommutaator nõudis %s parooli muutmist ja see muudeti; uuenda ZYXEL_PASSWORD\n",
+		"Password for inventory hosts without their own: ":                                       "Parool inventuuri seadmetele, millel oma parooli pole: ",
+		"failed to read password: %w":                                                            "parooli lugemine ebaõnnestus: %w",
+		"login rejected, switch asks again: %q":                                                  "sisselogimine lükati tagasi, kommutaator küsib uuesti: %q",
+		"SSH port (default: 22)":                                                                 "SSH port (vaikimisi: 22)",
+		"Password for 'enable' when login lands at a '>' prompt (default: ZYXEL_PASSWORD)":       "Parool käsule 'enable', kui sisselogimine jõuab '>' viibani (vaikimisi: ZYXEL_PASSWORD)",
+		"Command that disables paging (default: per model; 'none' to skip)":                      "Käsk, mis lülitab lehekülgede kaupa kuvamise välja (vaikimisi: mudelist sõltuv; 'none' jätab vahele)",
+		"Switch model, e.g. XGS2210, or generic (default: detected at login)":                    "Kommutaatori mudel, nt XGS2210, või generic (vaikimisi: tuvastatakse sisselogimisel)",
+		"Regex matching the switch prompt (default: learned from the login prompt)":              "Regulaaravaldis kommutaatori viiba tuvastamiseks (vaikimisi: õpitakse sisselogimisel)",
+		"New password to set when the switch forces a password change at login":                  "Uus parool, kui kommutaator nõuab sisselogimisel parooli muutmist",
+		"Terminal width announced to the switch (default: 200)":                                  "Kommutaatorile teatatav terminali laius (vaikimisi: 200)",
+		"Terminal height announced to the switch (default: 80)":                                  "Kommutaatorile teatatav terminali kõrgus (vaikimisi: 80)",
+		"Rejoin output lines wrapped at this many columns, e.g. 80 (default: off)":               "Ühenda sellel veerul murtud väljundi read, nt 80 (vaikimisi: väljas)",
+		"Port notation: flat, slot or unit-slot (default: from the running-config)":              "Portide märkimisviis: flat, slot või unit-slot (vaikimisi: running-config-ist)",
+		"Keep-alive interval during slow commands, e.g. 15s; 0 turns it off (default: 30s)":      "Ühenduse elushoidmise intervall aeglaste käskude ajal, nt 15s; 0 lülitab välja (vaikimisi: 30s)",
+		"Times to log in again when the switch drops the session; 0 turns it off (default: 3)":   "Mitu korda uuesti sisse logida, kui kommutaator seansi katkestab; 0 lülitab välja (vaikimisi: 3)",
+		"Write a transcript of each session to this file for zyxel replay ({host} expands)":      "Kirjuta iga seansi logi sellesse faili zyxel replay jaoks ({host} laieneb)",
+		"SSH login with password (default) or key":                                               "SSH sisselogimine parooliga (vaikimisi) või võtmega (key)",
+		"SSH private key for ZYXEL_AUTH=key":                                                     "SSH privaatvõti ZYXEL_AUTH=key jaoks",
+		"Named profile from the config file to connect with (also --profile)":                    "Seadistusfaili profiil, millega ühenduda (ka --profile)",
+		"Config file with profiles (default: ~/.config/zyxel/config.yaml)":                       "Profiilidega seadistusfail (vaikimisi: ~/.config/zyxel/config.yaml)",
+		"SNMP version, 2c or 3 (default: 2c)":                                                    "SNMP versioon, 2c või 3 (vaikimisi: 2c)",
+		"SNMPv2c community (default: public)":                                                    "SNMPv2c kogukond (vaikimisi: public)",
+		"SNMPv3 user, with ZYXEL_SNMP_AUTH/_AUTH_PASSWORD and ZYXEL_SNMP_PRIV/_PRIV_PASSWORD":    "SNMPv3 kasutaja, koos ZYXEL_SNMP_AUTH/_AUTH_PASSWORD ja ZYXEL_SNMP_PRIV/_PRIV_PASSWORD",
+		"Directory with your own playbooks (default: playbooks)":                                 "Sinu enda käsiraamatute kataloog (vaikimisi: playbooks)",
+		"Vault server to read credentials from, with ZYXEL_VAULT_PATH and VAULT_TOKEN":           "Vaulti server, kust kasutajaandmed lugeda, koos ZYXEL_VAULT_PATH ja VAULT_TOKEN-iga",
+		"Where history from earlier runs is kept (default: ~/.local/state/zyxel)":                "Varasemate käivituste ajaloo asukoht (vaikimisi: ~/.local/state/zyxel)",
+		"Inventory file for fleet subcommands (default: inventory.yaml)":                         "Inventuurifail mitut kommutaatorit puudutavatele alamkäskudele (vaikimisi: inventory.yaml)",
+		"ASCII-only output without colors or animations":                                         "Ainult ASCII-väljund, ilma värvide ja animatsioonideta",
+		"Language of messages, en or et (default: from LANG)":                                    "Teadete keel, en või et (vaikimisi: LANG järgi)",
+
+		"Zyxel command to execute (repeat for several)":                                        "Käivitatav Zyxeli käsk (mitme jaoks korda)",
+		"Print output exactly as received, without cleanup":                                    "Näita väljundit täpselt nii, nagu see saabus, ilma puhastamata",
+		"Write output to `file` instead of stdout ({host} expands to the switch address)":      "Kirjuta väljund standardväljundi asemel faili `file` ({host} asendatakse kommutaatori aadressiga)",
+		"Append to the -o file instead of overwriting it":                                      "Lisa -o faili lõppu selle ülekirjutamise asemel",
+		"Append LLDP neighbor name and port to port table rows":                                "Lisa portide tabeli ridadele LLDP naabri nimi ja port",
+		"Run the commands in configuration mode":                                               "Käivita käsud seadistusrežiimis",
+		"Write memory at the end of the session":                                               "Salvesta seadistus seansi lõpus (write memory)",
+		"With --configure: command to run afterwards to check the change (repeat for several)": "Koos --configure lipuga: käsk muudatuse kontrollimiseks pärast seda (võib korrata)",
+		"Print the configuration commands instead of sending them":                             "Väljasta seadistuskäsud nende saatmise asemel",
+		"With --configure: write a Markdown runbook of the change to `file` ({host} expands)":  "Koos --configure lipuga: kirjuta muudatuse Markdown-kokkuvõte faili `file` ({host} asendatakse)",
+		"Re-run the commands at this `interval`, highlighting changed lines":                   "Käivita käske uuesti selle intervalliga (`interval`), muutunud read esile tõstetud",
+		"Run commands such as reload or erase without asking":                                  "Käivita käsud nagu reload või erase küsimata",
+		"Output format: text, or json or table to parse the output of known commands":          "Väljundi vorming: text, või json või table tuntud käskude väljundi parsimiseks",
+		"With --format table: do not color the table":                                          "Koos --format table lipuga: ära värvi tabelit",
+		"Print only the output lines matching this `regex`":                                    "Väljasta ainult väljundi read, mis vastavad regulaaravaldisele `regex`",
+		"With --format json or table: keep only these comma-separated `fields`":                "Koos --format json või table lipuga: jäta alles ainult need komaga eraldatud väljad (`fields`)",
+		"With --format json or table: apply this jq `expression` to each parsed output":        "Koos --format json või table lipuga: rakenda seda jq avaldist (`expression`) igale parsitud väljundile",
+		"Run each command as an SSH exec request, without a terminal":                          "Käivita iga käsk eraldi SSH exec päringuna, ilma terminalita",
+		"Do not check the commands against the learned ones":                                   "Ära kontrolli käske õpitud käskude järgi",
+
+		"Output format: text or json":                                    "Väljundi vorming: text või json",
+		"permit or deny":                                                 "permit või deny",
+		"Match frames in this VLAN":                                      "Sobita selle VLAN-i kaadrid",
+		"Match frames received on this switch `port`":                    "Sobita kommutaatori pordil `port` vastu võetud kaadrid",
+		"Match this source MAC address":                                  "Sobita see allika MAC-aadress",
+		"Match this destination MAC address":                             "Sobita see sihtkoha MAC-aadress",
+		"Match this IP protocol: tcp, udp, icmp or a number":             "Sobita see IP-protokoll: tcp, udp, icmp või number",
+		"Match this source IPv4 address or prefix, e.g. 10.0.0.0/24":     "Sobita see allika IPv4-aadress või prefiks, nt 10.0.0.0/24",
+		"Match this destination IPv4 address or prefix":                  "Sobita see sihtkoha IPv4-aadress või prefiks",
+		"Match this TCP or UDP source port":                              "Sobita see TCP või UDP allikaport",
+		"Match this TCP or UDP destination port":                         "Sobita see TCP või UDP sihtport",
+		"Write memory after the change is verified":                      "Salvesta seadistus (write memory), kui muudatus on kontrollitud",
+		"Output format: text or ansible":                                 "Väljundi vorming: text või ansible",
+		"List the inventory (what Ansible asks a dynamic inventory for)": "Näita inventuuri (mida Ansible dünaamiliselt inventuurilt küsib)",
+		"Print the Ansible variables of one `host` only":                 "Näita ainult ühe seadme `host` Ansible muutujaid",
+		"Have the switches upload their configuration to a built-in TFTP server at this `address` or interface": "Lase kommutaatoritel laadida seadistus üles sisseehitatud TFTP-serverisse sellel aadressil või liidesel (`address`)",
+		"Unchanged lines shown around each change":                                                              "Muutmata ridu iga muudatuse ümber",
+		"Search every stored backup, not just the latest of each host":                                          "Otsi kõigist salvestatud varukoopiatest, mitte ainult iga seadme viimasest",
+		"Only search backups of this host":                                                                      "Otsi ainult selle seadme varukoopiatest",
+		"Oxidized or RANCID archive: a git repository or a directory of config files":                           "Oxidizedi või RANCIDi arhiiv: git-hoidla või seadistusfailide kataloog",
+		"Test ports even if they look like uplinks":                                                             "Testi porte ka siis, kui need näevad välja nagu üleslingid",
+		"How long to wait for the test to finish":                                                               "Kui kaua testi lõppu oodata",
+		"Show every place the MAC was seen, not just the last one":                                              "Näita kõiki kohti, kus MAC-aadressi nähti, mitte ainult viimast",
+		"How far back to look in the history":                                                                   "Kui kaugele ajalukku vaadata",
+		"Check every inventory switch instead of one":                                                           "Kontrolli ühe asemel kõiki inventuuri kommutaatoreid",
+		"NTP server every switch should use":                                                                    "NTP-server, mida iga kommutaator peab kasutama",
+		"Time zone every switch should have, e.g. +02:00":                                                       "Ajavöönd, mis igal kommutaatoril peab olema, nt +02:00",
+		"How far a clock may be off; 0 turns the check off":                                                     "Kui palju võib kell nihkes olla; 0 lülitab kontrolli välja",
+		"Set every inventory switch instead of one":                                                             "Seadista ühe asemel kõik inventuuri kommutaatorid",
+		"NTP server `address`":                                                                                  "NTP-serveri aadress (`address`)",
+		"Time zone, e.g. +02:00":                                                                                "Ajavöönd, nt +02:00",
+		"Ask about command lines of up to this many words":                                                      "Küsi kuni nii mitmest sõnast koosnevate käsuridade kohta",
+		"Ask the switch again although its model and firmware are known":                                        "Küsi kommutaatorilt uuesti, kuigi selle mudel ja püsivara on teada",
+		"Print the candidates for the last of the words after --, for the scripts":                              "Väljasta pärast -- viimase sõna variandid, skriptide jaoks",
+		"Login user (default: ZYXEL_USER, or asked)":                                                            "Kasutajanimi (vaikimisi: ZYXEL_USER, või küsitakse)",
+		"Remove the stored credentials instead":                                                                 "Eemalda hoopis salvestatud kasutajaandmed",
+		"Print the manifest as JSON (the only format)":                                                          "Väljasta manifest JSON-ina (ainus vorming)",
+		"Write memory after converging":                                                                         "Salvesta seadistus (write memory) pärast ühtlustamist",
+		"Apply the plan without asking":                                                                         "Rakenda plaan küsimata",
+		"Write a Markdown runbook of the change to `file` ({host} expands)":                                     "Kirjuta muudatuse Markdown-kokkuvõte faili `file` ({host} asendatakse)",
+		"Read the bindings of every inventory switch instead of one":                                            "Loe ühe asemel kõigi inventuuri kommutaatorite sidumised",
+		"Output format: csv or json":                                                                            "Väljundi vorming: csv või json",
+		"Number of addresses to probe at once":                                                                  "Korraga küsitletavate aadresside arv",
+		"How long to wait for each probe":                                                                       "Kui kaua iga päringu vastust oodata",
+		"List every address that answered, not just Zyxel devices":                                              "Näita kõiki vastanud aadresse, mitte ainult Zyxeli seadmeid",
+		"Add the switches found to this inventory `file`":                                                       "Lisa leitud kommutaatorid sellesse inventuurifaili (`file`)",
+		"With --write: tag the added hosts":                                                                     "Koos --write lipuga: märgista lisatud seadmed",
+		"Report access ports without 802.1X as problems":                                                        "Loe probleemiks juurdepääsuportid ilma 802.1X-ta",
+		"CRC errors since the last run that count as a problem (0 is off)":                                      "CRC-vead pärast eelmist käivitust, mida loetakse probleemiks (0 on väljas)",
+		"Collisions since the last run that count as a problem (0 is off)":                                      "Kokkupõrked pärast eelmist käivitust, mida loetakse probleemiks (0 on väljas)",
+		"Dropped packets since the last run that count as a problem (0 is off)":                                 "Kaotatud paketid pärast eelmist käivitust, mida loetakse probleemiks (0 on väljas)",
+		"Post the problems to the inventory's errors webhooks":                                                  "Saada probleemid inventuuri errors-veebihaakidele",
+		"Post the problems to this `url` (repeatable)":                                                          "Saada probleemid sellele aadressile (`url`) (võib korrata)",
+		"Address to serve /metrics on":                                                                          "Aadress, millel /metrics pakkuda",
+		"Where switch data comes from: cli or snmp":                                                             "Kust kommutaatori andmed tulevad: cli või snmp",
+		"Consecutive failures after which a host is no longer polled":                                           "Järjestikuste tõrgete arv, mille järel seadet enam ei küsitleta",
+		"How long a failing host is skipped before it is tried again":                                           "Kui kauaks tõrkuv seade vahele jäetakse, enne kui seda uuesti proovitakse",
+		"Also list uplinks and ports facing other switches":                                                     "Näita ka üleslinke ja teiste kommutaatorite poole suunatud porte",
+		"Firmware image `file` on the TFTP server, or a local file with --tftp-listen":                          "Püsivara tõmmis `file` TFTP-serveris, või kohalik fail koos --tftp-listen lipuga",
+		"TFTP server the switch loads the image from":                                                           "TFTP-server, kust kommutaator tõmmise laadib",
+		"Serve the image from a built-in TFTP server at this `address` or interface instead":                    "Paku tõmmist hoopis sisseehitatud TFTP-serverist sellel aadressil või liidesel (`address`)",
+		"Firmware version expected after the upgrade, e.g. V4.80(ABMH.2); switches already on it are skipped":   "Pärast uuendamist oodatav püsivara versioon, nt V4.80(ABMH.2); kommutaatorid, millel see juba on, jäetakse vahele",
+		"How long a switch may take to come back with its links up":                                             "Kui kaua võib kommutaatoril kuluda, et lingid üleval tagasi tulla",
+		"How long loading the image may take":                                                                   "Kui kaua võib tõmmise laadimine kesta",
+		"Upgrade the inventory switches one at a time, stopping at the first that fails":                        "Uuenda inventuuri kommutaatoreid ükshaaval, peatudes esimese ebaõnnestumise juures",
+		"Upgrade without asking":                                                                                "Uuenda küsimata",
+		"How far back to look for link changes":                                                                 "Kui kaugele ajalukku lingimuutusi otsida",
+		"Link changes (up or down) that make a port flapping":                                                   "Lingimuutused (üles või alla), mille järel port loetakse vilkuvaks",
+		"Count the link messages in this zyxel syslogd `log` instead of the snapshot history (repeatable)":      "Loe lingiteateid hetktõmmiste ajaloo asemel sellest zyxel syslogd logist (`log`) (võib korrata)",
+		"CPU load in percent that warns":                                                                        "Protsessori koormus protsentides, mis hoiatab",
+		"CPU load in percent that fails":                                                                        "Protsessori koormus protsentides, mis nurjub",
+		"Memory use in percent that warns":                                                                      "Mälukasutus protsentides, mis hoiatab",
+		"Memory use in percent that fails":                                                                      "Mälukasutus protsentides, mis nurjub",
+		"Warn when a temperature is this many degrees below the switch's threshold":                             "Hoiata, kui temperatuur on kommutaatori lävest nii mitu kraadi allpool",
+		"Output format: text, nagios or influx":                                                                 "Väljundi vorming: text, nagios või influx",
+		"How far back to look":                                                                                  "Kui kaugele ajalukku vaadata",
+		"Only show groups in this VLAN":                                                                         "Näita ainult selle VLAN-i gruppe",
+		"Inventory `file` listing the switches":                                                                 "Kommutaatoreid loetlev inventuurifail (`file`)",
+		"Only use inventory hosts with this tag":                                                                "Kasuta ainult selle sildiga inventuuri seadmeid",
+		"Number of switches to query at once":                                                                   "Korraga küsitletavate kommutaatorite arv",
+		"Connect to the next switches while the current ones are queried":                                       "Ühendu järgmiste kommutaatoritega, kuni praegusi küsitletakse",
+		"How far back to look for MAC moves":                                                                    "Kui kaugele ajalukku MAC-i liikumisi otsida",
+		"Number of moves within the window that counts as flapping":                                             "Liikumiste arv ajaaknas, mida loetakse vilkumiseks",
+		"Don't add this run to the MAC history":                                                                 "Ära lisa seda käivitust MAC-ajalukku",
+		"How far back to look for moves":                                                                        "Kui kaugele ajalukku liikumisi otsida",
+		"Post the moves to the inventory's mac-move webhooks":                                                   "Saada liikumised inventuuri mac-move-veebihaakidele",
+		"Post the moves to this `url` (repeatable)":                                                             "Saada liikumised sellele aadressile (`url`) (võib korrata)",
+		"Ports whose traffic to mirror":                                                                         "Pordid, mille liiklust peegeldada",
+		"Monitor `port` the capture is plugged into":                                                            "Jälgimisport (`port`), millesse salvesti on ühendatud",
+		"Direction to mirror: ingress, egress or both":                                                          "Peegeldamise suund: ingress, egress või both",
+		"Use a monitor port even if it looks like an uplink":                                                    "Kasuta jälgimisporti ka siis, kui see näeb välja nagu üleslink",
+		"Time between polls":                                                                                    "Aeg küsitluste vahel",
+		"Event types to watch: link, poe and mac-move":                                                          "Jälgitavad sündmuste tüübid: link, poe ja mac-move",
+		"Post every event to this `url` (repeatable; also the inventory's webhooks)":                            "Saada iga sündmus sellele aadressile (`url`) (võib korrata; ka inventuuri veebihaakidele)",
+		"Module temperature in C that warns, if the module has no thresholds":                                   "Mooduli temperatuur C-kraadides, mis hoiatab, kui moodulil endal lävesid pole",
+		"Module temperature in C that fails, if the module has no thresholds":                                   "Mooduli temperatuur C-kraadides, mis nurjub, kui moodulil endal lävesid pole",
+		"Received power in dBm at or below which to warn, if the module has no thresholds":                      "Vastuvõetud võimsus dBm-ides, millest alates hoiatada, kui moodulil endal lävesid pole",
+		"Received power in dBm at or below which to fail, if the module has no thresholds":                      "Vastuvõetud võimsus dBm-ides, millest alates nurjuda, kui moodulil endal lävesid pole",
+		"Output format: text, json or nagios":                                                                   "Väljundi vorming: text, json või nagios",
+		"Set a play variable, `name=value` (repeatable)":                                                        "Määra stsenaariumi muutuja, `name=value` (võib korrata)",
+		"Set a playbook variable, `name=value` (repeatable)":                                                    "Määra käsiraamatu muutuja, `name=value` (võib korrata)",
+		"Change ports even if they look like uplinks":                                                           "Muuda porte ka siis, kui need näevad välja nagu üleslingid",
+		"Write memory afterwards so the port stays off after a reboot":                                          "Salvesta pärast seadistus, et port jääks ka pärast taaskäivitust välja",
+		"Write memory afterwards":                                                                               "Salvesta pärast seadistus",
+		"How long the ports stay unpowered":                                                                     "Kui kauaks pordid vooluta jäävad",
+		"CSV or JSON `file` mapping devices to switch ports":                                                    "CSV- või JSON-fail (`file`), mis seob seadmed kommutaatori portidega",
+		"Time between the two samples":                                                                          "Aeg kahe mõõtmise vahel",
+		"With --format nagios: errors per port and interval that warn":                                          "Koos --format nagios lipuga: vead pordi ja intervalli kohta, mis hoiatavad",
+		"With --format nagios: errors per port and interval that are critical (default: never)":                 "Koos --format nagios lipuga: vead pordi ja intervalli kohta, mis on kriitilised (vaikimisi: mitte kunagi)",
+		"Telnet `address` to listen on":                                                                         "Telneti aadress (`address`), mida kuulata",
+		"Flag switches running firmware older than this `version`, e.g. V4.80(ABMH.2)":                          "Märgi kommutaatorid, mille püsivara on vanem kui see versioon (`version`), nt V4.80(ABMH.2)",
+		"Roll back to the checkpoint taken before the latest change":                                            "Taasta viimase muudatuse eel tehtud kontrollpunkt",
+		"List the checkpoints of the switch":                                                                    "Näita kommutaatori kontrollpunkte",
+		"Write memory after rolling back":                                                                       "Salvesta seadistus (write memory) pärast taastamist",
+		"Roll back without showing the commands and asking first":                                               "Taasta ilma käske näitamata ja eelnevalt küsimata",
+		"SSH `address` to listen on":                                                                            "SSH aadress (`address`), mida kuulata",
+		"Login user to accept":                                                                                  "Vastuvõetav kasutajanimi",
+		"Login password to accept":                                                                              "Vastuvõetav parool",
+		"Number of ports of the simulated switch":                                                               "Simuleeritud kommutaatori portide arv",
+		"Drop each connection at its `n`th command, to test reconnecting":                                       "Katkesta iga ühendus selle `n`-nda käsu juures, et uuesti ühendumist testida",
+		"Switch to poll (default: ZYXEL_HOST)":                                                                  "Küsitletav kommutaator (vaikimisi: ZYXEL_HOST)",
+		"Also report switches without an SNMPv3 user with privacy, or still answering v2c":                      "Anna teada ka kommutaatoritest, millel pole privaatsusega SNMPv3 kasutajat või mis vastavad veel v2c-le",
+		"Configure every inventory switch instead of one":                                                       "Seadista ühe asemel kõik inventuuri kommutaatorid",
+		"Stop answering SNMPv1/v2c requests":                                                                    "Lõpeta SNMPv1/v2c päringutele vastamine",
+		"Broadcast limit in packets/s, or off":                                                                  "Leviedastuse piirang pakettides sekundis, või off",
+		"Multicast limit in packets/s, or off":                                                                  "Multiedastuse piirang pakettides sekundis, või off",
+		"Unknown unicast (DLF) limit in packets/s, or off":                                                      "Tundmatu üksikedastuse (DLF) piirang pakettides sekundis, või off",
+		"Loop guard on or off":                                                                                  "Silmusekaitse (loop guard) on või off",
+		"Protections every access port needs: broadcast, multicast, dlf and loop-guard":                         "Kaitsed, mis igal juurdepääsupordil peavad olema: broadcast, multicast, dlf ja loop-guard",
+		"UDP address to receive syslog on":                                                                      "UDP aadress, millel syslogi vastu võtta",
+		"Append the JSON log to this `file` instead of printing it":                                             "Lisa JSON-logi selle väljastamise asemel faili `file`",
+		"Drop messages from senders not in the inventory":                                                       "Jäta kõrvale teated saatjatelt, keda inventuuris pole",
+		"Write memory after applying":                                                                           "Salvesta seadistus (write memory) pärast rakendamist",
+		"Apply without showing the commands and asking first":                                                   "Rakenda ilma käske näitamata ja eelnevalt küsimata",
+		"Command to run afterwards to check the change (repeat for several)":                                    "Käsk muudatuse kontrollimiseks pärast seda (mitme jaoks korda)",
+		"Output format: dot or json":                                                                            "Väljundi vorming: dot või json",
+		"Include every LLDP neighbor, not just switches and routers":                                            "Kaasa kõik LLDP naabrid, mitte ainult kommutaatorid ja ruuterid",
+		"Utilization percentage above which a port counts as an uplink":                                         "Kasutuse protsent, millest alates porti loetakse üleslingiks",
+		"Privilege level, 0 (read-only) to 14 (full access)":                                                    "Õiguste tase, 0 (ainult lugemine) kuni 14 (täielik juurdepääs)",
+		"Read the account's password from `file` instead of asking":                                             "Loe konto parool küsimise asemel failist `file`",
+		"Read the new password from `file` instead of asking":                                                   "Loe uus parool küsimise asemel failist `file`",
+		"Change the password on the inventory switches one at a time, stopping at the first that fails":         "Muuda parooli inventuuri kommutaatoritel ükshaaval, peatudes esimese ebaõnnestumise juures",
+		"Write memory after the new password is verified":                                                       "Salvesta seadistus (write memory), kui uus parool on kontrollitud",
+		"Change the untagged VLAN of ports even if they look like uplinks":                                      "Muuda portide sildistamata VLAN-i ka siis, kui need näevad välja nagu üleslingid",
+		"VLAN name":                      "VLAN-i nimi",
+		"Ports to add as tagged members": "Sildistatud liikmetena lisatavad pordid",
+		"Ports to add as untagged members, with the VLAN as their PVID": "Sildistamata liikmetena lisatavad pordid, VLAN nende PVID-ks",
+		"Ask every inventory switch instead of one":                     "Küsi ühe asemel kõigilt inventuuri kommutaatoritelt",
+
+		"missing required environment variables: %s":                   "puuduvad kohustuslikud keskkonnamuutujad: %s",
+		"invalid ZYXEL_PROMPT_REGEX: %w":                               "vigane ZYXEL_PROMPT_REGEX: %w",
+		"failed to connect to %s: %w":                                  "ühendus aadressiga %s ebaõnnestus: %w",
+		"enable failed: %w":                                            "enable ebaõnnestus: %w",
+		"enable failed: password rejected (set ZYXEL_ENABLE_PASSWORD)": "enable ebaõnnestus: parool lükati tagasi (määra ZYXEL_ENABLE_PASSWORD)",
+		"enable failed: still at unprivileged prompt %q":               "enable ebaõnnestus: endiselt piiratud õigustega viibas %q",
+		"timeout after %s, last output %q":                             "ooteaeg %s täis, viimane väljund %q",
+		"invalid --query: %w":                                          "vigane --query: %w",
+		"query: %w":                                                    "päring: %w",
+		"%s: connection lost, reconnecting (%d of %d)":                 "%s: ühendus katkes, ühendun uuesti (%d/%d)",
+		"%s: login %d of %d failed: %v":                                "%s: sisselogimine %d/%d ebaõnnestus: %v",
+		"%s: reconnected, running %q again":                            "%s: ühendus taastatud, käivitan %q uuesti",
+		"reconnect failed: %w":                                         "uuesti ühendumine ebaõnnestus: %w",
+		"reconnect failed after %d reconnects: %w":                     "uuesti ühendumine ebaõnnestus pärast %d taasühendumist: %w",
+		"reconnected, but %q failed: %w":                               "ühendus taastatud, kuid %q ebaõnnestus: %w",
+		"no prompt after %s without output, last line %q":              "viipa ei tulnud, %s ilma väljundita, viimane rida %q",
+		"connection closed: %w":                                        "ühendus suleti: %w",
+		"failed to create SSH session: %w":                             "SSH seansi loomine ebaõnnestus: %w",
+		"failed to learn the port notation: %w":                        "portide märkimisviisi tuvastamine ebaõnnestus: %w",
+		"failed to request PTY: %w":                                    "PTY taotlemine ebaõnnestus: %w",
+		"failed to get stdin pipe: %w":                                 "sisendkanali avamine ebaõnnestus: %w",
+		"failed to get stdout pipe: %w":                                "väljundkanali avamine ebaõnnestus: %w",
+		"failed to start shell: %w":                                    "käsukesta käivitamine ebaõnnestus: %w",
+		"timeout waiting for switch prompt":                            "kommutaatori viiba ootamine aegus",
+		"connection closed unexpectedly":                               "ühendus katkes ootamatult",
+		"failed to read inventory: %w":                                 "inventuuri lugemine ebaõnnestus: %w",
+		"failed to resolve %s: %w":                                     "nime %s lahendamine ebaõnnestus: %w",
+		"failed to locate config file: %w":                             "seadistusfaili asukoha leidmine ebaõnnestus: %w",
+		"failed to read config file: %w":                               "seadistusfaili lugemine ebaõnnestus: %w",
+		"failed to parse config file %s: %w":                           "seadistusfaili %s parsimine ebaõnnestus: %w",
+		"no profile %q in %s":                                          "profiili %q pole failis %s",
+		"failed to parse inventory %s: %w":                             "inventuuri %s parsimine ebaõnnestus: %w",
+		"inventory %s: host %d has no address":                         "inventuur %s: hostil %d puudub aadress",
+
+		"Unknown playbook command %q":                                            "Tundmatu käsiraamatu käsk %q",
+		"Unknown audit check %q":                                                 "Tundmatu audit %q",
+		"No hosts in inventory %s match":                                         "Inventuuris %s pole sobivaid hoste",
+		"Failed to read LLDP neighbors: %v":                                      "LLDP naabrite lugemine ebaõnnestus: %v",
+		"Configuration NOT saved: %v":                                            "Seadistust EI salvestatud: %v",
+		"Failed to read running-config: %v":                                      "running-config lugemine ebaõnnestus: %v",
+		"--verify and --runbook need --configure":                                "--verify ja --runbook vajavad --configure lippu",
+		"--dry-run needs --configure":                                            "--dry-run vajab --configure lippu",
+		"--runbook cannot be combined with --dry-run":                            "--runbook ei sobi kokku lipuga --dry-run",
+		"--format must be dot or json, not %q":                                   "--format peab olema dot või json, mitte %q",
+		"--format must be csv or json, not %q":                                   "--format peab olema csv või json, mitte %q",
+		"--format must be text, json or table, not %q":                           "--format peab olema text, json või table, mitte %q",
+		"--grep cannot be combined with --format %s or --watch":                  "--grep ei sobi kokku lippudega --format %s ega --watch",
+		"Invalid --grep pattern: %v":                                             "Vigane --grep muster: %v",
+		"--fields needs --format json or table":                                  "--fields vajab lippu --format json või table",
+		"--query needs --format json or table":                                   "--query vajab lippu --format json või table",
+		"No output has a field %q; there are %s":                                 "Üheski väljundis pole välja %q; on olemas %s",
+		"%s not found on any switch":                                             "%s ei leitud ühestki kommutaatorist",
+		"Invalid IP address %q":                                                  "Vigane IP-aadress %q",
+		"Usage: zyxel whohas <ip> [--fleet]":                                     "Kasutus: zyxel whohas <ip> [--fleet]",
+		"%s is in no ARP table or DHCP snooping binding":                         "%s puudub kõigist ARP-tabelitest ja DHCP snooping sidumistest",
+		"%s only seen on uplinks (%d entries); --all lists them":                 "%s on nähtud ainult ülslülidel (%d kirjet); --all näitab neid",
+		"--interval must be positive":                                            "--interval peab olema positiivne",
+		"--min must be positive":                                                 "--min peab olema positiivne",
+		"No interfaces found for %q":                                             "%q jaoks ei leitud ühtegi liidest",
+		"Usage: zyxel poe %s <ports>":                                            "Kasutus: zyxel poe %s <pordid>",
+		"Unknown mirror command %q":                                              "Tundmatu mirror käsk %q",
+		"Unknown storm command %q":                                               "Tundmatu storm käsk %q",
+		"Unknown acl command %q":                                                 "Tundmatu acl käsk %q",
+		"Unknown users command %q":                                               "Tundmatu users käsk %q",
+		"Unknown time command %q":                                                "Tundmatu time käsk %q",
+		"Usage: zyxel mirror start --src <ports> --dst <port>":                   "Kasutus: zyxel mirror start --src <pordid> --dst <port>",
+		"Usage: zyxel cable-diag <ports>":                                        "Kasutus: zyxel cable-diag <pordid>",
+		"Usage: zyxel play <runbook.yaml> [--var name=value ...]":                "Kasutus: zyxel play <tegevuskava.yaml> [--var nimi=väärtus ...]",
+		"Usage: zyxel script <file.star> [args...]":                              "Kasutus: zyxel script <fail.star> [argumendid...]",
+		"Usage: zyxel completion bash|zsh|fish":                                  "Kasutus: zyxel completion bash|zsh|fish",
+		"Unknown shell %q; use bash, zsh or fish":                                "Tundmatu kest %q; kasuta bashi, zsh-i või fishi",
+		"--depth must be at least 1":                                             "--depth peab olema vähemalt 1",
+		"%d words asked about, %d to go":                                         "%d sõna kohta küsitud, %d veel",
+		"%s: learned %d words of %s %s in %s":                                    "%s: õpitud %d sõna mudelilt %s %s ajaga %s",
+		"%s: using the commands learned from %s %s on %s":                        "%s: kasutan käske, mis õpiti mudelilt %s %s %s",
+		"%q: the switch knows no %q there, only: %s":                             "%q: kommutaator ei tunne seal %q, ainult: %s",
+		"%q is incomplete":                                                       "%q on poolik",
+		"Usage: zyxel replay <transcript.yaml> [--listen address]":               "Kasutus: zyxel replay <logi.yaml> [--listen aadress]",
+		"--no-pty only prints -c output; it takes no other session flags":        "--no-pty ainult prindib -c väljundi; muid seansi võtmeid see ei võta",
+		"--no-pty needs the ssh transport":                                       "--no-pty vajab ssh ühendust",
+		"%q exited with status %d":                                               "%q lõppes koodiga %d",
+		"Interrupted; closing %d session(s)":                                     "Katkestatud; suletakse %d seanssi",
+		"PoE is still OFF on port %s: %v":                                        "PoE on pordil %s endiselt VÄLJAS: %v",
+		"Unknown history %q; use interfaces, macs, system or uplinks":            "Tundmatu ajalugu %q; kasuta interfaces, macs, system või uplinks",
+		"No %s snapshots of %s in the last %s":                                   "Viimase %[3]s jooksul pole %[2]s kohta %[1]s hetktõmmiseid",
+		"failed to open history %s: %w":                                          "ajaloo %s avamine ebaõnnestus: %w",
+		"failed to save snapshot: %w":                                            "hetktõmmise salvestamine ebaõnnestus: %w",
+		"Unknown event type %q; use link, poe or mac-move":                       "Tundmatu sündmuse tüüp %q; kasuta link, poe või mac-move",
+		"Unknown webhook event %q":                                               "Tundmatu veebihaagi sündmus %q",
+		"failed to write to InfluxDB: %w":                                        "InfluxDB-sse kirjutamine ebaõnnestus: %w",
+		"InfluxDB rejected the write: %s: %s":                                    "InfluxDB lükkas kirjutamise tagasi: %s: %s",
+		"--format must be text, nagios or influx, not %q":                        "--format peab olema text, nagios või influx, mitte %q",
+		"--format must be text, json or nagios, not %q":                          "--format peab olema text, json või nagios, mitte %q",
+		"not upgraded":                                                           "ei uuendatud",
+		"stdin is not a terminal; pass --yes to upgrade":                         "sisend pole terminal; uuendamiseks lisa --yes",
+		"%s: %v; stopping, %d switches not upgraded":                             "%s: %v; lõpetan, %d kommutaatorit jäi uuendamata",
+		"Usage: zyxel vlan %s":                                                   "Kasutus: zyxel vlan %s",
+		"Usage: zyxel vlan add-port <id> --tagged <ports> | --untagged <ports>":  "Kasutus: zyxel vlan add-port <id> --tagged <pordid> | --untagged <pordid>",
+		"Usage: zyxel vlan remove-port <id> <ports>":                             "Kasutus: zyxel vlan remove-port <id> <pordid>",
+		"invalid VLAN ID %q (want 1-4094)":                                       "vigane VLAN-i ID %q (lubatud 1-4094)",
+		"VLAN %d already exists":                                                 "VLAN %d on juba olemas",
+		"VLAN %d does not exist":                                                 "VLAN-i %d pole olemas",
+		"VLAN %d does not exist; create it with zyxel vlan create %d":            "VLAN-i %d pole olemas; loo see käsuga zyxel vlan create %d",
+		"VLAN 1 is the default VLAN and cannot be deleted":                       "VLAN 1 on vaikimisi VLAN ja seda ei saa kustutada",
+		"Usage: zyxel port enable <ports>":                                       "Kasutus: zyxel port enable <pordid>",
+		"Usage: zyxel discover <subnet>":                                         "Kasutus: zyxel discover <alamvõrk>",
+		"Usage: zyxel %s <template> <vars.yaml>":                                 "Kasutus: zyxel %s <mall> <muutujad.yaml>",
+		"Usage: zyxel %s <state.yaml>":                                           "Kasutus: zyxel %s <olek.yaml>",
+		"Usage: zyxel rollback --last | <checkpoint>":                            "Kasutus: zyxel rollback --last | <kontrollpunkt>",
+		"No checkpoints of %s; one is taken before each change":                  "Seadmel %s pole kontrollpunkte; need tehakse enne iga muudatust",
+		"the template rendered no commands":                                      "mall ei andnud ühtegi käsku",
+		"not applied":                                                            "ei rakendatud",
+		"not run":                                                                "ei käivitatud",
+		"refusing to run %s without --yes":                                       "keeldun käivitamast %s ilma liputa --yes",
+		"These commands can disrupt the switch:":                                 "Need käsud võivad kommutaatori töö häirida:",
+		"reboots the switch":                                                     "taaskäivitab kommutaatori",
+		"deletes files or configuration":                                         "kustutab faile või seadistuse",
+		"changes what the switch starts with":                                    "muudab, millega kommutaator käivitub",
+		"changes the management address":                                         "muudab halduse aadressi",
+		"changes the management VLAN":                                            "muudab halduse VLAN-i",
+		"Usage: zyxel port disable <ports>":                                      "Kasutus: zyxel port disable <pordid>",
+		"Usage: zyxel port describe <ports> \"<text>\"":                          "Kasutus: zyxel port describe <pordid> \"<tekst>\"",
+		"--watch cannot be combined with --configure, --save or -o":              "--watch ei sobi kokku lippudega --configure, --save ega -o",
+		"MAC %s not seen in the last %s (run 'zyxel collect' to record history)": "MAC-aadressi %s pole viimase %s jooksul nähtud (ajaloo kogumiseks käivita 'zyxel collect')",
+
+		"the configuration commands of the %s are not known; name %s under commands in its profile": "mudeli %s seadistuskäsud pole teada; nimeta %s profiili all commands",
+
+		"failed to read running-config for the checkpoint: %w":                "running-config lugemine kontrollpunkti jaoks ebaõnnestus: %w",
+		"failed to take a checkpoint: empty running-config":                   "kontrollpunkti tegemine ebaõnnestus: running-config on tühi",
+		"failed to take a checkpoint: %w":                                     "kontrollpunkti tegemine ebaõnnestus: %w",
+		"Nothing to roll back; the running-config matches checkpoint %s":      "Pole midagi taastada; running-config vastab kontrollpunktile %s",
+		"The running-config still differs from checkpoint %s:":                "running-config erineb endiselt kontrollpunktist %s:",
+		"Rolled back to checkpoint %s":                                        "Taastatud kontrollpunkt %s",
+		"enabled":                                                             "lubatud",
+		"disabled":                                                            "keelatud",
+		"description set":                                                     "kirjeldus määratud",
+		"storm control and loop guard set":                                    "tormikontroll ja silmusekaitse määratud",
+		"port %s is still inactive in the running-config":                     "port %s on running-config-is endiselt mitteaktiivne",
+		"port %s is not inactive in the running-config":                       "port %s pole running-config-is mitteaktiivne",
+		"port %s is named %q in the running-config, not %q":                   "pordi %s nimi on running-config-is %q, mitte %q",
+		"failed to detect uplinks: %w":                                        "üleslinkide tuvastamine ebaõnnestus: %w",
+		"refusing to change uplink ports: %s; use --allow-uplink to override": "keeldun üleslinkide porte muutmast: %s; tühistamiseks kasuta --allow-uplink",
+		"No uplinks detected":                                                 "Üleslinke ei leitud",
+		"failed to locate state directory: %w":                                "olekukausta asukoha leidmine ebaõnnestus: %w",
+		"failed to create state directory: %w":                                "olekukausta loomine ebaõnnestus: %w",
+		"failed to open %s: %w":                                               "faili %s avamine ebaõnnestus: %w",
+		"failed to write %s: %w":                                              "faili %s kirjutamine ebaõnnestus: %w",
+		"failed to read %s: %w":                                               "faili %s lugemine ebaõnnestus: %w",
+		"failed to enter configuration mode (prompt is %q)":                   "seadistusrežiimi sisenemine ebaõnnestus (viip on %q)",
+		"failed to leave configuration mode (prompt is %q)":                   "seadistusrežiimist väljumine ebaõnnestus (viip on %q)",
+		"%w; %d of %d commands were applied, not from %q on":                  "%w; rakendati %d käsku %d-st, alates käsust %q mitte",
+		"switch rejected %q: %s":                                              "kommutaator lükkas %q tagasi: %s",
+		"write memory failed: %s":                                             "write memory ebaõnnestus: %s",
+		"write memory: the switch did not confirm the save (output %q); check the startup-config": "write memory: kommutaator ei kinnitanud salvestamist (väljund %q); kontrolli startup-config-i",
+		"unknown port dialect %q (want flat, slot or unit-slot)":                                  "tundmatu portide märkimisviis %q (oodati flat, slot või unit-slot)",
+		"invalid port %q":                                                "vigane port %q",
+		"empty port list":                                                "tühi portide loend",
+		"range %q spans units or slots":                                  "vahemik %q ulatub üle mitme üksuse või pesa",
+		"range %q is reversed":                                           "vahemik %q on tagurpidi",
+		"port %s cannot be written in flat notation":                     "porti %s ei saa kirjutada lihtsas märkimisviisis",
+		"port %s cannot be written in slot notation":                     "porti %s ei saa kirjutada pesaga märkimisviisis",
+		"%s, %s: give exactly one of run, configure and zyxel":           "%s, %s: anna täpselt üks neist: run, configure ja zyxel",
+		"%s, %s: on_failure must be abort, continue or rollback, not %q": "%s, %s: on_failure peab olema abort, continue või rollback, mitte %q",
+		"no inventory host matches %q":                                   "ükski inventuuri host ei vasta mustrile %q",
+		"failed to read running-config: %w":                              "running-config lugemine ebaõnnestus: %w",
+		"configuration NOT saved: %w":                                    "seadistust EI salvestatud: %w",
+		"output does not match %q":                                       "väljund ei vasta mustrile %q",
+		"output matches %q: %q":                                          "väljund vastab mustrile %q: %q",
+		"--var wants name=value, got %q":                                 "--var ootab kuju nimi=väärtus, sai %q",
+		"play %s needs --var for: %s":                                    "play %s vajab --var väärtust: %s",
+		"%s, %s: no hosts":                                               "%s, %s: hoste pole",
+		"%s %s: ok":                                                      "%s %s: korras",
+		"Nothing to roll back":                                           "Pole midagi taastada",
+		"play %s failed and could not be fully rolled back":              "play %s ebaõnnestus ja seda ei õnnestunud täielikult tagasi võtta",
+		"play %s stopped at %s":                                          "play %s peatus sammul %s",
+	},
+}
+
+// tr returns the translation of msg in the current language.
+func tr(msg string) string {
+	if t, ok := translations[lang][msg]; ok {
+		return t
+	}
+	return msg
+}
+
+// errorf is fmt.Errorf with a translated format.
+func errorf(format string, args ...any) error {
+	return fmt.Errorf(tr(format), args...)
+}
+
+// setupLanguage picks the language from --lang, or from LC_ALL,
+// LC_MESSAGES or LANG, and returns args with any --lang flag removed. It
+// runs before flag parsing so flag help can be translated too.
+func setupLanguage(args []string) []string {
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := os.Getenv(env); v != "" {
+			lang = normalizeLang(v)
+			break
+		}
+	}
+
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "--lang" || a == "-lang":
+			if i+1 < len(args) {
+				lang = normalizeLang(args[i+1])
+				i++
+			}
+		case strings.HasPrefix(a, "--lang=") || strings.HasPrefix(a, "-lang="):
+			_, v, _ := strings.Cut(a, "=")
+			lang = normalizeLang(v)
+		default:
+			rest = append(rest, a)
+		}
+	}
+	return rest
+}
+
+// normalizeLang turns a locale such as "et_EE.UTF-8" into "et". Unsupported
+// languages fall back to English.
+func normalizeLang(locale string) string {
+	l := strings.ToLower(locale)
+	if i := strings.IndexAny(l, "_.-@"); i >= 0 {
+		l = l[:i]
+	}
+	if _, ok := translations[l]; ok {
+		return l
+	}
+	return "en"
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+)
+
+// IGMPGroup is a multicast group joined in a VLAN and the ports with
+// listeners for it.
+type IGMPGroup struct {
+	VLAN  int      `json:"vlan"`
+	Group string   `json:"group"`
+	Ports []string `json:"ports"`
+}
+
+// parseIGMPGroups parses "show igmp-snooping group all". Firmware lists a
+// row per group and port, or per group with the ports as a list such as
+// "1-4,7"; rows of one group in one VLAN are merged.
+func parseIGMPGroups(output string) []IGMPGroup {
+	var rows []map[string]string
+	for _, first := range []string{"VLAN", "VID", "Index", "Port"} {
+		if rows = parseTable(output, first); rows != nil {
+			break
+		}
+	}
+
+	byKey := make(map[string]*IGMPGroup)
+	var groups []*IGMPGroup
+	for _, row := range rows {
+		group := ""
+		for name, v := range row {
+			if ip := net.ParseIP(v); ip != nil && ip.IsMulticast() && strings.Contains(strings.ToLower(name), "group") {
+				group = ip.String()
+			}
+		}
+		if group == "" {
+			continue
+		}
+		vlan := atoiOr(firstOf(row, "VLAN", "VID", "VLAN ID"), 0)
+		key := fmt.Sprintf("%d/%s", vlan, group)
+		g := byKey[key]
+		if g == nil {
+			g = &IGMPGroup{VLAN: vlan, Group: group, Ports: []string{}}
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+		list := firstOf(row, "Port", "Ports", "Member Ports", "Port List")
+		ports, err := ParsePortList(list)
+		if err != nil {
+			if list != "" {
+				g.Ports = append(g.Ports, list)
+			}
+			continue
+		}
+		for _, p := range ports {
+			g.Ports = append(g.Ports, p.String())
+		}
+	}
+
+	result := make([]IGMPGroup, len(groups))
+	for i, g := range groups {
+		result[i] = *g
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].VLAN != result[j].VLAN {
+			return result[i].VLAN < result[j].VLAN
+		}
+		return result[i].Group < result[j].Group
+	})
+	return result
+}
+
+func igmpGroups(s *Session) ([]IGMPGroup, error) {
+	out, err := s.Output(s.command("igmp-groups"))
+	if err != nil {
+		return nil, err
+	}
+	if looksLikeError(out) {
+		return nil, fmt.Errorf("show igmp-snooping group all: %s", strings.TrimSpace(out))
+	}
+	return parseIGMPGroups(out), nil
+}
+
+func runIGMP(fs *flag.FlagSet) func() {
+	cf := addConnFlags(fs)
+	format := fs.String("format", "text", tr("Output format: text or json"))
+	vlan := fs.Int("vlan", 0, tr("Only show groups in this VLAN"))
+	return func() {
+		if *format != "text" && *format != "json" {
+			fatal("--format must be text or json, not %q", *format)
+		}
+		_, s := cf.connect()
+		defer s.Close()
+		groups, err := igmpGroups(s)
+		if err != nil {
+			fatal("%v", err)
+		}
+		shown := []IGMPGroup{}
+		for _, g := range groups {
+			if *vlan == 0 || g.VLAN == *vlan {
+				shown = append(shown, g)
+			}
+		}
+
+		if *format == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(shown); err != nil {
+				fatal("%v", err)
+			}
+			return
+		}
+		fmt.Printf("%-6s %-16s %s\n", "VLAN", "Group", "Ports")
+		for _, g := range shown {
+			fmt.Printf("%-6d %-16s %s\n", g.VLAN, g.Group, strings.Join(g.Ports, ","))
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// influxPoint is one line of InfluxDB line protocol. Fields hold float64,
+// int64, uint64, bool or string values; uint64 ones are written as
+// integers, capped at the int64 maximum.
+type influxPoint struct {
+	Measurement string
+	Tags        [][2]string
+	Fields      map[string]any
+	Time        time.Time
+}
+
+var influxEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+
+// influxStringEscaper escapes a string field value, which line protocol
+// takes as is between double quotes but for those and backslashes.
+var influxStringEscaper = strings.NewReplacer(`"`, `\"`, `\`, `\\`)
+
+// String formats p as a line: measurement,tag=v field=v timestamp, with
+// fields sorted by key and the timestamp in nanoseconds.
+func (p influxPoint) String() string {
+	var b strings.Builder
+	b.WriteString(strings.NewReplacer(",", `\,`, " ", `\ `).Replace(p.Measurement))
+	for _, t := range p.Tags {
+		if t[1] != "" {
+			fmt.Fprintf(&b, ",%s=%s", influxEscaper.Replace(t[0]), influxEscaper.Replace(t[1]))
+		}
+	}
+	for i, k := range sortedStringKeys(p.Fields) {
+		sep := ","
+		if i == 0 {
+			sep = " "
+		}
+		var v string
+		switch x := p.Fields[k].(type) {
+		case float64:
+			v = strconv.FormatFloat(x, 'f', -1, 64)
+		case int64:
+			v = strconv.FormatInt(x, 10) + "i"
+		case uint64:
+			// As a signed integer, since InfluxDB 1.x takes the "u"
+			// suffix only with unsigned support turned on.
+			v = strconv.FormatInt(int64(min(x, math.MaxInt64)), 10) + "i"
+		case bool:
+			v = strconv.FormatBool(x)
+		default:
+			v = `"` + influxStringEscaper.Replace(fmt.Sprint(x)) + `"`
+		}
+		b.WriteString(sep + influxEscaper.Replace(k) + "=" + v)
+	}
+	fmt.Fprintf(&b, " %d", p.Time.UnixNano())
+	return b.String()
+}
+
+var nonWord = regexp.MustCompile(`[^a-z0-9]+`)
+
+// influxKey turns a counter name such as "RX Packet/Unicast" into a field
+// key, "rx_packet_unicast".
+func influxKey(name string) string {
+	return strings.Trim(nonWord.ReplaceAllString(strings.ToLower(name), "_"), "_")
+}
+
+// writeInflux prints points, or with ZYXEL_INFLUX_URL set writes them to
+// that URL, the write endpoint of the database including its parameters.
+// ZYXEL_INFLUX_TOKEN, if set, is sent as the API token.
+func writeInflux(points []influxPoint) error {
+	var body bytes.Buffer
+	for _, p := range points {
+		body.WriteString(p.String() + "\n")
+	}
+	url := os.Getenv("ZYXEL_INFLUX_URL")
+	if url == "" {
+		_, err := os.Stdout.Write(body.Bytes())
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if token := os.Getenv("ZYXEL_INFLUX_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Token "+token)
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return errorf("failed to write to InfluxDB: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return errorf("InfluxDB rejected the write: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d points to InfluxDB\n", len(points))
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestInfluxPointString(t *testing.T) {
+	tests := []struct {
+		name  string
+		point influxPoint
+		want  string
+	}{
+		{
+			name: "counters",
+			point: influxPoint{
+				Measurement: "zyxel_interface",
+				Tags:        [][2]string{{"host", "sw1"}, {"port", "1/1/5"}},
+				Fields: map[string]any{
+					"errors":    uint64(3),
+					"rx_kbps":   10240.5,
+					"rx_octets": uint64(math.MaxUint64),
+					"status":    int64(-1),
+					"up":        true,
+				},
+			},
+			want: `zyxel_interface,host=sw1,port=1/1/5 errors=3i,rx_kbps=10240.5,rx_octets=9223372036854775807i,status=-1i,up=true 1700000000000000000`,
+		},
+		{
+			// Only quotes and backslashes are escaped in a string field,
+			// the rest of the UTF-8 goes as is.
+			name: "non-ASCII port description",
+			point: influxPoint{
+				Measurement: "zyxel interface",
+				Tags:        [][2]string{{"host", "sw 1"}, {"port", "5"}, {"vlan", ""}},
+				Fields: map[string]any{
+					"description": `Kontor – 2. korrus "Põhja" \ AP`,
+				},
+			},
+			want: `zyxel\ interface,host=sw\ 1,port=5 description="Kontor – 2. korrus \"Põhja\" \\ AP" 1700000000000000000`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.point.Time = time.Unix(1700000000, 0)
+			if got := tt.point.String(); got != tt.want {
+				t.Errorf("String() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
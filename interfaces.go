@@ -0,0 +1,119 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Interface is one port block of "show interfaces".
+type Interface struct {
+	Port   string  `json:"port"`
+	Link   string  `json:"link"`
+	Status string  `json:"status"`
+	LACP   string  `json:"lacp"`
+	TxKBps float64 `json:"tx_kbps"`
+	RxKBps float64 `json:"rx_kbps"`
+	UpTime string  `json:"uptime"`
+	// Counters holds every numeric field keyed by section and name,
+	// e.g. "TX Packet/Unicast" or "Port Info/Errors".
+	Counters map[string]uint64 `json:"counters"`
+}
+
+// LinkUp reports whether the port has link.
+func (i Interface) LinkUp() bool {
+	l := strings.ToLower(i.Link)
+	return l != "" && l != "down"
+}
+
+var linkSpeed = regexp.MustCompile(`^(\d+)([MG])`)
+
+// SpeedKBps returns the link speed in kilobytes per second, or 0 when the
+// port is down or the speed is unknown.
+func (i Interface) SpeedKBps() float64 {
+	m := linkSpeed.FindStringSubmatch(i.Link)
+	if m == nil {
+		return 0
+	}
+	n, _ := strconv.ParseFloat(m[1], 64)
+	if m[2] == "G" {
+		n *= 1000
+	}
+	return n * 1000 / 8
+}
+
+// Utilization returns the busier direction as a fraction of link speed.
+func (i Interface) Utilization() float64 {
+	speed := i.SpeedKBps()
+	if speed == 0 {
+		return 0
+	}
+	return max(i.TxKBps, i.RxKBps) / speed
+}
+
+var sectionSplit = regexp.MustCompile(`^\s*(\S.*?)\s{2,}(\S.*)$`)
+
+// parseInterfaces parses "show interfaces" output. Each line is
+// "[Section]   Key   :value"; a section label carries over to the lines
+// below it until the next one.
+func parseInterfaces(output string) []Interface {
+	var ifaces []Interface
+	var cur *Interface
+	section := ""
+
+	for _, line := range strings.Split(strings.ReplaceAll(output, "\r", ""), "\n") {
+		left, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		key := strings.TrimSpace(left)
+		if m := sectionSplit.FindStringSubmatch(left); m != nil {
+			section, key = m[1], strings.TrimSpace(m[2])
+		}
+
+		if key == "Port NO." {
+			ifaces = append(ifaces, Interface{Port: value, Counters: map[string]uint64{}})
+			cur = &ifaces[len(ifaces)-1]
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+
+		switch key {
+		case "Link":
+			cur.Link = value
+		case "Status":
+			cur.Status = value
+		case "LACP":
+			cur.LACP = value
+		case "Up Time":
+			cur.UpTime = value
+		case "Tx KBs/s":
+			cur.TxKBps, _ = strconv.ParseFloat(value, 64)
+		case "Rx KBs/s":
+			cur.RxKBps, _ = strconv.ParseFloat(value, 64)
+		default:
+			if n, err := strconv.ParseUint(value, 10, 64); err == nil {
+				cur.Counters[section+"/"+key] = n
+			}
+		}
+	}
+	return ifaces
+}
+
+// interfaces collects "show interfaces" for the given port list, or for all
+// ports when ports is "*".
+func interfaces(s *Session, ports string) ([]Interface, error) {
+	out, err := s.Output(s.command("interfaces") + " " + ports)
+	if err != nil {
+		return nil, err
+	}
+	ifaces := parseInterfaces(out)
+	if len(ifaces) == 0 && strings.TrimSpace(out) != "" {
+		toolMetrics.parseFailures.Add(1)
+	}
+	return ifaces, nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// openSessions are the sessions not yet closed, so an interrupt can log
+// them out instead of leaving them to time out on the switch, where they
+// count against its session limit.
+var openSessions struct {
+	mu  sync.Mutex
+	set map[*Session]bool
+	// outputs are the -o files, synced before exiting.
+	outputs []*os.File
+	// flushers hold output back until its line is complete, and write
+	// it out before exiting.
+	flushers []flusher
+}
+
+// flusher is a writer that holds back an incomplete line, such as the
+// --grep filter and the output of the running command.
+type flusher interface {
+	flush()
+}
+
+func trackSession(s *Session) {
+	openSessions.mu.Lock()
+	defer openSessions.mu.Unlock()
+	if openSessions.set == nil {
+		openSessions.set = make(map[*Session]bool)
+	}
+	openSessions.set[s] = true
+}
+
+func untrackSession(s *Session) {
+	openSessions.mu.Lock()
+	defer openSessions.mu.Unlock()
+	delete(openSessions.set, s)
+}
+
+func trackOutput(f *os.File) {
+	openSessions.mu.Lock()
+	defer openSessions.mu.Unlock()
+	openSessions.outputs = append(openSessions.outputs, f)
+}
+
+func trackFlusher(f flusher) {
+	openSessions.mu.Lock()
+	defer openSessions.mu.Unlock()
+	openSessions.flushers = append(openSessions.flushers, f)
+}
+
+func untrackFlusher(f flusher) {
+	openSessions.mu.Lock()
+	defer openSessions.mu.Unlock()
+	openSessions.flushers = slices.DeleteFunc(openSessions.flushers, func(g flusher) bool { return g == f })
+}
+
+// interruptGrace is how long the sessions get to log out after Ctrl+C.
+const interruptGrace = 3 * time.Second
+
+// handleInterrupts makes Ctrl+C and SIGTERM write out the lines held back
+// by the flushers, close the open sessions with "exit", write their
+// transcripts and sync the -o files before exiting with 130. A second
+// Ctrl+C exits at once.
+func handleInterrupts() {
+	sig := make(chan os.Signal, 2)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		go func() {
+			<-sig
+			os.Exit(130)
+		}()
+
+		openSessions.mu.Lock()
+		sessions := make([]*Session, 0, len(openSessions.set))
+		for s := range openSessions.set {
+			sessions = append(sessions, s)
+		}
+		outputs := openSessions.outputs
+		flushers := slices.Clone(openSessions.flushers)
+		openSessions.mu.Unlock()
+
+		// The last tracked first, as a command's output goes through
+		// the --grep filter.
+		for _, f := range slices.Backward(flushers) {
+			f.flush()
+		}
+
+		if len(sessions) > 0 {
+			fmt.Fprintf(os.Stderr, "\n"+tr("Interrupted; closing %d session(s)")+"\n", len(sessions))
+		}
+		var wg sync.WaitGroup
+		for _, s := range sessions {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.interrupt()
+			}()
+		}
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(interruptGrace):
+		}
+		for _, f := range outputs {
+			f.Sync()
+		}
+		os.Exit(130)
+	}()
+}
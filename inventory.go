@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+const defaultInventory = "inventory.yaml"
+
+// Host is one switch in the inventory. Empty connection fields fall back
+// to the ZYXEL_* environment variables.
+type Host struct {
+	Name     string `yaml:"name"`
+	Address  string `yaml:"host"`
+	Port     string `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	// Transport is "ssh", "telnet", "http" or "https"; empty uses
+	// ZYXEL_TRANSPORT.
+	Transport string `yaml:"transport"`
+	// Profile names a profile from the config file whose settings apply
+	// before the ones above.
+	Profile string   `yaml:"profile"`
+	Tags    []string `yaml:"tags"`
+	// Uplinks is a port list of known uplinks on this switch.
+	Uplinks string    `yaml:"uplinks"`
+	STP     STPIntent `yaml:"stp"`
+}
+
+// Inventory is the list of managed switches plus fleet-wide intent.
+type Inventory struct {
+	Hosts []Host    `yaml:"hosts"`
+	STP   STPIntent `yaml:"stp"`
+	// VLANGateways maps a VLAN ID to the host that routes it.
+	VLANGateways map[int]string `yaml:"vlan_gateways"`
+	// Webhooks receive the events of "zyxel monitor".
+	Webhooks []Webhook `yaml:"webhooks"`
+}
+
+func loadInventory(path string) (*Inventory, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errorf("failed to read inventory: %w", err)
+	}
+
+	var inv Inventory
+	if err := yaml.Unmarshal(data, &inv); err != nil {
+		return nil, errorf("failed to parse inventory %s: %w", path, err)
+	}
+	for i, h := range inv.Hosts {
+		if h.Address == "" {
+			return nil, errorf("inventory %s: host %d has no address", path, i+1)
+		}
+		if h.Name == "" {
+			inv.Hosts[i].Name = h.Address
+		}
+	}
+	return &inv, nil
+}
+
+// host looks up a host by name.
+func (inv *Inventory) host(name string) (Host, bool) {
+	for _, h := range inv.Hosts {
+		if h.Name == name {
+			return h, true
+		}
+	}
+	return Host{}, false
+}
+
+// byNeighbor finds the inventory host an LLDP neighbor refers to, matching
+// on management address or system name.
+func (inv *Inventory) byNeighbor(n LLDPNeighbor) (Host, bool) {
+	for _, h := range inv.Hosts {
+		if n.MgmtAddress != "" && n.MgmtAddress == h.Address {
+			return h, true
+		}
+		if n.SystemName != "" && (strings.EqualFold(n.SystemName, h.Name) || n.SystemName == h.Address) {
+			return h, true
+		}
+	}
+	return Host{}, false
+}
+
+// config returns the connection settings for h on top of base.
+func (h Host) config(base Config) (Config, error) {
+	cfg := base
+	if h.Profile != "" {
+		p, err := loadProfile(h.Profile)
+		if err != nil {
+			return cfg, err
+		}
+		if err := p.apply(&cfg); err != nil {
+			return cfg, err
+		}
+	}
+	cfg.Host = h.Address
+	if h.Port != "" {
+		cfg.Port = h.Port
+	}
+	if h.User != "" {
+		cfg.User = h.User
+	}
+	if h.Password != "" {
+		cfg.Password = h.Password
+	}
+	if h.Transport != "" {
+		cfg.Transport = h.Transport
+	}
+	return cfg, nil
+}
+
+// uplinkPorts returns the ports listed as uplinks of h.
+func (h Host) uplinkPorts() []Port {
+	if h.Uplinks == "" {
+		return nil
+	}
+	ports, err := ParsePortList(h.Uplinks)
+	if err != nil {
+		return nil
+	}
+	return ports
+}
+
+// fleetFlags are the flags shared by subcommands that run on the inventory.
+type fleetFlags struct {
+	inventory string
+	tag       string
+	parallel  int
+	prewarm   bool
+}
+
+func addFleetFlags(fs *flag.FlagSet) *fleetFlags {
+	ff := &fleetFlags{}
+	inventory := os.Getenv("ZYXEL_INVENTORY")
+	if inventory == "" {
+		inventory = defaultInventory
+	}
+	fs.StringVar(&ff.inventory, "inventory", inventory, tr("Inventory `file` listing the switches"))
+	fs.StringVar(&ff.tag, "tag", "", tr("Only use inventory hosts with this tag"))
+	fs.IntVar(&ff.parallel, "parallel", 4, tr("Number of switches to query at once"))
+	fs.BoolVar(&ff.prewarm, "prewarm", false, tr("Connect to the next switches while the current ones are queried"))
+	return ff
+}
+
+// load reads the inventory and returns it with the hosts selected by --tag.
+func (ff *fleetFlags) load() (*Inventory, []Host) {
+	inv, err := loadInventory(ff.inventory)
+	if err != nil {
+		fatal("%v", err)
+	}
+
+	var hosts []Host
+	for _, h := range inv.Hosts {
+		if ff.tag == "" || slices.Contains(h.Tags, ff.tag) {
+			hosts = append(hosts, h)
+		}
+	}
+	if len(hosts) == 0 {
+		fatal("No hosts in inventory %s match", ff.inventory)
+	}
+	return inv, hosts
+}
+
+// resolveHosts looks up the host names of cfgs, parallel at a time, so a
+// slow resolver does not hold up the connection slots. A failed lookup is
+// recorded in errs.
+func resolveHosts(cfgs []Config, errs []error, parallel int) {
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i := range cfgs {
+		if errs[i] != nil || cfgs[i].Host == "" || net.ParseIP(cfgs[i].Host) != nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			ctx, cancel := context.WithTimeout(context.Background(), cfgs[i].connectTimeout())
+			defer cancel()
+			addrs, err := net.DefaultResolver.LookupHost(ctx, cfgs[i].Host)
+			if err != nil {
+				errs[i] = errorf("failed to resolve %s: %w", cfgs[i].Host, err)
+				return
+			}
+			cfgs[i].resolvedIP = addrs[0]
+		}()
+	}
+	wg.Wait()
+}
+
+// fleetResult is the outcome of running a function on one host.
+type fleetResult[T any] struct {
+	Host  Host
+	Value T
+	Err   error
+}
+
+// failAll returns err as the result of every host.
+func failAll[T any](hosts []Host, err error) []fleetResult[T] {
+	results := make([]fleetResult[T], len(hosts))
+	for i, h := range hosts {
+		results[i] = fleetResult[T]{Host: h, Err: err}
+	}
+	return results
+}
+
+// hostConfigs returns the connection settings of hosts, with the error
+// of any host whose settings could not be completed. Hosts without a
+// password share one that is asked for once; failing to read it is err.
+func hostConfigs(hosts []Host) (cfgs []Config, errs []error, err error) {
+	base := envConfig()
+	cfgs = make([]Config, len(hosts))
+	errs = make([]error, len(hosts))
+	for i, h := range hosts {
+		cfgs[i], errs[i] = h.config(base)
+		if errs[i] == nil {
+			errs[i] = vaultLookup(&cfgs[i])
+		}
+		keychainLookup(&cfgs[i])
+	}
+	if slices.ContainsFunc(cfgs, func(c Config) bool { return c.Password == "" && c.needsPassword() }) {
+		pw, err := readPassword(tr("Password for inventory hosts without their own: "))
+		if err != nil {
+			return nil, nil, err
+		}
+		for i := range cfgs {
+			if cfgs[i].Password == "" && cfgs[i].needsPassword() {
+				cfgs[i].Password = pw
+			}
+		}
+	}
+	return cfgs, errs, nil
+}
+
+// runFleet connects to every host, at most --parallel at a time, and calls
+// fn with an open session. Results are returned in host order. Host names
+// are resolved up front; with --prewarm up to --parallel more sessions are
+// opened in the background so their login overlaps the work on others.
+func runFleet[T any](hosts []Host, ff *fleetFlags, fn func(h Host, s *Session) (T, error)) []fleetResult[T] {
+	return runFleetContext(context.Background(), hosts, ff, fn)
+}
+
+// runFleetContext is runFleet with a context. When ctx is done, hosts not
+// yet connected fail with ctx.Err() and open sessions are shut down, which
+// ends whatever fn is waiting for on them.
+func runFleetContext[T any](ctx context.Context, hosts []Host, ff *fleetFlags, fn func(h Host, s *Session) (T, error)) []fleetResult[T] {
+	cfgs, errs, err := hostConfigs(hosts)
+	if err != nil {
+		return failAll[T](hosts, err)
+	}
+	parallel := max(ff.parallel, 1)
+	resolveHosts(cfgs, errs, parallel)
+
+	results := make([]fleetResult[T], len(hosts))
+	// open limits the sessions open at once, work the ones running fn;
+	// without --prewarm both are --parallel.
+	open := make(chan struct{}, parallel)
+	if ff.prewarm {
+		open = make(chan struct{}, 2*parallel)
+	}
+	work := make(chan struct{}, parallel)
+
+	var wg sync.WaitGroup
+	for i, h := range hosts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			open <- struct{}{}
+			defer func() { <-open }()
+
+			results[i].Host = h
+			cfg := cfgs[i]
+			if errs[i] != nil {
+				results[i].Err = errs[i]
+				return
+			}
+			if err := cfg.validate(); err != nil {
+				results[i].Err = err
+				return
+			}
+			s, err := DialContext(ctx, cfg)
+			if err != nil {
+				results[i].Err = err
+				return
+			}
+			defer s.Close()
+			stop := context.AfterFunc(ctx, func() { s.shutdown() })
+			defer stop()
+			work <- struct{}{}
+			defer func() { <-work }()
+			results[i].Value, results[i].Err = fn(h, s)
+			if err := ctx.Err(); err != nil && results[i].Err != nil {
+				results[i].Err = err
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// hostOrFleet runs fn on the switch of cf, or with fleet on the inventory
+// switches of ff, for subcommands with a --fleet flag.
+func hostOrFleet[T any](fleet bool, cf *connFlags, ff *fleetFlags, fn func(h Host, s *Session) (T, error)) []fleetResult[T] {
+	if fleet {
+		_, hosts := ff.load()
+		return runFleet(hosts, ff, fn)
+	}
+	cfg, s := cf.connect()
+	defer s.Close()
+	h := Host{Name: cfg.Host, Address: cfg.Host}
+	v, err := fn(h, s)
+	return []fleetResult[T]{{h, v, err}}
+}
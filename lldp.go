@@ -0,0 +1,117 @@
+package main
+
+import (
+	"strings"
+)
+
+// LLDPNeighbor is one entry of "show lldp info remote".
+type LLDPNeighbor struct {
+	LocalPort    string `json:"local_port"`
+	ChassisID    string `json:"chassis_id"`
+	PortID       string `json:"port_id"`
+	PortDesc     string `json:"port_desc"`
+	SystemName   string `json:"system_name"`
+	Capabilities string `json:"capabilities"`
+	MgmtAddress  string `json:"mgmt_address"`
+}
+
+// IsSwitch reports whether the neighbor advertises bridging.
+func (n LLDPNeighbor) IsSwitch() bool {
+	caps := strings.ToLower(n.Capabilities)
+	return strings.Contains(caps, "bridge") || strings.Contains(caps, "router")
+}
+
+// parseLLDPRemote parses the detailed remote table, where every neighbor
+// starts with a "Local Port" line followed by "Key : value" lines.
+func parseLLDPRemote(output string) []LLDPNeighbor {
+	var neighbors []LLDPNeighbor
+	var cur *LLDPNeighbor
+
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := splitKeyValue(line)
+		if !ok {
+			continue
+		}
+
+		switch strings.ToLower(key) {
+		case "local port":
+			neighbors = append(neighbors, LLDPNeighbor{LocalPort: value})
+			cur = &neighbors[len(neighbors)-1]
+		case "chassis id":
+			if cur != nil {
+				cur.ChassisID = value
+			}
+		case "port id":
+			if cur != nil {
+				cur.PortID = value
+			}
+		case "port description":
+			if cur != nil {
+				cur.PortDesc = value
+			}
+		case "system name":
+			if cur != nil {
+				cur.SystemName = value
+			}
+		case "system capabilities enabled":
+			if cur != nil {
+				cur.Capabilities = value
+			}
+		case "management address":
+			if cur != nil && cur.MgmtAddress == "" {
+				cur.MgmtAddress = value
+			}
+		}
+	}
+	return neighbors
+}
+
+// lldpNeighbors collects the LLDP remote table from the switch.
+func lldpNeighbors(s *Session) ([]LLDPNeighbor, error) {
+	out, err := s.Output(s.command("lldp-neighbors"))
+	if err != nil {
+		return nil, err
+	}
+	return parseLLDPRemote(out), nil
+}
+
+// neighborAnnotator returns a line rewriter that appends "→ name port" to
+// port table rows whose port has an LLDP neighbor. A row is recognised by
+// a leading port number or by a "Port NO. :n" field.
+func neighborAnnotator(neighbors []LLDPNeighbor) func(string) string {
+	byPort := make(map[Port]string)
+	for _, n := range neighbors {
+		p, err := parsePort(n.LocalPort)
+		if err != nil {
+			continue
+		}
+		name := n.SystemName
+		if name == "" {
+			name = n.ChassisID
+		}
+		byPort[p] = strings.TrimSpace(name + " " + n.PortID)
+	}
+
+	return func(line string) string {
+		field := ""
+		if key, value, ok := splitKeyValue(line); ok && strings.HasSuffix(key, "Port NO.") {
+			field = value
+		} else if f := strings.Fields(line); len(f) > 1 {
+			field = f[0]
+		}
+		if field == "" {
+			return line
+		}
+		p, err := parsePort(field)
+		if err != nil {
+			return line
+		}
+		if np, err := p.normalize(DialectFlat); err == nil {
+			p = np
+		}
+		if name, ok := byPort[p]; ok {
+			return line + "  " + symbol("→", "->") + " " + name
+		}
+		return line
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+type macData struct {
+	entries   []MACEntry
+	neighbors []LLDPNeighbor
+}
+
+// switchPorts returns the ports of h facing another switch by LLDP, and
+// its inventory uplinks.
+func switchPorts(h Host, neighbors []LLDPNeighbor) map[Port]bool {
+	ports := make(map[Port]bool)
+	for _, n := range neighbors {
+		if p, err := parsePort(n.LocalPort); err == nil && n.IsSwitch() {
+			ports[p] = true
+		}
+	}
+	for _, p := range h.uplinkPorts() {
+		ports[p] = true
+	}
+	return ports
+}
+
+// edgeSightings returns the MAC table entries learned on access ports,
+// skipping the CPU, inventory uplinks and ports facing another switch.
+func edgeSightings(h Host, d macData, now time.Time) []macSighting {
+	uplinks := switchPorts(h, d.neighbors)
+	var sightings []macSighting
+	for _, e := range d.entries {
+		p, err := parsePort(e.Port)
+		if err != nil || uplinks[p] {
+			continue
+		}
+		sightings = append(sightings, macSighting{now, h.Name, e.Port, e.VLAN, e.MAC})
+	}
+	return sightings
+}
+
+func runAuditMAC(fs *flag.FlagSet) func() {
+	ff := addFleetFlags(fs)
+	window := fs.Duration("window", time.Hour, tr("How far back to look for MAC moves"))
+	flaps := fs.Int("flaps", 3, tr("Number of moves within the window that counts as flapping"))
+	noRecord := fs.Bool("no-record", false, tr("Don't add this run to the MAC history"))
+	return func() {
+		_, hosts := ff.load()
+		results := runFleet(hosts, ff, func(h Host, s *Session) (macData, error) {
+			entries, err := macTable(s)
+			if err != nil {
+				return macData{}, err
+			}
+			neighbors, err := lldpNeighbors(s)
+			return macData{entries, neighbors}, err
+		})
+
+		now := time.Now()
+		var current []macSighting
+		for _, r := range results {
+			if r.Err == nil {
+				current = append(current, edgeSightings(r.Host, r.Value, now)...)
+			}
+		}
+
+		history, err := loadMACSightings(now.Add(-*window))
+		if err != nil {
+			fatal("%v", err)
+		}
+
+		findings := duplicateMACs(current)
+		findings = append(findings, flappingMACs(append(history, current...), *flaps, *window)...)
+
+		if !*noRecord {
+			if err := recordMACSightings(current); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}
+		reportFindings(results, findings)
+	}
+}
+
+// duplicateMACs reports MAC addresses currently learned on more than one
+// access port in the fleet.
+func duplicateMACs(sightings []macSighting) []finding {
+	where := make(map[string]map[string]bool)
+	for _, s := range sightings {
+		if where[s.MAC] == nil {
+			where[s.MAC] = make(map[string]bool)
+		}
+		where[s.MAC][s.Host+" port "+s.Port] = true
+	}
+
+	var findings []finding
+	for _, mac := range sortedStringKeys(where) {
+		if len(where[mac]) < 2 {
+			continue
+		}
+		places := sortedStringKeys(where[mac])
+		host, _, _ := strings.Cut(places[0], " ")
+		findings = append(findings, finding{host, fmt.Sprintf(
+			"MAC %s is on several access ports: %s", mac, strings.Join(places, ", "))})
+	}
+	return findings
+}
+
+// flappingMACs reports MAC addresses that moved between ports at least
+// threshold times in the given sightings.
+func flappingMACs(sightings []macSighting, threshold int, window time.Duration) []finding {
+	byMAC := make(map[string][]macSighting)
+	for _, s := range sightings {
+		byMAC[s.MAC] = append(byMAC[s.MAC], s)
+	}
+
+	var findings []finding
+	for _, mac := range sortedStringKeys(byMAC) {
+		list := byMAC[mac]
+		sort.SliceStable(list, func(i, j int) bool { return list[i].Time.Before(list[j].Time) })
+
+		var path []string
+		last := ""
+		for _, s := range list {
+			loc := s.Host + " port " + s.Port
+			if loc != last {
+				path = append(path, loc)
+				last = loc
+			}
+		}
+		if moves := len(path) - 1; moves >= threshold {
+			findings = append(findings, finding{list[len(list)-1].Host, fmt.Sprintf(
+				"MAC %s moved %d times in %s: %s", mac, moves, window, strings.Join(path, " -> "))})
+		}
+	}
+	return findings
+}
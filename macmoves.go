@@ -0,0 +1,172 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// macMove is a MAC address showing up on another access port than where
+// it was last seen.
+type macMove struct {
+	Time     time.Time
+	MAC      string
+	VLAN     int
+	FromHost string
+	FromPort string
+	Host     string
+	Port     string
+}
+
+func (m macMove) String() string {
+	return fmt.Sprintf("MAC %s moved from %s port %s to %s port %s", m.MAC, m.FromHost, m.FromPort, m.Host, m.Port)
+}
+
+// event returns the move as a monitor event.
+func (m macMove) event() event {
+	return event{Time: m.Time, Type: eventMACMove, Host: m.Host, Port: m.Port, MAC: m.MAC, VLAN: m.VLAN,
+		FromHost: m.FromHost, FromPort: m.FromPort, Message: m.String()}
+}
+
+// loadMACMoves returns the moves between the MAC snapshots of the fleet
+// since the given time. The last snapshot of each host before it is the
+// baseline. Addresses on uplinks are skipped, and a MAC that is on several
+// access ports at once has not moved while one of them is where it was.
+func loadMACMoves(db *sql.DB, since time.Time) ([]macMove, error) {
+	from := since.UTC().Format(time.RFC3339)
+	rows, err := db.Query(`SELECT time, host, kind, data FROM snapshots s
+		WHERE kind IN ('macs', 'uplinks') AND (time >= ? OR time = (
+			SELECT MAX(time) FROM snapshots WHERE host = s.host AND kind = s.kind AND time < ?))
+		ORDER BY time, id`, from, from)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Snapshots of one run share their time; each run is compared as a
+	// whole, so a MAC going from one switch to another is one move.
+	uplinks := make(map[string][]string)
+	last := make(map[string][2]string)
+	var moves []macMove
+	var runTime string
+	var run map[string]map[string]map[string]string
+	flush := func() {
+		t, _ := time.Parse(time.RFC3339, runTime)
+		seen := make(map[string][][2]string)
+		for _, host := range sortedStringKeys(run) {
+			for mac, vlans := range run[host] {
+				for vlan, port := range vlans {
+					if _, err := parsePort(port); err != nil || slices.Contains(uplinks[host], port) {
+						continue
+					}
+					key := mac + "/" + vlan
+					seen[key] = append(seen[key], [2]string{host, port})
+				}
+			}
+		}
+		for _, key := range sortedStringKeys(seen) {
+			places := seen[key]
+			prev, ok := last[key]
+			if ok && slices.Contains(places, prev) {
+				continue
+			}
+			last[key] = places[0]
+			if ok && !t.Before(since) {
+				mac, vlan, _ := strings.Cut(key, "/")
+				id, _ := strconv.Atoi(vlan)
+				moves = append(moves, macMove{t, mac, id, prev[0], prev[1], places[0][0], places[0][1]})
+			}
+		}
+	}
+	for rows.Next() {
+		var ts, host, kind, data string
+		if err := rows.Scan(&ts, &host, &kind, &data); err != nil {
+			return nil, err
+		}
+		if ts != runTime {
+			if run != nil {
+				flush()
+			}
+			runTime, run = ts, make(map[string]map[string]map[string]string)
+		}
+		switch kind {
+		case "uplinks":
+			var ports []string
+			if json.Unmarshal([]byte(data), &ports) == nil {
+				uplinks[host] = ports
+			}
+		case "macs":
+			var macs map[string]map[string]string
+			if json.Unmarshal([]byte(data), &macs) == nil {
+				run[host] = macs
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if run != nil {
+		flush()
+	}
+	return moves, nil
+}
+
+func runMACMoves(fs *flag.FlagSet) func() {
+	ff := addFleetFlags(fs)
+	since := fs.Duration("since", 24*time.Hour, tr("How far back to look for moves"))
+	notify := fs.Bool("notify", false, tr("Post the moves to the inventory's mac-move webhooks"))
+	var urls []string
+	fs.Func("webhook", tr("Post the moves to this `url` (repeatable)"), func(s string) error {
+		urls = append(urls, s)
+		return nil
+	})
+	return func() {
+		var webhooks []Webhook
+		if *notify {
+			inv, err := loadInventory(ff.inventory)
+			if err != nil {
+				fatal("%v", err)
+			}
+			webhooks = inv.Webhooks
+		}
+		for _, u := range urls {
+			webhooks = append(webhooks, Webhook{URL: u})
+		}
+
+		db, err := openHistory()
+		if err != nil {
+			fatal("%v", err)
+		}
+		if db == nil {
+			fatal("No history: set ZYXEL_HISTORY_DB to record snapshots in collect, monitor and backups take")
+		}
+		defer db.Close()
+		moves, err := loadMACMoves(db, time.Now().Add(-*since))
+		if err != nil {
+			fatal("%v", err)
+		}
+
+		failed := false
+		for _, m := range moves {
+			fmt.Printf("%s  VLAN %d  %s\n", m.Time.Local().Format(timeLayout), m.VLAN, m)
+			for _, w := range webhooks {
+				if !w.wants(eventMACMove) {
+					continue
+				}
+				if err := postWebhook(w, m.event()); err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: webhook: %v\n", err)
+					failed = true
+				}
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+	}
+}
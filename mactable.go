@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MACEntry is one row of the MAC address table.
+type MACEntry struct {
+	MAC  string `json:"mac"`
+	VLAN int    `json:"vlan"`
+	Port string `json:"port"`
+	Type string `json:"type"`
+}
+
+// normalizeMAC rewrites a MAC address in any common notation
+// (00-11-22-33-44-55, 0011.2233.4455, 001122334455) as 00:11:22:33:44:55.
+func normalizeMAC(s string) (string, error) {
+	hex := strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '-', '.':
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(s)))
+
+	if len(hex) != 12 || strings.Trim(hex, "0123456789abcdef") != "" {
+		return "", fmt.Errorf("invalid MAC address %q", s)
+	}
+	parts := make([]string, 6)
+	for i := range parts {
+		parts[i] = hex[i*2 : i*2+2]
+	}
+	return strings.Join(parts, ":"), nil
+}
+
+// parseMACTable parses "show mac address-table all".
+func parseMACTable(output string) []MACEntry {
+	var entries []MACEntry
+	for _, row := range parseTable(output, "Port") {
+		mac, err := normalizeMAC(firstOf(row, "MAC Address", "MAC"))
+		if err != nil {
+			continue
+		}
+		entries = append(entries, MACEntry{
+			MAC:  mac,
+			VLAN: atoiOr(firstOf(row, "VLAN ID", "VID", "VLAN"), 0),
+			Port: row["Port"],
+			Type: row["Type"],
+		})
+	}
+	return entries
+}
+
+func macTable(s *Session) ([]MACEntry, error) {
+	out, err := s.Output(s.command("mac-table"))
+	if err != nil {
+		return nil, err
+	}
+	return parseMACTable(out), nil
+}
@@ -1,240 +1,640 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"regexp"
+	"slices"
 	"strings"
-	"time"
 
-	"github.com/joho/godotenv"
-	"golang.org/x/crypto/ssh"
+	"github.com/itchyny/gojq"
+	"golang.org/x/term"
 )
 
 func fatal(format string, args ...interface{}) {
-	fmt.Fprintf(os.Stderr, "Error: "+format+"\n", args...)
+	fmt.Fprintf(os.Stderr, tr("Error: ")+tr(format)+"\n", args...)
 	os.Exit(1)
 }
 
-func main() {
-	command := flag.String("c", "", "Zyxel command to execute")
-	flag.Parse()
-
-	if *command == "" {
-		fmt.Println("Usage: zyxel -c '<command>'")
-		fmt.Println()
-		fmt.Println("Examples:")
-		fmt.Println("  zyxel -c 'show system-information'")
-		fmt.Println("  zyxel -c 'show running-config'")
-		fmt.Println("  zyxel -c 'show interface *'")
-		fmt.Println("  zyxel -c 'show mac address-table'")
-		fmt.Println("  zyxel -c 'show vlan'")
-		fmt.Println("  zyxel -c '?'                        # show available commands")
-		fmt.Println()
-		fmt.Println("Environment variables:")
-		fmt.Println("  ZYXEL_HOST      Switch IP address (required)")
-		fmt.Println("  ZYXEL_USER      SSH username (required)")
-		fmt.Println("  ZYXEL_PASSWORD  SSH password (required)")
-		fmt.Println("  ZYXEL_PORT      SSH port (default: 22)")
-		os.Exit(1)
-	}
-
-	// Load .env if present
-	_ = godotenv.Load()
-
-	host := os.Getenv("ZYXEL_HOST")
-	user := os.Getenv("ZYXEL_USER")
-	password := os.Getenv("ZYXEL_PASSWORD")
-	port := os.Getenv("ZYXEL_PORT")
-
-	var missing []string
-	if host == "" {
-		missing = append(missing, "ZYXEL_HOST")
-	}
-	if user == "" {
-		missing = append(missing, "ZYXEL_USER")
-	}
-	if password == "" {
-		missing = append(missing, "ZYXEL_PASSWORD")
-	}
-	if len(missing) > 0 {
-		fatal("Missing required environment variables: %s", strings.Join(missing, ", "))
-	}
-
-	if port == "" {
-		port = "22"
-	}
-
-	config := &ssh.ClientConfig{
-		User: user,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(password),
-			ssh.KeyboardInteractive(func(user, instruction string, questions []string, echos []bool) ([]string, error) {
-				answers := make([]string, len(questions))
-				for i := range questions {
-					answers[i] = password
-				}
-				return answers, nil
-			}),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		HostKeyAlgorithms: []string{
-			"ssh-rsa",
-			"rsa-sha2-256",
-			"rsa-sha2-512",
-		},
-		Config: ssh.Config{
-			KeyExchanges: []string{
-				"diffie-hellman-group-exchange-sha256",
-				"diffie-hellman-group14-sha256",
-				"diffie-hellman-group14-sha1",
-			},
-		},
-		Timeout: 10 * time.Second,
-	}
-
-	address := fmt.Sprintf("%s:%s", host, port)
-
-	client, err := ssh.Dial("tcp", address, config)
-	if err != nil {
-		fatal("Failed to connect to %s: %v", address, err)
-	}
-	defer client.Close()
+// subcommand is a named mode such as "zyxel uplinks". run defines its
+// flags on fs and returns the function that does the work once they are
+// parsed, so the flags can be listed without running it.
+type subcommand struct {
+	name    string
+	summary string
+	run     func(fs *flag.FlagSet) func()
+	// output describes what is printed on stdout, for "zyxel describe".
+	output string
+	// checks are nested subcommands such as "zyxel audit stp".
+	checks []subcommand
+}
 
-	session, err := client.NewSession()
-	if err != nil {
-		fatal("Failed to create SSH session: %v", err)
-	}
-	defer session.Close()
+// invoke parses args with the flags of sc and runs it.
+func (sc subcommand) invoke(name string, args []string) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	run := sc.run(fs)
+	fs.Parse(flagsFirst(fs, args))
+	run()
+}
 
-	modes := ssh.TerminalModes{
-		ssh.ECHO:          0,
-		ssh.TTY_OP_ISPEED: 14400,
-		ssh.TTY_OP_OSPEED: 14400,
+// flagsFirst moves the flags fs knows in front of the positional
+// arguments, so "client <mac> --timeline" works although the flag package
+// stops at the first positional. Unknown flags stay where they are, for
+// nested subcommands to parse.
+func flagsFirst(fs *flag.FlagSet, args []string) []string {
+	var flags, rest []string
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		name, _, hasValue := strings.Cut(strings.TrimLeft(a, "-"), "=")
+		f := fs.Lookup(name)
+		if !strings.HasPrefix(a, "-") || a == "-" || f == nil {
+			rest = append(rest, a)
+			continue
+		}
+		flags = append(flags, a)
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); !hasValue && !(ok && b.IsBoolFlag()) && i+1 < len(args) {
+			i++
+			flags = append(flags, args[i])
+		}
 	}
+	return append(flags, rest...)
+}
 
-	if err := session.RequestPty("xterm", 80, 200, modes); err != nil {
-		fatal("Failed to request PTY: %v", err)
-	}
+var subcommands = []subcommand{
+	{"uplinks", "List ports detected as uplinks", runUplinks,
+		"one line per uplink: port, then the reasons separated by commas", nil},
+	{"audit", "Check the inventory for configuration drift", runAudit, "", auditChecks},
+	{"collect", "Record MAC, ARP and DHCP snooping tables of the fleet", runCollect,
+		"one line per switch: \"<host>: <n> MACs, <m> IP bindings\"", nil},
+	{"find-mac", "Find the switch port a MAC address is learned on", runFindMAC,
+		"one \"<host> port <port> VLAN <id>\" line per access port (uplinks too with --all, marked \"(uplink)\")", nil},
+	{"whohas", "Find the switch port of an IP address from the ARP and MAC tables", runWhohas,
+		"\"<ip> is <mac> (<source> on <host>, ...)\" per MAC the IP is bound to, each followed by \"<host> port <port> VLAN <id>\" per access port", nil},
+	{"dhcp-bindings", "List the DHCP snooping bindings of a switch or the inventory", runDHCPBindings,
+		"CSV with a header row: host, mac, ip, lease (seconds left), vlan, port; or a JSON array with --format json; connection errors go to stderr", nil},
+	{"topology", "Export the LLDP topology of the inventory as Graphviz DOT or JSON", runTopology,
+		"a Graphviz graph, or with --format json an object with nodes (name, address, inventory, error) and links (a, a_port, b, b_port)", nil},
+	{"inventory", "List the inventory switches, also as an Ansible dynamic inventory", runInventory,
+		"a table: Name, Address, Tags; with --format ansible an Ansible dynamic inventory JSON document", nil},
+	{"discover", "Find Zyxel switches in a subnet over SSH and SNMP", runDiscover,
+		"a table: Address, Name, Model, SSH (banner); with --write the hosts are added to the inventory", nil},
+	{"client", "Show where a MAC address has been seen", runClient,
+		"\"MAC <mac>\", an optional \"Last IP: ...\" line, then \"<from> - <until|present> <host> port <port> VLAN <id>\" per stay", nil},
+	{"history", "Show how interfaces, MAC table and system information changed", runHistory,
+		"one line per change: time, path (e.g. interfaces.5.link) and the new value, \"(gone)\" when it disappeared", nil},
+	{"mac-moves", "Report MAC addresses that moved to another port", runMACMoves,
+		"one \"<time>  VLAN <id>  MAC <mac> moved from <host> port <port> to <host> port <port>\" line per move", nil},
+	{"flaps", "List ports whose link went up and down repeatedly", runFlaps,
+		"a table: Host, Port, Changes, Downs, Last change, most changes first; \"No flapping ports\" if none", nil},
+	{"backups", "Take, compare, search and import configuration backups", runBackups, "", backupCommands},
+	{"login", "Store credentials for a switch in the OS keychain", runLogin, "nothing; status goes to stderr", nil},
+	{"exporter", "Serve switch and tool metrics for Prometheus", runExporter,
+		"nothing; metrics are served over HTTP at /metrics", nil},
+	{"monitor", "Watch for link, PoE and MAC changes and post them to webhooks", runMonitor,
+		"one JSON object per event: time, type (link, poe or mac-move), host, port, message and the event's details; poll errors go to stderr", nil},
+	{"syslogd", "Receive syslog from the switches and log it as JSON", runSyslogd,
+		"one JSON object per message: time, switch (the inventory name), address, facility, severity, hostname, app and message", nil},
+	{"playbook", "Run a guided troubleshooting playbook", runPlaybook, "", playbookCommands},
+	{"play", "Run the steps of a YAML runbook on inventory switches", runPlay,
+		"each step's output under a \"== step @ host\" line; one status line per step goes to stderr; exit code 1 if a step failed", nil},
+	{"script", "Run a Starlark script against switches", runScript,
+		"whatever the script prints; a failed script prints its backtrace on stderr and exits 1", nil},
+	{"snmp", "Show system information and port counters over SNMP", runSNMP,
+		"\"Key: value\" system lines, a blank line, then a table: Port, Admin, Oper, Mbps, In octets, Out octets, In err, Out err", nil},
+	{"snmp-v3", "Set up the SNMPv3 user of ZYXEL_SNMP_* on switches", runSNMPv3,
+		"nothing; one line per switch goes to stderr; exit code 1 if a switch failed", nil},
+	{"render", "Render a configuration template with variables from a YAML file", runRender,
+		"the rendered configuration", nil},
+	{"apply", "Render a configuration template and apply it to a switch", runApply,
+		"the output of each configuration and --verify command", nil},
+	{"plan", "Show the changes that would bring a switch to a declared state", runPlan,
+		"one line per change (\"+\" create, \"~\" change) with its commands indented below, then \"Plan: <n> changes, <m> commands\"", nil},
+	{"converge", "Apply the changes that bring a switch to a declared state", runConverge,
+		"the plan as printed by plan, then the output of the configuration commands", nil},
+	{"rollback", "Restore the checkpoint taken before a change", runRollback,
+		"the output of the configuration commands; with --list one line per checkpoint: id (UTC time) and size", nil},
+	{"firmware", "Upgrade switch firmware, one switch or the inventory in turn", runFirmware, "", firmwareCommands},
+	{"report", "Report on the inventory switches", runReport, "", reportCommands},
+	{"health", "Check CPU, memory, temperature, fans, power supplies and trunks", runHealth,
+		"one line per check: name, ok/warn/fail and the readings, then \"Health: PASS|WARN|FAIL\"; with --format nagios a Nagios plugin status line with perfdata; with --format influx one zyxel_health line-protocol point per check; the exit code is 0, 1 or 2 accordingly, 3 if the switch could not be checked", nil},
+	{"port", "Enable, disable or describe ports", runPort, "", portCommandList},
+	{"mirror", "Mirror ports to a monitor port for packet captures", runMirror, "", mirrorCommandList},
+	{"storm", "Show and set storm control and loop guard on ports", runStorm, "", stormCommandList},
+	{"acl", "Show, add and remove classifier and policy rules", runACL, "", aclCommandList},
+	{"users", "List, add and remove switch accounts and change passwords", runUsers, "", userCommandList},
+	{"time", "Check and set the NTP server and time zone of the switches", runTime, "", timeCommandList},
+	{"vlan", "Create and delete VLANs and change their port membership", runVLAN, "", vlanCommandList},
+	{"trunks", "Show link aggregation groups and the state of their members", runTrunks,
+		"a table: Trunk, State, Mode, Members, Status (members up, those down or not synchronized); with --format json an array of objects with id, state, mode, members, up, down and unsynced; exit code 1 when a trunk has a member down", nil},
+	{"igmp", "Show the IGMP snooping groups and their member ports", runIGMP,
+		"a table: VLAN, Group, Ports (comma-separated); with --format json an array of objects with vlan, group and ports", nil},
+	{"poe", "Show PoE power usage and switch or power-cycle PoE ports", runPoE, "", poeCommandList},
+	{"stp", "Show the spanning-tree bridge, root and port roles and states", runSTP,
+		"\"Key: value\" lines for mode, bridge and root, a blank line, then a table: Port, Role, State, Cost, Priority; with --format json an object with the same fields", nil},
+	{"optics", "Show SFP module diagnostics and warn on bad readings", runOptics,
+		"one line per module: port, ok/warn/fail and the module and its readings; with --format json an array of transceivers with their readings, thresholds and status; with --format nagios a Nagios plugin status line with perfdata; the exit code is 0, 1 or 2 accordingly", nil},
+	{"cable-diag", "Test the cables on ports and show each pair's status and length", runCableDiag,
+		"a table: Port, Pair, Status, Length, Fault (distance to the fault of a bad pair); with --format json an array of objects with port, pair, status, length_m and fault_m (-1 when unknown)", nil},
+	{"rates", "Show per-port packet rates and error deltas over an interval", runRates,
+		"a table: Port, Rx pkt/s, Tx pkt/s, Rx KB/s, Tx KB/s, Errors, then the error counters that grew; with --format nagios a Nagios plugin status line with perfdata and the Nagios exit code; with --format influx one zyxel_interface line-protocol point per port", nil},
+	{"learn-commands", "Learn the switch's commands from its ? help", runLearnCommands,
+		"nothing; the number of words learned goes to stderr", nil},
+	{"replay", "Serve a recorded session over Telnet as a mock switch", runReplay,
+		"nothing; the listening address goes to stderr", nil},
+	{"simulate", "Run a simulated GS1920 over SSH for development and CI", runSimulate,
+		"nothing; the listening address goes to stderr", nil},
+}
 
-	stdin, err := session.StdinPipe()
-	if err != nil {
-		fatal("Failed to get stdin pipe: %v", err)
+func findSubcommand(name string) *subcommand {
+	for i := range subcommands {
+		if subcommands[i].name == name {
+			return &subcommands[i]
+		}
 	}
+	return nil
+}
 
-	stdout, err := session.StdoutPipe()
-	if err != nil {
-		fatal("Failed to get stdout pipe: %v", err)
+// environment lists the variables read by the tool and what they do.
+var environment = [][2]string{
+	{"ZYXEL_HOST", "Switch IP address (required)"},
+	{"ZYXEL_USER", "SSH username (required)"},
+	{"ZYXEL_PASSWORD", "SSH password (required)"},
+	{"ZYXEL_PORT", "SSH port (default: 22)"},
+	{"ZYXEL_TRANSPORT", "ssh, telnet, http or https (default: ssh; http/https are for GS1900 web management)"},
+	{"ZYXEL_ENABLE_PASSWORD", "Password for 'enable' when login lands at a '>' prompt (default: ZYXEL_PASSWORD)"},
+	{"ZYXEL_NEW_PASSWORD", "New password to set when the switch forces a password change at login"},
+	{"ZYXEL_PAGER_COMMAND", "Command that disables paging (default: per model; 'none' to skip)"},
+	{"ZYXEL_MODEL", "Switch model, e.g. XGS2210, or generic (default: detected at login)"},
+	{"ZYXEL_PROMPT_REGEX", "Regex matching the switch prompt (default: learned from the login prompt)"},
+	{"ZYXEL_TERM_WIDTH", "Terminal width announced to the switch (default: 200)"},
+	{"ZYXEL_TERM_HEIGHT", "Terminal height announced to the switch (default: 80)"},
+	{"ZYXEL_WRAP_WIDTH", "Rejoin output lines wrapped at this many columns, e.g. 80 (default: off)"},
+	{"ZYXEL_PORT_DIALECT", "Port notation: flat, slot or unit-slot (default: from the running-config)"},
+	{"ZYXEL_KEEPALIVE", "Keep-alive interval during slow commands, e.g. 15s; 0 turns it off (default: 30s)"},
+	{"ZYXEL_RECONNECT", "Times to log in again when the switch drops the session; 0 turns it off (default: 3)"},
+	{"ZYXEL_RECORD", "Write a transcript of each session to this file for zyxel replay ({host} expands)"},
+	{"ZYXEL_AUTH", "SSH login with password (default) or key"},
+	{"ZYXEL_KEY_FILE", "SSH private key for ZYXEL_AUTH=key"},
+	{"ZYXEL_PROFILE", "Named profile from the config file to connect with (also --profile)"},
+	{"ZYXEL_CONFIG", "Config file with profiles (default: ~/.config/zyxel/config.yaml)"},
+	{"ZYXEL_SNMP_VERSION", "SNMP version, 2c or 3 (default: 2c)"},
+	{"ZYXEL_SNMP_COMMUNITY", "SNMPv2c community (default: public)"},
+	{"ZYXEL_SNMP_USER", "SNMPv3 user, with ZYXEL_SNMP_AUTH/_AUTH_PASSWORD and ZYXEL_SNMP_PRIV/_PRIV_PASSWORD"},
+	{"ZYXEL_PLAYBOOKS", "Directory with your own playbooks (default: playbooks)"},
+	{"ZYXEL_VAULT_ADDR", "Vault server to read credentials from, with ZYXEL_VAULT_PATH and VAULT_TOKEN"},
+	{"ZYXEL_STATE_DIR", "Where history from earlier runs is kept (default: ~/.local/state/zyxel)"},
+	{"ZYXEL_INVENTORY", "Inventory file for fleet subcommands (default: inventory.yaml)"},
+	{"ZYXEL_HISTORY_DB", "SQLite file where collect, monitor and backups take record snapshots for history"},
+	{"ZYXEL_INFLUX_URL", "InfluxDB write URL that --format influx sends to instead of stdout, with ZYXEL_INFLUX_TOKEN"},
+	{"ZYXEL_PLAIN, --plain", "ASCII-only output without colors or animations"},
+	{"LANG, --lang", "Language of messages, en or et (default: from LANG)"},
+}
+
+func usage() {
+	fmt.Println(tr("Usage:") + " zyxel [--raw] [--neighbors] [-o file [--append]] -c '<command>' [-c ...]")
+	fmt.Println("       zyxel --watch 5s -c '<command>' [-c ...]")
+	fmt.Println("       zyxel [--configure] [--save] [--verify '<command>'] [--runbook file] -c '<command>' [-c ...]")
+	fmt.Println("       zyxel <subcommand> [flags] [args]")
+	fmt.Println()
+	fmt.Println(tr("Examples:"))
+	fmt.Println("  zyxel -c 'show system-information'")
+	fmt.Println("  zyxel -c 'show running-config'")
+	fmt.Println("  zyxel -c 'show interface *'")
+	fmt.Println("  zyxel -c 'show mac address-table'")
+	fmt.Println("  zyxel -c 'show vlan'")
+	fmt.Println("  zyxel -c '?'                        # " + tr("show available commands"))
+	fmt.Println("  zyxel --raw -c 'show running-config' # " + tr("untouched output"))
+	fmt.Println("  zyxel --neighbors -c 'show interfaces status'")
+	fmt.Println("  zyxel -o 'backup/{host}.cfg' -c 'show running-config'")
+	fmt.Println("  zyxel --configure --save -c 'interface port-channel 5' -c 'name printer'")
+	fmt.Println("  zyxel --configure --runbook 'change-{host}.md' --verify 'show interfaces 5' -c 'interface port-channel 5' -c 'no inactive'")
+	fmt.Println()
+	fmt.Println(tr("Subcommands:"))
+	for _, sc := range subcommands {
+		fmt.Printf("  %-14s %s\n", sc.name, tr(sc.summary))
+	}
+	fmt.Println()
+	fmt.Println(tr("Environment variables:"))
+	for _, env := range environment {
+		fmt.Printf("  %-22s %s\n", env[0], tr(env[1]))
 	}
+}
 
-	if err := session.Shell(); err != nil {
-		fatal("Failed to start shell: %v", err)
+func main() {
+	args := setupLanguage(globalFlags(os.Args[1:]))
+	handleInterrupts()
+	if len(args) > 0 {
+		if sc := findSubcommand(args[0]); sc != nil {
+			sc.invoke(sc.name, args[1:])
+			return
+		}
 	}
 
-	buf := make([]byte, 4096)
-	readCh := make(chan string, 100)
-	errCh := make(chan error, 1)
-	done := make(chan struct{})
+	run := runCommands(flag.CommandLine)
+	flag.CommandLine.Parse(args)
+	run()
+}
 
-	go func() {
-		for {
-			select {
-			case <-done:
-				return
-			default:
-				n, err := stdout.Read(buf)
-				if err != nil {
-					select {
-					case errCh <- err:
-					default:
-					}
-					return
-				}
-				readCh <- string(buf[:n])
+// runCommands is the default mode: run the -c commands on one switch.
+func runCommands(fs *flag.FlagSet) func() {
+	var commands stringList
+	fs.Var(&commands, "c", tr("Zyxel command to execute (repeat for several)"))
+	raw := fs.Bool("raw", false, tr("Print output exactly as received, without cleanup"))
+	outPath := fs.String("o", "", tr("Write output to `file` instead of stdout ({host} expands to the switch address)"))
+	appendOut := fs.Bool("append", false, tr("Append to the -o file instead of overwriting it"))
+	withNeighbors := fs.Bool("neighbors", false, tr("Append LLDP neighbor name and port to port table rows"))
+	configure := fs.Bool("configure", false, tr("Run the commands in configuration mode"))
+	save := fs.Bool("save", false, tr("Write memory at the end of the session"))
+	var verify stringList
+	fs.Var(&verify, "verify", tr("With --configure: command to run afterwards to check the change (repeat for several)"))
+	watch := fs.Duration("watch", 0, tr("Re-run the commands at this `interval`, highlighting changed lines"))
+	runbookPath := fs.String("runbook", "", tr("With --configure: write a Markdown runbook of the change to `file` ({host} expands)"))
+	dryRun := addDryRunFlag(fs)
+	yes := fs.Bool("yes", false, tr("Run commands such as reload or erase without asking"))
+	format := fs.String("format", "text", tr("Output format: text, or json or table to parse the output of known commands"))
+	noColor := fs.Bool("no-color", false, tr("With --format table: do not color the table"))
+	grep := fs.String("grep", "", tr("Print only the output lines matching this `regex`"))
+	fieldList := fs.String("fields", "", tr("With --format json or table: keep only these comma-separated `fields`"))
+	queryExpr := fs.String("query", "", tr("With --format json or table: apply this jq `expression` to each parsed output"))
+	noPTY := fs.Bool("no-pty", false, tr("Run each command as an SSH exec request, without a terminal"))
+	noCheck := fs.Bool("no-check", false, tr("Do not check the commands against the learned ones"))
+	cf := addConnFlags(fs)
+	return func() {
+		if len(commands) == 0 {
+			usage()
+			os.Exit(1)
+		}
+
+		var parsers []commandParser
+		switch *format {
+		case "text":
+		case "json", "table":
+			if *raw || *watch > 0 || *configure || *withNeighbors {
+				fatal("--format %s cannot be combined with --raw, --watch, --configure or --neighbors", *format)
+			}
+			var err error
+			if parsers, err = loadParsers(); err != nil {
+				fatal("%v", err)
 			}
+		default:
+			fatal("--format must be text, json or table, not %q", *format)
 		}
-	}()
 
-	// Wait for initial prompt
-	promptTimeout := time.After(5 * time.Second)
-waitPrompt:
-	for {
-		select {
-		case chunk := <-readCh:
-			if strings.Contains(chunk, "#") {
-				break waitPrompt
+		var match *regexp.Regexp
+		if *grep != "" {
+			if parsers != nil || *watch > 0 {
+				fatal("--grep cannot be combined with --format %s or --watch", *format)
+			}
+			var err error
+			if match, err = regexp.Compile(*grep); err != nil {
+				fatal("Invalid --grep pattern: %v", err)
+			}
+		}
+		var fields []string
+		for _, f := range strings.Split(*fieldList, ",") {
+			if f = strings.TrimSpace(f); f != "" {
+				fields = append(fields, f)
+			}
+		}
+		if fields != nil && parsers == nil {
+			fatal("--fields needs --format json or table")
+		}
+		var query *gojq.Code
+		if *queryExpr != "" {
+			if parsers == nil {
+				fatal("--query needs --format json or table")
+			}
+			var err error
+			if query, err = compileQuery(*queryExpr); err != nil {
+				fatal("%v", err)
 			}
-		case <-promptTimeout:
-			close(done)
-			fatal("Timeout waiting for switch prompt")
-		case <-errCh:
-			close(done)
-			fatal("Connection closed unexpectedly")
 		}
-	}
 
-	// Send command
-	fmt.Fprintf(stdin, "%s\n", *command)
+		if *watch > 0 && (*configure || *save || *outPath != "") {
+			fatal("--watch cannot be combined with --configure, --save or -o")
+		}
+		if *dryRun && !*configure {
+			fatal("--dry-run needs --configure")
+		}
 
-	// Read output
-	var output strings.Builder
-	timeout := time.After(30 * time.Second)
-	lastRead := time.Now()
-	seenContent := false
+		aliases, err := loadAliases()
+		if err != nil {
+			fatal("%v", err)
+		}
+		commands, verify = expandAliases(commands, aliases), expandAliases(verify, aliases)
 
-readLoop:
-	for {
-		select {
-		case chunk := <-readCh:
-			lastRead = time.Now()
-			output.WriteString(chunk)
+		cfg := cf.config()
+		if t := loadCommandTree(cfg.Host); t != nil && !*noCheck && !*configure {
+			for _, c := range commands {
+				if err := t.check(c); err != nil {
+					fatal("%v", err)
+				}
+			}
+		}
+		if !*dryRun {
+			confirmDangerous(commands, cfg.Host, *yes)
+		}
 
-			if strings.Contains(strings.ToLower(chunk), "more") {
-				fmt.Fprintf(stdin, " ")
-				continue
+		var w io.Writer = os.Stdout
+		if *outPath != "" {
+			f, err := openOutput(*outPath, cfg.Host, *appendOut)
+			if err != nil {
+				fatal("%v", err)
 			}
+			defer f.Close()
+			w = f
+		}
+		if match != nil {
+			g := &grepWriter{w: w, match: match}
+			trackFlusher(g)
+			defer g.flush()
+			w = g
+		}
+
+		if cfg.Transport == "http" || cfg.Transport == "https" {
+			if *configure || *save || *withNeighbors || *runbookPath != "" || *watch > 0 || parsers != nil {
+				fatal("--configure, --save, --runbook, --watch and --neighbors need a CLI transport")
+			}
+			if err := runWeb(cfg, commands, w); err != nil {
+				fatal("%v", err)
+			}
+			return
+		}
 
-			if strings.Contains(chunk, "\n") {
-				seenContent = true
+		if *noPTY {
+			if *configure || *save || *withNeighbors || *runbookPath != "" || *watch > 0 || parsers != nil {
+				fatal("--no-pty only prints -c output; it takes no other session flags")
 			}
+			if err := runExec(cfg, commands, w, *raw); err != nil {
+				fatal("%v", err)
+			}
+			return
+		}
 
-			if seenContent {
-				trimmed := strings.TrimRight(output.String(), " \r\n")
-				if strings.HasSuffix(trimmed, "#") {
-					break readLoop
+		s, err := Dial(cfg)
+		if err != nil {
+			fatal("%v", err)
+		}
+		defer s.Close()
+		s.dryRun = *dryRun
+		if commands, err = s.expandPorts(commands); err != nil {
+			fatal("%v", err)
+		}
+		if verify, err = s.expandPorts(verify); err != nil {
+			fatal("%v", err)
+		}
+
+		if *configure {
+			applyChange(s, cfg, commands, verify, *save, *runbookPath, w)
+			return
+		}
+		if len(verify) > 0 || *runbookPath != "" {
+			fatal("--verify and --runbook need --configure")
+		}
+		if parsers != nil {
+			results, err := parseCommandOutput(s, parsers, commands)
+			if err != nil {
+				fatal("%v", err)
+			}
+			if fields != nil {
+				found := make(map[string]bool)
+				var have []string
+				for i, r := range results {
+					var h []string
+					results[i].Data, h = selectFields(r.Data, fields, found)
+					for _, n := range h {
+						if !slices.Contains(have, n) {
+							have = append(have, n)
+						}
+					}
+				}
+				for _, f := range fields {
+					if !found[f] {
+						fatal("No output has a field %q; there are %s", f, strings.Join(have, ", "))
+					}
+				}
+			}
+			if query != nil {
+				for i, r := range results {
+					if results[i].Data, err = runQuery(query, r.Data); err != nil {
+						fatal("%s: %v", r.Command, err)
+					}
+				}
+			}
+			if *format == "table" {
+				// Colors only for a terminal: not in files, pipes or --plain.
+				tw := tableWriter{w, !*noColor && !plain && os.Getenv("NO_COLOR") == "" && *outPath == "" && term.IsTerminal(int(os.Stdout.Fd()))}
+				for i, r := range results {
+					if i > 0 {
+						fmt.Fprintln(w)
+					}
+					tw.write(r.Command, r.Data)
+				}
+			} else {
+				enc := json.NewEncoder(w)
+				enc.SetIndent("", "  ")
+				values := []any{results}
+				if query != nil {
+					// Like jq, the values a query yields are printed one by one.
+					values = nil
+					for _, r := range results {
+						values = append(values, r.Data.([]any)...)
+					}
+				}
+				for _, v := range values {
+					if err := enc.Encode(v); err != nil {
+						fatal("%v", err)
+					}
 				}
 			}
+			if *save {
+				saveConfig(s)
+			}
+			return
+		}
 
-		case <-errCh:
-			break readLoop
+		var annotate func(string) string
+		if *withNeighbors && !*raw {
+			neighbors, err := lldpNeighbors(s)
+			if err != nil {
+				fatal("Failed to read LLDP neighbors: %v", err)
+			}
+			annotate = neighborAnnotator(neighbors)
+		}
+		if *watch > 0 {
+			watchCommands(s, commands, *watch, *raw, annotate, cfg.WrapWidth)
+			return
+		}
+		for _, c := range commands {
+			err := s.retry(c, nil, func() error {
+				out := newLineStreamer(w, *raw)
+				out.wrap = cfg.WrapWidth
+				out.annotate = annotate
+				trackFlusher(out)
+				defer untrackFlusher(out)
+				return s.Run(c, out.Write)
+			})
+			if err != nil {
+				fatal("%v", err)
+			}
+		}
+		if *save {
+			saveConfig(s)
+		}
+	}
+}
 
-		case <-timeout:
-			break readLoop
+// saveConfig runs write memory and reports the result on stderr, exiting
+// on failure.
+func saveConfig(s *Session) {
+	msg, err := s.Save()
+	if err != nil {
+		fatal("Configuration NOT saved: %v", err)
+	}
+	if !s.dryRun {
+		fmt.Fprintf(os.Stderr, "Saved: %s\n", msg)
+	}
+}
 
+// globalFlags handles flags valid before or after any subcommand and
+// returns args without them.
+func globalFlags(args []string) []string {
+	plain = os.Getenv("ZYXEL_PLAIN") != ""
+	rest := make([]string, 0, len(args))
+	for _, a := range args {
+		switch a {
+		case "--plain", "-plain":
+			plain = true
 		default:
-			if time.Since(lastRead) > 500*time.Millisecond && output.Len() > 0 {
-				break readLoop
-			}
-			time.Sleep(10 * time.Millisecond)
+			rest = append(rest, a)
 		}
 	}
+	return rest
+}
+
+// stringList is a flag that may be given several times.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, "; ") }
 
-	close(done)
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// connFlags are the flags that override connection settings from the
+// environment.
+type connFlags struct {
+	host         string
+	user         string
+	passwordFile string
+	port         string
+	transport    string
+	profile      string
+	termWidth    int
+	termHeight   int
+	wrapWidth    int
+}
 
-	// Clean and print output
-	result := output.String()
-	lines := strings.Split(result, "\n")
+func addConnFlags(fs *flag.FlagSet) *connFlags {
+	cf := &connFlags{}
+	fs.StringVar(&cf.host, "host", "", tr("Switch address (default: ZYXEL_HOST)"))
+	fs.StringVar(&cf.user, "user", "", tr("Login user (default: ZYXEL_USER)"))
+	fs.StringVar(&cf.passwordFile, "password-file", "", tr("Read the password from `file` (default: ZYXEL_PASSWORD)"))
+	fs.StringVar(&cf.port, "port", "", tr("Port to connect to (default: ZYXEL_PORT or the transport's port)"))
+	fs.StringVar(&cf.transport, "transport", "", tr("Connection type: ssh, telnet, http or https (default: ZYXEL_TRANSPORT or ssh)"))
+	fs.IntVar(&cf.termWidth, "term-width", 0, tr("Terminal width announced to the switch (default: ZYXEL_TERM_WIDTH or 200)"))
+	fs.IntVar(&cf.termHeight, "term-height", 0, tr("Terminal height announced to the switch (default: ZYXEL_TERM_HEIGHT or 80)"))
+	fs.IntVar(&cf.wrapWidth, "wrap-width", 0, tr("Rejoin output lines the switch wrapped at this many columns (default: ZYXEL_WRAP_WIDTH)"))
+	fs.StringVar(&cf.profile, "profile", os.Getenv("ZYXEL_PROFILE"), tr("Use the connection settings of this profile from the config file"))
+	return cf
+}
 
-	if len(lines) > 2 {
-		lines = lines[1 : len(lines)-1]
+func (cf *connFlags) apply(cfg *Config) error {
+	if cf.profile != "" {
+		p, err := loadProfile(cf.profile)
+		if err != nil {
+			return err
+		}
+		if err := p.apply(cfg); err != nil {
+			return err
+		}
+	}
+	if cf.host != "" {
+		cfg.Host = cf.host
+	}
+	if cf.user != "" {
+		cfg.User = cf.user
+	}
+	if cf.passwordFile != "" {
+		data, err := os.ReadFile(cf.passwordFile)
+		if err != nil {
+			return err
+		}
+		cfg.Password = strings.TrimRight(string(data), "\r\n")
+	}
+	if cf.port != "" {
+		cfg.Port = cf.port
 	}
+	if cf.transport != "" {
+		cfg.Transport = cf.transport
+	}
+	if cf.termWidth > 0 {
+		cfg.TermWidth = cf.termWidth
+	}
+	if cf.termHeight > 0 {
+		cfg.TermHeight = cf.termHeight
+	}
+	if cf.wrapWidth > 0 {
+		cfg.WrapWidth = cf.wrapWidth
+	}
+	return nil
+}
+
+// config loads and validates the connection settings, exiting on failure.
+func (cf *connFlags) config() Config {
+	cfg, err := cf.load()
+	if err != nil {
+		fatal("%v", err)
+	}
+	return cfg
+}
 
-	for _, line := range lines {
-		line = strings.TrimRight(line, "\r")
-		if line != "" {
-			fmt.Println(line)
+// load is config returning the failure, for checks that must report it
+// in a format of their own.
+func (cf *connFlags) load() (Config, error) {
+	cfg := envConfig()
+	if err := cf.apply(&cfg); err != nil {
+		return cfg, err
+	}
+	if err := vaultLookup(&cfg); err != nil {
+		return cfg, err
+	}
+	keychainLookup(&cfg)
+	if cfg.Password == "" && cfg.needsPassword() && cfg.Host != "" && cfg.User != "" {
+		pw, err := readPassword(fmt.Sprintf(tr("Password for %s@%s: "), cfg.User, cfg.Host))
+		if err != nil {
+			return cfg, err
 		}
+		cfg.Password = pw
 	}
+	return cfg, cfg.validate()
+}
 
-	fmt.Fprintf(stdin, "exit\n")
+// connect loads the connection settings and opens a session, exiting on
+// failure.
+func (cf *connFlags) connect() (Config, *Session) {
+	cfg := cf.config()
+	s, err := Dial(cfg)
+	if err != nil {
+		fatal("%v", err)
+	}
+	return cfg, s
 }
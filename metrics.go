@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// toolMetrics count what the tool itself does, so the automation layer
+// can be monitored alongside the switches.
+var toolMetrics struct {
+	sessionsOpen  atomic.Int64
+	commands      atomic.Int64
+	parseFailures atomic.Int64
+	timeouts      atomic.Int64
+}
+
+// breaker stops polling a host after repeated failures, so one dead
+// switch does not slow down every scrape. After the cooldown it lets a
+// single attempt through (half-open) and closes again on success.
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  map[string]int
+	openUntil map[string]time.Time
+}
+
+const (
+	breakerClosed = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{
+		threshold: max(threshold, 1),
+		cooldown:  cooldown,
+		failures:  make(map[string]int),
+		openUntil: make(map[string]time.Time),
+	}
+}
+
+// state returns breakerClosed, breakerOpen or breakerHalfOpen for host.
+func (b *breaker) state(host string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	until, tripped := b.openUntil[host]
+	switch {
+	case !tripped:
+		return breakerClosed
+	case time.Now().Before(until):
+		return breakerOpen
+	default:
+		return breakerHalfOpen
+	}
+}
+
+// allow reports whether host may be polled now.
+func (b *breaker) allow(host string) bool {
+	return b.state(host) != breakerOpen
+}
+
+// record notes the outcome of polling host.
+func (b *breaker) record(host string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		delete(b.failures, host)
+		delete(b.openUntil, host)
+		return
+	}
+	b.failures[host]++
+	if b.failures[host] >= b.threshold {
+		b.openUntil[host] = time.Now().Add(b.cooldown)
+	}
+}
+
+// metricWriter collects samples in the Prometheus text exposition format.
+// Samples are grouped by metric name, as the format requires, in the order
+// the names first appear.
+type metricWriter struct {
+	names   []string
+	headers map[string]string
+	samples map[string][]string
+}
+
+func newMetricWriter() *metricWriter {
+	return &metricWriter{headers: make(map[string]string), samples: make(map[string][]string)}
+}
+
+// metric adds one sample. labels alternate name and value.
+func (m *metricWriter) metric(name, typ, help string, value float64, labels ...string) {
+	if _, ok := m.headers[name]; !ok {
+		m.names = append(m.names, name)
+		m.headers[name] = fmt.Sprintf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+	var pairs []string
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", labels[i], labels[i+1]))
+	}
+	sample := fmt.Sprintf("%s %g\n", name, value)
+	if len(pairs) > 0 {
+		sample = fmt.Sprintf("%s{%s} %g\n", name, strings.Join(pairs, ","), value)
+	}
+	m.samples[name] = append(m.samples[name], sample)
+}
+
+// writeTo writes all collected metrics to w.
+func (m *metricWriter) writeTo(w io.Writer) {
+	for _, name := range m.names {
+		io.WriteString(w, m.headers[name])
+		for _, sample := range m.samples[name] {
+			io.WriteString(w, sample)
+		}
+	}
+}
+
+// writeToolMetrics writes the internal counters and the breaker state of
+// every host.
+func writeToolMetrics(m *metricWriter, b *breaker, hosts []string) {
+	m.metric("zyxel_sessions_open", "gauge", "Switch sessions currently open.", float64(toolMetrics.sessionsOpen.Load()))
+	m.metric("zyxel_commands_total", "counter", "Commands sent to switches.", float64(toolMetrics.commands.Load()))
+	m.metric("zyxel_parse_failures_total", "counter", "Command outputs that could not be parsed.", float64(toolMetrics.parseFailures.Load()))
+	m.metric("zyxel_timeouts_total", "counter", "Waits for switch output that timed out.", float64(toolMetrics.timeouts.Load()))
+
+	sorted := append([]string(nil), hosts...)
+	sort.Strings(sorted)
+	for _, h := range sorted {
+		m.metric("zyxel_breaker_state", "gauge", "Circuit breaker per host: 0 closed, 1 open, 2 half-open.", float64(b.state(h)), "host", h)
+	}
+}
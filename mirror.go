@@ -0,0 +1,266 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// mirrorState is the port mirroring set up in a running-config: the
+// destination (monitor) port and the mirrored ports with their direction.
+type mirrorState struct {
+	Dest    string
+	Sources map[Port]string
+}
+
+// parseMirror reads the mirroring from a running-config: the global
+// "mirror-port <port>" and "mirror" with an optional "mirror dir
+// ingress|egress|both" in the blocks of the mirrored ports, or the
+// commands cmd gives for them.
+func parseMirror(rc *RunningConfig, cmd func(operation string) string) mirrorState {
+	st := mirrorState{Sources: make(map[Port]string)}
+	for _, c := range rc.Global {
+		if dest, ok := strings.CutPrefix(c, cmd("mirror-port")+" "); ok {
+			st.Dest = strings.TrimSpace(dest)
+		}
+	}
+	for p, commands := range rc.Ports {
+		for _, c := range commands {
+			switch c = strings.TrimSpace(c); {
+			case c == cmd("mirror"):
+				if st.Sources[p] == "" {
+					st.Sources[p] = "both"
+				}
+			case strings.HasPrefix(c, cmd("mirror-direction")+" "):
+				st.Sources[p] = strings.TrimSpace(strings.TrimPrefix(c, cmd("mirror-direction")))
+			}
+		}
+	}
+	return st
+}
+
+// sources returns the mirrored ports in order.
+func (st mirrorState) sources() []Port {
+	var ports []Port
+	for p := range st.Sources {
+		ports = append(ports, p)
+	}
+	sort.Slice(ports, func(i, j int) bool { return ports[i].less(ports[j]) })
+	return ports
+}
+
+// mirrorStartCommands mirror the traffic of src, a port list in the
+// notation of the switch, in direction dir to the port dst, in the syntax
+// cmd gives.
+func mirrorStartCommands(cmd func(operation string) string, src, dst, dir string) []string {
+	return []string{
+		cmd("mirror-port") + " " + dst,
+		cmd("port-config") + " " + src,
+		cmd("mirror"),
+		cmd("mirror-direction") + " " + dir,
+		"exit",
+	}
+}
+
+// mirrorStopCommands remove the mirroring of st.
+func mirrorStopCommands(cmd func(operation string) string, st mirrorState, d Dialect) ([]string, error) {
+	var commands []string
+	if src := st.sources(); len(src) > 0 {
+		list, err := FormatPortList(src, d)
+		if err != nil {
+			return nil, err
+		}
+		commands = append(commands, cmd("port-config")+" "+list, "no "+cmd("mirror"), "exit")
+	}
+	if st.Dest != "" {
+		commands = append(commands, "no "+cmd("mirror-port"))
+	}
+	return commands, nil
+}
+
+var mirrorCommandList = []subcommand{
+	{"start", "Mirror the traffic of ports to a monitor port", runMirrorStart, "nothing; status goes to stderr", nil},
+	{"stop", "Remove all port mirroring", runMirrorStop, "nothing; status goes to stderr", nil},
+	{"status", "Show the monitor port and the mirrored ports", runMirrorStatus,
+		"\"Destination: <port>\" then one \"<port> <direction>\" line per mirrored port; \"Mirroring is off\" if none", nil},
+}
+
+func runMirror(fs *flag.FlagSet) func() {
+	return func() {
+		args := fs.Args()
+		if len(args) == 0 {
+			fmt.Println("Usage: zyxel mirror <command> [flags]")
+			fmt.Println()
+			fmt.Println("Commands:")
+			for _, c := range mirrorCommandList {
+				fmt.Printf("  %-10s %s\n", c.name, c.summary)
+			}
+			os.Exit(1)
+		}
+		for _, c := range mirrorCommandList {
+			if c.name == args[0] {
+				c.invoke("mirror "+c.name, args[1:])
+				return
+			}
+		}
+		fatal("Unknown mirror command %q", args[0])
+	}
+}
+
+func runMirrorStart(fs *flag.FlagSet) func() {
+	cf := addConnFlags(fs)
+	src := fs.String("src", "", tr("Ports whose traffic to mirror"))
+	dst := fs.String("dst", "", tr("Monitor `port` the capture is plugged into"))
+	dir := fs.String("dir", "both", tr("Direction to mirror: ingress, egress or both"))
+	allowUplink := fs.Bool("allow-uplink", false, tr("Use a monitor port even if it looks like an uplink"))
+	save := fs.Bool("save", false, tr("Write memory after the change is verified"))
+	dryRun := addDryRunFlag(fs)
+	return func() {
+		if *src == "" || *dst == "" {
+			fatal("Usage: zyxel mirror start --src <ports> --dst <port>")
+		}
+		if *dir != "ingress" && *dir != "egress" && *dir != "both" {
+			fatal("--dir must be ingress, egress or both, not %q", *dir)
+		}
+		srcPorts, err := ParsePortList(*src)
+		if err != nil {
+			fatal("%v", err)
+		}
+		dstPorts, err := ParsePortList(*dst)
+		if err != nil {
+			fatal("%v", err)
+		}
+		if len(dstPorts) != 1 {
+			fatal("--dst must be a single port")
+		}
+		if slices.Contains(srcPorts, dstPorts[0]) {
+			fatal("Port %s cannot mirror to itself", dstPorts[0])
+		}
+
+		_, s := cf.connect()
+		defer s.Close()
+		s.dryRun = *dryRun
+		if err := s.checkConfigSyntax(); err != nil {
+			fatal("%v", err)
+		}
+		// The monitor port stops forwarding its own traffic.
+		if err := guardUplinks(s, dstPorts, *allowUplink); err != nil {
+			fatal("%v", err)
+		}
+		d, err := s.portDialect()
+		if err != nil {
+			fatal("%v", err)
+		}
+		srcList, err := FormatPortList(srcPorts, d)
+		if err != nil {
+			fatal("%v", err)
+		}
+		dstPort, err := dstPorts[0].normalize(d)
+		if err != nil {
+			fatal("%v", err)
+		}
+		if err := s.Configure(mirrorStartCommands(s.command, srcList, dstPort.String(), *dir), io.Discard); err != nil {
+			fatal("%v", err)
+		}
+		if s.dryRun {
+			if *save {
+				saveConfig(s)
+			}
+			return
+		}
+
+		rc, err := runningConfig(s)
+		if err != nil {
+			fatal("%v", err)
+		}
+		st := parseMirror(rc, s.command)
+		if st.Dest != dstPort.String() {
+			fatal("The monitor port is %q in the running-config, not %s", st.Dest, dstPort)
+		}
+		for _, p := range srcPorts {
+			np, _ := p.normalize(d)
+			if _, ok := st.Sources[np]; !ok {
+				fatal("Port %s is not mirrored in the running-config", p)
+			}
+		}
+		fmt.Fprintf(os.Stderr, "Mirroring port %s (%s) to port %s\n", srcList, *dir, dstPort)
+		if *save {
+			saveConfig(s)
+		}
+	}
+}
+
+func runMirrorStop(fs *flag.FlagSet) func() {
+	cf := addConnFlags(fs)
+	save := fs.Bool("save", false, tr("Write memory after the change is verified"))
+	dryRun := addDryRunFlag(fs)
+	return func() {
+		_, s := cf.connect()
+		defer s.Close()
+		s.dryRun = *dryRun
+		if err := s.checkConfigSyntax(); err != nil {
+			fatal("%v", err)
+		}
+		rc, err := runningConfig(s)
+		if err != nil {
+			fatal("%v", err)
+		}
+		d, err := s.portDialect()
+		if err != nil {
+			fatal("%v", err)
+		}
+		commands, err := mirrorStopCommands(s.command, parseMirror(rc, s.command), d)
+		if err != nil {
+			fatal("%v", err)
+		}
+		if len(commands) == 0 {
+			fmt.Fprintln(os.Stderr, "Mirroring is off")
+			return
+		}
+		if err := s.Configure(commands, io.Discard); err != nil {
+			fatal("%v", err)
+		}
+		if s.dryRun {
+			if *save {
+				saveConfig(s)
+			}
+			return
+		}
+
+		if rc, err = runningConfig(s); err != nil {
+			fatal("%v", err)
+		}
+		if st := parseMirror(rc, s.command); st.Dest != "" || len(st.Sources) > 0 {
+			fatal("Mirroring is still set up in the running-config")
+		}
+		fmt.Fprintln(os.Stderr, "Mirroring stopped")
+		if *save {
+			saveConfig(s)
+		}
+	}
+}
+
+func runMirrorStatus(fs *flag.FlagSet) func() {
+	cf := addConnFlags(fs)
+	return func() {
+		_, s := cf.connect()
+		defer s.Close()
+		rc, err := runningConfig(s)
+		if err != nil {
+			fatal("%v", err)
+		}
+		st := parseMirror(rc, s.command)
+		if st.Dest == "" && len(st.Sources) == 0 {
+			fmt.Println("Mirroring is off")
+			return
+		}
+		fmt.Printf("Destination: %s\n", st.Dest)
+		for _, p := range st.sources() {
+			fmt.Printf("%s %s\n", p, st.Sources[p])
+		}
+	}
+}
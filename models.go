@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+// switchModel is how a family of switches differs in driving its CLI. The
+// GS1920, the XGS2210 and the GS2220 run different firmware lines, and
+// what works on one breaks somewhere on another.
+type switchModel struct {
+	// family is matched against the start of the model name, so "XGS2210"
+	// covers the XGS2210-28HP and the XGS2210-52.
+	family string
+	// pager lists the commands that turn paging off, tried in turn until
+	// the switch accepts one.
+	pager []string
+	// modes matches the mode between the parentheses of a prompt, such as
+	// "config" or "config-vlan".
+	modes string
+	// cidr is set when classifiers take "10.0.0.0/24" instead of
+	// "10.0.0.0 mask-bits 24".
+	cidr bool
+	// commands are the CLI commands of operations whose syntax differs
+	// from defaultCommands on the model.
+	commands map[string]string
+	// configUnknown is set when the configuration syntax of the model
+	// differs from defaultCommands in ways not mapped here; changes are
+	// then refused unless the profile names the commands.
+	configUnknown bool
+}
+
+// defaultCommands maps the operations of the subcommands to the CLI
+// command that performs them on most models. Commands taking ports get
+// them appended after a space.
+var defaultCommands = map[string]string{
+	"arp":                "show ip arp",
+	"cable-diagnostics":  "cable-diagnostics",
+	"clock":              "show time",
+	"cpu":                "show cpu-utilization",
+	"dhcp-snooping":      "show dhcp snooping binding",
+	"dot1x":              "show port-access-authenticator",
+	"hardware-monitor":   "show hardware-monitor C",
+	"igmp-groups":        "show igmp-snooping group all",
+	"interfaces":         "show interfaces",
+	"lldp-neighbors":     "show lldp info remote",
+	"mac-table":          "show mac address-table all",
+	"memory":             "show memory",
+	"poe":                "show poe-status",
+	"running-config":     "show running-config",
+	"save":               "write memory",
+	"spanning-tree":      "show spanning-tree config",
+	"system-information": "show system-information",
+	"transceivers":       "show interfaces transceiver",
+	"trunks":             "show trunk",
+	"version":            "show version",
+	"vlans":              "show vlan",
+
+	// The configuration commands of the port, vlan, poe and mirror
+	// subcommands, without their arguments; "no" and the command undoes
+	// one.
+	"mirror":           "mirror",
+	"mirror-direction": "mirror dir",
+	"mirror-port":      "mirror-port",
+	"poe-power":        "pwr",
+	"port-config":      "interface port-channel",
+	"port-inactive":    "inactive",
+	"port-name":        "name",
+	"pvid":             "pvid",
+	"vlan-config":      "vlan",
+	"vlan-fixed":       "fixed",
+	"vlan-name":        "name",
+	"vlan-untagged":    "untagged",
+}
+
+// configOperations are the operations of defaultCommands that change the
+// configuration.
+var configOperations = []string{
+	"mirror", "mirror-direction", "mirror-port", "poe-power", "port-config", "port-inactive",
+	"port-name", "pvid", "vlan-config", "vlan-fixed", "vlan-name", "vlan-untagged",
+}
+
+// defaultModes is the prompt mode of most firmware.
+const defaultModes = `[\w\-/]*`
+
+// switchModels are the families with known differences. Switches not
+// listed get genericModel.
+var switchModels = []switchModel{
+	// The XGS2220 and GS2220 firmware writes interface modes such as
+	// "config-interface-ge 1/0/1" and prefixes in classifiers, lists the
+	// whole MAC table without "all" and saves with "copy". Its interface
+	// and VLAN configuration is entered differently too.
+	{family: "XGS2220", pager: []string{"terminal length 0", "terminal pager disable"}, modes: `[\w\-/. ]*`, cidr: true,
+		commands: gs2220Commands, configUnknown: true},
+	{family: "GS2220", pager: []string{"terminal length 0", "terminal pager disable"}, modes: `[\w\-/. ]*`, cidr: true,
+		commands: gs2220Commands, configUnknown: true},
+	// The XS1930 and XGS1930 have no "write memory".
+	{family: "XGS1930", pager: []string{"terminal length 0"}, modes: defaultModes, commands: xs1930Commands},
+	{family: "XS1930", pager: []string{"terminal length 0"}, modes: defaultModes, commands: xs1930Commands},
+	// The GS2210 line accepts "no terminal more" where older releases of
+	// it reject "terminal length".
+	{family: "XGS2210", pager: []string{"terminal length 0", "no terminal more"}, modes: defaultModes},
+	{family: "GS2210", pager: []string{"terminal length 0", "no terminal more"}, modes: defaultModes},
+	{family: "GS1920", pager: []string{"terminal length 0"}, modes: defaultModes},
+}
+
+var (
+	gs2220Commands = map[string]string{
+		"mac-table": "show mac address-table",
+		"save":      "copy running-config startup-config",
+	}
+	xs1930Commands = map[string]string{
+		"save": "copy running-config startup-config",
+	}
+)
+
+var genericModel = switchModel{family: "generic", pager: []string{defaultPagerCommand}, modes: defaultModes}
+
+// modelName finds a Zyxel model name, e.g. "GS1920-24HPv2", in a login
+// banner.
+var modelName = regexp.MustCompile(`\b(X?[GM]?S\d{4}[\w-]*)`)
+
+// lookupModel returns the entry for a model name such as "XGS2210-28HP"
+// or a family such as "gs2220", and false for unknown ones.
+func lookupModel(name string) (switchModel, bool) {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	for _, m := range switchModels {
+		if strings.HasPrefix(name, m.family) {
+			return m, true
+		}
+	}
+	return genericModel, false
+}
+
+// detectModel sets the model of the session from the login banner, or
+// when it names none, from "show system-information". Config.Model skips
+// detection; "generic" drives the switch without model knowledge.
+func (s *Session) detectModel(ctx context.Context, cfg Config) {
+	s.model = genericModel
+	switch {
+	case cfg.Model == "generic":
+		return
+	case cfg.Model != "":
+		s.model, _ = lookupModel(cfg.Model)
+		s.modelName = cfg.Model
+		return
+	}
+	// The prompt is left out: the hostname defaults to the model name,
+	// and a renamed switch may carry that of another model.
+	banner := s.banner[:strings.LastIndex(s.banner, "\n")+1]
+	if m := modelName.FindString(banner); m != "" {
+		if model, ok := lookupModel(m); ok {
+			s.model, s.modelName = model, m
+			// The banner does not tell the firmware.
+			if out, err := s.OutputContext(ctx, s.command("version")); err == nil && !looksLikeError(out) {
+				s.firmware = parseSystemInfo(out).Firmware
+			}
+			return
+		}
+	}
+	out, err := s.OutputContext(ctx, s.command("system-information"))
+	if err != nil || looksLikeError(out) {
+		return
+	}
+	si := parseSystemInfo(out)
+	s.model, _ = lookupModel(si.Model)
+	s.modelName, s.firmware = si.Model, si.Firmware
+}
+
+// Model returns the model name of the switch, e.g. "GS1920-24HPv2", and
+// its firmware version from "show system-information" or "show version".
+// Either is empty when the switch did not tell.
+func (s *Session) Model() (name, firmware string) {
+	return s.modelName, s.firmware
+}
+
+// command returns the CLI command of operation on the model of the
+// session, such as "show mac address-table all" for "mac-table".
+// Config.Commands overrides it for models the table does not know.
+func (s *Session) command(operation string) string {
+	if c, ok := s.cfg.Commands[operation]; ok {
+		return c
+	}
+	if c, ok := s.model.commands[operation]; ok {
+		return c
+	}
+	return defaultCommands[operation]
+}
+
+// checkConfigSyntax tells whether command knows the configuration
+// commands of the port, vlan, poe and mirror subcommands on the switch. On
+// a model whose syntax is not mapped it fails unless Config.Commands names
+// all of them, rather than let the commands of another firmware line be
+// sent.
+func (s *Session) checkConfigSyntax() error {
+	if s.model.configUnknown {
+		var missing []string
+		for _, op := range configOperations {
+			if _, ok := s.cfg.Commands[op]; !ok {
+				missing = append(missing, op)
+			}
+		}
+		if len(missing) > 0 {
+			return errorf("the configuration commands of the %s are not known; name %s under commands in its profile", s.modelName, strings.Join(missing, ", "))
+		}
+	}
+	return nil
+}
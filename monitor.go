@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Event types reported by monitor.
+const (
+	eventLink    = "link"
+	eventPoE     = "poe"
+	eventMACMove = "mac-move"
+	// eventErrors is posted by "audit errors", not monitor.
+	eventErrors = "errors"
+)
+
+var eventTypes = []string{eventLink, eventPoE, eventMACMove}
+
+// webhookEvents are the event types a webhook can take.
+var webhookEvents = append(slices.Clip(eventTypes), eventErrors)
+
+// Webhook is a URL that monitor posts events to.
+type Webhook struct {
+	URL string `yaml:"url"`
+	// Events limits the webhook to these event types; empty means all.
+	Events []string `yaml:"events"`
+}
+
+func (w Webhook) wants(eventType string) bool {
+	return len(w.Events) == 0 || slices.Contains(w.Events, eventType)
+}
+
+// event is a state change seen by monitor, and the JSON payload of its
+// webhooks.
+type event struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	Host    string    `json:"host"`
+	Port    string    `json:"port"`
+	Message string    `json:"message"`
+	// State is "up" or "down" for link events.
+	State string `json:"state,omitempty"`
+	// PowerW and MaxW are the draw and limit of a port over its budget.
+	PowerW float64 `json:"power_w,omitempty"`
+	MaxW   float64 `json:"max_w,omitempty"`
+	// MAC, VLAN and the From fields describe where a MAC moved from; Host
+	// and Port are where it is now.
+	MAC      string `json:"mac,omitempty"`
+	VLAN     int    `json:"vlan,omitempty"`
+	FromHost string `json:"from_host,omitempty"`
+	FromPort string `json:"from_port,omitempty"`
+	// Counters are the error counters that increased, by how much.
+	Counters map[string]uint64 `json:"counters,omitempty"`
+}
+
+// monitorPoll is what one poll read from a switch.
+type monitorPoll struct {
+	ifaces []Interface
+	// poe is nil on switches without PoE.
+	poe  *PoEStatus
+	macs []macSighting
+	// snap is stored in the history when it is enabled.
+	snap snapshot
+}
+
+// pollMonitor reads the tables needed for the watched event types.
+func pollMonitor(h Host, s *Session, watch []string, now time.Time) (monitorPoll, error) {
+	var p monitorPoll
+	var err error
+	if slices.Contains(watch, eventLink) {
+		if p.ifaces, err = interfaces(s, "*"); err != nil {
+			return p, err
+		}
+		p.snap.Interfaces = nonNil(p.ifaces)
+	}
+	if slices.Contains(watch, eventPoE) {
+		if st, err := poeStatus(s); err == nil {
+			p.poe = &st
+		}
+	}
+	if slices.Contains(watch, eventMACMove) {
+		entries, err := macTable(s)
+		if err != nil {
+			return p, err
+		}
+		neighbors, err := lldpNeighbors(s)
+		if err != nil {
+			return p, err
+		}
+		p.macs = edgeSightings(h, macData{entries, neighbors}, now)
+		p.snap.MACs = nonNil(entries)
+		p.snap.Uplinks = uplinkNames(h, neighbors)
+	}
+	return p, nil
+}
+
+// monitorState is what earlier polls saw, to tell what changed. Hosts
+// that fail a poll keep their last state, so an outage is not reported as
+// every port going down.
+type monitorState struct {
+	links map[string]bool
+	// overBudget holds the ports already reported over their PoE limit.
+	overBudget map[string]bool
+	// macs is the last place each MAC was seen, by MAC and VLAN.
+	macs map[string]macSighting
+}
+
+func newMonitorState() *monitorState {
+	return &monitorState{
+		links:      make(map[string]bool),
+		overBudget: make(map[string]bool),
+		macs:       make(map[string]macSighting),
+	}
+}
+
+// update records a poll and returns the changes since the last one; the
+// first poll of a port or MAC only sets its baseline.
+func (st *monitorState) update(results []fleetResult[monitorPoll], now time.Time) []event {
+	var events []event
+	for _, r := range results {
+		if r.Err != nil {
+			continue
+		}
+		host := r.Host.Name
+		for _, i := range r.Value.ifaces {
+			key := host + "/" + i.Port
+			up, seen := st.links[key]
+			st.links[key] = i.LinkUp()
+			if !seen || up == i.LinkUp() {
+				continue
+			}
+			state := "down"
+			if i.LinkUp() {
+				state = "up"
+			}
+			events = append(events, event{Time: now, Type: eventLink, Host: host, Port: i.Port, State: state,
+				Message: fmt.Sprintf("%s port %s went %s", host, i.Port, state)})
+		}
+		if poe := r.Value.poe; poe != nil {
+			for _, p := range poe.Ports {
+				key := host + "/" + p.Port.String()
+				over := p.MaxW > 0 && p.PowerW > p.MaxW
+				if over && !st.overBudget[key] {
+					events = append(events, event{Time: now, Type: eventPoE, Host: host, Port: p.Port.String(), PowerW: p.PowerW, MaxW: p.MaxW,
+						Message: fmt.Sprintf("%s port %s draws %.1f W, over its %.1f W limit", host, p.Port, p.PowerW, p.MaxW)})
+				}
+				st.overBudget[key] = over
+			}
+		}
+	}
+	for _, r := range results {
+		for _, m := range r.Value.macs {
+			key := fmt.Sprintf("%s/%d", m.MAC, m.VLAN)
+			prev, seen := st.macs[key]
+			st.macs[key] = m
+			if !seen || prev.Host == m.Host && prev.Port == m.Port {
+				continue
+			}
+			events = append(events, macMove{now, m.MAC, m.VLAN, prev.Host, prev.Port, m.Host, m.Port}.event())
+		}
+	}
+	return events
+}
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// postWebhook sends ev to the webhook as JSON.
+func postWebhook(w Webhook, ev event) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	resp, err := webhookClient.Post(w.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s answered %s", w.URL, resp.Status)
+	}
+	return nil
+}
+
+func runMonitor(fs *flag.FlagSet) func() {
+	ff := addFleetFlags(fs)
+	interval := fs.Duration("interval", time.Minute, tr("Time between polls"))
+	events := fs.String("events", strings.Join(eventTypes, ","), tr("Event types to watch: link, poe and mac-move"))
+	var urls []string
+	fs.Func("webhook", tr("Post every event to this `url` (repeatable; also the inventory's webhooks)"), func(s string) error {
+		urls = append(urls, s)
+		return nil
+	})
+	return func() {
+		if *interval <= 0 {
+			fatal("--interval must be positive")
+		}
+		watch := strings.Split(*events, ",")
+		for _, e := range watch {
+			if !slices.Contains(eventTypes, e) {
+				fatal("Unknown event type %q; use link, poe or mac-move", e)
+			}
+		}
+		inv, hosts := ff.load()
+		webhooks := inv.Webhooks
+		for _, w := range webhooks {
+			for _, e := range w.Events {
+				if !slices.Contains(webhookEvents, e) {
+					fatal("Unknown webhook event %q", e)
+				}
+			}
+		}
+		for _, u := range urls {
+			webhooks = append(webhooks, Webhook{URL: u})
+		}
+
+		st := newMonitorState()
+		enc := json.NewEncoder(os.Stdout)
+		fmt.Fprintf(os.Stderr, "Watching %d switches every %s\n", len(hosts), *interval)
+		for {
+			now := time.Now()
+			results := runFleet(hosts, ff, func(h Host, s *Session) (monitorPoll, error) {
+				return pollMonitor(h, s, watch, now)
+			})
+			snaps := make(map[string]snapshot)
+			for _, r := range results {
+				if r.Err != nil {
+					fmt.Fprintf(os.Stderr, "%s: %v\n", r.Host.Name, r.Err)
+					continue
+				}
+				snaps[r.Host.Name] = r.Value.snap
+			}
+			if historyEnabled() {
+				storeSnapshots(now, snaps)
+			}
+			for _, ev := range st.update(results, now) {
+				enc.Encode(ev)
+				for _, w := range webhooks {
+					if !w.wants(ev.Type) {
+						continue
+					}
+					if err := postWebhook(w, ev); err != nil {
+						fmt.Fprintf(os.Stderr, "Warning: webhook: %v\n", err)
+					}
+				}
+			}
+			time.Sleep(time.Until(now.Add(*interval)))
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+)
+
+// frameSize returns the configured maximum frame size of port p, falling
+// back to the global setting. 0 means the switch default.
+func (rc *RunningConfig) frameSize(p Port) int {
+	keys := []string{"frame-size", "mtu", "jumbo-frame"}
+	if v := setting(rc.Ports[p], keys...); v != "" {
+		return atoiOr(v, 0)
+	}
+	return atoiOr(setting(rc.Global, keys...), 0)
+}
+
+func formatFrameSize(n int) string {
+	if n == 0 {
+		return "default"
+	}
+	return fmt.Sprint(n)
+}
+
+type mtuData struct {
+	config    *RunningConfig
+	neighbors []LLDPNeighbor
+}
+
+func runAuditMTU(fs *flag.FlagSet) func() {
+	ff := addFleetFlags(fs)
+	return func() {
+		inv, hosts := ff.load()
+		results := runFleet(hosts, ff, func(h Host, s *Session) (mtuData, error) {
+			rc, err := runningConfig(s)
+			if err != nil {
+				return mtuData{}, err
+			}
+			neighbors, err := lldpNeighbors(s)
+			return mtuData{rc, neighbors}, err
+		})
+
+		reportFindings(results, auditMTU(inv, results))
+	}
+}
+
+// auditMTU compares the frame size on both ends of every LLDP link between
+// inventory hosts. Each link is reported once.
+func auditMTU(inv *Inventory, results []fleetResult[mtuData]) []finding {
+	byName := make(map[string]mtuData)
+	for _, r := range results {
+		if r.Err == nil {
+			byName[r.Host.Name] = r.Value
+		}
+	}
+
+	var findings []finding
+	reported := make(map[string]bool)
+	for _, r := range results {
+		if r.Err != nil {
+			continue
+		}
+		for _, n := range r.Value.neighbors {
+			remote, ok := inv.byNeighbor(n)
+			if !ok {
+				continue
+			}
+			remoteData, ok := byName[remote.Name]
+			if !ok {
+				continue
+			}
+			lp, err1 := parsePort(n.LocalPort)
+			rp, err2 := parsePort(n.PortID)
+			if err1 != nil || err2 != nil {
+				continue
+			}
+
+			a := fmt.Sprintf("%s %s", r.Host.Name, lp)
+			b := fmt.Sprintf("%s %s", remote.Name, rp)
+			pair := []string{a, b}
+			sort.Strings(pair)
+			key := pair[0] + "|" + pair[1]
+			if reported[key] {
+				continue
+			}
+			reported[key] = true
+
+			local := r.Value.config.frameSize(lp)
+			other := remoteData.config.frameSize(rp)
+			if local != other {
+				findings = append(findings, finding{r.Host.Name, fmt.Sprintf(
+					"frame size mismatch on link %s (%s) <-> %s (%s)",
+					a, formatFrameSize(local), b, formatFrameSize(other))})
+			}
+		}
+	}
+	return findings
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// nagiosStates are the Nagios names of the health statuses.
+var nagiosStates = [...]string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+// String formats p as Nagios perfdata: 'label'=value[unit];[warn];[crit].
+func (p perfValue) String() string {
+	label := p.Label
+	if strings.ContainsAny(label, " '=") {
+		label = "'" + strings.ReplaceAll(label, "'", "''") + "'"
+	}
+	threshold := func(v *float64) string {
+		if v == nil {
+			return ""
+		}
+		return fmt.Sprintf("%g", *v)
+	}
+	return strings.TrimRight(fmt.Sprintf("%s=%g%s;%s;%s", label, p.Value, p.Unit, threshold(p.Warn), threshold(p.Crit)), ";")
+}
+
+// worstStatus returns the most severe status of checks.
+func worstStatus(checks []healthCheck) healthStatus {
+	worst := healthOK
+	for _, c := range checks {
+		worst = max(worst, c.Status)
+	}
+	return worst
+}
+
+// printNagios prints checks the way a Nagios plugin does: a status line
+// naming the checks that are not OK (or okText when all are), with the
+// perfdata after "|", then one line per check as long output. It returns
+// the overall status, which is the plugin's exit code.
+func printNagios(service, okText string, checks []healthCheck) healthStatus {
+	worst := worstStatus(checks)
+	var problems []string
+	var perf []string
+	for _, c := range checks {
+		if c.Status != healthOK {
+			problems = append(problems, c.Name+": "+c.Detail)
+		}
+		for _, p := range c.Perf {
+			perf = append(perf, p.String())
+		}
+	}
+	text := okText
+	if len(problems) > 0 {
+		text = strings.Join(problems, "; ")
+	}
+	line := fmt.Sprintf("%s %s - %s", service, nagiosStates[worst], text)
+	if len(perf) > 0 {
+		line += " | " + strings.Join(perf, " ")
+	}
+	fmt.Println(line)
+	for _, c := range checks {
+		fmt.Printf("%s: %s %s\n", c.Name, nagiosStates[c.Status], c.Detail)
+	}
+	return worst
+}
+
+// nagiosUnknown reports that the check could not run and exits with the
+// UNKNOWN code. The error is put on the one status line.
+func nagiosUnknown(service string, err error) {
+	fmt.Printf("%s UNKNOWN - %s\n", service, strings.Join(strings.Fields(err.Error()), " "))
+	os.Exit(int(healthUnknown))
+}
@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestRateChecksZeroThresholds(t *testing.T) {
+	zero, one := 0.0, 1.0
+	rates := []portRate{{Port: "1"}, {Port: "2", Errors: 1}}
+	tests := []struct {
+		name       string
+		warn, crit *float64
+		want       []healthStatus
+		perf       string
+	}{
+		{"crit unset", &one, nil, []healthStatus{healthOK, healthWarn}, "errors_2=1;1"},
+		{"crit 0", &one, &zero, []healthStatus{healthFail, healthFail}, "errors_2=1;1;0"},
+		{"warn 0", &zero, nil, []healthStatus{healthWarn, healthWarn}, "errors_2=1;0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checks := rateChecks(rates, tt.warn, tt.crit)
+			for i, c := range checks {
+				if c.Status != tt.want[i] {
+					t.Errorf("%s: status %s, want %s", c.Name, c.Status, tt.want[i])
+				}
+			}
+			if got := checks[1].Perf[2].String(); got != tt.perf {
+				t.Errorf("perfdata %q, want %q", got, tt.perf)
+			}
+		})
+	}
+}
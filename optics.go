@@ -0,0 +1,298 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ddmValue is one digital diagnostics reading of a transceiver with the
+// alarm and warning thresholds stored in the module; nil thresholds are
+// not reported.
+type ddmValue struct {
+	Value     float64  `json:"value"`
+	HighAlarm *float64 `json:"high_alarm,omitempty"`
+	HighWarn  *float64 `json:"high_warn,omitempty"`
+	LowWarn   *float64 `json:"low_warn,omitempty"`
+	LowAlarm  *float64 `json:"low_alarm,omitempty"`
+}
+
+// Transceiver is the SFP module of a port and its DDM readings; readings
+// the module does not support are nil.
+type Transceiver struct {
+	Port        string    `json:"port"`
+	Vendor      string    `json:"vendor,omitempty"`
+	PartNumber  string    `json:"part_number,omitempty"`
+	Serial      string    `json:"serial,omitempty"`
+	Type        string    `json:"type,omitempty"`
+	Temperature *ddmValue `json:"temperature_c,omitempty"`
+	Voltage     *ddmValue `json:"voltage_v,omitempty"`
+	TXBias      *ddmValue `json:"tx_bias_ma,omitempty"`
+	TXPower     *ddmValue `json:"tx_power_dbm,omitempty"`
+	RXPower     *ddmValue `json:"rx_power_dbm,omitempty"`
+}
+
+// reading returns the field of t for a DDM row name such as
+// "Temperature(C)", "TX Power (dBm)" or "Rx Power", or nil.
+func (t *Transceiver) reading(name string) **ddmValue {
+	n := strings.ToLower(strings.Join(strings.FieldsFunc(name, func(r rune) bool {
+		return !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z')
+	}), ""))
+	switch {
+	case strings.HasPrefix(n, "temp"):
+		return &t.Temperature
+	case strings.HasPrefix(n, "volt"), strings.HasPrefix(n, "vcc"):
+		return &t.Voltage
+	case strings.HasPrefix(n, "txbias"), strings.HasPrefix(n, "bias"):
+		return &t.TXBias
+	case strings.HasPrefix(n, "txpow"), strings.HasPrefix(n, "txoutput"):
+		return &t.TXPower
+	case strings.HasPrefix(n, "rxpow"), strings.HasPrefix(n, "rxinput"):
+		return &t.RXPower
+	}
+	return nil
+}
+
+var ddmNumber = regexp.MustCompile(`-?\d+(?:\.\d+)?`)
+
+// parseDDMValue parses the numbers after a reading's name: the current
+// value, then the high alarm, high warning, low warning and low alarm
+// thresholds when the switch shows them. "N/A" and "--" parse to nothing.
+func parseDDMValue(s string) *ddmValue {
+	nums := ddmNumber.FindAllString(s, -1)
+	if len(nums) == 0 {
+		return nil
+	}
+	f := make([]float64, len(nums))
+	for i, n := range nums {
+		f[i], _ = strconv.ParseFloat(n, 64)
+	}
+	v := &ddmValue{Value: f[0]}
+	if len(f) >= 5 {
+		v.HighAlarm, v.HighWarn, v.LowWarn, v.LowAlarm = &f[1], &f[2], &f[3], &f[4]
+	}
+	return v
+}
+
+// parseTransceivers parses "show interfaces transceiver": a block per port
+// starting with "Port : n", with "Key : value" lines for the module and
+// its readings, or a table of the readings with one row per name.
+func parseTransceivers(output string) []Transceiver {
+	var all []Transceiver
+	var t *Transceiver
+	for _, line := range strings.Split(strings.ReplaceAll(output, "\r", ""), "\n") {
+		key, value, ok := splitKeyValue(line)
+		if ok && strings.EqualFold(key, "port") {
+			all = append(all, Transceiver{Port: value})
+			t = &all[len(all)-1]
+			continue
+		}
+		if t == nil {
+			continue
+		}
+		if !ok {
+			// A table row: the name, then the numbers.
+			i := strings.IndexFunc(line, func(r rune) bool { return r == '-' || '0' <= r && r <= '9' })
+			if i <= 0 {
+				continue
+			}
+			key, value = strings.TrimSpace(line[:i]), line[i:]
+			// The unit in parentheses belongs to the name.
+			if j := strings.IndexByte(value, ')'); j >= 0 && strings.Contains(key, "(") && !strings.Contains(key, ")") {
+				key, value = key+value[:j+1], value[j+1:]
+			}
+		}
+		lk := strings.ToLower(key)
+		switch {
+		case lk == "vendor", lk == "vendor name":
+			t.Vendor = value
+		case strings.Contains(lk, "part"), lk == "vendor pn":
+			t.PartNumber = value
+		case strings.Contains(lk, "serial"), lk == "vendor sn":
+			t.Serial = value
+		case lk == "transceiver", lk == "type", strings.Contains(lk, "transceiver type"):
+			t.Type = value
+		default:
+			if r := t.reading(key); r != nil {
+				*r = parseDDMValue(value)
+			}
+		}
+	}
+
+	// Ports without a module list nothing but their number.
+	found := all[:0]
+	for _, t := range all {
+		if t.Vendor != "" || t.Serial != "" || t.RXPower != nil || t.Temperature != nil {
+			found = append(found, t)
+		}
+	}
+	return found
+}
+
+// opticsLimits are the thresholds for readings whose module does not
+// store its own.
+type opticsLimits struct {
+	tempWarn, tempCrit float64
+	rxWarn, rxCrit     float64
+}
+
+// rate returns the status of a reading and a note when it is not OK. The
+// module's thresholds win; otherwise upper (temperature) or lower (RX
+// power) limits from the flags apply when given.
+func (v *ddmValue) rate(upperWarn, upperCrit, lowerWarn, lowerCrit *float64) (healthStatus, string) {
+	if v == nil {
+		return healthOK, ""
+	}
+	if v.HighAlarm != nil {
+		upperWarn, upperCrit, lowerWarn, lowerCrit = v.HighWarn, v.HighAlarm, v.LowWarn, v.LowAlarm
+	}
+	switch {
+	case upperCrit != nil && v.Value >= *upperCrit:
+		return healthFail, fmt.Sprintf("above alarm %g", *upperCrit)
+	case lowerCrit != nil && v.Value <= *lowerCrit:
+		return healthFail, fmt.Sprintf("below alarm %g", *lowerCrit)
+	case upperWarn != nil && v.Value >= *upperWarn:
+		return healthWarn, fmt.Sprintf("above warning %g", *upperWarn)
+	case lowerWarn != nil && v.Value <= *lowerWarn:
+		return healthWarn, fmt.Sprintf("below warning %g", *lowerWarn)
+	}
+	return healthOK, ""
+}
+
+// check rates the readings of t as a health check named after its port.
+func (t Transceiver) check(lim opticsLimits) healthCheck {
+	c := healthCheck{Name: "Port " + t.Port}
+	var parts []string
+	if id := strings.TrimSpace(strings.Join([]string{t.Vendor, t.PartNumber}, " ")); id != "" {
+		parts = append(parts, id)
+	}
+	if t.Serial != "" {
+		parts = append(parts, "S/N "+t.Serial)
+	}
+	for _, r := range []struct {
+		v                    *ddmValue
+		prefix, label, unit  string
+		upperWarn, upperCrit *float64
+		lowerWarn, lowerCrit *float64
+	}{
+		{t.Temperature, "", "temp", "C", &lim.tempWarn, &lim.tempCrit, nil, nil},
+		{t.Voltage, "", "voltage", "V", nil, nil, nil, nil},
+		{t.TXBias, "bias ", "tx_bias", "mA", nil, nil, nil, nil},
+		{t.TXPower, "TX ", "tx_power", "dBm", nil, nil, nil, nil},
+		{t.RXPower, "RX ", "rx_power", "dBm", nil, nil, &lim.rxWarn, &lim.rxCrit},
+	} {
+		if r.v == nil {
+			continue
+		}
+		st, note := r.v.rate(r.upperWarn, r.upperCrit, r.lowerWarn, r.lowerCrit)
+		c.Status = max(c.Status, st)
+		part := fmt.Sprintf("%s%g %s", r.prefix, r.v.Value, r.unit)
+		if note != "" {
+			part += " (" + note + ")"
+		}
+		parts = append(parts, part)
+		// Nagios thresholds are upper limits only.
+		pv := perfValue{Label: t.Port + "_" + r.label, Value: r.v.Value}
+		if r.label == "temp" {
+			pv.Unit = "C"
+			if r.v.HighAlarm != nil {
+				pv.Warn, pv.Crit = r.v.HighWarn, r.v.HighAlarm
+			} else {
+				pv.Warn, pv.Crit = &lim.tempWarn, &lim.tempCrit
+			}
+		}
+		c.Perf = append(c.Perf, pv)
+	}
+	c.Detail = strings.Join(parts, ", ")
+	return c
+}
+
+// transceivers reads the modules of ports, a port list in the notation of
+// the switch or "*".
+func transceivers(s *Session, ports string) ([]Transceiver, error) {
+	out, err := s.Output(s.command("transceivers") + " " + ports)
+	if err != nil {
+		return nil, err
+	}
+	if looksLikeError(out) {
+		return nil, fmt.Errorf("show interfaces transceiver: %s", strings.TrimSpace(out))
+	}
+	return parseTransceivers(out), nil
+}
+
+func runOptics(fs *flag.FlagSet) func() {
+	var lim opticsLimits
+	fs.Float64Var(&lim.tempWarn, "temp-warn", 70, tr("Module temperature in C that warns, if the module has no thresholds"))
+	fs.Float64Var(&lim.tempCrit, "temp-crit", 80, tr("Module temperature in C that fails, if the module has no thresholds"))
+	fs.Float64Var(&lim.rxWarn, "rx-warn", -20, tr("Received power in dBm at or below which to warn, if the module has no thresholds"))
+	fs.Float64Var(&lim.rxCrit, "rx-crit", -25, tr("Received power in dBm at or below which to fail, if the module has no thresholds"))
+	format := fs.String("format", "text", tr("Output format: text, json or nagios"))
+	cf := addConnFlags(fs)
+	return func() {
+		if *format != "text" && *format != "json" && *format != "nagios" {
+			fatal("--format must be text, json or nagios, not %q", *format)
+		}
+		ports := "*"
+		if fs.NArg() > 0 {
+			ports = fs.Arg(0)
+		}
+		cfg, err := cf.load()
+		mods, err := func() ([]Transceiver, error) {
+			if err != nil {
+				return nil, err
+			}
+			s, err := Dial(cfg)
+			if err != nil {
+				return nil, err
+			}
+			defer s.Close()
+			if ports != "*" {
+				if ports, err = s.portList(ports); err != nil {
+					return nil, err
+				}
+			}
+			return transceivers(s, ports)
+		}()
+		if err != nil {
+			if *format == "nagios" {
+				nagiosUnknown("ZYXEL OPTICS", err)
+			}
+			fatal("%v", err)
+		}
+
+		checks := make([]healthCheck, len(mods))
+		for i, t := range mods {
+			checks[i] = t.check(lim)
+		}
+		switch *format {
+		case "nagios":
+			os.Exit(int(printNagios("ZYXEL OPTICS", fmt.Sprintf("%d transceivers OK", len(checks)), checks)))
+		case "json":
+			type row struct {
+				Transceiver
+				Status string `json:"status"`
+			}
+			rows := make([]row, len(mods))
+			for i, t := range mods {
+				rows[i] = row{t, checks[i].Status.String()}
+			}
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(rows); err != nil {
+				fatal("%v", err)
+			}
+		default:
+			if len(mods) == 0 {
+				fmt.Println("No transceivers")
+			}
+			for _, c := range checks {
+				fmt.Printf("%-12s %-5s %s\n", c.Name, c.Status, c.Detail)
+			}
+		}
+		os.Exit(int(worstStatus(checks)))
+	}
+}
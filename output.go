@@ -0,0 +1,250 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+var (
+	// pagerPrompt matches the prompts of the switch pager, e.g. "-- More --"
+	// or "--More-- next page: Space, continue: c, quit: ESC".
+	pagerPrompt = regexp.MustCompile(`(?i)-+\s*more\s*-+(?:[^\n]*?quit:?\s*\S+)?|next page:?\s*space[^\n]*?quit:?\s*\S+`)
+)
+
+// plain is set by --plain: output is strictly ASCII-symbol, uncolored and
+// free of animations, for screen readers and strict log processors.
+var plain bool
+
+// symbol returns fancy, or ascii in plain mode.
+func symbol(fancy, ascii string) string {
+	if plain {
+		return ascii
+	}
+	return fancy
+}
+
+// plainText replaces box-drawing characters with ASCII equivalents.
+func plainText(line string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '─' || r == '━' || r == '═':
+			return '-'
+		case r == '│' || r == '┃' || r == '║':
+			return '|'
+		case r >= 0x2500 && r <= 0x257f:
+			return '+'
+		case r == '→':
+			return '>'
+		}
+		return r
+	}, line)
+}
+
+// cleanLine removes terminal artifacts from a single line: ANSI escapes,
+// NULs, backspace sequences, carriage-return overwrites and pager prompts.
+func cleanLine(line string) string {
+	return strings.TrimRight(terminalLine(line), " \t")
+}
+
+// terminalLine is cleanLine without trimming trailing blanks, so the width
+// of the line as the switch wrote it is kept.
+func terminalLine(line string) string {
+	return pagerPrompt.ReplaceAllString(sanitizeLine(line), "")
+}
+
+// sanitizeLine returns what a terminal would show for line. Backspaces,
+// carriage returns and the ANSI cursor movements and erasures firmware
+// uses to redraw a line (CSI C, D, G, K and J) are replayed; other escape
+// sequences, including OSC titles, are dropped, and so are NULs, bells and
+// the other control characters except tabs.
+func sanitizeLine(line string) string {
+	var buf []rune
+	col := 0
+	put := func(r rune) {
+		for len(buf) < col {
+			buf = append(buf, ' ')
+		}
+		if col < len(buf) {
+			buf[col] = r
+		} else {
+			buf = append(buf, r)
+		}
+		col++
+	}
+
+	rs := []rune(line)
+	for i := 0; i < len(rs); i++ {
+		switch r := rs[i]; {
+		case r == '\b':
+			col = max(col-1, 0)
+		case r == '\r':
+			col = 0
+		case r == '\t':
+			put(r)
+		case r == 0x1b && i+1 < len(rs) && rs[i+1] == '[':
+			// CSI: parameters, intermediates, then the final byte.
+			j := i + 2
+			for j < len(rs) && rs[j] >= 0x20 && rs[j] <= 0x3f {
+				j++
+			}
+			if j == len(rs) {
+				i = j
+				break
+			}
+			n := atoiOr(strings.TrimLeft(string(rs[i+2:j]), "?"), 0)
+			switch rs[j] {
+			case 'C':
+				col += max(n, 1)
+			case 'D':
+				col = max(col-max(n, 1), 0)
+			case 'G':
+				col = max(n-1, 0)
+			case 'K', 'J':
+				switch n {
+				case 0:
+					buf = buf[:min(col, len(buf))]
+				case 1:
+					for k := 0; k < col && k < len(buf); k++ {
+						buf[k] = ' '
+					}
+				default:
+					buf = buf[:0]
+				}
+			}
+			i = j
+		case r == 0x1b && i+1 < len(rs) && rs[i+1] == ']':
+			// OSC, such as a window title: up to BEL or ESC \.
+			j := i + 2
+			for j < len(rs) && rs[j] != 0x07 && rs[j] != 0x1b {
+				j++
+			}
+			if j+1 < len(rs) && rs[j] == 0x1b && rs[j+1] == '\\' {
+				j++
+			}
+			i = j
+		case r == 0x1b:
+			// A two-character escape such as ESC 7 or ESC =.
+			i++
+		case r < 0x20 || r == 0x7f:
+		default:
+			put(r)
+		}
+	}
+	return string(buf)
+}
+
+// lineStreamer prints switch output line by line as it arrives. The first
+// line is the echoed command and is dropped; the unterminated line at the
+// end (the prompt) is never printed. In raw mode chunks are written through
+// untouched.
+type lineStreamer struct {
+	// mu guards against a flush from the interrupt handler.
+	mu      sync.Mutex
+	w       io.Writer
+	raw     bool
+	partial string
+	skipped bool
+	// flushed is set by flush; later output is dropped.
+	flushed bool
+	// annotate, when set, may rewrite each cleaned line before printing.
+	annotate func(line string) string
+	// wrap, when set, joins lines of exactly wrap columns with the next
+	// one; wrapped holds the pieces so far.
+	wrap    int
+	wrapped string
+}
+
+func newLineStreamer(w io.Writer, raw bool) *lineStreamer {
+	return &lineStreamer{w: w, raw: raw}
+}
+
+func (s *lineStreamer) Write(chunk string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.flushed {
+		return
+	}
+	if s.raw {
+		io.WriteString(s.w, chunk)
+		return
+	}
+
+	s.partial += chunk
+	for {
+		i := strings.IndexByte(s.partial, '\n')
+		if i < 0 {
+			return
+		}
+		text := terminalLine(s.partial[:i])
+		s.partial = s.partial[i+1:]
+		if s.wrap > 0 && utf8.RuneCountInString(text) == s.wrap {
+			s.wrapped += text
+			continue
+		}
+		line := strings.TrimRight(s.wrapped+text, " \t")
+		s.wrapped = ""
+
+		if !s.skipped {
+			s.skipped = true
+			continue
+		}
+		if line != "" {
+			s.print(line)
+		}
+	}
+}
+
+// flush prints the line the output stopped in, for a command that is
+// interrupted before its prompt.
+func (s *lineStreamer) flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flushed = true
+	if s.raw || !s.skipped {
+		return
+	}
+	if line := strings.TrimRight(s.wrapped+terminalLine(s.partial), " \t"); line != "" {
+		s.print(line)
+	}
+	s.partial, s.wrapped = "", ""
+}
+
+func (s *lineStreamer) print(line string) {
+	if s.annotate != nil {
+		line = s.annotate(line)
+	}
+	if plain {
+		line = plainText(line)
+	}
+	fmt.Fprintln(s.w, line)
+}
+
+// openOutput opens the -o destination. "{host}" in path is replaced with
+// the switch address so multi-host runs can write one file per switch.
+// Missing parent directories are created.
+func openOutput(path, host string, appendMode bool) (*os.File, error) {
+	path = strings.ReplaceAll(path, "{host}", host)
+
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appendMode {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	f, err := os.OpenFile(path, flags, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open output file: %w", err)
+	}
+	trackOutput(f)
+	return f, nil
+}
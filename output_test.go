@@ -0,0 +1,182 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// received returns what the switch of transcript testdata/name.yaml sent
+// for command, with the pager pages that followed it; an empty command
+// gives the login.
+func received(t *testing.T, name, command string) string {
+	t.Helper()
+	tr, err := loadTranscript("testdata/" + name + ".yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, e := range tr.Exchanges {
+		if command == "" && i > 0 || command != "" && e.Send != command+"\n" {
+			continue
+		}
+		out := e.Receive
+		for _, page := range tr.Exchanges[i+1:] {
+			if page.Send != " " {
+				break
+			}
+			out += page.Receive
+		}
+		return out
+	}
+	t.Fatalf("%s: no %q in the transcript", name, command)
+	return ""
+}
+
+func TestSanitizeLineTranscripts(t *testing.T) {
+	tests := []struct {
+		transcript string
+		command    string
+		want       []string
+	}{
+		{
+			// A NUL after every line end.
+			transcript: "gs1920-v4.50",
+			command:    "show system-information",
+			want: []string{
+				"show system-information",
+				"  Product Model    : GS1920-24HPv2",
+				"  System Name      : sw-lab1",
+				"  System Contact   :",
+				"  System Location  : lab rack 2",
+				"  System up Time   : 12days 4:07:31 (103c6b1d ticks)",
+				"  Ethernet Address : bc:99:11:6e:10:00",
+				"  ZyNOS F/W Version: V4.50(ABMH.6) | 06/26/2020",
+				"  Serial Number    : S182L21000123",
+				"sw-lab1#",
+			},
+		},
+		{
+			// The pager prompt is overwritten with spaces by the next page.
+			transcript: "gs1920-v4.50",
+			command:    "show mac address-table all",
+			want: []string{
+				"show mac address-table all",
+				"Port      VLAN ID        MAC Address         Type",
+				"1         1              00:19:cb:6e:10:01   Dynamic",
+				"2         1              00:19:cb:6e:10:02   Dynamic",
+				"3         1              00:19:cb:6e:10:03   Dynamic",
+				"4         1              00:19:cb:6e:10:04   Dynamic",
+				"5         10             00:19:cb:6e:10:05   Dynamic",
+				"6         10             00:19:cb:6e:10:06   Dynamic",
+				"7         10             00:19:cb:6e:10:07   Dynamic",
+				"8         10             00:19:cb:6e:10:08   Dynamic",
+				"9         10             00:19:cb:6e:10:09   Dynamic",
+				"sw-lab1#",
+			},
+		},
+		{
+			// Cursor key and keypad modes set on login.
+			transcript: "xgs2210-v4.80",
+			want: []string{
+				"",
+				"",
+				"  XGS2210-28HP",
+				"  Copyright (c) 1994 - 2021 Zyxel Communications Corp.",
+				"",
+				"core-sw#",
+			},
+		},
+		{
+			// Every line erased to its end, and a progress message taken
+			// back with backspaces.
+			transcript: "xgs2210-v4.80",
+			command:    "show interfaces 25",
+			want: []string{
+				"show interfaces 25",
+				"  Port Info        Port NO.          :25",
+				"                   Link              :10G/F",
+				"                   Status            :FORWARDING",
+				"                   LACP              :Disabled",
+				"                   TotalPkts         :981234567",
+				"                   Tx KBs/s          :8812.250",
+				"                   Rx KBs/s          :10240.500",
+				"                   Up Time           :12:04:11",
+				"  Tx Packet        Unicast           :490617283",
+				"                   Multicast         :9812345",
+				"                   Broadcast         :4906172",
+				"  Rx Packet        Unicast           :490617283",
+				"                   Multicast         :9812345",
+				"                   Broadcast         :4906172",
+				"  Error Packet     RX CRC            :0",
+				"",
+				"core-sw#",
+			},
+		},
+		{
+			// A window title before the prompt and bold headers.
+			transcript: "xgs2220-v4.90",
+			command:    "show mac address-table",
+			want: []string{
+				"show mac address-table",
+				"Port      VLAN ID        MAC Address         Type",
+				"1/1/5     1              00:19:cb:22:01:05   Dynamic",
+				"1/1/6     1              00:19:cb:22:01:06   Dynamic",
+				"1/1/26    100            00:19:cb:22:01:1a   Static",
+				"",
+				"Total number of entries: 3",
+				"edge-sw#",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.transcript+"/"+tt.command, func(t *testing.T) {
+			lines := strings.Split(received(t, tt.transcript, tt.command), "\n")
+			if len(lines) != len(tt.want) {
+				t.Fatalf("%d lines, want %d", len(lines), len(tt.want))
+			}
+			for i, l := range lines {
+				// What the pager prompt was erased with stays behind as
+				// spaces.
+				if got := strings.TrimRight(sanitizeLine(l), " "); got != tt.want[i] {
+					t.Errorf("sanitizeLine(%q) = %q, want %q", l, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSanitizeLine(t *testing.T) {
+	tests := []struct {
+		line, want string
+	}{
+		{"plain", "plain"},
+		{"abc\x1b[2Dx", "axc"},
+		{"abc\x1b[1Gx", "xbc"},
+		{"abcdef\x1b[3G\x1b[K", "ab"},
+		{"abcdef\x1b[3G\x1b[1K", "  cdef"},
+		{"ab\x1b[3Cc", "ab   c"},
+		{"\x1b[1;32mgreen\x1b[0m", "green"},
+		{"\x1b]0;title\x1b\\text", "text"},
+		{"abc\b\bX", "aXc"},
+		{"\x00a\x7fb\x07", "ab"},
+		{"cut \x1b[", "cut "},
+	}
+	for _, tt := range tests {
+		if got := sanitizeLine(tt.line); got != tt.want {
+			t.Errorf("sanitizeLine(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestLineStreamerFlush(t *testing.T) {
+	var b strings.Builder
+	g := &grepWriter{w: &b, match: regexp.MustCompile(`Port`)}
+	out := newLineStreamer(g, false)
+	out.Write("show interfaces status\r\nPort 1  Up\r\nVLAN 1\r\nPort 2  Do")
+	out.flush()
+	g.flush()
+	out.Write("wn\r\nsw1#")
+	if want := "Port 1  Up\nPort 2  Do\n"; b.String() != want {
+		t.Errorf("interrupted output %q, want %q", b.String(), want)
+	}
+}
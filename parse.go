@@ -0,0 +1,139 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// splitKeyValue splits a "Key        : value" line at the first colon.
+func splitKeyValue(line string) (key, value string, ok bool) {
+	key, value, ok = strings.Cut(line, ":")
+	if !ok {
+		return "", "", false
+	}
+	return strings.TrimSpace(key), strings.TrimSpace(value), true
+}
+
+var columnGap = regexp.MustCompile(`\S+(?: \S+)*`)
+
+// isRule reports whether line is a dashed separator such as "----  -----".
+func isRule(line string) bool {
+	t := strings.TrimSpace(line)
+	return t != "" && strings.Trim(t, "- =") == ""
+}
+
+// parseTable parses column-aligned output. The header row is the first line
+// starting with firstHeader; column boundaries come from the dashed rule
+// below it when there is one, otherwise from the header words themselves
+// (columns are separated by two or more spaces). Rows run until the next
+// blank line. Each row is keyed by header name.
+func parseTable(output, firstHeader string) []map[string]string {
+	lines := strings.Split(strings.ReplaceAll(output, "\r", ""), "\n")
+
+	start := -1
+	for i, line := range lines {
+		if strings.HasPrefix(strings.ToLower(strings.TrimSpace(line)), strings.ToLower(firstHeader)) {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return nil
+	}
+
+	header := lines[start]
+	spans := columnGap.FindAllStringIndex(header, -1)
+	body := lines[start+1:]
+	if len(body) > 0 && isRule(body[0]) {
+		if rule := regexp.MustCompile(`[-=]+`).FindAllStringIndex(body[0], -1); len(rule) == len(spans) {
+			spans = rule
+		}
+		body = body[1:]
+	}
+
+	names := make([]string, len(spans))
+	for i, sp := range spans {
+		end := len(header)
+		if i+1 < len(spans) {
+			end = spans[i+1][0]
+		}
+		names[i] = strings.TrimSpace(header[sp[0]:min(end, len(header))])
+	}
+
+	var rows []map[string]string
+	for _, line := range body {
+		if strings.TrimSpace(line) == "" {
+			if len(rows) > 0 {
+				break
+			}
+			continue
+		}
+		if isRule(line) {
+			continue
+		}
+
+		row := make(map[string]string, len(names))
+		fields := columnGap.FindAllString(line, -1)
+		if len(fields) == len(names) {
+			for i, name := range names {
+				row[name] = fields[i]
+			}
+		} else {
+			for i, name := range names {
+				from := spans[i][0]
+				to := len(line)
+				if i+1 < len(spans) {
+					to = spans[i+1][0]
+				}
+				if from >= len(line) {
+					break
+				}
+				row[name] = strings.TrimSpace(line[from:min(to, len(line))])
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// atoiOr parses s as a decimal integer, returning def when it is not one.
+func atoiOr(s string, def int) int {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+// sortedKeys returns the keys of an int-keyed map in ascending order.
+func sortedKeys[V any](m map[int]V) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+// firstOf returns the first non-empty value of row among keys, for tables
+// whose column names differ between firmware versions.
+func firstOf(row map[string]string, keys ...string) string {
+	for _, k := range keys {
+		if v := row[k]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// sortedStringKeys returns the keys of a string-keyed map in order.
+func sortedStringKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
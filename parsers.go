@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// commandParser turns the output of the commands its pattern matches into
+// data for --format json.
+type commandParser struct {
+	pattern *regexp.Regexp
+	// name is the Go function or the external program, for error messages.
+	name  string
+	parse func(command, output string) (any, error)
+}
+
+// parsed adapts one of the tool's own output parsers.
+func parsed[T any](parse func(output string) T) func(command, output string) (any, error) {
+	return func(_, output string) (any, error) {
+		return parse(output), nil
+	}
+}
+
+// builtinParsers are the commands the tool knows the output of, most
+// specific pattern first.
+var builtinParsers = []commandParser{
+	{regexp.MustCompile(`^show\s+interfaces?\s+transceiver\b`), "parseTransceivers", parsed(parseTransceivers)},
+	{regexp.MustCompile(`^show\s+interfaces?\s+[\d*/,\-]+$`), "parseInterfaces", parsed(parseInterfaces)},
+	{regexp.MustCompile(`^show\s+mac\s+address-table\b`), "parseMACTable", parsed(parseMACTable)},
+	{regexp.MustCompile(`^show\s+ip\s+arp\b`), "parseARP", parsed(parseARP)},
+	{regexp.MustCompile(`^show\s+system-information$`), "parseSystemInfo", parsed(parseSystemInfo)},
+	{regexp.MustCompile(`^show\s+lldp\s+info\s+remote\b`), "parseLLDPRemote", parsed(parseLLDPRemote)},
+	{regexp.MustCompile(`^show\s+vlan$`), "parseShowVLAN", parsed(func(output string) []VLAN {
+		vlans := parseShowVLAN(output)
+		list := make([]VLAN, 0, len(vlans))
+		for _, id := range sortedKeys(vlans) {
+			list = append(list, vlans[id])
+		}
+		return list
+	})},
+	{regexp.MustCompile(`^show\s+trunk$`), "parseTrunks", parsed(parseTrunks)},
+	{regexp.MustCompile(`^show\s+spanning-tree\s+config$`), "parseSTPStatus", parsed(parseSTPStatus)},
+	{regexp.MustCompile(`^show\s+poe-status$`), "parsePoEStatus", parsed(parsePoEStatus)},
+	{regexp.MustCompile(`^show\s+igmp-snooping\s+group\b`), "parseIGMPGroups", parsed(parseIGMPGroups)},
+	{regexp.MustCompile(`^show\s+dhcp\s+snooping\s+binding$`), "parseDHCPBindings", parsed(parseDHCPBindings)},
+	{regexp.MustCompile(`^show\s+port-access-authenticator\b`), "parseAuthPorts", parsed(parseAuthPorts)},
+	{regexp.MustCompile(`^cable-diagnostics\b`), "parseCableDiag", parsed(parseCableDiag)},
+}
+
+// externalParser is an entry under "parsers:" in the config file: a
+// program that parses the output of the commands Match finds. It gets
+// {"command": ..., "output": ...} as JSON on stdin and prints the parsed
+// output as JSON on stdout; a non-zero exit is an error, with stderr as
+// the message.
+type externalParser struct {
+	Match   string   `yaml:"match"`
+	Command []string `yaml:"command"`
+}
+
+// loadParsers returns the external parsers of the config file, which take
+// precedence over the built-in ones, followed by the built-in ones. A
+// missing config file just means no external parsers.
+func loadParsers() ([]commandParser, error) {
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return builtinParsers, nil
+	}
+	if err != nil {
+		return nil, errorf("failed to read config file: %w", err)
+	}
+	var file struct {
+		Parsers []externalParser `yaml:"parsers"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	var parsers []commandParser
+	for i, ep := range file.Parsers {
+		if ep.Match == "" || len(ep.Command) == 0 {
+			return nil, errorf("%s: parser %d needs match and command", path, i+1)
+		}
+		re, err := regexp.Compile(ep.Match)
+		if err != nil {
+			return nil, errorf("%s: parser %d: %w", path, i+1, err)
+		}
+		parsers = append(parsers, commandParser{re, ep.Command[0], ep.run})
+	}
+	return append(parsers, builtinParsers...), nil
+}
+
+func (ep externalParser) run(command, output string) (any, error) {
+	input, err := json.Marshal(map[string]string{"command": command, "output": output})
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(ep.Command[0], ep.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	var v any
+	if err := json.Unmarshal(stdout.Bytes(), &v); err != nil {
+		return nil, fmt.Errorf("output is not JSON: %w", err)
+	}
+	return v, nil
+}
+
+// findParser returns the first parser whose pattern matches command.
+func findParser(parsers []commandParser, command string) (commandParser, bool) {
+	command = strings.Join(strings.Fields(command), " ")
+	for _, p := range parsers {
+		if p.pattern.MatchString(command) {
+			return p, true
+		}
+	}
+	return commandParser{}, false
+}
+
+// parsedOutput is one command of -c with --format json.
+type parsedOutput struct {
+	Command string `json:"command"`
+	Data    any    `json:"data"`
+}
+
+// parseCommandOutput runs commands and parses their output with parsers.
+func parseCommandOutput(s *Session, parsers []commandParser, commands []string) ([]parsedOutput, error) {
+	var results []parsedOutput
+	for _, c := range commands {
+		p, ok := findParser(parsers, c)
+		if !ok {
+			return nil, errorf("no parser for %q; add one under parsers: in the config file", c)
+		}
+		out, err := s.Output(c)
+		if err != nil {
+			return nil, err
+		}
+		// Not looksLikeError: interface counters say "error" too.
+		if t := strings.TrimSpace(out); strings.HasPrefix(t, "%") {
+			line, _, _ := strings.Cut(t, "\n")
+			return nil, errorf("switch rejected %q: %s", c, line)
+		}
+		data, err := p.parse(c, out)
+		if err != nil {
+			return nil, errorf("parser %s for %q: %w", p.name, c, err)
+		}
+		results = append(results, parsedOutput{c, data})
+	}
+	return results, nil
+}
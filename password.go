@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Prompts of the forced password change some firmware runs at login once
+// the password has expired.
+var (
+	newPasswordPrompt = regexp.MustCompile(`(?i)((new|re-?type|re-?enter|confirm|verify)\b[^:>]*password|password[^:>]*(again|confirm\w*))\s*[:>]\s*$`)
+	oldPasswordPrompt = regexp.MustCompile(`(?i)(old|current)\b[^:>]*password\s*[:>]\s*$`)
+	passwordExpired   = regexp.MustCompile(`(?i)password\s+(has\s+)?expired|must\s+change\s+(your\s+)?password`)
+)
+
+// PasswordExpiredError is returned by Dial when the switch demands a new
+// password at login and ZYXEL_NEW_PASSWORD is not set.
+type PasswordExpiredError struct {
+	Host string
+	// Prompt is what the switch asked.
+	Prompt string
+}
+
+func (e *PasswordExpiredError) Error() string {
+	return fmt.Sprintf(tr("password for %s has expired (switch: %q); set ZYXEL_NEW_PASSWORD to change it at login"), e.Host, e.Prompt)
+}
+
+// expiryMessage returns the line of output announcing the expiry.
+func expiryMessage(output string) string {
+	for _, l := range strings.Split(output, "\n") {
+		if l = strings.TrimSpace(cleanLine(l)); passwordExpired.MatchString(l) {
+			return l
+		}
+	}
+	return strings.TrimSpace(cleanLine(tailLine(output)))
+}
+
+// passwordChange answers the prompts of a forced password change.
+type passwordChange struct {
+	host        string
+	old, new    string
+	changed     bool
+	answerCount int
+}
+
+// answer returns the reply to prompt, or ok false when prompt is not part
+// of a password change. Without a new password it fails with
+// PasswordExpiredError.
+func (pc *passwordChange) answer(prompt string) (reply string, ok bool, err error) {
+	prompt = strings.TrimSpace(prompt)
+	switch {
+	case newPasswordPrompt.MatchString(prompt):
+		if pc.new == "" {
+			return "", true, &PasswordExpiredError{Host: pc.host, Prompt: prompt}
+		}
+		// New, confirm and perhaps a retry after a rejected choice.
+		if pc.answerCount++; pc.answerCount > 4 {
+			return "", true, errorf("switch rejected the new password for %s (last prompt %q)", pc.host, prompt)
+		}
+		pc.changed = true
+		return pc.new, true, nil
+	case oldPasswordPrompt.MatchString(prompt):
+		return pc.old, true, nil
+	}
+	return "", false, nil
+}
+
+// reportChange tells the user that the stored password is now stale.
+func (pc *passwordChange) reportChange() {
+	if pc.changed {
+		fmt.Fprintf(os.Stderr, tr("Password for %s was changed at login as the switch required; update ZYXEL_PASSWORD\n"), pc.host)
+	}
+}
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Play is a runbook of ordered steps against inventory switches, loaded
+// from YAML and run by "zyxel play". Unlike a playbook it may change the
+// configuration, and each step says what happens when it fails.
+type Play struct {
+	Name string `yaml:"name"`
+	// Vars maps variable names to defaults, as in playbooks.
+	Vars map[string]string `yaml:"vars"`
+	// Hosts are the default hosts of the steps.
+	Hosts stringList `yaml:"hosts"`
+	Steps []PlayStep `yaml:"steps"`
+}
+
+// PlayStep does one of three things on each of its hosts: Run executes
+// commands, Configure runs commands in configuration mode, and Zyxel runs
+// a subcommand of this tool. Expect patterns must all match the output and
+// Reject patterns none of it.
+type PlayStep struct {
+	Name string `yaml:"name"`
+	// Hosts are inventory names or "tag:<tag>"; empty uses the play's.
+	// Zyxel steps without hosts of their own run once, for subcommands
+	// that work on the whole inventory.
+	Hosts     stringList `yaml:"hosts"`
+	Run       stringList `yaml:"run"`
+	Configure stringList `yaml:"configure"`
+	Zyxel     stringList `yaml:"zyxel"`
+	// Save writes memory after a Configure step that passed.
+	Save   bool       `yaml:"save"`
+	Expect stringList `yaml:"expect"`
+	Reject stringList `yaml:"reject"`
+	// OnFailure is "abort" (the default), "continue", or "rollback",
+	// which restores every switch the play changed and stops.
+	OnFailure string `yaml:"on_failure"`
+}
+
+// UnmarshalYAML takes a single string as a list of one, so a step can say
+// "run: show vlan" as well as give a list.
+func (l *stringList) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		*l = stringList{n.Value}
+		return nil
+	}
+	var list []string
+	if err := n.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+func loadPlay(path string) (*Play, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var p Play
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if p.Name == "" {
+		p.Name = path
+	}
+	for i, st := range p.Steps {
+		if st.Name == "" {
+			p.Steps[i].Name = fmt.Sprintf("step %d", i+1)
+			st.Name = p.Steps[i].Name
+		}
+		kinds := 0
+		for _, l := range []stringList{st.Run, st.Configure, st.Zyxel} {
+			if len(l) > 0 {
+				kinds++
+			}
+		}
+		if kinds != 1 {
+			return nil, errorf("%s, %s: give exactly one of run, configure and zyxel", path, st.Name)
+		}
+		if !slices.Contains([]string{"", "abort", "continue", "rollback"}, st.OnFailure) {
+			return nil, errorf("%s, %s: on_failure must be abort, continue or rollback, not %q", path, st.Name, st.OnFailure)
+		}
+		for _, pattern := range slices.Concat(st.Expect, st.Reject) {
+			if _, err := regexp.Compile(expandVars(pattern, nil)); err != nil {
+				return nil, fmt.Errorf("%s, %s: %w", path, st.Name, err)
+			}
+		}
+	}
+	return &p, nil
+}
+
+// selectHosts resolves inventory names and "tag:<tag>" entries.
+func selectHosts(inv *Inventory, names []string) ([]Host, error) {
+	var hosts []Host
+	for _, n := range names {
+		tag, isTag := strings.CutPrefix(n, "tag:")
+		found := false
+		for _, h := range inv.Hosts {
+			if (isTag && slices.Contains(h.Tags, tag) || !isTag && h.Name == n) &&
+				!slices.ContainsFunc(hosts, func(o Host) bool { return o.Name == h.Name }) {
+				hosts = append(hosts, h)
+				found = true
+			}
+		}
+		if !found && !slices.ContainsFunc(hosts, func(o Host) bool { return o.Name == n }) {
+			return nil, errorf("no inventory host matches %q", n)
+		}
+	}
+	return hosts, nil
+}
+
+// player runs the steps of a play, keeping one session per host open and
+// the running-config of each host from before the play changed it.
+type player struct {
+	cfgs     map[string]Config
+	sessions map[string]*Session
+	before   map[string]string
+	vars     map[string]string
+	// changed lists the hosts configured so far, in order.
+	changed []string
+}
+
+func (pl *player) session(name string) (*Session, error) {
+	if s, ok := pl.sessions[name]; ok {
+		return s, nil
+	}
+	cfg := pl.cfgs[name]
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	s, err := Dial(cfg)
+	if err != nil {
+		return nil, err
+	}
+	pl.sessions[name] = s
+	return s, nil
+}
+
+func (pl *player) close() {
+	for _, s := range pl.sessions {
+		s.Close()
+	}
+}
+
+// step runs st on one host, or on none for a Zyxel step without hosts,
+// and returns its output.
+func (pl *player) step(st PlayStep, host string) (string, error) {
+	if len(st.Zyxel) > 0 {
+		return pl.subcommand(st, host)
+	}
+	s, err := pl.session(host)
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	if len(st.Configure) > 0 {
+		if _, ok := pl.before[host]; !ok {
+			config, err := s.Output(s.command("running-config"))
+			if err != nil {
+				return "", errorf("failed to read running-config: %w", err)
+			}
+			pl.before[host] = config
+		}
+		commands := make([]string, len(st.Configure))
+		for i, c := range st.Configure {
+			commands[i] = expandVars(c, pl.vars)
+		}
+		if !slices.Contains(pl.changed, host) {
+			pl.changed = append(pl.changed, host)
+		}
+		if err := s.Configure(commands, &out); err != nil {
+			return out.String(), err
+		}
+		if st.Save {
+			msg, err := s.Save()
+			if err != nil {
+				return out.String(), errorf("configuration NOT saved: %w", err)
+			}
+			fmt.Fprintf(&out, "Saved: %s\n", msg)
+		}
+		return out.String(), nil
+	}
+
+	for _, c := range st.Run {
+		c = expandVars(c, pl.vars)
+		o, err := s.Output(c)
+		out.WriteString(o)
+		if err != nil {
+			return out.String(), err
+		}
+		if looksLikeError(o) {
+			return out.String(), errorf("switch rejected %q: %s", c, strings.TrimSpace(o))
+		}
+	}
+	return out.String(), nil
+}
+
+// subcommand runs this tool with the step's arguments, connected to host
+// through the ZYXEL_* variables when one is given.
+func (pl *player) subcommand(st PlayStep, host string) (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	args := make([]string, len(st.Zyxel))
+	for i, a := range st.Zyxel {
+		args[i] = expandVars(a, pl.vars)
+	}
+	cmd := exec.Command(exe, args...)
+	cmd.Env = os.Environ()
+	if host != "" {
+		cfg := pl.cfgs[host]
+		cmd.Env = append(cmd.Env, "ZYXEL_HOST="+cfg.Host, "ZYXEL_USER="+cfg.User, "ZYXEL_PASSWORD="+cfg.Password,
+			"ZYXEL_PORT="+cfg.Port, "ZYXEL_TRANSPORT="+cfg.Transport)
+	}
+	var out bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &out
+	if err := cmd.Run(); err != nil {
+		return out.String(), fmt.Errorf("zyxel %s: %w", strings.Join(args, " "), err)
+	}
+	return out.String(), nil
+}
+
+// check applies the Expect and Reject patterns of st to out.
+func (pl *player) check(st PlayStep, out string) error {
+	for _, p := range st.Expect {
+		if !regexp.MustCompile(expandVars(p, pl.vars)).MatchString(out) {
+			return errorf("output does not match %q", p)
+		}
+	}
+	for _, p := range st.Reject {
+		if m := regexp.MustCompile(expandVars(p, pl.vars)).FindString(out); m != "" {
+			return errorf("output matches %q: %q", p, m)
+		}
+	}
+	return nil
+}
+
+// rollback restores the running-config from before the play on every host
+// it changed, latest first.
+func (pl *player) rollback(w io.Writer) bool {
+	ok := true
+	for i := len(pl.changed) - 1; i >= 0; i-- {
+		host := pl.changed[i]
+		err := func() error {
+			s, err := pl.session(host)
+			if err != nil {
+				return err
+			}
+			current, err := s.Output(s.command("running-config"))
+			if err != nil {
+				return err
+			}
+			commands := rollbackCommands(current, pl.before[host])
+			if len(commands) == 0 {
+				return nil
+			}
+			return s.Configure(commands, io.Discard)
+		}()
+		if err != nil {
+			fmt.Fprintf(w, "%s: rollback FAILED: %v\n", host, err)
+			ok = false
+			continue
+		}
+		fmt.Fprintf(w, "%s: rolled back\n", host)
+	}
+	return ok
+}
+
+func runPlay(fs *flag.FlagSet) func() {
+	var varFlags stringList
+	fs.Var(&varFlags, "var", tr("Set a play variable, `name=value` (repeatable)"))
+	ff := addFleetFlags(fs)
+	return func() {
+		if fs.NArg() != 1 {
+			fatal("Usage: zyxel play <runbook.yaml> [--var name=value ...]")
+		}
+		p, err := loadPlay(fs.Arg(0))
+		if err != nil {
+			fatal("%v", err)
+		}
+		vars := make(map[string]string)
+		for k, v := range p.Vars {
+			vars[k] = v
+		}
+		for _, kv := range varFlags {
+			k, v, ok := strings.Cut(kv, "=")
+			if !ok {
+				fatal("--var wants name=value, got %q", kv)
+			}
+			vars[k] = v
+		}
+		var missing []string
+		for _, k := range sortedStringKeys(p.Vars) {
+			if vars[k] == "" {
+				missing = append(missing, k)
+			}
+		}
+		if len(missing) > 0 {
+			fatal("play %s needs --var for: %s", p.Name, strings.Join(missing, ", "))
+		}
+
+		inv, err := loadInventory(ff.inventory)
+		if err != nil && slices.ContainsFunc(p.Steps, func(st PlayStep) bool { return len(st.Zyxel) == 0 || len(st.Hosts) > 0 }) {
+			fatal("%v", err)
+		}
+		// The hosts of every step, resolved up front so a typo fails the
+		// play before anything runs.
+		stepHosts := make([][]string, len(p.Steps))
+		var all []Host
+		for i, st := range p.Steps {
+			names := st.Hosts
+			if len(names) == 0 && len(st.Zyxel) == 0 {
+				names = p.Hosts
+			}
+			if len(names) == 0 {
+				if len(st.Zyxel) == 0 {
+					fatal("%s, %s: no hosts", p.Name, st.Name)
+				}
+				stepHosts[i] = []string{""}
+				continue
+			}
+			hosts, err := selectHosts(inv, names)
+			if err != nil {
+				fatal("%s, %s: %v", p.Name, st.Name, err)
+			}
+			for _, h := range hosts {
+				stepHosts[i] = append(stepHosts[i], h.Name)
+				if !slices.ContainsFunc(all, func(o Host) bool { return o.Name == h.Name }) {
+					all = append(all, h)
+				}
+			}
+		}
+		cfgs, errs, err := hostConfigs(all)
+		if err != nil {
+			fatal("%v", err)
+		}
+		pl := &player{
+			cfgs:     make(map[string]Config),
+			sessions: make(map[string]*Session),
+			before:   make(map[string]string),
+			vars:     vars,
+		}
+		defer pl.close()
+		for i, h := range all {
+			if errs[i] != nil {
+				fatal("%s: %v", h.Name, errs[i])
+			}
+			pl.cfgs[h.Name] = cfgs[i]
+		}
+
+		failed := false
+		for i, st := range p.Steps {
+			for _, host := range stepHosts[i] {
+				label := st.Name
+				if host != "" {
+					label += " @ " + host
+				}
+				fmt.Printf("== %s\n", label)
+				out, err := pl.step(st, host)
+				fmt.Print(out)
+				if out != "" && !strings.HasSuffix(out, "\n") {
+					fmt.Println()
+				}
+				if err == nil {
+					err = pl.check(st, out)
+				}
+				if err == nil {
+					fmt.Fprintf(os.Stderr, tr("%s %s: ok")+"\n", symbol("✓", "+"), label)
+					continue
+				}
+				fmt.Fprintf(os.Stderr, "%s %s: %v\n", symbol("✗", "x"), label, err)
+				failed = true
+				switch st.OnFailure {
+				case "continue":
+					continue
+				case "rollback":
+					if len(pl.changed) == 0 {
+						fmt.Fprintln(os.Stderr, tr("Nothing to roll back"))
+					} else if !pl.rollback(os.Stderr) {
+						fatal("play %s failed and could not be fully rolled back", p.Name)
+					}
+				}
+				fatal("play %s stopped at %s", p.Name, label)
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+	}
+}
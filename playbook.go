@@ -0,0 +1,334 @@
+package main
+
+import (
+	"embed"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Playbook is a guided troubleshooting sequence loaded from YAML. Each
+// step runs a command and checks its output; a check that matches is a
+// problem and its message ends up in the verdict.
+type Playbook struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	// Vars maps variable names to defaults; an empty default makes the
+	// variable required.
+	Vars  map[string]string `yaml:"vars"`
+	Steps []PlaybookStep    `yaml:"steps"`
+	// OK is the verdict when no check matched.
+	OK string `yaml:"ok"`
+}
+
+type PlaybookStep struct {
+	Name string `yaml:"name"`
+	Run  string `yaml:"run"`
+	// Send writes a line without waiting for the prompt, for interactive
+	// sequences such as reload confirmations; the step then waits until
+	// the output matches Expect, or for the prompt without it. Timeout
+	// bounds the wait, e.g. "2m".
+	Send    string `yaml:"send"`
+	Expect  string `yaml:"expect"`
+	Timeout string `yaml:"timeout"`
+	// When names an earlier step that must have found a problem, or with
+	// a leading "!" one that must not have, for the step to run.
+	When   string          `yaml:"when"`
+	Checks []PlaybookCheck `yaml:"checks"`
+}
+
+// PlaybookCheck matches Match against the output. With Above set, the
+// first capture group must also be a number greater than it. {{1}} in
+// Message is replaced with that group.
+type PlaybookCheck struct {
+	Match   string `yaml:"match"`
+	Above   string `yaml:"above"`
+	Message string `yaml:"message"`
+}
+
+//go:embed playbooks/*.yaml
+var builtinPlaybooks embed.FS
+
+// playbookDir is where user playbooks are looked up by name.
+func playbookDir() string {
+	if d := os.Getenv("ZYXEL_PLAYBOOKS"); d != "" {
+		return d
+	}
+	return "playbooks"
+}
+
+// loadPlaybook reads a playbook by file path, or by name from the
+// playbook directory and then the built-in ones.
+func loadPlaybook(name string) (*Playbook, error) {
+	var data []byte
+	var err error
+	if strings.ContainsRune(name, os.PathSeparator) || strings.HasSuffix(name, ".yaml") {
+		data, err = os.ReadFile(name)
+	} else {
+		data, err = os.ReadFile(filepath.Join(playbookDir(), name+".yaml"))
+		if os.IsNotExist(err) {
+			data, err = builtinPlaybooks.ReadFile("playbooks/" + name + ".yaml")
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("playbook %s: %w", name, err)
+	}
+
+	var pb Playbook
+	if err := yaml.Unmarshal(data, &pb); err != nil {
+		return nil, fmt.Errorf("playbook %s: %w", name, err)
+	}
+	if pb.Name == "" {
+		pb.Name = strings.TrimSuffix(filepath.Base(name), ".yaml")
+	}
+	for _, st := range pb.Steps {
+		if (st.Run == "") == (st.Send == "") {
+			return nil, fmt.Errorf("playbook %s, step %s: give exactly one of run and send", pb.Name, st.Name)
+		}
+		if st.Run != "" && (st.Expect != "" || st.Timeout != "") {
+			return nil, fmt.Errorf("playbook %s, step %s: expect and timeout go with send", pb.Name, st.Name)
+		}
+		if _, err := regexp.Compile(expandVars(st.Expect, nil)); err != nil {
+			return nil, fmt.Errorf("playbook %s, step %s: %w", pb.Name, st.Name, err)
+		}
+		if st.Timeout != "" {
+			if _, err := time.ParseDuration(st.Timeout); err != nil {
+				return nil, fmt.Errorf("playbook %s, step %s: %w", pb.Name, st.Name, err)
+			}
+		}
+		for _, c := range st.Checks {
+			// Variables are substituted first, so check the pattern with
+			// placeholders neutralized.
+			if _, err := regexp.Compile(expandVars(c.Match, nil)); err != nil {
+				return nil, fmt.Errorf("playbook %s, step %s: %w", pb.Name, st.Name, err)
+			}
+		}
+	}
+	return &pb, nil
+}
+
+var placeholder = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+
+// expandVars replaces {{name}} with vars[name]; unknown names become "".
+func expandVars(s string, vars map[string]string) string {
+	return placeholder.ReplaceAllStringFunc(s, func(m string) string {
+		return vars[placeholder.FindStringSubmatch(m)[1]]
+	})
+}
+
+// playbookStepResult is the outcome of one step.
+type playbookStepResult struct {
+	Name     string
+	Skipped  bool
+	Err      error
+	Problems []string
+}
+
+// runPlaybookSteps executes pb on s with the given variables.
+func runPlaybookSteps(s *Session, pb *Playbook, vars map[string]string) []playbookStepResult {
+	found := make(map[string]bool)
+	var results []playbookStepResult
+	for _, st := range pb.Steps {
+		r := playbookStepResult{Name: st.Name}
+		if want, negate := strings.CutPrefix(st.When, "!"); st.When != "" && found[want] == negate {
+			r.Skipped = true
+			results = append(results, r)
+			continue
+		}
+
+		var out string
+		var err error
+		cmd := expandVars(st.Run, vars)
+		if st.Send != "" {
+			cmd = expandVars(st.Send, vars)
+			out, err = sendExpect(s, cmd, expandVars(st.Expect, vars), st.Timeout)
+		} else {
+			out, err = s.Output(cmd)
+		}
+		if t := strings.TrimSpace(out); err == nil && strings.HasPrefix(t, "%") {
+			line, _, _ := strings.Cut(t, "\n")
+			err = fmt.Errorf("switch rejected %q: %s", cmd, line)
+		}
+		if err != nil {
+			r.Err = err
+			results = append(results, r)
+			continue
+		}
+		for _, c := range st.Checks {
+			m := regexp.MustCompile(expandVars(c.Match, vars)).FindStringSubmatch(out)
+			if m == nil {
+				continue
+			}
+			group := ""
+			if len(m) > 1 {
+				group = m[1]
+			}
+			if c.Above != "" {
+				limit, err := strconv.ParseFloat(expandVars(c.Above, vars), 64)
+				n, nerr := strconv.ParseFloat(group, 64)
+				if err != nil || nerr != nil || n <= limit {
+					continue
+				}
+			}
+			msg := expandVars(strings.ReplaceAll(c.Message, "{{1}}", group), vars)
+			r.Problems = append(r.Problems, msg)
+		}
+		found[st.Name] = len(r.Problems) > 0
+		results = append(results, r)
+	}
+	return results
+}
+
+// sendExpect sends line and waits for expect, or for the prompt when it is
+// empty. The timeout was checked when the playbook was loaded.
+func sendExpect(s *Session, line, expect, timeout string) (string, error) {
+	var pattern *regexp.Regexp
+	if expect != "" {
+		pattern = regexp.MustCompile(expect)
+	}
+	limit, _ := time.ParseDuration(timeout)
+	if err := s.Send(line); err != nil {
+		return "", err
+	}
+	return s.Expect(pattern, limit)
+}
+
+var playbookCommands = []subcommand{
+	{"run", "Run a playbook against a switch", runPlaybookRun,
+		"one line per step (ok, problem, skipped or error), then \"Verdict: ...\" followed by the problems", nil},
+	{"list", "List available playbooks", runPlaybookList,
+		"one line per playbook: name and description", nil},
+}
+
+func runPlaybook(fs *flag.FlagSet) func() {
+	return func() {
+		args := fs.Args()
+		if len(args) == 0 {
+			fmt.Println("Usage: zyxel playbook <command> [flags]")
+			fmt.Println()
+			fmt.Println("Commands:")
+			for _, c := range playbookCommands {
+				fmt.Printf("  %-10s %s\n", c.name, c.summary)
+			}
+			os.Exit(1)
+		}
+		for _, c := range playbookCommands {
+			if c.name == args[0] {
+				c.invoke("playbook "+c.name, args[1:])
+				return
+			}
+		}
+		fatal("Unknown playbook command %q", args[0])
+	}
+}
+
+func runPlaybookRun(fs *flag.FlagSet) func() {
+	var varFlags stringList
+	fs.Var(&varFlags, "var", tr("Set a playbook variable, `name=value` (repeatable)"))
+	cf := addConnFlags(fs)
+	return func() {
+		if fs.NArg() != 1 {
+			fatal("Usage: zyxel playbook run <name|file.yaml> [--var name=value ...]")
+		}
+		pb, err := loadPlaybook(fs.Arg(0))
+		if err != nil {
+			fatal("%v", err)
+		}
+
+		vars := make(map[string]string)
+		for k, v := range pb.Vars {
+			vars[k] = v
+		}
+		for _, kv := range varFlags {
+			k, v, ok := strings.Cut(kv, "=")
+			if !ok {
+				fatal("--var wants name=value, got %q", kv)
+			}
+			vars[k] = v
+		}
+		var missing []string
+		for _, k := range sortedStringKeys(pb.Vars) {
+			if vars[k] == "" {
+				missing = append(missing, k)
+			}
+		}
+		if len(missing) > 0 {
+			fatal("playbook %s needs --var for: %s", pb.Name, strings.Join(missing, ", "))
+		}
+
+		_, s := cf.connect()
+		defer s.Close()
+
+		var settings []string
+		for _, k := range sortedStringKeys(vars) {
+			settings = append(settings, k+"="+vars[k])
+		}
+		fmt.Printf("Playbook %s (%s)\n", pb.Name, strings.Join(settings, ", "))
+
+		var problems []string
+		failed := 0
+		for _, r := range runPlaybookSteps(s, pb, vars) {
+			switch {
+			case r.Skipped:
+				fmt.Printf("%s %-14s skipped\n", symbol("·", "-"), r.Name)
+			case r.Err != nil:
+				fmt.Printf("%s %-14s error: %v\n", symbol("!", "!"), r.Name, r.Err)
+				failed++
+			case len(r.Problems) > 0:
+				fmt.Printf("%s %-14s %s\n", symbol("✗", "x"), r.Name, strings.Join(r.Problems, "; "))
+				problems = append(problems, r.Problems...)
+			default:
+				fmt.Printf("%s %-14s ok\n", symbol("✓", "+"), r.Name)
+			}
+		}
+
+		fmt.Println()
+		if len(problems) == 0 && failed > 0 {
+			fmt.Printf("Verdict: inconclusive, %d step(s) could not run\n", failed)
+			os.Exit(1)
+		}
+		if len(problems) == 0 {
+			verdict := pb.OK
+			if verdict == "" {
+				verdict = "no problems found"
+			}
+			fmt.Printf("Verdict: %s\n", verdict)
+			return
+		}
+		fmt.Printf("Verdict: %d problem(s) found\n", len(problems))
+		for _, p := range problems {
+			fmt.Printf("  - %s\n", p)
+		}
+		os.Exit(1)
+	}
+}
+
+func runPlaybookList(fs *flag.FlagSet) func() {
+	return func() {
+		names := make(map[string]string)
+		builtin, _ := builtinPlaybooks.ReadDir("playbooks")
+		local, _ := os.ReadDir(playbookDir())
+		for _, e := range append(builtin, local...) {
+			name, ok := strings.CutSuffix(e.Name(), ".yaml")
+			if !ok {
+				continue
+			}
+			if pb, err := loadPlaybook(name); err == nil {
+				names[name] = pb.Description
+			} else {
+				names[name] = err.Error()
+			}
+		}
+
+		for _, name := range sortedStringKeys(names) {
+			fmt.Printf("  %-16s %s\n", name, names[name])
+		}
+	}
+}
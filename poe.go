@@ -0,0 +1,326 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PoEPort is one row of the port table of "show poe-status".
+type PoEPort struct {
+	Port     Port   `json:"port"`
+	State    string `json:"state"`
+	Class    string `json:"class"`
+	Priority string `json:"priority"`
+	// MaxW and PowerW are the port's power limit and draw in watts.
+	MaxW   float64 `json:"max_w"`
+	PowerW float64 `json:"power_w"`
+}
+
+// PoEStatus is the power budget of a switch and the draw of its ports, in
+// watts.
+type PoEStatus struct {
+	Mode      string    `json:"mode"`
+	Total     float64   `json:"total_w"`
+	Consuming float64   `json:"consuming_w"`
+	Allocated float64   `json:"allocated_w"`
+	Remaining float64   `json:"remaining_w"`
+	Ports     []PoEPort `json:"ports"`
+}
+
+// watts parses a power value such as "180.0", "4.6 W" or "15400", scaled
+// by unit when the column or key is in milliwatts.
+func watts(value, name string) float64 {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return 0
+	}
+	n, err := strconv.ParseFloat(strings.TrimSuffix(strings.ToLower(fields[0]), "w"), 64)
+	if err != nil {
+		return 0
+	}
+	if strings.Contains(strings.ToLower(name), "mw") {
+		n /= 1000
+	}
+	return n
+}
+
+// parsePoEStatus parses "show poe-status": "Key : value" lines with the
+// budget, then a table with one row per PoE port. Power columns are in mW
+// on most firmware; the unit is taken from the header.
+func parsePoEStatus(output string) PoEStatus {
+	var st PoEStatus
+	for _, line := range strings.Split(strings.ReplaceAll(output, "\r", ""), "\n") {
+		key, value, ok := splitKeyValue(line)
+		if !ok || value == "" {
+			continue
+		}
+		lk := strings.ToLower(key)
+		switch {
+		case strings.Contains(lk, "mode"):
+			st.Mode = value
+		case strings.HasPrefix(lk, "total"):
+			st.Total = watts(value, key)
+		case strings.HasPrefix(lk, "consum"):
+			st.Consuming = watts(value, key)
+		case strings.HasPrefix(lk, "allocated"):
+			st.Allocated = watts(value, key)
+		case strings.HasPrefix(lk, "remain"):
+			st.Remaining = watts(value, key)
+		}
+	}
+
+	for _, row := range parseTable(output, "Port") {
+		p, err := parsePort(row["Port"])
+		if err != nil {
+			continue
+		}
+		pp := PoEPort{
+			Port:     p,
+			State:    firstOf(row, "State", "Admin State", "Admin"),
+			Class:    firstOf(row, "Class"),
+			Priority: firstOf(row, "PD Priority", "Priority"),
+		}
+		for name, v := range row {
+			ln := strings.ToLower(name)
+			switch {
+			case strings.HasPrefix(ln, "max"):
+				pp.MaxW = watts(v, name)
+			case strings.HasPrefix(ln, "consum") || ln == "power(mw)" || ln == "power(w)":
+				pp.PowerW = watts(v, name)
+			}
+		}
+		st.Ports = append(st.Ports, pp)
+	}
+
+	if st.Consuming == 0 {
+		for _, p := range st.Ports {
+			st.Consuming += p.PowerW
+		}
+	}
+	if st.Remaining == 0 && st.Total > 0 {
+		st.Remaining = st.Total - st.Consuming
+	}
+	return st
+}
+
+// poeStatus collects the PoE budget and port table from the switch.
+func poeStatus(s *Session) (PoEStatus, error) {
+	out, err := s.Output(s.command("poe"))
+	if err != nil {
+		return PoEStatus{}, err
+	}
+	st := parsePoEStatus(out)
+	if len(st.Ports) == 0 && st.Total == 0 {
+		return st, fmt.Errorf("no PoE status in %q output; does the switch support PoE?", s.command("poe"))
+	}
+	return st, nil
+}
+
+// poeCommands returns the configuration commands that turn PoE on or off
+// on ports, a port list in the notation of the switch, in the syntax cmd
+// gives.
+func poeCommands(cmd func(operation string) string, ports string, on bool) []string {
+	power := cmd("poe-power")
+	if !on {
+		power = "no " + power
+	}
+	return []string{cmd("port-config") + " " + ports, power}
+}
+
+var poeCommandList = []subcommand{
+	{"status", "Show the PoE power budget and the draw of each port", runPoEStatus,
+		"\"Key: value\" budget lines, a blank line, then a table: Port, State, Class, Priority, Power (W), Max (W)", nil},
+	{"off", "Turn PoE off on ports", runPoEOff, "nothing; status goes to stderr", nil},
+	{"on", "Turn PoE on on ports", runPoEOn, "nothing; status goes to stderr", nil},
+	{"cycle", "Power-cycle the devices on ports", runPoECycle, "nothing; status goes to stderr", nil},
+}
+
+func runPoE(fs *flag.FlagSet) func() {
+	return func() {
+		args := fs.Args()
+		if len(args) == 0 {
+			fmt.Println("Usage: zyxel poe <command> [flags]")
+			fmt.Println()
+			fmt.Println("Commands:")
+			for _, c := range poeCommandList {
+				fmt.Printf("  %-10s %s\n", c.name, c.summary)
+			}
+			os.Exit(1)
+		}
+		for _, c := range poeCommandList {
+			if c.name == args[0] {
+				c.invoke("poe "+c.name, args[1:])
+				return
+			}
+		}
+		fatal("Unknown poe command %q", args[0])
+	}
+}
+
+func runPoEStatus(fs *flag.FlagSet) func() {
+	cf := addConnFlags(fs)
+	return func() {
+		var ports []Port
+		if fs.NArg() > 0 {
+			var err error
+			if ports, err = ParsePortList(fs.Arg(0)); err != nil {
+				fatal("%v", err)
+			}
+		}
+		_, s := cf.connect()
+		defer s.Close()
+
+		var filter map[Port]bool
+		if ports != nil {
+			d, err := s.portDialect()
+			if err != nil {
+				fatal("%v", err)
+			}
+			filter = make(map[Port]bool)
+			for _, p := range ports {
+				if np, err := p.normalize(d); err == nil {
+					filter[np] = true
+				}
+			}
+		}
+
+		st, err := poeStatus(s)
+		if err != nil {
+			fatal("%v", err)
+		}
+		if st.Mode != "" {
+			fmt.Printf("Mode: %s\n", st.Mode)
+		}
+		used := ""
+		if st.Total > 0 {
+			used = fmt.Sprintf(" (%.0f%%)", st.Consuming/st.Total*100)
+		}
+		fmt.Printf("Total: %.1f W\n", st.Total)
+		fmt.Printf("Consuming: %.1f W%s\n", st.Consuming, used)
+		if st.Allocated > 0 {
+			fmt.Printf("Allocated: %.1f W\n", st.Allocated)
+		}
+		fmt.Printf("Remaining: %.1f W\n", st.Remaining)
+		fmt.Println()
+
+		fmt.Printf("%-8s %-10s %-6s %-9s %9s %8s\n", "Port", "State", "Class", "Priority", "Power (W)", "Max (W)")
+		for _, p := range st.Ports {
+			if filter != nil && !filter[p.Port] {
+				continue
+			}
+			fmt.Printf("%-8s %-10s %-6s %-9s %9.1f %8.1f\n", p.Port, p.State, p.Class, p.Priority, p.PowerW, p.MaxW)
+		}
+	}
+}
+
+// poeFlags are shared by the subcommands that switch PoE.
+type poeFlags struct {
+	conn        *connFlags
+	allowUplink *bool
+	dryRun      *bool
+}
+
+func addPoEFlags(fs *flag.FlagSet) *poeFlags {
+	return &poeFlags{
+		conn:        addConnFlags(fs),
+		allowUplink: fs.Bool("allow-uplink", false, tr("Change ports even if they look like uplinks")),
+		dryRun:      addDryRunFlag(fs),
+	}
+}
+
+// connect checks the port argument, opens a session and refuses uplinks
+// and switches whose configuration syntax is not known.
+func (pf *poeFlags) connect(fs *flag.FlagSet, command string) (string, *Session) {
+	if fs.NArg() != 1 {
+		fatal("Usage: zyxel poe %s <ports>", command)
+	}
+	ports, err := ParsePortList(fs.Arg(0))
+	if err != nil {
+		fatal("%v", err)
+	}
+	_, s := pf.conn.connect()
+	s.dryRun = *pf.dryRun
+	if err := s.checkConfigSyntax(); err != nil {
+		s.Close()
+		fatal("%v", err)
+	}
+	if err := guardUplinks(s, ports, *pf.allowUplink); err != nil {
+		s.Close()
+		fatal("%v", err)
+	}
+	list, err := s.portList(fs.Arg(0))
+	if err != nil {
+		s.Close()
+		fatal("%v", err)
+	}
+	return list, s
+}
+
+// setPoE switches PoE on ports and reports it on stderr.
+func setPoE(s *Session, ports string, on bool) error {
+	if err := s.Configure(poeCommands(s.command, ports, on), io.Discard); err != nil {
+		return err
+	}
+	if s.dryRun {
+		return nil
+	}
+	state := "off"
+	if on {
+		state = "on"
+	}
+	fmt.Fprintf(os.Stderr, "Port %s: PoE %s\n", ports, state)
+	return nil
+}
+
+func runPoEOff(fs *flag.FlagSet) func() {
+	pf := addPoEFlags(fs)
+	save := fs.Bool("save", false, tr("Write memory afterwards so the port stays off after a reboot"))
+	return func() {
+		ports, s := pf.connect(fs, "off")
+		defer s.Close()
+		if err := setPoE(s, ports, false); err != nil {
+			fatal("%v", err)
+		}
+		if *save {
+			saveConfig(s)
+		}
+	}
+}
+
+func runPoEOn(fs *flag.FlagSet) func() {
+	pf := addPoEFlags(fs)
+	save := fs.Bool("save", false, tr("Write memory afterwards"))
+	return func() {
+		ports, s := pf.connect(fs, "on")
+		defer s.Close()
+		if err := setPoE(s, ports, true); err != nil {
+			fatal("%v", err)
+		}
+		if *save {
+			saveConfig(s)
+		}
+	}
+}
+
+func runPoECycle(fs *flag.FlagSet) func() {
+	pf := addPoEFlags(fs)
+	wait := fs.Duration("wait", 5*time.Second, tr("How long the ports stay unpowered"))
+	return func() {
+		ports, s := pf.connect(fs, "cycle")
+		defer s.Close()
+		if err := setPoE(s, ports, false); err != nil {
+			fatal("%v", err)
+		}
+		if !s.dryRun {
+			time.Sleep(*wait)
+		}
+		if err := setPoE(s, ports, true); err != nil {
+			fatal("PoE is still OFF on port %s: %v", ports, err)
+		}
+	}
+}
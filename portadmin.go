@@ -0,0 +1,195 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// portName returns the description set by the name command in commands,
+// without the quotes the switch may add.
+func portName(name string, commands []string) string {
+	for _, c := range commands {
+		if rest, ok := strings.CutPrefix(c, name+" "); ok {
+			if s, err := strconv.Unquote(strings.TrimSpace(rest)); err == nil {
+				return s
+			}
+			return strings.TrimSpace(rest)
+		}
+	}
+	return ""
+}
+
+// nameCommand returns the name command that sets the description of a
+// port or VLAN to text, quoting text that contains spaces. An empty text
+// removes it.
+func nameCommand(name, text string) string {
+	switch {
+	case text == "":
+		return "no " + name
+	case strings.ContainsAny(text, " \t"):
+		return name + " " + strconv.Quote(text)
+	}
+	return name + " " + text
+}
+
+var portCommandList = []subcommand{
+	{"enable", "Enable ports", runPortEnable, "nothing; status goes to stderr", nil},
+	{"disable", "Shut ports down", runPortDisable, "nothing; status goes to stderr", nil},
+	{"describe", "Set the description of ports", runPortDescribe, "nothing; status goes to stderr", nil},
+}
+
+func runPort(fs *flag.FlagSet) func() {
+	return func() {
+		args := fs.Args()
+		if len(args) == 0 {
+			fmt.Println("Usage: zyxel port <command> [flags]")
+			fmt.Println()
+			fmt.Println("Commands:")
+			for _, c := range portCommandList {
+				fmt.Printf("  %-10s %s\n", c.name, c.summary)
+			}
+			os.Exit(1)
+		}
+		for _, c := range portCommandList {
+			if c.name == args[0] {
+				c.invoke("port "+c.name, args[1:])
+				return
+			}
+		}
+		fatal("Unknown port command %q", args[0])
+	}
+}
+
+// portFlags are shared by the port subcommands.
+type portFlags struct {
+	conn        *connFlags
+	save        *bool
+	allowUplink *bool
+	dryRun      *bool
+}
+
+func addPortFlags(fs *flag.FlagSet) *portFlags {
+	return &portFlags{
+		conn:        addConnFlags(fs),
+		save:        fs.Bool("save", false, tr("Write memory after the change is verified")),
+		allowUplink: fs.Bool("allow-uplink", false, tr("Change ports even if they look like uplinks")),
+		dryRun:      addDryRunFlag(fs),
+	}
+}
+
+// change configures ports with the commands returned by commands, in the
+// configuration syntax of the switch, reads the running-config back and
+// hands each port's commands to verify. When all is well it reports done
+// and saves if asked.
+func (pf *portFlags) change(list string, guard bool, commands func(cmd func(string) string) []string, done string, verify func(p Port, commands []string) error) {
+	ports, err := ParsePortList(list)
+	if err != nil {
+		fatal("%v", err)
+	}
+	_, s := pf.conn.connect()
+	defer s.Close()
+	s.dryRun = *pf.dryRun
+	if err := s.checkConfigSyntax(); err != nil {
+		fatal("%v", err)
+	}
+	if guard {
+		if err := guardUplinks(s, ports, *pf.allowUplink); err != nil {
+			fatal("%v", err)
+		}
+	}
+
+	d, err := s.portDialect()
+	if err != nil {
+		fatal("%v", err)
+	}
+	list, err = FormatPortList(ports, d)
+	if err != nil {
+		fatal("%v", err)
+	}
+	if err := s.Configure(append([]string{s.command("port-config") + " " + list}, commands(s.command)...), io.Discard); err != nil {
+		fatal("%v", err)
+	}
+	if s.dryRun {
+		if *pf.save {
+			saveConfig(s)
+		}
+		return
+	}
+	rc, err := runningConfig(s)
+	if err != nil {
+		fatal("%v", err)
+	}
+	for _, p := range ports {
+		np, _ := p.normalize(d)
+		if err := verify(p, rc.Ports[np]); err != nil {
+			fatal("%v", err)
+		}
+	}
+	fmt.Fprintf(os.Stderr, tr("Port %s: %s")+"\n", list, tr(done))
+	if *pf.save {
+		saveConfig(s)
+	}
+}
+
+func runPortEnable(fs *flag.FlagSet) func() {
+	pf := addPortFlags(fs)
+	return func() {
+		if fs.NArg() != 1 {
+			fatal("Usage: zyxel port enable <ports>")
+		}
+		var inactive string
+		pf.change(fs.Arg(0), false, func(cmd func(string) string) []string {
+			inactive = cmd("port-inactive")
+			return []string{"no " + inactive}
+		}, "enabled", func(p Port, commands []string) error {
+			if slices.Contains(commands, inactive) {
+				return errorf("port %s is still inactive in the running-config", p)
+			}
+			return nil
+		})
+	}
+}
+
+func runPortDisable(fs *flag.FlagSet) func() {
+	pf := addPortFlags(fs)
+	return func() {
+		if fs.NArg() != 1 {
+			fatal("Usage: zyxel port disable <ports>")
+		}
+		var inactive string
+		pf.change(fs.Arg(0), true, func(cmd func(string) string) []string {
+			inactive = cmd("port-inactive")
+			return []string{inactive}
+		}, "disabled", func(p Port, commands []string) error {
+			if !slices.Contains(commands, inactive) {
+				return errorf("port %s is not inactive in the running-config", p)
+			}
+			return nil
+		})
+	}
+}
+
+func runPortDescribe(fs *flag.FlagSet) func() {
+	pf := addPortFlags(fs)
+	return func() {
+		if fs.NArg() != 2 {
+			fatal("Usage: zyxel port describe <ports> \"<text>\"")
+		}
+		text := fs.Arg(1)
+		var name string
+		pf.change(fs.Arg(0), false, func(cmd func(string) string) []string {
+			name = cmd("port-name")
+			return []string{nameCommand(name, text)}
+		}, "description set", func(p Port, commands []string) error {
+			if got := portName(name, commands); got != text {
+				return errorf("port %s is named %q in the running-config, not %q", p, got, text)
+			}
+			return nil
+		})
+	}
+}
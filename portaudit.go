@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// expectedDevice is one row of an expected device-to-port mapping, as
+// exported from a CMDB. Either MAC or LLDP (the neighbor's system name)
+// identifies the device.
+type expectedDevice struct {
+	Host   string `json:"host"`
+	Port   string `json:"port"`
+	MAC    string `json:"mac"`
+	LLDP   string `json:"lldp"`
+	Device string `json:"device"`
+}
+
+// loadExpected reads a mapping from a .json file (an array of objects) or
+// a CSV file with a header row naming the host, port, mac, lldp and device
+// columns.
+func loadExpected(path string) ([]expectedDevice, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open mapping: %w", err)
+	}
+	defer f.Close()
+
+	var devices []expectedDevice
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		if err := json.NewDecoder(f).Decode(&devices); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	} else {
+		r := csv.NewReader(f)
+		r.FieldsPerRecord = -1
+		rows, err := r.ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		if len(rows) == 0 {
+			return nil, fmt.Errorf("%s is empty", path)
+		}
+		col := make(map[string]int)
+		for i, name := range rows[0] {
+			col[strings.ToLower(strings.TrimSpace(name))] = i
+		}
+		get := func(row []string, name string) string {
+			if i, ok := col[name]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+		for _, row := range rows[1:] {
+			devices = append(devices, expectedDevice{
+				Host:   get(row, "host"),
+				Port:   get(row, "port"),
+				MAC:    get(row, "mac"),
+				LLDP:   get(row, "lldp"),
+				Device: get(row, "device"),
+			})
+		}
+	}
+
+	for i, d := range devices {
+		if d.Host == "" || d.Port == "" || (d.MAC == "" && d.LLDP == "") {
+			return nil, fmt.Errorf("%s: entry %d needs host, port and a mac or lldp name", path, i+1)
+		}
+		if d.MAC != "" {
+			mac, err := normalizeMAC(d.MAC)
+			if err != nil {
+				return nil, fmt.Errorf("%s: entry %d: %w", path, i+1, err)
+			}
+			devices[i].MAC = mac
+		}
+	}
+	return devices, nil
+}
+
+func runAuditPorts(fs *flag.FlagSet) func() {
+	ff := addFleetFlags(fs)
+	expectedPath := fs.String("expected", "", tr("CSV or JSON `file` mapping devices to switch ports"))
+	return func() {
+		if *expectedPath == "" {
+			fatal("Usage: zyxel audit ports --expected map.csv")
+		}
+		expected, err := loadExpected(*expectedPath)
+		if err != nil {
+			fatal("%v", err)
+		}
+
+		_, hosts := ff.load()
+		results := runFleet(hosts, ff, func(h Host, s *Session) (macData, error) {
+			entries, err := macTable(s)
+			if err != nil {
+				return macData{}, err
+			}
+			neighbors, err := lldpNeighbors(s)
+			return macData{entries, neighbors}, err
+		})
+
+		reportFindings(results, auditPorts(expected, results))
+	}
+}
+
+// auditPorts compares the observed MAC and LLDP identity of each expected
+// port with the mapping.
+func auditPorts(expected []expectedDevice, results []fleetResult[macData]) []finding {
+	type place struct {
+		host string
+		port Port
+	}
+	macs := make(map[place][]string)
+	lldp := make(map[place]string)
+	macAt := make(map[string][]string)
+	audited := make(map[string]bool)
+
+	for _, r := range results {
+		if r.Err != nil {
+			continue
+		}
+		audited[r.Host.Name] = true
+		for _, e := range r.Value.entries {
+			if p, err := parsePort(e.Port); err == nil {
+				macs[place{r.Host.Name, p}] = append(macs[place{r.Host.Name, p}], e.MAC)
+				macAt[e.MAC] = append(macAt[e.MAC], fmt.Sprintf("%s port %s", r.Host.Name, p))
+			}
+		}
+		for _, n := range r.Value.neighbors {
+			if p, err := parsePort(n.LocalPort); err == nil {
+				lldp[place{r.Host.Name, p}] = n.SystemName
+			}
+		}
+	}
+
+	var findings []finding
+	for _, d := range expected {
+		if !audited[d.Host] {
+			continue
+		}
+		p, err := parsePort(d.Port)
+		if err != nil {
+			findings = append(findings, finding{d.Host, fmt.Sprintf("mapping has invalid port %q", d.Port)})
+			continue
+		}
+		at := place{d.Host, p}
+		label := d.Device
+		if label == "" {
+			label = strings.TrimSpace(d.MAC + " " + d.LLDP)
+		}
+
+		if d.MAC != "" && !slices.Contains(macs[at], d.MAC) {
+			msg := fmt.Sprintf("port %s: expected %s (%s) not seen", p, label, d.MAC)
+			if elsewhere := macAt[d.MAC]; len(elsewhere) > 0 {
+				msg += ", found on " + strings.Join(elsewhere, ", ")
+			}
+			if len(macs[at]) > 0 {
+				msg += "; port has " + strings.Join(macs[at], ", ")
+			}
+			findings = append(findings, finding{d.Host, msg})
+		}
+		if d.LLDP != "" && !strings.EqualFold(lldp[at], d.LLDP) {
+			got := lldp[at]
+			if got == "" {
+				got = "no LLDP neighbor"
+			}
+			findings = append(findings, finding{d.Host, fmt.Sprintf(
+				"port %s: expected LLDP neighbor %s (%s), found %s", p, d.LLDP, label, got)})
+		}
+	}
+	return findings
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Port is a single switch port. Unit and Slot are 0 when the notation
+// did not include them (plain "7" instead of "1/7" or "1/1/7").
+type Port struct {
+	Unit int
+	Slot int
+	Num  int
+}
+
+// Dialect controls how port lists are written when generating commands.
+type Dialect int
+
+const (
+	// DialectFlat is the standalone GS/XGS notation: 1-8,10,12-24
+	DialectFlat Dialect = iota
+	// DialectSlot is the stacking notation: 1/1-1/12
+	DialectSlot
+	// DialectUnitSlot is the unit/slot/port notation: 1/1/1-1/1/12
+	DialectUnitSlot
+)
+
+func parseDialect(s string) (Dialect, error) {
+	switch strings.ToLower(s) {
+	case "", "flat":
+		return DialectFlat, nil
+	case "slot":
+		return DialectSlot, nil
+	case "unit-slot", "unitslot":
+		return DialectUnitSlot, nil
+	}
+	return DialectFlat, errorf("unknown port dialect %q (want flat, slot or unit-slot)", s)
+}
+
+func (p Port) String() string {
+	switch {
+	case p.Unit > 0 && p.Slot > 0:
+		return fmt.Sprintf("%d/%d/%d", p.Unit, p.Slot, p.Num)
+	case p.Unit > 0:
+		return fmt.Sprintf("%d/%d", p.Unit, p.Num)
+	}
+	return strconv.Itoa(p.Num)
+}
+
+// MarshalJSON writes p the way the switch does, as "5", "1/5" or "1/1/5".
+func (p Port) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.String())
+}
+
+// UnmarshalJSON reads a port written by MarshalJSON.
+func (p *Port) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	port, err := parsePort(s)
+	if err != nil {
+		return err
+	}
+	*p = port
+	return nil
+}
+
+func (p Port) less(q Port) bool {
+	if p.Unit != q.Unit {
+		return p.Unit < q.Unit
+	}
+	if p.Slot != q.Slot {
+		return p.Slot < q.Slot
+	}
+	return p.Num < q.Num
+}
+
+// parsePort parses "7", "1/7" or "1/1/7".
+func parsePort(s string) (Port, error) {
+	parts := strings.Split(strings.TrimSpace(s), "/")
+	if len(parts) > 3 {
+		return Port{}, errorf("invalid port %q", s)
+	}
+	nums := make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 1 {
+			return Port{}, errorf("invalid port %q", s)
+		}
+		nums[i] = n
+	}
+	switch len(nums) {
+	case 1:
+		return Port{Num: nums[0]}, nil
+	case 2:
+		return Port{Unit: nums[0], Num: nums[1]}, nil
+	}
+	return Port{Unit: nums[0], Slot: nums[1], Num: nums[2]}, nil
+}
+
+// ParsePortList expands a port list such as "1-8,10,12-24", "1/1-1/12" or
+// "1/1/1-1/1/12" into individual ports. The end of a range may be given in
+// full or as a bare port number ("1/1/1-12"). The result is sorted and
+// free of duplicates.
+func ParsePortList(s string) ([]Port, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, errorf("empty port list")
+	}
+
+	seen := make(map[Port]bool)
+	var ports []Port
+	add := func(p Port) {
+		if !seen[p] {
+			seen[p] = true
+			ports = append(ports, p)
+		}
+	}
+
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		from, to, isRange := strings.Cut(item, "-")
+		start, err := parsePort(from)
+		if err != nil {
+			return nil, err
+		}
+		if !isRange {
+			add(start)
+			continue
+		}
+
+		end, err := parsePort(to)
+		if err != nil {
+			return nil, err
+		}
+		if end.Unit == 0 && end.Slot == 0 {
+			end.Unit, end.Slot = start.Unit, start.Slot
+		}
+		if end.Unit != start.Unit || end.Slot != start.Slot {
+			return nil, errorf("range %q spans units or slots", item)
+		}
+		if end.Num < start.Num {
+			return nil, errorf("range %q is reversed", item)
+		}
+		for n := start.Num; n <= end.Num; n++ {
+			add(Port{Unit: start.Unit, Slot: start.Slot, Num: n})
+		}
+	}
+
+	if len(ports) == 0 {
+		return nil, errorf("empty port list")
+	}
+	sort.Slice(ports, func(i, j int) bool { return ports[i].less(ports[j]) })
+	return ports, nil
+}
+
+// normalize rewrites p into the shape expected by dialect d. Missing unit
+// and slot default to 1; dropping a unit or slot other than 1 is an error.
+func (p Port) normalize(d Dialect) (Port, error) {
+	switch d {
+	case DialectFlat:
+		if (p.Unit > 1) || (p.Slot > 1) {
+			return Port{}, errorf("port %s cannot be written in flat notation", p)
+		}
+		return Port{Num: p.Num}, nil
+	case DialectSlot:
+		if p.Slot > 1 {
+			return Port{}, errorf("port %s cannot be written in slot notation", p)
+		}
+		unit := p.Unit
+		if unit == 0 {
+			unit = 1
+		}
+		return Port{Unit: unit, Num: p.Num}, nil
+	}
+	unit, slot := p.Unit, p.Slot
+	if unit == 0 {
+		unit = 1
+	}
+	if slot == 0 {
+		slot = 1
+	}
+	return Port{Unit: unit, Slot: slot, Num: p.Num}, nil
+}
+
+// FormatPortList writes ports in dialect d, collapsing consecutive ports
+// into ranges: [1 2 3 5] becomes "1-3,5" or "1/1-1/3,1/5".
+func FormatPortList(ports []Port, d Dialect) (string, error) {
+	norm := make([]Port, 0, len(ports))
+	seen := make(map[Port]bool)
+	for _, p := range ports {
+		np, err := p.normalize(d)
+		if err != nil {
+			return "", err
+		}
+		if !seen[np] {
+			seen[np] = true
+			norm = append(norm, np)
+		}
+	}
+	sort.Slice(norm, func(i, j int) bool { return norm[i].less(norm[j]) })
+
+	var parts []string
+	for i := 0; i < len(norm); {
+		j := i
+		for j+1 < len(norm) && norm[j+1].Unit == norm[i].Unit &&
+			norm[j+1].Slot == norm[i].Slot && norm[j+1].Num == norm[j].Num+1 {
+			j++
+		}
+		if j == i {
+			parts = append(parts, norm[i].String())
+		} else {
+			parts = append(parts, norm[i].String()+"-"+norm[j].String())
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ","), nil
+}
+
+// portListCommand matches commands whose last argument is a port list.
+var portListCommand = regexp.MustCompile(`^(\s*(?:no\s+)?(?:interface\s+port-channel|show\s+interfaces(?:\s+status)?|fixed|untagged|forbidden|normal)\s+)(\S+)\s*$`)
+
+// expandPortList rewrites the port list a command ends with, as in "show
+// interfaces 1-4", in the notation dialect returns. dialect is only asked
+// when there is a port list; arguments that are not one, such as "*", are
+// left alone.
+func expandPortList(command string, dialect func() (Dialect, error)) (string, error) {
+	m := portListCommand.FindStringSubmatch(command)
+	if m == nil {
+		return command, nil
+	}
+	ports, err := ParsePortList(m[2])
+	if err != nil {
+		return command, nil
+	}
+	d, err := dialect()
+	if err != nil {
+		return "", err
+	}
+	list, err := FormatPortList(ports, d)
+	if err != nil {
+		return "", err
+	}
+	return m[1] + list, nil
+}
+
+// dialectOf guesses the port notation from the ports a running-config
+// names, e.g. "interface port-channel 1/1/5" on a stack. Without any
+// port blocks it assumes flat.
+func dialectOf(rc *RunningConfig) Dialect {
+	d := DialectFlat
+	for p := range rc.Ports {
+		switch {
+		case p.Slot > 0:
+			return DialectUnitSlot
+		case p.Unit > 0:
+			d = DialectSlot
+		}
+	}
+	return d
+}
+
+// portDialect returns the port notation of the switch, reading the
+// running-config the first time unless ZYXEL_PORT_DIALECT set it or an
+// earlier run learned it.
+func (s *Session) portDialect() (Dialect, error) {
+	if !s.dialectKnown {
+		if d, ok := learnedPortDialect(s.host, s.modelName); ok {
+			s.dialect, s.dialectKnown = d, true
+			return d, nil
+		}
+		var rc *RunningConfig
+		err := s.retry(s.command("running-config"), nil, func() (err error) {
+			rc, err = runningConfig(s)
+			return err
+		})
+		if err != nil {
+			return DialectFlat, errorf("failed to learn the port notation: %w", err)
+		}
+		s.dialect, s.dialectKnown = dialectOf(rc), true
+		learnPortDialect(s.host, s.modelName, s.dialect)
+	}
+	return s.dialect, nil
+}
+
+// portDialects maps a switch address to the port notation learned from its
+// running-config, and the model it was learned on, so that later runs do
+// not read the running-config again.
+const portDialects = "dialects.json"
+
+type learnedDialect struct {
+	Model   string  `json:"model"`
+	Dialect Dialect `json:"dialect"`
+}
+
+func loadPortDialects() map[string]learnedDialect {
+	known := make(map[string]learnedDialect)
+	if dir, err := stateDir(); err == nil {
+		if data, err := os.ReadFile(filepath.Join(dir, portDialects)); err == nil {
+			json.Unmarshal(data, &known)
+		}
+	}
+	return known
+}
+
+// learnedPortDialect returns the notation an earlier run learned for host,
+// unless the switch has since been replaced by another model. An empty
+// model, as without a banner to tell it, matches any.
+func learnedPortDialect(host, model string) (Dialect, bool) {
+	l, ok := loadPortDialects()[host]
+	if !ok || host == "" || model != "" && l.Model != "" && l.Model != model {
+		return DialectFlat, false
+	}
+	return l.Dialect, true
+}
+
+// learnPortDialect records the notation of host for later runs. It is only
+// a cache: when it cannot be written, the next run reads the
+// running-config again.
+func learnPortDialect(host, model string, d Dialect) {
+	dir, err := stateDir()
+	if err != nil || host == "" || os.MkdirAll(dir, 0o755) != nil {
+		return
+	}
+	known := loadPortDialects()
+	known[host] = learnedDialect{Model: model, Dialect: d}
+	if data, err := json.MarshalIndent(known, "", "  "); err == nil {
+		os.WriteFile(filepath.Join(dir, portDialects), data, 0o644)
+	}
+}
+
+// portList rewrites a port list such as "1-4,9" in the notation of the
+// switch, e.g. "1/1-1/4,1/9" on a stack.
+func (s *Session) portList(list string) (string, error) {
+	ports, err := ParsePortList(list)
+	if err != nil {
+		return "", err
+	}
+	d, err := s.portDialect()
+	if err != nil {
+		return "", err
+	}
+	return FormatPortList(ports, d)
+}
+
+// expandPorts rewrites the port lists in commands in the notation of the
+// switch. Arguments that are not port lists, such as "*", are left alone.
+func (s *Session) expandPorts(commands []string) ([]string, error) {
+	out := make([]string, len(commands))
+	for i, c := range commands {
+		var err error
+		if out[i], err = expandPortList(c, s.portDialect); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
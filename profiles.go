@@ -0,0 +1,176 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Profile is a named set of connection settings from the config file, so
+// each switch need not have its own .env.
+type Profile struct {
+	Host         string `yaml:"host"`
+	Port         string `yaml:"port"`
+	User         string `yaml:"user"`
+	PasswordFile string `yaml:"password_file"`
+	Transport    string `yaml:"transport"`
+	// Auth is "password" (default) or "key".
+	Auth           string        `yaml:"auth"`
+	KeyFile        string        `yaml:"key_file"`
+	PromptRegex    string        `yaml:"prompt_regex"`
+	PagerCommand   string        `yaml:"pager_command"`
+	Model          string        `yaml:"model"`
+	ConnectTimeout time.Duration `yaml:"connect_timeout"`
+	CommandTimeout time.Duration `yaml:"command_timeout"`
+	KeepAlive      string        `yaml:"keep_alive"`
+	TermWidth      int           `yaml:"term_width"`
+	TermHeight     int           `yaml:"term_height"`
+	WrapWidth      int           `yaml:"wrap_width"`
+	PortDialect    string        `yaml:"port_dialect"`
+
+	// Commands override the CLI command of operations, e.g.
+	// mac-table: show mac address-table.
+	Commands map[string]string `yaml:"commands"`
+}
+
+// configPath returns ZYXEL_CONFIG, or config.yaml in the zyxel directory
+// under XDG_CONFIG_HOME (default ~/.config).
+func configPath() (string, error) {
+	if path := os.Getenv("ZYXEL_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", errorf("failed to locate config file: %w", err)
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "zyxel", "config.yaml"), nil
+}
+
+// configFile is the config file: connection profiles and command aliases.
+type configFile struct {
+	Profiles map[string]Profile `yaml:"profiles"`
+	Aliases  map[string]string  `yaml:"aliases"`
+}
+
+// loadConfigFile reads the config file and returns it with its path.
+func loadConfigFile() (*configFile, string, error) {
+	path, err := configPath()
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, path, errorf("failed to read config file: %w", err)
+	}
+	var file configFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, path, errorf("failed to parse config file %s: %w", path, err)
+	}
+	return &file, path, nil
+}
+
+// loadProfiles reads the profiles of the config file and returns them
+// with the path of the file.
+func loadProfiles() (map[string]Profile, string, error) {
+	file, path, err := loadConfigFile()
+	if err != nil {
+		return nil, path, err
+	}
+	return file.Profiles, path, nil
+}
+
+// loadProfile reads the named profile from the config file.
+func loadProfile(name string) (Profile, error) {
+	profiles, path, err := loadProfiles()
+	if err != nil {
+		return Profile{}, err
+	}
+	p, ok := profiles[name]
+	if !ok {
+		return Profile{}, errorf("no profile %q in %s", name, path)
+	}
+	return p, nil
+}
+
+// apply overrides cfg with the settings the profile sets.
+func (p Profile) apply(cfg *Config) error {
+	if p.Host != "" {
+		cfg.Host = p.Host
+	}
+	if p.Port != "" {
+		cfg.Port = p.Port
+	}
+	if p.User != "" {
+		cfg.User = p.User
+	}
+	if p.Transport != "" {
+		cfg.Transport = p.Transport
+	}
+	if p.Auth != "" {
+		cfg.Auth = p.Auth
+	}
+	if p.KeyFile != "" {
+		cfg.KeyFile = expandHome(p.KeyFile)
+	}
+	if p.PromptRegex != "" {
+		cfg.PromptRegex = p.PromptRegex
+	}
+	if p.PagerCommand != "" {
+		cfg.PagerCommand = p.PagerCommand
+	}
+	if p.Model != "" {
+		cfg.Model = p.Model
+	}
+	if p.Commands != nil {
+		cfg.Commands = p.Commands
+	}
+	if p.PasswordFile != "" {
+		data, err := os.ReadFile(expandHome(p.PasswordFile))
+		if err != nil {
+			return err
+		}
+		cfg.Password = strings.TrimRight(string(data), "\r\n")
+	}
+	if p.ConnectTimeout != 0 {
+		cfg.ConnectTimeout = p.ConnectTimeout
+	}
+	if p.CommandTimeout != 0 {
+		cfg.CommandTimeout = p.CommandTimeout
+	}
+	if p.KeepAlive != "" {
+		cfg.KeepAlive = keepAliveSetting(p.KeepAlive)
+	}
+	if p.TermWidth != 0 {
+		cfg.TermWidth = p.TermWidth
+	}
+	if p.TermHeight != 0 {
+		cfg.TermHeight = p.TermHeight
+	}
+	if p.WrapWidth != 0 {
+		cfg.WrapWidth = p.WrapWidth
+	}
+	if p.PortDialect != "" {
+		cfg.PortDialect = p.PortDialect
+	}
+	return nil
+}
+
+// expandHome replaces a leading "~/" with the home directory.
+func expandHome(path string) string {
+	rest, ok := strings.CutPrefix(path, "~/")
+	if !ok {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, rest)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/itchyny/gojq"
+)
+
+// compileQuery compiles a --query jq expression, so that a typo fails
+// before connecting.
+func compileQuery(expr string) (*gojq.Code, error) {
+	q, err := gojq.Parse(expr)
+	if err != nil {
+		return nil, errorf("invalid --query: %w", err)
+	}
+	code, err := gojq.Compile(q)
+	if err != nil {
+		return nil, errorf("invalid --query: %w", err)
+	}
+	return code, nil
+}
+
+// runQuery runs a compiled query on the result of a parser as --format
+// json writes it, and returns the values it yields.
+func runQuery(code *gojq.Code, data any) ([]any, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	out := []any{}
+	iter := code.Run(v)
+	for {
+		x, ok := iter.Next()
+		if !ok {
+			return out, nil
+		}
+		if err, ok := x.(error); ok {
+			return nil, errorf("query: %w", err)
+		}
+		out = append(out, x)
+	}
+}
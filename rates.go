@@ -0,0 +1,227 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+// Packet counters in "show interfaces", by preference; models differ in
+// which they show.
+var (
+	rxPacketKeys = []string{"Port Info/Rx Pkt", "RX Packet/Rx Packets", "RX Packet/Unicast"}
+	txPacketKeys = []string{"Port Info/Tx Pkt", "TX Packet/Tx Packets", "TX Packet/Unicast"}
+)
+
+// portRate is how one port's counters moved between two samples.
+type portRate struct {
+	Port         string
+	RxPps, TxPps float64
+	// RxKBps and TxKBps are the switch's own rates at the second sample;
+	// the CLI has no byte counters.
+	RxKBps, TxKBps float64
+	Errors         uint64
+	// Counters are the absolute counters of the second sample.
+	Counters map[string]uint64
+	// ErrorDeltas holds the error counters that increased, e.g. "RX CRC".
+	ErrorDeltas map[string]uint64
+}
+
+// counterDelta returns how far a counter moved, treating a decrease as a
+// reset to zero in between.
+func counterDelta(before, after uint64) uint64 {
+	if after < before {
+		return after
+	}
+	return after - before
+}
+
+// firstCounter returns the delta of the first of keys present in both
+// samples.
+func firstCounter(a, b Interface, keys []string) (uint64, bool) {
+	for _, k := range keys {
+		before, ok1 := a.Counters[k]
+		after, ok2 := b.Counters[k]
+		if ok1 && ok2 {
+			return counterDelta(before, after), true
+		}
+	}
+	return 0, false
+}
+
+// interfaceRates compares two samples of the same ports taken elapsed apart.
+func interfaceRates(first, second []Interface, elapsed time.Duration) []portRate {
+	earlier := make(map[string]Interface, len(first))
+	for _, i := range first {
+		earlier[i.Port] = i
+	}
+	secs := elapsed.Seconds()
+
+	var rates []portRate
+	for _, b := range second {
+		a, ok := earlier[b.Port]
+		if !ok {
+			continue
+		}
+		r := portRate{Port: b.Port, RxKBps: b.RxKBps, TxKBps: b.TxKBps, Counters: b.Counters, ErrorDeltas: map[string]uint64{}}
+		if n, ok := firstCounter(a, b, rxPacketKeys); ok {
+			r.RxPps = float64(n) / secs
+		}
+		if n, ok := firstCounter(a, b, txPacketKeys); ok {
+			r.TxPps = float64(n) / secs
+		}
+		for k, after := range b.Counters {
+			name, ok := strings.CutPrefix(k, "Error Packet/")
+			if !ok {
+				continue
+			}
+			if d := counterDelta(a.Counters[k], after); d > 0 {
+				r.ErrorDeltas[name] = d
+				r.Errors += d
+			}
+		}
+		if d, ok := firstCounter(a, b, []string{"Port Info/Errors"}); ok {
+			r.Errors = max(r.Errors, d)
+		}
+		rates = append(rates, r)
+	}
+	return rates
+}
+
+// rateChecks rates each port by the errors counted during the interval;
+// a nil crit never fails.
+func rateChecks(rates []portRate, warn, crit *float64) []healthCheck {
+	checks := make([]healthCheck, 0, len(rates))
+	for _, r := range rates {
+		var detail []string
+		for _, n := range sortedStringKeys(r.ErrorDeltas) {
+			detail = append(detail, fmt.Sprintf("%s +%d", n, r.ErrorDeltas[n]))
+		}
+		text := fmt.Sprintf("%.1f/%.1f pkt/s in/out, %d errors", r.RxPps, r.TxPps, r.Errors)
+		if len(detail) > 0 {
+			text += " (" + strings.Join(detail, ", ") + ")"
+		}
+		checks = append(checks, healthCheck{
+			Name:   "Port " + r.Port,
+			Status: level(float64(r.Errors), warn, crit),
+			Detail: text,
+			Perf: []perfValue{
+				{Label: "rx_pps_" + r.Port, Value: math.Round(r.RxPps*10) / 10},
+				{Label: "tx_pps_" + r.Port, Value: math.Round(r.TxPps*10) / 10},
+				{Label: "errors_" + r.Port, Value: float64(r.Errors), Warn: warn, Crit: crit},
+			},
+		})
+	}
+	return checks
+}
+
+// ratePoints are the rates and counters of each port as
+// zyxel_interface points; counter fields are named by influxKey.
+func ratePoints(host string, rates []portRate, t time.Time) []influxPoint {
+	points := make([]influxPoint, 0, len(rates))
+	for _, r := range rates {
+		fields := map[string]any{
+			"rx_pps":  math.Round(r.RxPps*100) / 100,
+			"tx_pps":  math.Round(r.TxPps*100) / 100,
+			"rx_kbps": r.RxKBps,
+			"tx_kbps": r.TxKBps,
+			"errors":  r.Errors,
+		}
+		for k, v := range r.Counters {
+			fields[influxKey(k)] = v
+		}
+		points = append(points, influxPoint{
+			Measurement: "zyxel_interface",
+			Tags:        [][2]string{{"host", host}, {"port", r.Port}},
+			Fields:      fields,
+			Time:        t,
+		})
+	}
+	return points
+}
+
+func runRates(fs *flag.FlagSet) func() {
+	interval := fs.Duration("interval", 10*time.Second, tr("Time between the two samples"))
+	format := fs.String("format", "text", tr("Output format: text, nagios or influx"))
+	errorsWarn := fs.Float64("errors-warn", 1, tr("With --format nagios: errors per port and interval that warn"))
+	errorsCrit := fs.Float64("errors-crit", 0, tr("With --format nagios: errors per port and interval that are critical (default: never)"))
+	cf := addConnFlags(fs)
+	return func() {
+		ports := "*"
+		if fs.NArg() > 0 {
+			ports = fs.Arg(0)
+		}
+		if *interval <= 0 {
+			fatal("--interval must be positive")
+		}
+		if *format != "text" && *format != "nagios" && *format != "influx" {
+			fatal("--format must be text, nagios or influx, not %q", *format)
+		}
+		cfg, err := cf.load()
+		rates, err := func() ([]portRate, error) {
+			if err != nil {
+				return nil, err
+			}
+			s, err := Dial(cfg)
+			if err != nil {
+				return nil, err
+			}
+			defer s.Close()
+			if ports != "*" {
+				if ports, err = s.portList(ports); err != nil {
+					return nil, err
+				}
+			}
+			first, err := interfaces(s, ports)
+			if err != nil {
+				return nil, err
+			}
+			start := time.Now()
+			time.Sleep(*interval)
+			second, err := interfaces(s, ports)
+			if err != nil {
+				return nil, err
+			}
+			rates := interfaceRates(first, second, time.Since(start))
+			if len(rates) == 0 {
+				return nil, errorf("No interfaces found for %q", ports)
+			}
+			return rates, nil
+		}()
+
+		if *format == "nagios" {
+			if err != nil {
+				nagiosUnknown("ZYXEL INTERFACES", err)
+			}
+			// --errors-crit is unset unless given, and 0 a threshold.
+			var crit *float64
+			fs.Visit(func(f *flag.Flag) {
+				if f.Name == "errors-crit" {
+					crit = errorsCrit
+				}
+			})
+			checks := rateChecks(rates, errorsWarn, crit)
+			os.Exit(int(printNagios("ZYXEL INTERFACES", fmt.Sprintf("%d ports without errors", len(checks)), checks)))
+		}
+		if err != nil {
+			fatal("%v", err)
+		}
+		if *format == "influx" {
+			if err := writeInflux(ratePoints(cfg.Host, rates, time.Now())); err != nil {
+				fatal("%v", err)
+			}
+			return
+		}
+		fmt.Printf("%-8s %10s %10s %10s %10s %8s  %s\n", "Port", "Rx pkt/s", "Tx pkt/s", "Rx KB/s", "Tx KB/s", "Errors", "Error counters")
+		for _, r := range rates {
+			var detail []string
+			for _, n := range sortedStringKeys(r.ErrorDeltas) {
+				detail = append(detail, fmt.Sprintf("%s +%d", n, r.ErrorDeltas[n]))
+			}
+			fmt.Printf("%-8s %10.1f %10.1f %10.2f %10.2f %8d  %s\n", r.Port, r.RxPps, r.TxPps, r.RxKBps, r.TxKBps, r.Errors, strings.Join(detail, ", "))
+		}
+	}
+}
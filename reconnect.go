@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	// defaultReconnects is how many times a session reconnects after the
+	// switch dropped it, unless ZYXEL_RECONNECT says otherwise.
+	defaultReconnects = 3
+	// reconnectDials is how often each reconnect tries to log in, waiting
+	// reconnectDelay longer before each retry, as a switch that dropped
+	// the connection while saving may take a while to accept a new one.
+	reconnectDials = 5
+	reconnectDelay = 2 * time.Second
+)
+
+func (cfg Config) reconnects() int {
+	switch {
+	case cfg.Reconnects < 0:
+		return 0
+	case cfg.Reconnects == 0:
+		return defaultReconnects
+	}
+	return cfg.Reconnects
+}
+
+// canReconnect reports whether err is the switch having dropped the
+// connection, and the session may reconnect once more.
+func (s *Session) canReconnect(err error) bool {
+	return err != nil && !s.dryRun && s.cfg.Host != "" && s.out.ended() &&
+		s.reconnected < s.cfg.reconnects()
+}
+
+// reconnect logs in again with the settings of the lost connection and
+// runs modes, such as "configure" and "vlan 10", to return to the mode
+// the session was in. Dial already takes care of enable and the pager.
+func (s *Session) reconnect(modes []string) error {
+	s.reconnected++
+	s.closeFn()
+
+	fmt.Fprintf(os.Stderr, tr("%s: connection lost, reconnecting (%d of %d)")+"\n", s.host, s.reconnected, s.cfg.reconnects())
+	var n *Session
+	var err error
+	for i := 1; i <= reconnectDials; i++ {
+		time.Sleep(time.Duration(i-1) * reconnectDelay)
+		if n, err = Dial(s.cfg); err == nil {
+			break
+		}
+		if i < reconnectDials {
+			fmt.Fprintf(os.Stderr, tr("%s: login %d of %d failed: %v")+"\n", s.host, i, reconnectDials, err)
+		}
+	}
+	if err != nil {
+		return errorf("reconnect failed: %w", err)
+	}
+
+	// The new connection takes the place of the lost one in s.
+	s.mu.Lock()
+	s.closeFn, s.stdin, s.out = n.closeFn, n.stdin, n.out
+	s.prompt, s.lastPrompt, s.noPager = n.prompt, n.lastPrompt, n.noPager
+	s.mu.Unlock()
+	untrackSession(n)
+	toolMetrics.sessionsOpen.Add(-1)
+
+	for _, m := range modes {
+		if _, err := s.Output(m); err != nil {
+			return errorf("reconnected, but %q failed: %w", m, err)
+		}
+	}
+	return nil
+}
+
+// retry runs fn, which sends command, and when the switch drops the
+// connection during it, reconnects in modes and runs it again. Once the
+// reconnects are used up, a lost connection is a reconnect failure.
+func (s *Session) retry(command string, modes []string, fn func() error) error {
+	for {
+		err := fn()
+		if !s.canReconnect(err) {
+			if err != nil && s.out.ended() && s.reconnected > 0 {
+				return errorf("reconnect failed after %d reconnects: %w", s.reconnected, err)
+			}
+			return err
+		}
+		if rerr := s.reconnect(modes); rerr != nil {
+			return rerr
+		}
+		fmt.Fprintf(os.Stderr, tr("%s: reconnected, running %q again")+"\n", s.host, command)
+	}
+}
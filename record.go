@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// transcript is what was sent to and received from a switch in one
+// session, recorded with ZYXEL_RECORD and played back by "zyxel replay".
+type transcript struct {
+	Host      string     `yaml:"host"`
+	Exchanges []exchange `yaml:"exchanges"`
+}
+
+// exchange is one write to the switch and everything read after it up to
+// the next write. The first exchange of a session sends nothing.
+type exchange struct {
+	Send    string `yaml:"send,omitempty"`
+	Receive string `yaml:"receive"`
+}
+
+// recorder keeps the transcript of one connection.
+type recorder struct {
+	mu sync.Mutex
+	t  transcript
+}
+
+type recordWriter struct {
+	rec *recorder
+	w   io.Writer
+}
+
+// Write records p as a new exchange. A reply to a password prompt is
+// recorded masked, so transcripts can be shared.
+func (rw recordWriter) Write(p []byte) (int, error) {
+	rw.rec.mu.Lock()
+	send := string(p)
+	if n := len(rw.rec.t.Exchanges); n > 0 && passwordPrompt.MatchString(promptLine(rw.rec.t.Exchanges[n-1].Receive)) {
+		send = "********" + send[len(strings.TrimRight(send, "\r\n")):]
+	}
+	rw.rec.t.Exchanges = append(rw.rec.t.Exchanges, exchange{Send: send})
+	rw.rec.mu.Unlock()
+	return rw.w.Write(p)
+}
+
+type recordReader struct {
+	rec *recorder
+	r   io.Reader
+}
+
+func (rr recordReader) Read(p []byte) (int, error) {
+	n, err := rr.r.Read(p)
+	if n > 0 {
+		rr.rec.mu.Lock()
+		if len(rr.rec.t.Exchanges) == 0 {
+			rr.rec.t.Exchanges = append(rr.rec.t.Exchanges, exchange{})
+		}
+		rr.rec.t.Exchanges[len(rr.rec.t.Exchanges)-1].Receive += string(p[:n])
+		rr.rec.mu.Unlock()
+	}
+	return n, err
+}
+
+// recording wraps the streams of a new connection to host so the session
+// is written as a transcript to path when it closes. "{host}" in path
+// expands as with -o. An empty path leaves the streams alone.
+func recording(path, host string, stdin io.Writer, stdout io.Reader, closeFn func() error) (io.Writer, io.Reader, func() error) {
+	if path == "" {
+		return stdin, stdout, closeFn
+	}
+	rec := &recorder{t: transcript{Host: host}}
+	return recordWriter{rec, stdin}, recordReader{rec, stdout}, func() error {
+		err := closeFn()
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		f, ferr := openOutput(path, host, false)
+		if ferr != nil {
+			fmt.Fprintf(os.Stderr, "%s: transcript not written: %v\n", host, ferr)
+			return err
+		}
+		defer f.Close()
+		enc := yaml.NewEncoder(f)
+		enc.SetIndent(2)
+		if ferr := enc.Encode(rec.t); ferr != nil {
+			fmt.Fprintf(os.Stderr, "%s: transcript not written: %v\n", host, ferr)
+		}
+		return err
+	}
+}
+
+func loadTranscript(path string) (*transcript, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var t transcript
+	if err := yaml.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &t, nil
+}
+
+// replayer answers a Telnet client from a transcript: a login, then the
+// recorded output of each command it knows, with the pager pages that
+// followed it handed out one space at a time.
+type replayer struct {
+	// greeting is the output up to the first prompt after login.
+	greeting string
+	prompt   string
+	// commands maps a command line to the exchanges that answered it,
+	// handed out in turn and the last one again after that.
+	commands map[string][]int
+	served   map[string]int
+	ex       []exchange
+}
+
+func newReplayer(t *transcript) (*replayer, error) {
+	r := &replayer{commands: make(map[string][]int), served: make(map[string]int), ex: t.Exchanges}
+	start := -1
+	for i, e := range t.Exchanges {
+		if line := promptLine(e.Receive); anyPrompt.MatchString(line) {
+			r.greeting, r.prompt, start = e.Receive, line, i
+			break
+		}
+	}
+	if start < 0 {
+		return nil, fmt.Errorf("no switch prompt in the transcript")
+	}
+	for i := start + 1; i < len(t.Exchanges); i++ {
+		if cmd, ok := strings.CutSuffix(t.Exchanges[i].Send, "\n"); ok {
+			cmd = strings.TrimRight(cmd, "\r")
+			r.commands[cmd] = append(r.commands[cmd], i)
+		}
+	}
+	return r, nil
+}
+
+// serve runs one client connection.
+func (r *replayer) serve(conn net.Conn) {
+	defer conn.Close()
+	in := bufio.NewReader(conn)
+	readLine := func() (string, error) {
+		line, err := in.ReadString('\n')
+		// Drop the client's keep-alive NOPs.
+		line = strings.ReplaceAll(line, "\xff\xf1", "")
+		return strings.TrimRight(line, "\r\n"), err
+	}
+
+	fmt.Fprint(conn, "User name: ")
+	if _, err := readLine(); err != nil {
+		return
+	}
+	fmt.Fprint(conn, "Password: ")
+	if _, err := readLine(); err != nil {
+		return
+	}
+	io.WriteString(conn, r.greeting)
+
+	// pages are the pager exchanges still to come for the last command,
+	// next the exchange after it.
+	var pages []exchange
+	next := -1
+	for {
+		b, err := in.Peek(1)
+		if err != nil {
+			return
+		}
+		if b[0] == ' ' && len(pages) > 0 {
+			in.ReadByte()
+			io.WriteString(conn, pages[0].Receive)
+			pages = pages[1:]
+			continue
+		}
+		cmd, err := readLine()
+		if err != nil {
+			return
+		}
+		cmd = strings.TrimSpace(cmd)
+		var i int
+		if next > 0 && next < len(r.ex) && passwordPrompt.MatchString(promptLine(r.ex[next-1].Receive)) {
+			// The password was masked when recorded; take any.
+			i = next
+		} else {
+			if cmd == "exit" || cmd == "logout" {
+				return
+			}
+			list, ok := r.commands[cmd]
+			if !ok {
+				fmt.Fprintf(conn, "%s\r\n%% Unknown command.\r\n%s ", cmd, r.prompt)
+				next = -1
+				continue
+			}
+			i = list[min(r.served[cmd], len(list)-1)]
+			r.served[cmd]++
+		}
+		io.WriteString(conn, r.ex[i].Receive)
+		if line := promptLine(r.ex[i].Receive); anyPrompt.MatchString(line) {
+			r.prompt = line
+		}
+		next = i + 1
+		pages = nil
+		for j := i + 1; j < len(r.ex) && r.ex[j].Send == " "; j++ {
+			pages = append(pages, r.ex[j])
+		}
+	}
+}
+
+func runReplay(fs *flag.FlagSet) func() {
+	listen := fs.String("listen", "127.0.0.1:2323", tr("Telnet `address` to listen on"))
+	return func() {
+		if fs.NArg() != 1 {
+			fatal("Usage: zyxel replay <transcript.yaml> [--listen address]")
+		}
+		t, err := loadTranscript(fs.Arg(0))
+		if err != nil {
+			fatal("%v", err)
+		}
+		if _, err := newReplayer(t); err != nil {
+			fatal("%s: %v", fs.Arg(0), err)
+		}
+		ln, err := net.Listen("tcp", *listen)
+		if err != nil {
+			fatal("%v", err)
+		}
+		fmt.Fprintf(os.Stderr, "Replaying %s (%d exchanges) on %s; connect with ZYXEL_TRANSPORT=telnet\n", t.Host, len(t.Exchanges), ln.Addr())
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				fatal("%v", err)
+			}
+			// Each client starts from the beginning of the transcript.
+			r, _ := newReplayer(t)
+			go r.serve(conn)
+		}
+	}
+}
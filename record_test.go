@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+)
+
+// replay serves transcript testdata/name.yaml as "zyxel replay" does and
+// returns a session logged in to it over Telnet.
+func replay(t *testing.T, name string) *Session {
+	t.Helper()
+	tr, err := loadTranscript("testdata/" + name + ".yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newReplayer(tr); err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			r, _ := newReplayer(tr)
+			go r.serve(conn)
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	s, err := DialContext(t.Context(), Config{
+		Host: host, Port: port, Transport: "telnet", User: "admin", Password: "secret",
+		KeepAlive: -1, Reconnects: -1,
+	})
+	if err != nil {
+		t.Fatalf("replaying %s: %v", name, err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestReplay(t *testing.T) {
+	tests := []struct {
+		transcript string
+		prompt     string
+		model      string
+		firmware   string
+		// paged is whether the switch kept its pager on.
+		paged bool
+		// interfaces is the port list to read "show interfaces" of, and
+		// links the port and link of each in the output.
+		interfaces string
+		links      []string
+		macs       []MACEntry
+		// vlans is every VLAN with its member and untagged ports.
+		vlans []string
+	}{
+		{
+			transcript: "gs1920-v4.50",
+			prompt:     "sw-lab1#",
+			model:      "GS1920-24HPv2",
+			firmware:   "V4.50(ABMH.6)",
+			paged:      true,
+			interfaces: "1-2",
+			links:      []string{"1 1000M/F", "2 Down"},
+			macs: []MACEntry{
+				{"00:19:cb:6e:10:01", 1, "1", "Dynamic"},
+				{"00:19:cb:6e:10:02", 1, "2", "Dynamic"},
+				{"00:19:cb:6e:10:03", 1, "3", "Dynamic"},
+				{"00:19:cb:6e:10:04", 1, "4", "Dynamic"},
+				{"00:19:cb:6e:10:05", 10, "5", "Dynamic"},
+				{"00:19:cb:6e:10:06", 10, "6", "Dynamic"},
+				{"00:19:cb:6e:10:07", 10, "7", "Dynamic"},
+				{"00:19:cb:6e:10:08", 10, "8", "Dynamic"},
+				{"00:19:cb:6e:10:09", 10, "9", "Dynamic"},
+			},
+		},
+		{
+			transcript: "xgs2210-v4.80",
+			prompt:     "core-sw#",
+			model:      "XGS2210-28HP",
+			firmware:   "V4.80(AAZI.2)",
+			interfaces: "25",
+			links:      []string{"25 10G/F"},
+			vlans: []string{
+				"1 [1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16 17 18 19 20 21 22 23 24 25 26 27 28] [1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16 17 18 19 20 21 22 23 24]",
+				"10 [5 6 7 8 25 26 27 28] [5 6 7 8]",
+				"20 [25 26 27 28] []",
+			},
+		},
+		{
+			transcript: "xgs2220-v4.90",
+			prompt:     "edge-sw#",
+			model:      "XGS2220-30",
+			firmware:   "V4.90(ABUZ.1)",
+			macs: []MACEntry{
+				{"00:19:cb:22:01:05", 1, "1/1/5", "Dynamic"},
+				{"00:19:cb:22:01:06", 1, "1/1/6", "Dynamic"},
+				{"00:19:cb:22:01:1a", 100, "1/1/26", "Static"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.transcript, func(t *testing.T) {
+			s := replay(t, tt.transcript)
+			if s.lastPrompt != tt.prompt {
+				t.Errorf("prompt = %q, want %q", s.lastPrompt, tt.prompt)
+			}
+			if model, firmware := s.Model(); model != tt.model || firmware != tt.firmware {
+				t.Errorf("Model() = %q, %q, want %q, %q", model, firmware, tt.model, tt.firmware)
+			}
+			if s.noPager == tt.paged {
+				t.Errorf("noPager = %v, want %v", s.noPager, !tt.paged)
+			}
+
+			if tt.interfaces != "" {
+				ifaces, err := interfaces(s, tt.interfaces)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var links []string
+				for _, i := range ifaces {
+					links = append(links, i.Port+" "+i.Link)
+				}
+				if !reflect.DeepEqual(links, tt.links) {
+					t.Errorf("interfaces %s: ports and links %q, want %q", tt.interfaces, links, tt.links)
+				}
+			}
+			if tt.macs != nil {
+				macs, err := macTable(s)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(macs, tt.macs) {
+					t.Errorf("MAC table %v, want %v", macs, tt.macs)
+				}
+			}
+			if tt.vlans != nil {
+				vlans, err := showVLAN(s)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var got []string
+				for _, id := range sortedKeys(vlans) {
+					v := vlans[id]
+					got = append(got, fmt.Sprint(v.ID, " ", v.Members, " ", v.Untagged))
+				}
+				if !reflect.DeepEqual(got, tt.vlans) {
+					t.Errorf("VLANs %q, want %q", got, tt.vlans)
+				}
+			}
+		})
+	}
+}
+
+func TestReplayerWithoutPrompt(t *testing.T) {
+	tr := &transcript{Host: "sw1", Exchanges: []exchange{{Receive: "User name: "}}}
+	if _, err := newReplayer(tr); err == nil {
+		t.Error("newReplayer accepted a transcript without a switch prompt")
+	}
+}
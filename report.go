@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var versionNumber = regexp.MustCompile(`\d+`)
+
+// compareFirmware compares Zyxel firmware versions such as "V4.50(AAZJ.5)"
+// by their numbers in order (4, 50, 5), ignoring the model code. It
+// returns -1, 0 or +1 like strings.Compare.
+func compareFirmware(a, b string) int {
+	parts := func(v string) []int {
+		var n []int
+		for _, s := range versionNumber.FindAllString(v, -1) {
+			i, _ := strconv.Atoi(s)
+			n = append(n, i)
+		}
+		return n
+	}
+	return slices.Compare(parts(a), parts(b))
+}
+
+// inventoryRow is one switch in the inventory report.
+type inventoryRow struct {
+	Host      string `json:"host"`
+	Address   string `json:"address"`
+	Model     string `json:"model"`
+	Serial    string `json:"serial"`
+	Firmware  string `json:"firmware"`
+	Uptime    string `json:"uptime"`
+	BootImage string `json:"boot_image"`
+	// Outdated is set when the firmware is below --min-firmware.
+	Outdated bool   `json:"outdated"`
+	Error    string `json:"error,omitempty"`
+}
+
+// stpRow is one switch in the spanning-tree report.
+type stpRow struct {
+	Host     string `json:"host"`
+	Mode     string `json:"mode"`
+	BridgeID string `json:"bridge_id"`
+	Priority int    `json:"priority"`
+	// Root is the inventory name of the root bridge, or its ID when it is
+	// not an inventory switch.
+	Root     string `json:"root"`
+	RootPort string `json:"root_port"`
+	RootCost int    `json:"root_cost"`
+	// Blocked counts the ports in the discarding or blocking state.
+	Blocked int    `json:"blocked"`
+	Problem string `json:"problem,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+var reportCommands = []subcommand{
+	{"inventory", "Model, serial, firmware, uptime and boot image of every inventory switch", runReportInventory,
+		"CSV with a header row: host, address, model, serial, firmware, uptime, boot_image, outdated, error; or a JSON array with --format json", nil},
+	{"stp", "Spanning-tree root, root port and cost of every inventory switch", runReportSTP,
+		"CSV with a header row: host, mode, bridge_id, priority, root, root_port, root_cost, blocked, problem, error; or a JSON array with --format json", nil},
+	{"dot1x", "802.1X state, supplicant and method of every port of the inventory switches", runReportDot1x,
+		"CSV with a header row: host, port, enabled, status, supplicant_mac, method, vlan, uplink, problem, error; or a JSON array with --format json", nil},
+}
+
+func runReport(fs *flag.FlagSet) func() {
+	return func() {
+		args := fs.Args()
+		if len(args) == 0 {
+			fmt.Println("Usage: zyxel report <command> [flags]")
+			fmt.Println()
+			fmt.Println("Commands:")
+			for _, c := range reportCommands {
+				fmt.Printf("  %-10s %s\n", c.name, c.summary)
+			}
+			os.Exit(1)
+		}
+		for _, c := range reportCommands {
+			if c.name == args[0] {
+				c.invoke("report "+c.name, args[1:])
+				return
+			}
+		}
+		fatal("Unknown report command %q", args[0])
+	}
+}
+
+func runReportInventory(fs *flag.FlagSet) func() {
+	ff := addFleetFlags(fs)
+	format := fs.String("format", "csv", tr("Output format: csv or json"))
+	minFirmware := fs.String("min-firmware", "", tr("Flag switches running firmware older than this `version`, e.g. V4.80(ABMH.2)"))
+	return func() {
+		if *format != "csv" && *format != "json" {
+			fatal("--format must be csv or json, not %q", *format)
+		}
+		_, hosts := ff.load()
+		results := runFleet(hosts, ff, func(h Host, s *Session) (SystemInfo, error) {
+			return systemInfo(s)
+		})
+
+		rows := make([]inventoryRow, 0, len(results))
+		failed := false
+		for _, r := range results {
+			row := inventoryRow{
+				Host:      r.Host.Name,
+				Address:   r.Host.Address,
+				Model:     r.Value.Model,
+				Serial:    r.Value.Serial,
+				Firmware:  r.Value.Firmware,
+				Uptime:    r.Value.Uptime,
+				BootImage: r.Value.BootImage,
+			}
+			if r.Err != nil {
+				row.Error = r.Err.Error()
+				failed = true
+			} else if *minFirmware != "" && compareFirmware(row.Firmware, *minFirmware) < 0 {
+				row.Outdated = true
+				failed = true
+			}
+			rows = append(rows, row)
+		}
+
+		if *format == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(rows); err != nil {
+				fatal("%v", err)
+			}
+		} else {
+			w := csv.NewWriter(os.Stdout)
+			w.Write([]string{"host", "address", "model", "serial", "firmware", "uptime", "boot_image", "outdated", "error"})
+			for _, r := range rows {
+				w.Write([]string{r.Host, r.Address, r.Model, r.Serial, r.Firmware, r.Uptime, r.BootImage, strconv.FormatBool(r.Outdated), r.Error})
+			}
+			w.Flush()
+			if err := w.Error(); err != nil {
+				fatal("%v", err)
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+	}
+}
+
+// stpRows builds the spanning-tree report. The root of every switch is
+// named after the inventory switch with that bridge ID; a switch is a
+// problem when its root is not the intended one or, without one in the
+// inventory, not the root most switches see.
+func stpRows(intended string, results []fleetResult[STPStatus]) []stpRow {
+	names := make(map[string]string)
+	for _, r := range results {
+		if r.Err == nil && r.Value.BridgeID != "" {
+			names[r.Value.BridgeID] = r.Host.Name
+		}
+	}
+
+	rows := make([]stpRow, 0, len(results))
+	votes := make(map[string]int)
+	for _, r := range results {
+		st := r.Value
+		row := stpRow{Host: r.Host.Name, Mode: st.Mode, BridgeID: st.BridgeID, Priority: st.Priority,
+			RootPort: st.RootPort, RootCost: st.RootCost}
+		if r.Err != nil {
+			row.Error = r.Err.Error()
+			rows = append(rows, row)
+			continue
+		}
+		row.Root = st.RootID
+		if st.IsRoot {
+			row.Root = r.Host.Name
+		} else if name, ok := names[st.RootID]; ok {
+			row.Root = name
+		}
+		for _, p := range st.Ports {
+			if l := strings.ToLower(p.State); strings.HasPrefix(l, "discard") || strings.HasPrefix(l, "block") {
+				row.Blocked++
+			}
+		}
+		votes[row.Root]++
+		rows = append(rows, row)
+	}
+
+	expected := intended
+	if expected == "" {
+		for root, n := range votes {
+			if n > votes[expected] || n == votes[expected] && root < expected {
+				expected = root
+			}
+		}
+	}
+	for i, row := range rows {
+		switch {
+		case row.Error != "" || row.Root == expected:
+		case intended != "":
+			rows[i].Problem = fmt.Sprintf("root is %s, expected %s", row.Root, intended)
+		default:
+			rows[i].Problem = fmt.Sprintf("root is %s, most switches see %s", row.Root, expected)
+		}
+	}
+	return rows
+}
+
+func runReportSTP(fs *flag.FlagSet) func() {
+	ff := addFleetFlags(fs)
+	format := fs.String("format", "csv", tr("Output format: csv or json"))
+	return func() {
+		if *format != "csv" && *format != "json" {
+			fatal("--format must be csv or json, not %q", *format)
+		}
+		inv, hosts := ff.load()
+		results := runFleet(hosts, ff, func(h Host, s *Session) (STPStatus, error) {
+			return stpStatus(s)
+		})
+
+		rows := stpRows(inv.STP.Root, results)
+		failed := false
+		for _, r := range rows {
+			if r.Error != "" || r.Problem != "" {
+				failed = true
+			}
+		}
+
+		if *format == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(rows); err != nil {
+				fatal("%v", err)
+			}
+		} else {
+			w := csv.NewWriter(os.Stdout)
+			w.Write([]string{"host", "mode", "bridge_id", "priority", "root", "root_port", "root_cost", "blocked", "problem", "error"})
+			for _, r := range rows {
+				w.Write([]string{r.Host, r.Mode, r.BridgeID, strconv.Itoa(r.Priority), r.Root, r.RootPort,
+					strconv.Itoa(r.RootCost), strconv.Itoa(r.Blocked), r.Problem, r.Error})
+			}
+			w.Flush()
+			if err := w.Error(); err != nil {
+				fatal("%v", err)
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+	}
+}
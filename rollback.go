@@ -0,0 +1,279 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// Checkpoints are stored like backups, under <state dir>/checkpoints, and
+// only the latest maxCheckpoints of each host are kept.
+
+const maxCheckpoints = 20
+
+func checkpointRoot() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "checkpoints"), nil
+}
+
+func listCheckpoints(host string) ([]backup, error) {
+	root, err := checkpointRoot()
+	if err != nil {
+		return nil, err
+	}
+	return storedConfigs(root, host)
+}
+
+// checkpoint saves the running-config before the first change of the
+// session, so the change can be rolled back.
+func (s *Session) checkpoint() error {
+	if s.checkpointed {
+		return nil
+	}
+	config, err := s.Output(s.command("running-config"))
+	if err != nil {
+		return errorf("failed to read running-config for the checkpoint: %w", err)
+	}
+	if strings.TrimSpace(config) == "" {
+		return errorf("failed to take a checkpoint: empty running-config")
+	}
+	root, err := checkpointRoot()
+	if err != nil {
+		return errorf("failed to take a checkpoint: %w", err)
+	}
+	if _, err := storeConfig(root, s.host, time.Now(), config); err != nil {
+		return errorf("failed to take a checkpoint: %w", err)
+	}
+	s.checkpointed = true
+
+	checkpoints, err := storedConfigs(root, s.host)
+	if err != nil {
+		return nil
+	}
+	for len(checkpoints) > maxCheckpoints {
+		os.Remove(checkpoints[0].Path)
+		checkpoints = checkpoints[1:]
+	}
+	return nil
+}
+
+// configBlock is the top-level commands of a configuration, with Header
+// "", or the commands of one "interface" or "vlan" block.
+type configBlock struct {
+	Header string
+	Lines  []string
+}
+
+// splitBlocks splits a configuration into blocks the way
+// parseRunningConfig reads it, in order of first appearance. Repeated
+// blocks are merged.
+func splitBlocks(config string) []configBlock {
+	blocks := []configBlock{{Header: ""}}
+	index := map[string]int{"": 0}
+	cur := 0
+	for _, line := range configLines(config) {
+		fields := strings.Fields(line)
+		switch {
+		case line == "exit":
+			cur = 0
+			continue
+		case cur == 0 && (fields[0] == "interface" || fields[0] == "vlan" && len(fields) == 2):
+			i, ok := index[line]
+			if !ok {
+				i = len(blocks)
+				index[line] = i
+				blocks = append(blocks, configBlock{Header: line})
+			}
+			cur = i
+			continue
+		}
+		blocks[cur].Lines = append(blocks[cur].Lines, line)
+	}
+	return blocks
+}
+
+// listKeywords are commands that add up rather than replace each other,
+// such as the port lists of a VLAN.
+var listKeywords = []string{"fixed", "forbidden", "untagged", "normal", "tagged"}
+
+// negate returns the command that undoes line.
+func negate(line string) string {
+	if rest, ok := strings.CutPrefix(line, "no "); ok {
+		return rest
+	}
+	return "no " + line
+}
+
+// keyword is the command line sets, ignoring a leading "no".
+func keyword(line string) string {
+	f := strings.Fields(strings.TrimPrefix(line, "no "))
+	if len(f) == 0 {
+		return ""
+	}
+	return f[0]
+}
+
+// undoLines returns the commands that turn the lines of a block from cur
+// into want: negations of what cur has and want lacks, then what want has
+// and cur lacks. A command want sets again, like "name" or "pvid", is
+// replaced rather than negated first.
+func undoLines(cur, want []string) []string {
+	var add, remove []string
+	for _, l := range want {
+		if !slices.Contains(cur, l) {
+			add = append(add, l)
+		}
+	}
+	for _, l := range cur {
+		if slices.Contains(want, l) {
+			continue
+		}
+		k := keyword(l)
+		if !slices.Contains(listKeywords, k) && slices.ContainsFunc(add, func(a string) bool { return keyword(a) == k }) {
+			continue
+		}
+		remove = append(remove, negate(l))
+	}
+	return append(remove, add...)
+}
+
+// rollbackCommands returns the configuration commands that take a switch
+// from the configuration current back to target. VLANs are restored
+// first, so ports can refer to them, and VLANs the target does not have
+// are deleted last.
+func rollbackCommands(current, target string) []string {
+	cur := make(map[string][]string)
+	for _, b := range splitBlocks(current) {
+		cur[b.Header] = b.Lines
+	}
+	want := make(map[string]bool)
+	var vlans, others, global, deleted []string
+	blocks := splitBlocks(target)
+	for _, b := range blocks {
+		want[b.Header] = true
+	}
+	for _, b := range splitBlocks(current) {
+		if !want[b.Header] {
+			if strings.HasPrefix(b.Header, "vlan ") {
+				deleted = append(deleted, "no "+b.Header)
+				continue
+			}
+			blocks = append(blocks, configBlock{Header: b.Header})
+		}
+	}
+
+	for _, b := range blocks {
+		undo := undoLines(cur[b.Header], b.Lines)
+		if len(undo) == 0 {
+			continue
+		}
+		switch {
+		case b.Header == "":
+			global = undo
+		case strings.HasPrefix(b.Header, "vlan "):
+			vlans = append(vlans, b.Header)
+			vlans = append(vlans, undo...)
+			vlans = append(vlans, "exit")
+		default:
+			others = append(others, b.Header)
+			others = append(others, undo...)
+			others = append(others, "exit")
+		}
+	}
+	return slices.Concat(vlans, others, global, deleted)
+}
+
+func runRollback(fs *flag.FlagSet) func() {
+	last := fs.Bool("last", false, tr("Roll back to the checkpoint taken before the latest change"))
+	list := fs.Bool("list", false, tr("List the checkpoints of the switch"))
+	save := fs.Bool("save", false, tr("Write memory after rolling back"))
+	yes := fs.Bool("yes", false, tr("Roll back without showing the commands and asking first"))
+	dryRun := addDryRunFlag(fs)
+	cf := addConnFlags(fs)
+	return func() {
+		cfg := cf.config()
+		checkpoints, err := listCheckpoints(cfg.Host)
+		if err != nil {
+			fatal("%v", err)
+		}
+		if *list {
+			for _, c := range checkpoints {
+				size := int64(0)
+				if st, err := os.Stat(c.Path); err == nil {
+					size = st.Size()
+				}
+				fmt.Printf("%s %8d\n", c.ID(), size)
+			}
+			return
+		}
+		if *last == (fs.NArg() == 1) || fs.NArg() > 1 {
+			fatal("Usage: zyxel rollback --last | <checkpoint>")
+		}
+		if len(checkpoints) == 0 {
+			fatal("No checkpoints of %s; one is taken before each change", cfg.Host)
+		}
+		cp, err := findBackup(checkpoints, fs.Arg(0))
+		if err != nil {
+			fatal("%v", err)
+		}
+		data, err := os.ReadFile(cp.Path)
+		if err != nil {
+			fatal("%v", err)
+		}
+		target := string(data)
+		if !*yes && !*dryRun && !term.IsTerminal(int(os.Stdin.Fd())) {
+			fatal("stdin is not a terminal; review with --dry-run and pass --yes to roll back")
+		}
+
+		s, err := Dial(cfg)
+		if err != nil {
+			fatal("%v", err)
+		}
+		defer s.Close()
+		s.dryRun = *dryRun
+		current, err := s.Output(s.command("running-config"))
+		if err != nil {
+			fatal("Failed to read running-config: %v", err)
+		}
+		commands := rollbackCommands(current, target)
+		if len(commands) == 0 {
+			fmt.Printf(tr("Nothing to roll back; the running-config matches checkpoint %s")+"\n", cp.ID())
+			return
+		}
+		if !*yes && !*dryRun {
+			for _, c := range commands {
+				fmt.Fprintf(os.Stderr, "  %s\n", c)
+			}
+			confirmApply(len(commands), cfg.Host)
+		}
+		// Saved only once the rollback is verified; a dry run just shows
+		// the write memory.
+		applyChange(s, cfg, commands, nil, *save && s.dryRun, "", os.Stdout)
+		if s.dryRun {
+			return
+		}
+
+		after, err := s.Output(s.command("running-config"))
+		if err != nil {
+			fatal("Failed to read running-config: %v", err)
+		}
+		if len(rollbackCommands(after, target)) > 0 {
+			fmt.Fprintf(os.Stderr, tr("The running-config still differs from checkpoint %s:")+"\n", cp.ID())
+			writeDiff(os.Stderr, lineDiff(strings.Join(configLines(target), "\n"), strings.Join(configLines(after), "\n")), 1)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, tr("Rolled back to checkpoint %s")+"\n", cp.ID())
+		if *save {
+			saveConfig(s)
+		}
+	}
+}
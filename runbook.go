@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// runbook documents one configuration change on a switch, in Markdown,
+// for pasting into the change ticket.
+type runbook struct {
+	Config        Config
+	Start, End    time.Time
+	Before, After string
+	Commands      []string
+	Steps         []runbookStep
+	Err           error
+	Save          bool
+	Saved         string
+	SaveErr       error
+	Verify        []runbookStep
+}
+
+type runbookStep struct {
+	Command string
+	Output  string
+}
+
+// applyChange runs commands in configuration mode, saves if asked and
+// then runs the verify commands, writing all output to w. With a runbook
+// path the running-config is read before and after, and the runbook is
+// written even when the change fails. It exits on failure. In dry-run mode
+// it only prints the commands.
+func applyChange(s *Session, cfg Config, commands, verify []string, save bool, runbookPath string, w io.Writer) {
+	if s.dryRun {
+		if runbookPath != "" {
+			fatal("--runbook cannot be combined with --dry-run")
+		}
+		printDryRun(commands)
+		if save {
+			fmt.Println(s.command("save"))
+		}
+		return
+	}
+	rb := &runbook{Config: cfg, Start: time.Now(), Commands: commands, Save: save}
+	if runbookPath != "" {
+		before, err := s.Output(s.command("running-config"))
+		if err != nil {
+			fatal("Failed to read running-config: %v", err)
+		}
+		rb.Before = before
+	}
+
+	rb.Err = s.configure(commands, func(c, out string) {
+		io.WriteString(w, out)
+		rb.Steps = append(rb.Steps, runbookStep{c, out})
+	})
+	if rb.Err == nil && save {
+		rb.Saved, rb.SaveErr = s.Save()
+	}
+	if rb.Err == nil {
+		for _, c := range verify {
+			out, err := s.Output(c)
+			io.WriteString(w, out)
+			rb.Verify = append(rb.Verify, runbookStep{c, out})
+			if err != nil {
+				rb.Err = err
+				break
+			}
+		}
+	}
+
+	if runbookPath != "" {
+		// A failed change may still have altered the configuration, so the
+		// after state is read regardless.
+		rb.After, _ = s.Output(s.command("running-config"))
+		rb.End = time.Now()
+		f, err := openOutput(runbookPath, cfg.Host, false)
+		if err != nil {
+			fatal("%v", err)
+		}
+		rb.write(f)
+		if err := f.Close(); err != nil {
+			fatal("%v", err)
+		}
+		fmt.Fprintf(os.Stderr, "Runbook written to %s\n", f.Name())
+	}
+
+	if rb.Err != nil {
+		fatal("%v", rb.Err)
+	}
+	if save {
+		if rb.SaveErr != nil {
+			fatal("Configuration NOT saved: %v", rb.SaveErr)
+		}
+		fmt.Fprintf(os.Stderr, "Saved: %s\n", rb.Saved)
+	}
+}
+
+func (rb *runbook) result() string {
+	switch {
+	case rb.Err != nil:
+		return "FAILED: " + rb.Err.Error()
+	case rb.SaveErr != nil:
+		return "applied, NOT saved: " + rb.SaveErr.Error()
+	case rb.Save:
+		return "applied and saved"
+	default:
+		return "applied, not saved (running-config only)"
+	}
+}
+
+// write renders the runbook as Markdown.
+func (rb *runbook) write(w io.Writer) {
+	cfg := rb.Config
+	fmt.Fprintf(w, "# Change on %s\n\n", cfg.Host)
+	fmt.Fprintf(w, "- Device: %s (%s, user %s)\n", cfg.address(), cfg.Transport, cfg.User)
+	fmt.Fprintf(w, "- Started: %s\n", rb.Start.UTC().Format(time.RFC3339))
+	fmt.Fprintf(w, "- Finished: %s\n", rb.End.UTC().Format(time.RFC3339))
+	fmt.Fprintf(w, "- Result: %s\n", rb.result())
+
+	fmt.Fprintf(w, "\n## Executed commands\n\n")
+	for i, st := range rb.Steps {
+		fmt.Fprintf(w, "%d. `%s`\n", i+1, st.Command)
+		if strings.TrimSpace(st.Output) != "" {
+			writeCodeBlock(w, "", st.Output, "   ")
+		}
+	}
+	for i := len(rb.Steps); i < len(rb.Commands); i++ {
+		fmt.Fprintf(w, "%d. `%s` (not run)\n", i+1, rb.Commands[i])
+	}
+
+	fmt.Fprintf(w, "\n## Configuration changes\n\n")
+	d := lineDiff(rb.Before, rb.After)
+	switch {
+	case rb.After == "":
+		fmt.Fprintln(w, "The running-config could not be read after the change.")
+	case !diffChanged(d):
+		fmt.Fprintln(w, "The running-config did not change.")
+	default:
+		var b strings.Builder
+		writeDiff(&b, d, 3)
+		writeCodeBlock(w, "diff", b.String(), "")
+	}
+
+	fmt.Fprintf(w, "\n## Verification\n\n")
+	switch {
+	case !rb.Save:
+	case rb.SaveErr != nil:
+		fmt.Fprintf(w, "- write memory: FAILED: %v\n", rb.SaveErr)
+	case rb.Saved != "":
+		fmt.Fprintf(w, "- write memory: %s\n", rb.Saved)
+	}
+	if len(rb.Verify) == 0 {
+		fmt.Fprintln(w, "- No verification commands were run.")
+	}
+	for _, st := range rb.Verify {
+		fmt.Fprintf(w, "\n### `%s`\n\n", st.Command)
+		writeCodeBlock(w, "", st.Output, "")
+	}
+}
+
+// writeCodeBlock writes text as a fenced code block, each line prefixed
+// with indent so it can sit inside a list item.
+func writeCodeBlock(w io.Writer, lang, text, indent string) {
+	fmt.Fprintf(w, "%s```%s\n", indent, lang)
+	for _, l := range splitLines(text) {
+		fmt.Fprintf(w, "%s%s\n", indent, l)
+	}
+	fmt.Fprintf(w, "%s```\n", indent)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"strings"
+)
+
+// RunningConfig is "show running-config" split into its blocks. Port
+// blocks ("interface port-channel 1-4") are expanded so every port maps to
+// the commands applied to it.
+type RunningConfig struct {
+	// Global holds top-level commands that do not open a block.
+	Global []string
+	// Ports maps each port to the commands from its interface blocks.
+	Ports map[Port][]string
+	// VLANs maps a VLAN ID to the commands inside its "vlan" block.
+	VLANs map[int][]string
+}
+
+// parseRunningConfig parses Zyxel-style configuration, where blocks are
+// opened by "interface ..." or "vlan ..." and closed by "exit".
+func parseRunningConfig(output string) *RunningConfig {
+	rc := &RunningConfig{
+		Ports: make(map[Port][]string),
+		VLANs: make(map[int][]string),
+	}
+
+	var ports []Port
+	vlan := 0
+	inBlock := false
+
+	for _, line := range strings.Split(strings.ReplaceAll(output, "\r", ""), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "!") {
+			continue
+		}
+		fields := strings.Fields(line)
+
+		switch {
+		case line == "exit":
+			ports, vlan, inBlock = nil, 0, false
+			continue
+		case fields[0] == "interface" && len(fields) >= 3 && fields[1] == "port-channel":
+			ports, _ = ParsePortList(fields[2])
+			vlan, inBlock = 0, true
+			continue
+		case fields[0] == "interface":
+			ports, vlan, inBlock = nil, 0, true
+			continue
+		case fields[0] == "vlan" && len(fields) == 2 && !inBlock:
+			vlan = atoiOr(fields[1], 0)
+			ports, inBlock = nil, vlan != 0
+			if vlan != 0 {
+				if _, ok := rc.VLANs[vlan]; !ok {
+					rc.VLANs[vlan] = nil
+				}
+			}
+			continue
+		}
+
+		switch {
+		case len(ports) > 0:
+			for _, p := range ports {
+				rc.Ports[p] = append(rc.Ports[p], line)
+			}
+		case vlan != 0:
+			rc.VLANs[vlan] = append(rc.VLANs[vlan], line)
+		case !inBlock:
+			rc.Global = append(rc.Global, line)
+		}
+	}
+	return rc
+}
+
+// setting returns the argument of the first command starting with one of
+// keywords, or "" if none is present.
+func setting(commands []string, keywords ...string) string {
+	for _, c := range commands {
+		f := strings.Fields(c)
+		for _, k := range keywords {
+			if len(f) >= 2 && f[0] == k {
+				return f[len(f)-1]
+			}
+		}
+	}
+	return ""
+}
+
+func runningConfig(s *Session) (*RunningConfig, error) {
+	out, err := s.Output(s.command("running-config"))
+	if err != nil {
+		return nil, err
+	}
+	return parseRunningConfig(out), nil
+}
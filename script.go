@@ -0,0 +1,283 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+)
+
+// scriptEnv is what a Starlark script of "zyxel script" works with: the
+// switch of the connection flags, opened on first use, and the inventory
+// switches it connects to by name.
+type scriptEnv struct {
+	cf       *connFlags
+	ff       *fleetFlags
+	dryRun   bool
+	parsers  []commandParser
+	inv      *Inventory
+	sessions map[string]*Session
+}
+
+// session returns the session of the inventory host name, or of the
+// connection flags when name is empty.
+func (e *scriptEnv) session(name string) (*Session, error) {
+	if s, ok := e.sessions[name]; ok {
+		return s, nil
+	}
+	var cfg Config
+	if name == "" {
+		cfg = e.cf.config()
+	} else {
+		inv, err := e.inventory()
+		if err != nil {
+			return nil, err
+		}
+		i := -1
+		for j, h := range inv.Hosts {
+			if h.Name == name {
+				i = j
+			}
+		}
+		if i < 0 {
+			return nil, fmt.Errorf("no inventory host named %s", name)
+		}
+		cfgs, errs, err := hostConfigs(inv.Hosts[i : i+1])
+		if err != nil {
+			return nil, err
+		}
+		if errs[0] != nil {
+			return nil, errs[0]
+		}
+		cfg = cfgs[0]
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	s, err := Dial(cfg)
+	if err != nil {
+		return nil, err
+	}
+	s.dryRun = e.dryRun
+	e.sessions[name] = s
+	return s, nil
+}
+
+func (e *scriptEnv) inventory() (*Inventory, error) {
+	if e.inv == nil {
+		inv, err := loadInventory(e.ff.inventory)
+		if err != nil {
+			return nil, err
+		}
+		e.inv = inv
+	}
+	return e.inv, nil
+}
+
+func (e *scriptEnv) close() {
+	for _, s := range e.sessions {
+		s.Close()
+	}
+}
+
+// switchBuiltins are run, parse, configure and save bound to the session
+// of host, or of the connection flags when host is empty.
+func (e *scriptEnv) switchBuiltins(host string) starlark.StringDict {
+	return starlark.StringDict{
+		"run": starlark.NewBuiltin("run", func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			var cmd string
+			if err := starlark.UnpackArgs(b.Name(), args, kwargs, "cmd", &cmd); err != nil {
+				return nil, err
+			}
+			s, err := e.session(host)
+			if err != nil {
+				return nil, err
+			}
+			out, err := s.Output(cmd)
+			if err != nil {
+				return nil, err
+			}
+			return starlark.String(out), nil
+		}),
+		"parse": starlark.NewBuiltin("parse", func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			var cmd string
+			if err := starlark.UnpackArgs(b.Name(), args, kwargs, "cmd", &cmd); err != nil {
+				return nil, err
+			}
+			s, err := e.session(host)
+			if err != nil {
+				return nil, err
+			}
+			results, err := parseCommandOutput(s, e.parsers, []string{cmd})
+			if err != nil {
+				return nil, err
+			}
+			return toStarlark(results[0].Data)
+		}),
+		"configure": starlark.NewBuiltin("configure", func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			var list *starlark.List
+			if err := starlark.UnpackArgs(b.Name(), args, kwargs, "cmds", &list); err != nil {
+				return nil, err
+			}
+			var commands []string
+			for i := range list.Len() {
+				c, ok := starlark.AsString(list.Index(i))
+				if !ok {
+					return nil, fmt.Errorf("%s: commands must be strings, got %s", b.Name(), list.Index(i).Type())
+				}
+				commands = append(commands, c)
+			}
+			s, err := e.session(host)
+			if err != nil {
+				return nil, err
+			}
+			var out strings.Builder
+			if err := s.Configure(commands, &out); err != nil {
+				return nil, err
+			}
+			return starlark.String(out.String()), nil
+		}),
+		"save": starlark.NewBuiltin("save", func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			if err := starlark.UnpackArgs(b.Name(), args, kwargs); err != nil {
+				return nil, err
+			}
+			s, err := e.session(host)
+			if err != nil {
+				return nil, err
+			}
+			msg, err := s.Save()
+			if err != nil {
+				return nil, err
+			}
+			return starlark.String(msg), nil
+		}),
+	}
+}
+
+// predeclared are the globals of a script: the switch builtins for the
+// default switch, inventory(), connect(name) and args.
+func (e *scriptEnv) predeclared(args []string) starlark.StringDict {
+	d := e.switchBuiltins("")
+	d["inventory"] = starlark.NewBuiltin("inventory", func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var tag string
+		if err := starlark.UnpackArgs(b.Name(), args, kwargs, "tag?", &tag); err != nil {
+			return nil, err
+		}
+		inv, err := e.inventory()
+		if err != nil {
+			return nil, err
+		}
+		var hosts []any
+		for _, h := range inv.Hosts {
+			if tag != "" && !slices.Contains(h.Tags, tag) {
+				continue
+			}
+			tags := make([]any, len(h.Tags))
+			for i, t := range h.Tags {
+				tags[i] = t
+			}
+			hosts = append(hosts, map[string]any{"name": h.Name, "host": h.Address, "tags": tags, "uplinks": h.Uplinks})
+		}
+		return toStarlark(hosts)
+	})
+	d["connect"] = starlark.NewBuiltin("connect", func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var name string
+		if err := starlark.UnpackArgs(b.Name(), args, kwargs, "name", &name); err != nil {
+			return nil, err
+		}
+		if _, err := e.session(name); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		methods := e.switchBuiltins(name)
+		methods["name"] = starlark.String(name)
+		return starlarkstruct.FromStringDict(starlark.String("switch"), methods), nil
+	})
+	list := make([]starlark.Value, len(args))
+	for i, a := range args {
+		list[i] = starlark.String(a)
+	}
+	d["args"] = starlark.NewList(list)
+	return d
+}
+
+// toStarlark converts parsed output to Starlark values by way of JSON, so
+// struct fields come out as dict keys the way --format json names them.
+func toStarlark(v any) (starlark.Value, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var generic any
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return nil, err
+	}
+	return jsonToStarlark(generic), nil
+}
+
+func jsonToStarlark(v any) starlark.Value {
+	switch v := v.(type) {
+	case map[string]any:
+		d := starlark.NewDict(len(v))
+		for _, k := range sortedStringKeys(v) {
+			d.SetKey(starlark.String(k), jsonToStarlark(v[k]))
+		}
+		return d
+	case []any:
+		list := make([]starlark.Value, len(v))
+		for i, e := range v {
+			list[i] = jsonToStarlark(e)
+		}
+		return starlark.NewList(list)
+	case string:
+		return starlark.String(v)
+	case bool:
+		return starlark.Bool(v)
+	case float64:
+		if v == float64(int64(v)) {
+			return starlark.MakeInt64(int64(v))
+		}
+		return starlark.Float(v)
+	}
+	return starlark.None
+}
+
+func runScript(fs *flag.FlagSet) func() {
+	cf := addConnFlags(fs)
+	ff := addFleetFlags(fs)
+	dryRun := addDryRunFlag(fs)
+	return func() {
+		if fs.NArg() < 1 {
+			fatal("Usage: zyxel script <file.star> [args...]")
+		}
+		parsers, err := loadParsers()
+		if err != nil {
+			fatal("%v", err)
+		}
+		e := &scriptEnv{cf: cf, ff: ff, dryRun: *dryRun, parsers: parsers, sessions: make(map[string]*Session)}
+		defer e.close()
+
+		thread := &starlark.Thread{
+			Name:  fs.Arg(0),
+			Print: func(_ *starlark.Thread, msg string) { fmt.Println(msg) },
+		}
+		opts := &syntax.FileOptions{Set: true, While: true, TopLevelControl: true, GlobalReassign: true}
+		_, err = starlark.ExecFileOptions(opts, thread, fs.Arg(0), nil, e.predeclared(fs.Args()[1:]))
+		var evalErr *starlark.EvalError
+		if errors.As(err, &evalErr) {
+			e.close()
+			fmt.Fprintln(os.Stderr, evalErr.Backtrace())
+			os.Exit(1)
+		}
+		if err != nil {
+			e.close()
+			fatal("%v", err)
+		}
+	}
+}
@@ -0,0 +1,1038 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joho/godotenv"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+)
+
+// Config holds the connection settings for a single switch.
+type Config struct {
+	Host     string
+	User     string
+	Password string
+	Port     string
+	// Transport is "ssh" (default), "telnet", or "http" or "https" for
+	// the web interface of the GS1900 series, which has no CLI session.
+	Transport string
+	// PagerCommand disables output paging; "none" skips it. Empty tries
+	// the pager commands of the model.
+	PagerCommand string
+	// Model is the model or family of the switch, e.g. "XGS2210", to use
+	// instead of detecting it on connect; "generic" assumes nothing.
+	Model string
+	// Commands map operations such as "mac-table" to the CLI command
+	// that performs them, for models that differ from the known ones.
+	Commands map[string]string
+	// EnablePassword is sent to "enable" when the login lands in user
+	// mode; the login password is used when it is empty.
+	EnablePassword string
+	// NewPassword answers a forced password change at login.
+	NewPassword string
+	// PromptRegex overrides prompt detection. It is matched against the
+	// last line of output.
+	PromptRegex string
+	// Auth is "password" (default; also answers keyboard-interactive) or
+	// "key", which logs in over SSH with KeyFile instead.
+	Auth    string
+	KeyFile string
+	// ConnectTimeout bounds connecting and logging in. CommandTimeout is
+	// how long a command may go without output before it fails, so long
+	// outputs are not cut off as long as they keep coming. Zero uses the
+	// defaults.
+	ConnectTimeout time.Duration
+	CommandTimeout time.Duration
+	// TermWidth and TermHeight are the terminal size announced to the
+	// switch; zero uses defaultTermWidth and defaultTermHeight.
+	TermWidth  int
+	TermHeight int
+	// WrapWidth, when set, rejoins output lines of exactly that many
+	// columns with the line after them, undoing the switch's line wrapping.
+	WrapWidth int
+	// PortDialect is the port notation of the switch: flat, slot or
+	// unit-slot. Empty or "auto" learns it from the running-config.
+	PortDialect string
+	// KeepAlive is how often a keep-alive is sent on the connection, so
+	// NAT and firewall idle timeouts do not cut it during slow commands.
+	// Zero uses defaultKeepAlive, negative turns it off.
+	KeepAlive time.Duration
+	// Reconnects is how many times a session logs in again after the
+	// switch dropped it. Zero uses defaultReconnects, negative never.
+	Reconnects int
+	// RecordFile, when set, is where the transcript of each session is
+	// written for "zyxel replay"; "{host}" expands to the switch address.
+	RecordFile string
+
+	// resolvedIP is Host looked up ahead of a fleet run; it is dialed
+	// instead of Host when set.
+	resolvedIP string
+}
+
+const (
+	defaultPagerCommand   = "terminal length 0"
+	defaultConnectTimeout = 10 * time.Second
+	defaultCommandTimeout = 30 * time.Second
+	defaultTermWidth      = 200
+	defaultTermHeight     = 80
+	defaultKeepAlive      = 30 * time.Second
+)
+
+// loadConfig reads the connection settings from the environment, loading
+// .env first if present.
+func loadConfig() (Config, error) {
+	cfg := envConfig()
+	return cfg, cfg.validate()
+}
+
+// envConfig reads whatever connection settings the environment provides,
+// filling in defaults but without checking for required ones.
+func envConfig() Config {
+	_ = godotenv.Load()
+
+	cfg := Config{
+		Host:     os.Getenv("ZYXEL_HOST"),
+		User:     os.Getenv("ZYXEL_USER"),
+		Password: os.Getenv("ZYXEL_PASSWORD"),
+		Port:     os.Getenv("ZYXEL_PORT"),
+
+		Transport: os.Getenv("ZYXEL_TRANSPORT"),
+
+		PagerCommand: os.Getenv("ZYXEL_PAGER_COMMAND"),
+		PromptRegex:  os.Getenv("ZYXEL_PROMPT_REGEX"),
+		Model:        os.Getenv("ZYXEL_MODEL"),
+
+		EnablePassword: os.Getenv("ZYXEL_ENABLE_PASSWORD"),
+		NewPassword:    os.Getenv("ZYXEL_NEW_PASSWORD"),
+
+		Auth:    os.Getenv("ZYXEL_AUTH"),
+		KeyFile: os.Getenv("ZYXEL_KEY_FILE"),
+
+		TermWidth:  atoiOr(os.Getenv("ZYXEL_TERM_WIDTH"), 0),
+		TermHeight: atoiOr(os.Getenv("ZYXEL_TERM_HEIGHT"), 0),
+		WrapWidth:  atoiOr(os.Getenv("ZYXEL_WRAP_WIDTH"), 0),
+
+		PortDialect: os.Getenv("ZYXEL_PORT_DIALECT"),
+		RecordFile:  os.Getenv("ZYXEL_RECORD"),
+	}
+	if v := os.Getenv("ZYXEL_KEEPALIVE"); v != "" {
+		cfg.KeepAlive = keepAliveSetting(v)
+	}
+	if v := os.Getenv("ZYXEL_RECONNECT"); v != "" {
+		if cfg.Reconnects = atoiOr(v, 0); cfg.Reconnects <= 0 {
+			cfg.Reconnects = -1
+		}
+	}
+	return cfg
+}
+
+// address returns host:port, defaulting the port for the transport.
+func (cfg Config) address() string {
+	return fmt.Sprintf("%s:%s", cfg.Host, cfg.port())
+}
+
+// dialAddress is address with the pre-resolved IP, if any, as the host.
+func (cfg Config) dialAddress() string {
+	if cfg.resolvedIP == "" {
+		return cfg.address()
+	}
+	return net.JoinHostPort(cfg.resolvedIP, cfg.port())
+}
+
+func (cfg Config) port() string {
+	if cfg.Port != "" {
+		return cfg.Port
+	}
+	switch cfg.Transport {
+	case "telnet":
+		return "23"
+	case "http":
+		return "80"
+	case "https":
+		return "443"
+	default:
+		return "22"
+	}
+}
+
+func (cfg Config) connectTimeout() time.Duration {
+	if cfg.ConnectTimeout > 0 {
+		return cfg.ConnectTimeout
+	}
+	return defaultConnectTimeout
+}
+
+func (cfg Config) termWidth() int {
+	if cfg.TermWidth > 0 {
+		return cfg.TermWidth
+	}
+	return defaultTermWidth
+}
+
+func (cfg Config) termHeight() int {
+	if cfg.TermHeight > 0 {
+		return cfg.TermHeight
+	}
+	return defaultTermHeight
+}
+
+// keepAlive returns the keep-alive interval, zero when it is off.
+func (cfg Config) keepAlive() time.Duration {
+	switch {
+	case cfg.KeepAlive < 0:
+		return 0
+	case cfg.KeepAlive == 0:
+		return defaultKeepAlive
+	}
+	return cfg.KeepAlive
+}
+
+// keepAliveSetting parses a keep-alive interval such as "15s"; "0" or
+// "off" turns keep-alives off.
+func keepAliveSetting(v string) time.Duration {
+	d, err := time.ParseDuration(v)
+	if v == "off" || err == nil && d <= 0 {
+		return -1
+	}
+	return d
+}
+
+// needsPassword reports whether logging in takes a password.
+func (cfg Config) needsPassword() bool {
+	return cfg.Auth != "key"
+}
+
+// readPassword asks for a password on the terminal with echo off, so it
+// need not be kept in the environment or a .env file. Without a terminal
+// it returns "" and validate reports the missing ZYXEL_PASSWORD.
+func readPassword(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", nil
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	pw, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", errorf("failed to read password: %w", err)
+	}
+	return string(pw), nil
+}
+
+func (cfg Config) validate() error {
+	var missing []string
+	if cfg.Host == "" {
+		missing = append(missing, "ZYXEL_HOST")
+	}
+	if cfg.User == "" {
+		missing = append(missing, "ZYXEL_USER")
+	}
+	if cfg.Password == "" && cfg.needsPassword() {
+		missing = append(missing, "ZYXEL_PASSWORD")
+	}
+	if len(missing) > 0 {
+		return errorf("missing required environment variables: %s", strings.Join(missing, ", "))
+	}
+	switch cfg.Transport {
+	case "", "ssh", "telnet", "http", "https":
+	default:
+		return errorf("unknown transport %q (want ssh, telnet, http or https)", cfg.Transport)
+	}
+	switch cfg.Auth {
+	case "", "password":
+	case "key":
+		if cfg.Transport != "" && cfg.Transport != "ssh" {
+			return errorf("key authentication needs the ssh transport")
+		}
+		if cfg.KeyFile == "" {
+			return errorf("key authentication needs ZYXEL_KEY_FILE")
+		}
+	default:
+		return errorf("unknown auth method %q (want password or key)", cfg.Auth)
+	}
+	if cfg.PromptRegex != "" {
+		if _, err := regexp.Compile(cfg.PromptRegex); err != nil {
+			return errorf("invalid ZYXEL_PROMPT_REGEX: %w", err)
+		}
+	}
+	if cfg.PortDialect != "auto" {
+		if _, err := parseDialect(cfg.PortDialect); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Session is an interactive shell on a switch, positioned at the prompt.
+// Each session owns its reader, so several sessions can be used from
+// different goroutines; calls on one session are serialized.
+type Session struct {
+	mu        sync.Mutex
+	closeFn   func() error
+	closeOnce sync.Once
+	stdin     io.Writer
+	out       *reader
+	// prompt matches the last line of output when the switch is ready.
+	prompt *regexp.Regexp
+	// lastPrompt is the most recent prompt line, e.g. "sw1>" or "sw1#".
+	lastPrompt string
+	// banner is the output of the login up to the first prompt.
+	banner string
+	// model is how to drive the switch, detected on connect, and
+	// modelName and firmware what it reported about itself.
+	model     switchModel
+	modelName string
+	firmware  string
+	// noPager is set once paging was turned off, so "more" in the output
+	// is no longer answered with a space.
+	noPager bool
+	// commandTimeout is Config.CommandTimeout; zero uses
+	// defaultCommandTimeout.
+	commandTimeout time.Duration
+	// wrapWidth is Config.WrapWidth, for the line streamers of Output.
+	wrapWidth int
+	// dialect is the port notation, once set from Config.PortDialect or
+	// learned by portDialect.
+	dialect      Dialect
+	dialectKnown bool
+	// dryRun prints configuration commands and write memory on stdout
+	// instead of sending them; commands that only read still run.
+	dryRun bool
+	// host is Config.Host, naming the checkpoints taken before changes.
+	host string
+	// checkpointed is set once the running-config was saved as a
+	// checkpoint in this session.
+	checkpointed bool
+	// cfg is what the session was dialed with, to reconnect with, and
+	// reconnected how often that happened.
+	cfg         Config
+	reconnected int
+}
+
+// Dial connects to the switch and waits for the first prompt.
+func Dial(cfg Config) (*Session, error) {
+	return DialContext(context.Background(), cfg)
+}
+
+// DialContext is Dial with a context: canceling it, or its deadline
+// passing, stops connecting, logging in and waiting for the prompt.
+func DialContext(ctx context.Context, cfg Config) (*Session, error) {
+	var s *Session
+	var err error
+	pc := &passwordChange{host: cfg.Host, old: cfg.Password, new: cfg.NewPassword}
+	switch cfg.Transport {
+	case "telnet":
+		s, err = dialTelnet(ctx, cfg, pc)
+	case "http", "https":
+		return nil, errorf("the %s transport has no CLI; only -c with show running-config, show vlan or show interfaces status is supported", cfg.Transport)
+	default:
+		s, err = dialSSH(ctx, cfg, pc)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if pc.changed {
+		pc.reportChange()
+		cfg.Password = cfg.NewPassword
+	}
+	cfg.NewPassword = ""
+
+	if s.userMode() {
+		password := cfg.EnablePassword
+		if password == "" {
+			password = cfg.Password
+		}
+		if err := s.enable(ctx, password, cfg.connectTimeout()); err != nil {
+			s.Close()
+			return nil, err
+		}
+	}
+
+	s.commandTimeout = cfg.CommandTimeout
+	s.host = cfg.Host
+	s.cfg = cfg
+	s.wrapWidth = cfg.WrapWidth
+	if cfg.PortDialect != "" && cfg.PortDialect != "auto" {
+		s.dialect, _ = parseDialect(cfg.PortDialect)
+		s.dialectKnown = true
+	}
+	s.detectModel(ctx, cfg)
+	if cfg.PromptRegex == "" {
+		if m := anyPrompt.FindStringSubmatch(s.lastPrompt); m != nil {
+			s.prompt = hostPrompt(m[1], s.model.modes)
+		}
+	}
+	if cfg.PagerCommand != "" {
+		s.disablePaging(ctx, cfg.PagerCommand)
+	} else {
+		for _, command := range s.model.pager {
+			if s.disablePaging(ctx, command); s.noPager {
+				break
+			}
+		}
+	}
+	return s, nil
+}
+
+// sshAuth returns the SSH authentication methods for cfg.Auth.
+// Keyboard-interactive questions of a forced password change go to pc.
+func sshAuth(cfg Config, pc *passwordChange) ([]ssh.AuthMethod, error) {
+	if cfg.Auth == "key" {
+		key, err := os.ReadFile(cfg.KeyFile)
+		if err != nil {
+			return nil, errorf("failed to read SSH key: %w", err)
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, errorf("failed to parse SSH key %s: %w", cfg.KeyFile, err)
+		}
+		return []ssh.AuthMethod{ssh.PublicKeys(signer)}, nil
+	}
+	return []ssh.AuthMethod{
+		ssh.Password(cfg.Password),
+		ssh.KeyboardInteractive(func(user, instruction string, questions []string, echos []bool) ([]string, error) {
+			answers := make([]string, len(questions))
+			for i, q := range questions {
+				a, ok, err := pc.answer(q)
+				if err != nil {
+					return nil, err
+				}
+				if !ok {
+					a = cfg.Password
+				}
+				answers[i] = a
+			}
+			return answers, nil
+		}),
+	}, nil
+}
+
+func dialSSH(ctx context.Context, cfg Config, pc *passwordChange) (*Session, error) {
+	client, err := sshClient(ctx, cfg, pc)
+	if err != nil {
+		return nil, err
+	}
+	s, err := startShell(client, cfg)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	if err := s.waitPrompt(ctx, cfg.PromptRegex, cfg.connectTimeout(), pc); err != nil {
+		s.shutdown()
+		return nil, err
+	}
+	return s, nil
+}
+
+// sshClient connects and logs in over SSH.
+func sshClient(ctx context.Context, cfg Config, pc *passwordChange) (*ssh.Client, error) {
+	auth, err := sshAuth(cfg, pc)
+	if err != nil {
+		return nil, err
+	}
+	config := &ssh.ClientConfig{
+		User:            cfg.User,
+		Auth:            auth,
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		HostKeyAlgorithms: []string{
+			"ssh-rsa",
+			"rsa-sha2-256",
+			"rsa-sha2-512",
+		},
+		Config: ssh.Config{
+			KeyExchanges: []string{
+				"diffie-hellman-group-exchange-sha256",
+				"diffie-hellman-group14-sha256",
+				"diffie-hellman-group14-sha1",
+			},
+		},
+		Timeout: cfg.connectTimeout(),
+	}
+
+	address := cfg.address()
+
+	d := net.Dialer{Timeout: config.Timeout}
+	conn, err := d.DialContext(ctx, "tcp", cfg.dialAddress())
+	if err != nil {
+		return nil, errorf("failed to connect to %s: %w", address, err)
+	}
+	// The SSH handshake does not take a context; closing the connection
+	// is what ends it early. ssh.Dial bounds it by the connect timeout.
+	conn.SetDeadline(time.Now().Add(config.Timeout))
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	c, chans, reqs, err := ssh.NewClientConn(conn, cfg.dialAddress(), config)
+	if !stop() || err != nil {
+		conn.Close()
+		return nil, errorf("failed to connect to %s: %w", address, cmp.Or(ctx.Err(), err))
+	}
+	conn.SetDeadline(time.Time{})
+	client := ssh.NewClient(c, chans, reqs)
+	if interval := cfg.keepAlive(); interval > 0 {
+		go sshKeepAlive(client, interval)
+	}
+	return client, nil
+}
+
+// sshKeepAlive sends an OpenSSH keep-alive request every interval until
+// the connection is gone. The switch answers it without touching the CLI,
+// so it is safe in the middle of a command.
+func sshKeepAlive(client *ssh.Client, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for range t.C {
+		if _, _, err := client.SendRequest("keepalive@openssh.com", true, nil); err != nil {
+			return
+		}
+	}
+}
+
+var passwordPrompt = regexp.MustCompile(`(?i)password\s*:\s*$`)
+
+// userMode reports whether the switch is at an unprivileged ">" prompt.
+func (s *Session) userMode() bool {
+	return strings.HasSuffix(s.lastPrompt, ">")
+}
+
+// enable moves from user mode to privileged mode, answering the password
+// prompt if the switch asks for one. Each answer is waited for up to
+// timeout.
+func (s *Session) enable(ctx context.Context, password string, timeout time.Duration) error {
+	fmt.Fprintf(s.stdin, "enable\n")
+	line, _, err := s.waitFor(ctx, func(line string) bool {
+		return passwordPrompt.MatchString(line) || s.prompt.MatchString(line)
+	}, timeout)
+	if err != nil {
+		return errorf("enable failed: %w", err)
+	}
+
+	if passwordPrompt.MatchString(line) {
+		fmt.Fprintf(s.stdin, "%s\n", password)
+		line, _, err = s.waitFor(ctx, func(line string) bool {
+			return passwordPrompt.MatchString(line) || s.prompt.MatchString(line)
+		}, timeout)
+		if err != nil {
+			return errorf("enable failed: %w", err)
+		}
+		if passwordPrompt.MatchString(line) {
+			return errorf("enable failed: password rejected (set ZYXEL_ENABLE_PASSWORD)")
+		}
+	}
+
+	s.lastPrompt = line
+	if s.userMode() {
+		return errorf("enable failed: still at unprivileged prompt %q", line)
+	}
+	return nil
+}
+
+// waitFor reads output until the last line satisfies match. It returns that
+// line and everything read before it. It gives up after timeout or when
+// ctx is done.
+func (s *Session) waitFor(ctx context.Context, match func(line string) bool, timeout time.Duration) (line, output string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.waitForLocked(ctx, match, timeout)
+}
+
+// waitForLocked is waitFor for callers that hold s.mu.
+func (s *Session) waitForLocked(ctx context.Context, match func(line string) bool, timeout time.Duration) (line, output string, err error) {
+	var tail string
+	deadline := time.After(timeout)
+	for {
+		select {
+		case chunk, ok := <-s.out.data:
+			if !ok {
+				return "", tail, errorf("connection closed: %w", s.out.closed())
+			}
+			tail += chunk
+			if line := promptLine(tail); match(line) {
+				rest := strings.TrimRight(tail, " \r\n")
+				return line, strings.TrimSuffix(rest, tailLine(rest)), nil
+			}
+		case <-deadline:
+			toolMetrics.timeouts.Add(1)
+			return "", tail, errorf("timeout after %s, last output %q", timeout, promptLine(tail))
+		case <-ctx.Done():
+			return "", tail, ctx.Err()
+		}
+	}
+}
+
+// disablePaging sends command to turn the pager off. Switches that reject
+// it keep their pager and Run falls back to answering "more" prompts.
+func (s *Session) disablePaging(ctx context.Context, command string) {
+	if command == "" || command == "none" {
+		return
+	}
+	out, err := s.OutputContext(ctx, command)
+	if err != nil || looksLikeError(out) {
+		return
+	}
+	s.noPager = true
+}
+
+// looksLikeError reports whether output is the switch rejecting a command.
+func looksLikeError(output string) bool {
+	for _, line := range strings.Split(output, "\n") {
+		l := strings.ToLower(strings.TrimSpace(line))
+		if strings.HasPrefix(l, "%") {
+			return true
+		}
+		for _, marker := range []string{"invalid", "unknown command", "unrecognized", "incomplete", "error"} {
+			if strings.Contains(l, marker) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// anyPrompt matches a prompt before the hostname is known: a single word
+// ending in "#" or ">", optionally with a mode such as "(config)".
+var anyPrompt = regexp.MustCompile(`^\s*([\w.\-/:]+)(?:\([\w\-/]*\))?[#>]$`)
+
+// hostPrompt builds the default prompt pattern from the hostname seen in
+// the first prompt, so "#" inside output lines is not mistaken for it.
+// modes matches the mode in parentheses, which differs between models.
+func hostPrompt(hostname, modes string) *regexp.Regexp {
+	return regexp.MustCompile(`^\s*` + regexp.QuoteMeta(hostname) + `(?:\(` + modes + `\))?[#>]$`)
+}
+
+// promptLine returns the cleaned last line of tail, ignoring trailing
+// whitespace, for matching against a prompt pattern.
+func promptLine(tail string) string {
+	return strings.TrimSpace(cleanLine(tailLine(strings.TrimRight(tail, " \r\n"))))
+}
+
+// atPrompt reports whether tail ends with the switch prompt.
+func (s *Session) atPrompt(tail string) bool {
+	return s.prompt.MatchString(promptLine(tail))
+}
+
+func startShell(client *ssh.Client, cfg Config) (*Session, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, errorf("failed to create SSH session: %w", err)
+	}
+
+	modes := ssh.TerminalModes{
+		ssh.ECHO:          0,
+		ssh.TTY_OP_ISPEED: 14400,
+		ssh.TTY_OP_OSPEED: 14400,
+	}
+
+	if err := session.RequestPty("xterm", cfg.termHeight(), cfg.termWidth(), modes); err != nil {
+		session.Close()
+		return nil, errorf("failed to request PTY: %w", err)
+	}
+
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		session.Close()
+		return nil, errorf("failed to get stdin pipe: %w", err)
+	}
+
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		session.Close()
+		return nil, errorf("failed to get stdout pipe: %w", err)
+	}
+
+	if err := session.Shell(); err != nil {
+		session.Close()
+		return nil, errorf("failed to start shell: %w", err)
+	}
+
+	return newSession(recording(cfg.RecordFile, cfg.Host, stdin, stdout, func() error {
+		session.Close()
+		return client.Close()
+	})), nil
+}
+
+// reader pumps the output of one connection into a channel with its own
+// buffer. data is closed after the last chunk; err then tells why.
+type reader struct {
+	data chan string
+	err  error
+	done chan struct{}
+	// finished is closed when the stream ended.
+	finished chan struct{}
+}
+
+func newReader(r io.Reader) *reader {
+	rd := &reader{data: make(chan string, 100), done: make(chan struct{}), finished: make(chan struct{})}
+	go func() {
+		defer close(rd.finished)
+		defer close(rd.data)
+		buf := make([]byte, 4096)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				select {
+				case rd.data <- string(buf[:n]):
+				case <-rd.done:
+					return
+				}
+			}
+			if err != nil {
+				rd.err = err
+				return
+			}
+		}
+	}()
+	return rd
+}
+
+// stop makes the read loop exit instead of blocking on a full channel once
+// nobody is reading anymore.
+func (rd *reader) stop() {
+	close(rd.done)
+}
+
+// ended reports whether the stream has ended.
+func (rd *reader) ended() bool {
+	select {
+	case <-rd.finished:
+		return true
+	default:
+		return false
+	}
+}
+
+// closed returns the error that ended the stream.
+func (rd *reader) closed() error {
+	if rd.err == nil {
+		return io.EOF
+	}
+	return rd.err
+}
+
+// newSession starts reading stdout in the background. closeFn tears down
+// the underlying connection.
+func newSession(stdin io.Writer, stdout io.Reader, closeFn func() error) *Session {
+	toolMetrics.sessionsOpen.Add(1)
+	s := &Session{
+		closeFn: closeFn,
+		stdin:   stdin,
+		out:     newReader(stdout),
+	}
+	trackSession(s)
+	return s
+}
+
+// waitPrompt waits up to timeout for the first prompt. Unless promptRegex
+// is given, the prompt pattern is learned from the hostname in it. A forced
+// password change on the way is answered by pc.
+func (s *Session) waitPrompt(ctx context.Context, promptRegex string, timeout time.Duration, pc *passwordChange) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if promptRegex != "" {
+		s.prompt = regexp.MustCompile(promptRegex)
+	}
+
+	var tail string
+	promptTimeout := time.After(timeout)
+	for {
+		select {
+		case chunk, ok := <-s.out.data:
+			if !ok {
+				return errorf("connection closed unexpectedly")
+			}
+			tail += chunk
+			line := promptLine(tail)
+			reply, ok, err := pc.answer(line)
+			if err != nil {
+				return err
+			}
+			if ok {
+				fmt.Fprintf(s.stdin, "%s\n", reply)
+				tail = ""
+				continue
+			}
+			if pc.new == "" && passwordExpired.MatchString(tail) {
+				return &PasswordExpiredError{Host: pc.host, Prompt: expiryMessage(tail)}
+			}
+			if loginPrompt.MatchString(line) || passwordPrompt.MatchString(line) {
+				return errorf("login rejected, switch asks again: %q", line)
+			}
+			if s.prompt != nil {
+				if s.atPrompt(tail) {
+					s.lastPrompt, s.banner = promptLine(tail), tail
+					return nil
+				}
+				continue
+			}
+			if m := anyPrompt.FindStringSubmatch(promptLine(tail)); m != nil {
+				s.prompt = hostPrompt(m[1], defaultModes)
+				s.lastPrompt, s.banner = promptLine(tail), tail
+				return nil
+			}
+		case <-promptTimeout:
+			toolMetrics.timeouts.Add(1)
+			return errorf("timeout waiting for switch prompt")
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Run sends command and passes every chunk of output to emit until the
+// prompt comes back. If the switch sends nothing for the command timeout
+// before that, Run fails, as the output may be incomplete.
+func (s *Session) Run(command string, emit func(chunk string)) error {
+	return s.RunContext(context.Background(), command, emit)
+}
+
+// RunContext is Run with a context. When ctx is done before the prompt
+// comes back it returns ctx.Err(); the rest of the output is then still
+// on its way, so the session should be closed rather than reused.
+func (s *Session) RunContext(ctx context.Context, command string, emit func(chunk string)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	toolMetrics.commands.Add(1)
+	fmt.Fprintf(s.stdin, "%s\n", command)
+
+	// The tail of the stream is kept separately for prompt detection.
+	var tail string
+	limit := s.commandTimeout
+	if limit <= 0 {
+		limit = defaultCommandTimeout
+	}
+	timeout := time.NewTimer(limit)
+	defer timeout.Stop()
+	// idle fires when the output pauses at what looks like a prompt other
+	// than the known one, or at a confirmation.
+	idle := time.NewTimer(limit)
+	idle.Stop()
+	defer idle.Stop()
+	seenContent := false
+	// probe is the prompt-like line an empty line was sent after, to see
+	// whether the switch repeats it; held is the output since.
+	var probe, held string
+
+	for {
+		select {
+		case chunk, ok := <-s.out.data:
+			if !ok {
+				return errorf("connection closed: %w", s.out.closed())
+			}
+			tail += chunk
+			if len(tail) > 512 {
+				tail = tail[len(tail)-512:]
+			}
+			timeout.Reset(limit)
+			if probe != "" {
+				// The echo of the probe is not output of the command, but
+				// what comes before the known prompt is.
+				held += chunk
+				switch {
+				case strings.TrimSpace(cleanLine(tailLine(tail))) == probe:
+					s.adoptPrompt(probe)
+					return nil
+				case s.atPrompt(tail):
+					emit(held)
+					s.lastPrompt = promptLine(tail)
+					return nil
+				}
+				continue
+			}
+			emit(chunk)
+			if line := promptLine(tail); anyPrompt.MatchString(line) || confirmPrompt.MatchString(line) {
+				idle.Reset(promptIdle)
+			} else {
+				idle.Stop()
+			}
+
+			// Answer the pager and forget its prompt so it is not answered twice.
+			if !s.noPager && pagerPrompt.MatchString(sanitizeLine(tailLine(tail))) {
+				fmt.Fprintf(s.stdin, " ")
+				tail = ""
+				continue
+			}
+
+			if strings.Contains(chunk, "\n") {
+				seenContent = true
+			}
+
+			if seenContent && s.atPrompt(tail) {
+				s.lastPrompt = promptLine(tail)
+				return nil
+			}
+
+		case <-idle.C:
+			line := promptLine(tail)
+			if confirmPrompt.MatchString(line) {
+				// The switch asks for a confirmation; Send answers it.
+				return nil
+			}
+			// Not the prompt we know, though shaped like one: a command
+			// such as hostname may have changed it. It is the prompt only
+			// if the switch repeats it for an empty line; anything else
+			// waits for the known prompt until the timeout.
+			probe, tail = line, ""
+			fmt.Fprintf(s.stdin, "\n")
+
+		case <-timeout.C:
+			toolMetrics.timeouts.Add(1)
+			return errorf("no prompt after %s without output, last line %q", limit, promptLine(tail))
+
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// promptIdle is how long the output must pause at a prompt other than
+// the known one, or at a confirmation, before Run looks at it.
+const promptIdle = 500 * time.Millisecond
+
+// confirmPrompt matches a last line where the switch explicitly waits for
+// an answer rather than a command. A bare "?" or "]" is not enough: output
+// lines end in those too.
+var confirmPrompt = regexp.MustCompile(`(?i)(\(y/n\)|\[y/n\]|\(yes/no\)|\[yes/no\]|password\s*:)\s*$`)
+
+// adoptPrompt makes line, a prompt the switch repeated for an empty line,
+// the prompt of the session, as after a hostname change.
+func (s *Session) adoptPrompt(line string) {
+	s.lastPrompt = line
+	if s.atPrompt(line) {
+		return
+	}
+	modes := s.model.modes
+	if modes == "" {
+		modes = defaultModes
+	}
+	if m := anyPrompt.FindStringSubmatch(line); m != nil {
+		s.prompt = hostPrompt(m[1], modes)
+	}
+}
+
+// tailLine returns the last, unterminated line of s.
+func tailLine(s string) string {
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
+
+// Output runs command and returns its cleaned output.
+func (s *Session) Output(command string) (string, error) {
+	return s.OutputContext(context.Background(), command)
+}
+
+// OutputContext is Output with a context, as RunContext.
+func (s *Session) OutputContext(ctx context.Context, command string) (string, error) {
+	var b strings.Builder
+	out := newLineStreamer(&b, false)
+	out.wrap = s.wrapWidth
+	err := s.RunContext(ctx, command, out.Write)
+	return b.String(), err
+}
+
+// Send writes line to the switch without waiting for the prompt, for
+// interactive sequences such as confirmations and password prompts. Read
+// what the switch answers with Expect.
+func (s *Session) Send(line string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	toolMetrics.commands.Add(1)
+	_, err := fmt.Fprintf(s.stdin, "%s\n", line)
+	return err
+}
+
+// Expect reads output until pattern matches it and returns the cleaned
+// output up to the end of the match; whatever the switch sent after the
+// match is dropped. A nil pattern waits for the prompt, which leaves the
+// session ready for Run again. Zero timeout uses the command timeout.
+func (s *Session) Expect(pattern *regexp.Regexp, timeout time.Duration) (string, error) {
+	return s.ExpectContext(context.Background(), pattern, timeout)
+}
+
+// ExpectContext is Expect with a context; it returns ctx.Err() when ctx
+// is done first.
+func (s *Session) ExpectContext(ctx context.Context, pattern *regexp.Regexp, timeout time.Duration) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if timeout <= 0 {
+		timeout = cmp.Or(s.commandTimeout, defaultCommandTimeout)
+	}
+	var raw, text string
+	deadline := time.After(timeout)
+	for {
+		select {
+		case chunk, ok := <-s.out.data:
+			if !ok {
+				return "", errorf("connection closed: %w", s.out.closed())
+			}
+			raw += chunk
+			lines := strings.Split(raw, "\n")
+			for i, l := range lines {
+				lines[i] = cleanLine(l)
+			}
+			text = strings.Join(lines, "\n")
+			if pattern == nil {
+				if s.atPrompt(raw) {
+					s.lastPrompt = promptLine(raw)
+					return strings.TrimSuffix(strings.TrimRight(text, " \r\n"), s.lastPrompt), nil
+				}
+				continue
+			}
+			if loc := pattern.FindStringIndex(text); loc != nil {
+				if s.atPrompt(raw) {
+					s.lastPrompt = promptLine(raw)
+				}
+				return text[:loc[1]], nil
+			}
+		case <-deadline:
+			toolMetrics.timeouts.Add(1)
+			want := "the prompt"
+			if pattern != nil {
+				want = fmt.Sprintf("%q", pattern)
+			}
+			return text, errorf("timeout after %s waiting for %s, last output %q", timeout, want, promptLine(raw))
+		case <-ctx.Done():
+			return text, ctx.Err()
+		}
+	}
+}
+
+// Close logs out of the switch and closes the connection.
+func (s *Session) Close() error {
+	fmt.Fprintf(s.stdin, "exit\n")
+	return s.shutdown()
+}
+
+// interrupt closes the session from a signal handler, while a command may
+// still be running: Ctrl+C first leaves a pager prompt or a half-typed
+// line, so the switch sees the exit.
+func (s *Session) interrupt() error {
+	io.WriteString(s.stdin, "\x03")
+	return s.Close()
+}
+
+// shutdown stops the reader and closes the connection; later calls do
+// nothing.
+func (s *Session) shutdown() error {
+	var err error
+	s.closeOnce.Do(func() {
+		toolMetrics.sessionsOpen.Add(-1)
+		untrackSession(s)
+		s.out.stop()
+		err = s.closeFn()
+	})
+	return err
+}
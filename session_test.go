@@ -0,0 +1,256 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// step is one turn of a fake switch: it waits for the session to send
+// expect, pauses, then writes each chunk of send as a read of its own.
+type step struct {
+	expect string
+	pause  time.Duration
+	send   []string
+}
+
+// fakeSwitch returns a session at the prompt "sw1#" talking to a switch
+// that plays steps over a pipe and then hangs up if hangUp is set. stray
+// returns, once the session is closed, whatever the session sent that no
+// step waited for.
+func fakeSwitch(t *testing.T, steps []step, hangUp bool) (s *Session, stray func() string) {
+	t.Helper()
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	s = newSession(inW, outR, func() error {
+		inW.Close()
+		outR.Close()
+		return nil
+	})
+	s.prompt = hostPrompt("sw1", defaultModes)
+	s.lastPrompt = "sw1#"
+	s.commandTimeout = 2 * time.Second
+
+	input := make(chan byte, 4096)
+	go func() {
+		defer close(input)
+		buf := make([]byte, 256)
+		for {
+			n, err := inR.Read(buf)
+			for _, b := range buf[:n] {
+				input <- b
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, st := range steps {
+			var got []byte
+			for len(got) < len(st.expect) {
+				select {
+				case b, ok := <-input:
+					if !ok {
+						t.Errorf("switch waited for %q, connection closed after %q", st.expect, got)
+						return
+					}
+					got = append(got, b)
+				case <-time.After(5 * time.Second):
+					t.Errorf("switch waited for %q, got %q", st.expect, got)
+					return
+				}
+			}
+			if string(got) != st.expect {
+				t.Errorf("switch waited for %q, got %q", st.expect, got)
+				return
+			}
+			time.Sleep(st.pause)
+			for _, chunk := range st.send {
+				if _, err := io.WriteString(outW, chunk); err != nil {
+					return
+				}
+			}
+		}
+		if hangUp {
+			outW.Close()
+		}
+	}()
+
+	return s, func() string {
+		s.shutdown()
+		<-done
+		var rest []byte
+		for b := range input {
+			rest = append(rest, b)
+		}
+		return string(rest)
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		steps   []step
+		hangUp  bool
+		timeout time.Duration
+		// want is the output as Output returns it; wantErr a part of
+		// the error, if one is expected.
+		want       string
+		wantErr    string
+		wantPrompt string
+	}{
+		{
+			name:    "prompt split across reads",
+			command: "show vlan",
+			steps: []step{{expect: "show vlan\n", send: []string{
+				"show vlan\r\n  VID  Name\r\n    1  default\r", "\n", "sw", "1#",
+			}}},
+			want:       "  VID  Name\n    1  default\n",
+			wantPrompt: "sw1#",
+		},
+		{
+			name:    "prompt with trailing space in its own read",
+			command: "show version",
+			steps: []step{{expect: "show version\n", send: []string{
+				"show version\r\nFirmware: V4.80\r\n", "sw1#", " ",
+			}}},
+			want:       "Firmware: V4.80\n",
+			wantPrompt: "sw1#",
+		},
+		{
+			name:    "pager at a chunk boundary",
+			command: "show mac address-table",
+			steps: []step{
+				{expect: "show mac address-table\n", send: []string{
+					"show mac address-table\r\n 1  00:11:22:33:44:55  1\r\n-- mo", "re --",
+				}},
+				{expect: " ", send: []string{
+					"\r          \r 2  00:11:22:33:44:66  1\r\nsw1#",
+				}},
+			},
+			want:       " 1  00:11:22:33:44:55  1\n 2  00:11:22:33:44:66  1\n",
+			wantPrompt: "sw1#",
+		},
+		{
+			name:    "pager split inside its quit hint",
+			command: "show interfaces status",
+			steps: []step{
+				{expect: "show interfaces status\n", send: []string{
+					"show interfaces status\r\n1  Up\r\n", "Next page: Spa", "ce, Quit: q",
+				}},
+				{expect: " ", send: []string{
+					"\x1b[2K\r2  Down\r\nsw1#",
+				}},
+			},
+			want:       "1  Up\n2  Down\n",
+			wantPrompt: "sw1#",
+		},
+		{
+			name:    "question mark at a pause is not a prompt",
+			command: "show logging",
+			steps: []step{{expect: "show logging\n", send: []string{
+				"show logging\r\nport 3 link flapping, check cable?",
+			}}, {pause: 2 * promptIdle, send: []string{
+				"\r\nsw1#",
+			}}},
+			want:       "port 3 link flapping, check cable?\n",
+			wantPrompt: "sw1#",
+		},
+		{
+			name:    "bracket at a pause is not a prompt",
+			command: "show running-config",
+			steps: []step{{expect: "show running-config\n", send: []string{
+				"show running-config\r\n  name [uplink]",
+			}}, {pause: 2 * promptIdle, send: []string{
+				"\r\nsw1#",
+			}}},
+			want:       "  name [uplink]\n",
+			wantPrompt: "sw1#",
+		},
+		{
+			name:    "question mark without a prompt times out",
+			command: "show logging",
+			steps: []step{{expect: "show logging\n", send: []string{
+				"show logging\r\nreboot required?",
+			}}},
+			timeout: 3 * promptIdle,
+			wantErr: `last line "reboot required?"`,
+		},
+		{
+			name:    "confirmation",
+			command: "erase running-config",
+			steps: []step{{expect: "erase running-config\n", send: []string{
+				"erase running-config\r\nErase the configuration? (y/n) ",
+			}}},
+			wantPrompt: "sw1#",
+		},
+		{
+			name:    "prompt changed by the command",
+			command: "hostname sw2",
+			steps: []step{
+				{expect: "hostname sw2\n", send: []string{"hostname sw2\r\nsw2#"}},
+				{expect: "\n", send: []string{"\r\nsw2#"}},
+			},
+			wantPrompt: "sw2#",
+		},
+		{
+			name:    "connection lost",
+			command: "show system-information",
+			steps: []step{{expect: "show system-information\n", send: []string{
+				"show system-information\r\nSystem Name: sw1\r\n",
+			}}},
+			hangUp:  true,
+			want:    "System Name: sw1\n",
+			wantErr: "connection closed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, stray := fakeSwitch(t, tt.steps, tt.hangUp)
+			if tt.timeout > 0 {
+				s.commandTimeout = tt.timeout
+			}
+			got, err := s.Output(tt.command)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("Output(%q) failed: %v", tt.command, err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("Output(%q) error = %v, want one with %q", tt.command, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Output(%q) = %q, want %q", tt.command, got, tt.want)
+			}
+			if tt.wantPrompt != "" && s.lastPrompt != tt.wantPrompt {
+				t.Errorf("lastPrompt = %q, want %q", s.lastPrompt, tt.wantPrompt)
+			}
+			if rest := stray(); rest != "" {
+				t.Errorf("the session also sent %q", rest)
+			}
+		})
+	}
+}
+
+func TestWaitPrompt(t *testing.T) {
+	s, stray := fakeSwitch(t, []step{{send: []string{
+		"\r\n\x1b[2J\x1b[H  Welcome\r\n\r\nGS1920", "-24HP", "#",
+	}}}, false)
+	s.prompt = nil
+	if err := s.waitPrompt(t.Context(), "", 5*time.Second, &passwordChange{}); err != nil {
+		t.Fatalf("waitPrompt failed: %v", err)
+	}
+	if s.lastPrompt != "GS1920-24HP#" {
+		t.Errorf("lastPrompt = %q, want %q", s.lastPrompt, "GS1920-24HP#")
+	}
+	if !s.atPrompt("\r\nGS1920-24HP(config)#") {
+		t.Errorf("prompt %q does not match the configuration prompt", s.prompt)
+	}
+	if rest := stray(); rest != "" {
+		t.Errorf("the session also sent %q", rest)
+	}
+}
@@ -0,0 +1,602 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/rsa"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// simSwitch is the switch of "zyxel simulate": a GS1920 whose
+// running-config follows the configuration commands it is sent, and whose
+// "show" output is derived from it. Only what the tool itself uses is
+// simulated.
+type simSwitch struct {
+	mu       sync.Mutex
+	hostname string
+	ports    int
+	global   []string
+	vlans    map[int]*simVLAN
+	// portConfig holds the commands of each port's interface block.
+	portConfig map[int][]string
+	// dropAfter, when set, drops each connection when it sends that many
+	// commands, the last one unanswered.
+	dropAfter int
+}
+
+type simVLAN struct {
+	lines []string
+	// members are the fixed, untagged and forbidden port sets.
+	members map[string]map[int]bool
+}
+
+const simPageLines = 20
+
+func newSimSwitch(ports int) *simSwitch {
+	sw := &simSwitch{
+		hostname:   "GS1920",
+		ports:      ports,
+		vlans:      make(map[int]*simVLAN),
+		portConfig: make(map[int][]string),
+	}
+	v := sw.vlan(1)
+	for p := 1; p <= ports; p++ {
+		v.members["fixed"][p] = true
+		v.members["untagged"][p] = true
+	}
+	v.lines = append(v.lines, "ip address default-management 192.168.1.1 255.255.255.0")
+	return sw
+}
+
+// vlan returns VLAN id, creating it.
+func (sw *simSwitch) vlan(id int) *simVLAN {
+	if v, ok := sw.vlans[id]; ok {
+		return v
+	}
+	v := &simVLAN{
+		lines:   []string{fmt.Sprintf("name %d", id)},
+		members: map[string]map[int]bool{"fixed": {}, "untagged": {}, "forbidden": {}},
+	}
+	sw.vlans[id] = v
+	return v
+}
+
+// setLine applies cmd to a block: "no X" removes the lines X starts, other
+// commands replace the line with the same words up to the last, or are
+// added.
+func setLine(lines []string, cmd string) []string {
+	if rest, ok := strings.CutPrefix(cmd, "no "); ok {
+		return slices.DeleteFunc(lines, func(l string) bool { return l == rest || strings.HasPrefix(l, rest+" ") })
+	}
+	f := strings.Fields(cmd)
+	key := strings.Join(f[:max(len(f)-1, 1)], " ")
+	for i, l := range lines {
+		if lf := strings.Fields(l); strings.Join(lf[:max(len(lf)-1, 1)], " ") == key {
+			lines[i] = cmd
+			return lines
+		}
+	}
+	return append(lines, cmd)
+}
+
+func (sw *simSwitch) portSet(list string) ([]int, error) {
+	ports, err := ParsePortList(list)
+	if err != nil {
+		return nil, err
+	}
+	var nums []int
+	for _, p := range ports {
+		if p.Unit > 1 || p.Slot > 1 || p.Num < 1 || p.Num > sw.ports {
+			return nil, fmt.Errorf("no port %s", p)
+		}
+		nums = append(nums, p.Num)
+	}
+	return nums, nil
+}
+
+// configure applies a configuration-mode command in mode ("", "vlan N" or
+// "interface port-channel LIST") and returns the mode after it.
+func (sw *simSwitch) configure(mode, cmd string) (string, error) {
+	f := strings.Fields(cmd)
+	negate := f[0] == "no"
+	args := f
+	if negate {
+		args = f[1:]
+		if len(args) == 0 {
+			return mode, fmt.Errorf("incomplete command")
+		}
+	}
+
+	switch {
+	case mode == "" && len(args) == 2 && args[0] == "vlan":
+		id := atoiOr(args[1], 0)
+		if id < 1 || id > 4094 {
+			return mode, fmt.Errorf("invalid VLAN %q", args[1])
+		}
+		if negate {
+			delete(sw.vlans, id)
+			return mode, nil
+		}
+		sw.vlan(id)
+		return cmd, nil
+	case mode == "" && !negate && len(args) == 3 && args[0] == "interface" && args[1] == "port-channel":
+		if _, err := sw.portSet(args[2]); err != nil {
+			return mode, err
+		}
+		return cmd, nil
+	case mode == "" && !negate && len(args) == 2 && args[0] == "hostname":
+		sw.hostname = args[1]
+		return mode, nil
+	case mode == "":
+		sw.global = setLine(sw.global, cmd)
+		return mode, nil
+	}
+
+	if id, ok := strings.CutPrefix(mode, "vlan "); ok {
+		v := sw.vlans[atoiOr(id, 0)]
+		if v == nil {
+			return "", fmt.Errorf("VLAN %s was deleted", id)
+		}
+		if set, ok := v.members[args[0]]; ok && len(args) == 2 {
+			ports, err := sw.portSet(args[1])
+			if err != nil {
+				return mode, err
+			}
+			for _, p := range ports {
+				if negate {
+					delete(set, p)
+				} else {
+					set[p] = true
+				}
+			}
+			return mode, nil
+		}
+		v.lines = setLine(v.lines, cmd)
+		return mode, nil
+	}
+
+	ports, _ := sw.portSet(strings.Fields(mode)[2])
+	for _, p := range ports {
+		sw.portConfig[p] = setLine(sw.portConfig[p], cmd)
+	}
+	return mode, nil
+}
+
+func simPortList(set map[int]bool) string {
+	var ports []Port
+	for p := range set {
+		ports = append(ports, Port{Num: p})
+	}
+	slices.SortFunc(ports, func(a, b Port) int { return a.Num - b.Num })
+	list, _ := FormatPortList(ports, DialectFlat)
+	return list
+}
+
+func (sw *simSwitch) runningConfig() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "hostname %s\n", sw.hostname)
+	for _, l := range sw.global {
+		fmt.Fprintln(&b, l)
+	}
+	for _, id := range sortedKeys(sw.vlans) {
+		v := sw.vlans[id]
+		fmt.Fprintf(&b, "vlan %d\n", id)
+		lines := slices.Clone(v.lines)
+		for _, kind := range []string{"fixed", "forbidden", "untagged"} {
+			if list := simPortList(v.members[kind]); list != "" {
+				lines = append(lines, kind+" "+list)
+			}
+		}
+		for _, l := range lines {
+			fmt.Fprintf(&b, "  %s\n", l)
+		}
+		fmt.Fprintln(&b, "exit")
+	}
+	for _, p := range sortedKeys(sw.portConfig) {
+		if len(sw.portConfig[p]) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "interface port-channel %d\n", p)
+		for _, l := range sw.portConfig[p] {
+			fmt.Fprintf(&b, "  %s\n", l)
+		}
+		fmt.Fprintln(&b, "exit")
+	}
+	return b.String()
+}
+
+func (sw *simSwitch) showVLAN() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "  The Number of VLAN :   %d\n", len(sw.vlans))
+	fmt.Fprintln(&b, "  Idx.  VID   Status     Elap-Time  TagCtl")
+	fmt.Fprintln(&b, "  ----  ----  ---------  ---------  ---------")
+	for i, id := range sortedKeys(sw.vlans) {
+		v := sw.vlans[id]
+		tagged := make(map[int]bool)
+		for p := range v.members["fixed"] {
+			if !v.members["untagged"][p] {
+				tagged[p] = true
+			}
+		}
+		untagged := make(map[int]bool)
+		for p := range v.members["untagged"] {
+			if v.members["fixed"][p] {
+				untagged[p] = true
+			}
+		}
+		fmt.Fprintf(&b, "  %4d  %4d  Static     0:37:54    Untagged :%s\n", i+1, id, simPortList(untagged))
+		fmt.Fprintf(&b, "                                    Tagged :%s\n", simPortList(tagged))
+	}
+	return b.String()
+}
+
+// linkUp is what the simulated cabling gives: the first four ports have a
+// link unless they were made inactive.
+func (sw *simSwitch) linkUp(p int) bool {
+	return p <= 4 && !slices.Contains(sw.portConfig[p], "inactive")
+}
+
+func (sw *simSwitch) showInterfaces(list string) (string, error) {
+	var ports []int
+	if list == "*" {
+		for p := 1; p <= sw.ports; p++ {
+			ports = append(ports, p)
+		}
+	} else {
+		var err error
+		if ports, err = sw.portSet(list); err != nil {
+			return "", err
+		}
+	}
+	var b strings.Builder
+	for _, p := range ports {
+		link, status := "Down", "STOP"
+		var pkts uint64
+		if sw.linkUp(p) {
+			link, status, pkts = "1000M/F", "FORWARDING", uint64(100000*p)
+		}
+		fmt.Fprintf(&b, "  Port Info        Port NO.          :%d\n", p)
+		fmt.Fprintf(&b, "                   Link              :%s\n", link)
+		fmt.Fprintf(&b, "                   Status            :%s\n", status)
+		fmt.Fprintf(&b, "                   LACP              :Disabled\n")
+		fmt.Fprintf(&b, "                   TotalPkts         :%d\n", pkts)
+		fmt.Fprintf(&b, "                   Tx KBs/s          :%.3f\n", float64(pkts%997)/10)
+		fmt.Fprintf(&b, "                   Rx KBs/s          :%.3f\n", float64(pkts%991)/10)
+		fmt.Fprintf(&b, "                   Up Time           :1:02:03\n")
+		fmt.Fprintf(&b, "  Tx Packet        Unicast           :%d\n", pkts/2)
+		fmt.Fprintf(&b, "                   Multicast         :%d\n", pkts/100)
+		fmt.Fprintf(&b, "                   Broadcast         :%d\n", pkts/200)
+		fmt.Fprintf(&b, "  Rx Packet        Unicast           :%d\n", pkts/2)
+		fmt.Fprintf(&b, "                   Multicast         :%d\n", pkts/100)
+		fmt.Fprintf(&b, "                   Broadcast         :%d\n", pkts/200)
+		fmt.Fprintf(&b, "  TX Collison      Single            :0\n")
+		fmt.Fprintf(&b, "  Error Packet     RX CRC            :0\n")
+		fmt.Fprintln(&b)
+	}
+	return b.String(), nil
+}
+
+func (sw *simSwitch) showMACTable() string {
+	var b strings.Builder
+	fmt.Fprintln(&b, "Port      VLAN ID        MAC Address         Type")
+	for p := 1; p <= sw.ports; p++ {
+		if sw.linkUp(p) {
+			fmt.Fprintf(&b, "%-10d%-15d00:19:cb:00:00:%02x   Dynamic\n", p, 1, p)
+		}
+	}
+	return b.String()
+}
+
+// exec runs a command given at the privileged prompt.
+func (sw *simSwitch) exec(cmd string) (string, error) {
+	f := strings.Fields(cmd)
+	switch {
+	case cmd == "show system-information":
+		return fmt.Sprintf(`  Product Model           : GS1920-24HPv2
+  System Name             : %s
+  System Contact          :
+  System Location         :
+  System up Time          : 1days 2:03:04 (d9a7c2 ticks)
+  Ethernet Address        : bc:99:11:00:00:01
+  ZyNOS F/W Version       : V4.80(ABMH.2) | 11/04/2022
+  Serial Number           : S222L00000001
+  Current Boot Image      : 1
+`, sw.hostname), nil
+	case cmd == "show running-config":
+		return "  Building configuration...\n\n  Current configuration:\n\n" + sw.runningConfig(), nil
+	case cmd == "show vlan":
+		return sw.showVLAN(), nil
+	case len(f) == 3 && f[0] == "show" && (f[1] == "interfaces" || f[1] == "interface"):
+		return sw.showInterfaces(f[2])
+	case cmd == "show mac address-table all":
+		return sw.showMACTable(), nil
+	case cmd == "show lldp info remote":
+		return "", nil
+	}
+	return "", fmt.Errorf("invalid command")
+}
+
+// simHelp is what "?" lists at the privileged prompt after the words of
+// the key.
+var simHelp = map[string][]string{
+	"": {"configure              Enter configuration mode", "copy                   Copy a configuration",
+		"exit                   Log out", "show                   Show system information",
+		"terminal               Terminal settings", "write                  Save the configuration"},
+	"copy":                   {"running-config         Current configuration"},
+	"copy running-config":    {"startup-config         Saved configuration"},
+	"show":                   {"interfaces             Port status and counters", "lldp                   LLDP information", "mac                    MAC address table", "running-config         Current configuration", "system-information     System information", "vlan                   VLAN table"},
+	"show interfaces":        {"<port-list>            Ports, e.g. 1-5,7"},
+	"show lldp":              {"info                   LLDP information"},
+	"show lldp info":         {"remote                 Neighbors"},
+	"show mac":               {"address-table          MAC address table"},
+	"show mac address-table": {"all                    All entries"},
+	"terminal":               {"length                 Lines per page"},
+	"terminal length":        {"<0-512>                0 turns paging off"},
+	"write":                  {"memory                 Save the running configuration"},
+}
+
+// simHelpEnds are the words after which "?" offers <cr>.
+var simHelpEnds = []string{"configure", "exit", "show running-config", "show system-information", "show vlan",
+	"show lldp info remote", "show mac address-table all", "write memory", "copy running-config startup-config"}
+
+// simShell is one CLI session on the simulated switch.
+type simShell struct {
+	sw    *simSwitch
+	rw    io.ReadWriter
+	in    *bufio.Reader
+	mode  string // "" at the privileged prompt, else the configuration mode
+	pager bool
+	// commands counts the commands received.
+	commands int
+}
+
+func (sh *simShell) prompt() string {
+	switch {
+	case sh.mode == "":
+		return sh.sw.hostname + "# "
+	case sh.mode == "config":
+		return sh.sw.hostname + "(config)# "
+	case strings.HasPrefix(sh.mode, "vlan"):
+		return sh.sw.hostname + "(config-vlan)# "
+	}
+	return sh.sw.hostname + "(config-interface)# "
+}
+
+// readLine reads a command, echoing it as the switch does.
+func (sh *simShell) readLine() (string, error) {
+	var line []byte
+	for {
+		b, err := sh.in.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		switch b {
+		case '\r', '\n':
+			if b == '\r' {
+				if next, err := sh.in.Peek(1); err == nil && next[0] == '\n' {
+					sh.in.ReadByte()
+				}
+			}
+			io.WriteString(sh.rw, string(line)+"\r\n")
+			return strings.TrimSpace(string(line)), nil
+		case 3: // Ctrl+C
+			line = line[:0]
+			io.WriteString(sh.rw, "\r\n"+sh.prompt())
+		case '\b', 0x7f:
+			if len(line) > 0 {
+				line = line[:len(line)-1]
+				io.WriteString(sh.rw, "\b \b")
+			}
+		case '?':
+			words := strings.Join(strings.Fields(string(line)), " ")
+			io.WriteString(sh.rw, "?\r\n")
+			if sh.mode == "" {
+				for _, h := range simHelp[words] {
+					io.WriteString(sh.rw, "  "+h+"\r\n")
+				}
+				if slices.Contains(simHelpEnds, words) {
+					io.WriteString(sh.rw, "  <cr>\r\n")
+				}
+			}
+			io.WriteString(sh.rw, sh.prompt()+string(line))
+		default:
+			line = append(line, b)
+		}
+	}
+}
+
+// write prints output a page at a time while the pager is on. It reports
+// false when the user quit at a pager prompt.
+func (sh *simShell) write(output string) (bool, error) {
+	lines := strings.SplitAfter(output, "\n")
+	for i, l := range lines {
+		if sh.pager && i > 0 && i%simPageLines == 0 {
+			io.WriteString(sh.rw, "-- more --, next page: Space, continue: g, quit: ^C")
+			b, err := sh.in.ReadByte()
+			if err != nil {
+				return false, err
+			}
+			io.WriteString(sh.rw, "\r"+strings.Repeat(" ", 52)+"\r")
+			switch b {
+			case 'g':
+				sh.pager = false
+				defer func() { sh.pager = true }()
+			case ' ':
+			default:
+				return false, nil
+			}
+		}
+		io.WriteString(sh.rw, strings.ReplaceAll(l, "\n", "\r\n"))
+	}
+	return true, nil
+}
+
+func (sh *simShell) run() {
+	io.WriteString(sh.rw, "\r\n"+sh.prompt())
+	for {
+		cmd, err := sh.readLine()
+		if err != nil {
+			return
+		}
+		if cmd == "" {
+			io.WriteString(sh.rw, sh.prompt())
+			continue
+		}
+		if sh.commands++; sh.commands == sh.sw.dropAfter {
+			return
+		}
+		if quit := sh.command(cmd); quit {
+			return
+		}
+		io.WriteString(sh.rw, sh.prompt())
+	}
+}
+
+// command runs cmd and reports whether the session ends.
+func (sh *simShell) command(cmd string) bool {
+	sh.sw.mu.Lock()
+	defer sh.sw.mu.Unlock()
+
+	switch {
+	case cmd == "exit" && sh.mode == "":
+		return true
+	case cmd == "exit" && sh.mode == "config":
+		sh.mode = ""
+	case cmd == "exit":
+		sh.mode = "config"
+	case sh.mode == "" && (cmd == "configure" || cmd == "configure terminal"):
+		sh.mode = "config"
+	case sh.mode == "" && (cmd == "terminal length 0" || cmd == "no terminal length"):
+		sh.pager = cmd != "terminal length 0"
+	case sh.mode == "" && (cmd == "write memory" || cmd == "copy running-config startup-config"):
+		io.WriteString(sh.rw, "Save successfully\r\n")
+	case sh.mode == "":
+		out, err := sh.sw.exec(cmd)
+		if err != nil {
+			fmt.Fprintf(sh.rw, "%% %s: %s\r\n", err, cmd)
+			return false
+		}
+		if _, err := sh.write(out); err != nil {
+			return true
+		}
+	default:
+		mode := strings.TrimPrefix(sh.mode, "config")
+		next, err := sh.sw.configure(mode, cmd)
+		if err != nil {
+			fmt.Fprintf(sh.rw, "%% Invalid input: %s\r\n", err)
+			return false
+		}
+		if next != "" {
+			sh.mode = next
+		} else if mode == "" {
+			sh.mode = "config"
+		}
+	}
+	return false
+}
+
+// serveSSH runs the SSH connection conn, giving each shell channel a
+// simShell.
+func (sw *simSwitch) serveSSH(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for nc := range chans {
+		if nc.ChannelType() != "session" {
+			nc.Reject(ssh.UnknownChannelType, "only sessions are supported")
+			continue
+		}
+		ch, requests, err := nc.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			for req := range requests {
+				switch req.Type {
+				case "pty-req", "env", "window-change":
+					req.Reply(true, nil)
+				case "exec":
+					// Direct command execution, as "zyxel -c --no-pty" uses.
+					var payload struct{ Command string }
+					if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+						req.Reply(false, nil)
+						continue
+					}
+					req.Reply(true, nil)
+					sw.mu.Lock()
+					out, err := sw.exec(payload.Command)
+					sw.mu.Unlock()
+					status := 0
+					if err != nil {
+						out, status = fmt.Sprintf("%% %s: %s\n", err, payload.Command), 1
+					}
+					io.WriteString(ch, strings.ReplaceAll(out, "\n", "\r\n"))
+					ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{uint32(status)}))
+					ch.Close()
+				case "shell":
+					req.Reply(true, nil)
+					go func() {
+						defer ch.Close()
+						sh := &simShell{sw: sw, rw: ch, in: bufio.NewReader(ch), pager: true}
+						sh.run()
+					}()
+				default:
+					req.Reply(false, nil)
+				}
+			}
+		}()
+	}
+}
+
+func runSimulate(fs *flag.FlagSet) func() {
+	listen := fs.String("listen", "127.0.0.1:2222", tr("SSH `address` to listen on"))
+	user := fs.String("user", "admin", tr("Login user to accept"))
+	password := fs.String("password", "1234", tr("Login password to accept"))
+	ports := fs.Int("ports", 28, tr("Number of ports of the simulated switch"))
+	dropAfter := fs.Int("drop-after", 0, tr("Drop each connection at its `n`th command, to test reconnecting"))
+	return func() {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			fatal("%v", err)
+		}
+		signer, err := ssh.NewSignerFromKey(key)
+		if err != nil {
+			fatal("%v", err)
+		}
+		config := &ssh.ServerConfig{
+			PasswordCallback: func(c ssh.ConnMetadata, pw []byte) (*ssh.Permissions, error) {
+				if c.User() != *user || string(pw) != *password {
+					return nil, fmt.Errorf("wrong user name or password")
+				}
+				return nil, nil
+			},
+		}
+		config.AddHostKey(signer)
+
+		ln, err := net.Listen("tcp", *listen)
+		if err != nil {
+			fatal("%v", err)
+		}
+		fmt.Fprintf(os.Stderr, "Simulating a GS1920 with %d ports on %s (user %s)\n", *ports, ln.Addr(), *user)
+		sw := newSimSwitch(*ports)
+		sw.dropAfter = *dropAfter
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				fatal("%v", err)
+			}
+			go sw.serveSSH(conn, config)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+// SNMP reads interface counters, port status and system information
+// without taking one of the few CLI sessions a switch allows.
+
+// SysInfo is the SNMPv2-MIB system group.
+type SysInfo struct {
+	Descr    string
+	ObjectID string
+	UpTime   time.Duration
+	Contact  string
+	Name     string
+	Location string
+}
+
+// SNMPPort is one ethernet row of the IF-MIB interface tables.
+type SNMPPort struct {
+	Index     int
+	Name      string
+	Descr     string
+	AdminUp   bool
+	OperUp    bool
+	SpeedMbps uint64
+	// Counters is keyed by IF-MIB object name, e.g. "ifHCInOctets".
+	Counters map[string]uint64
+}
+
+const (
+	oidSystem   = ".1.3.6.1.2.1.1"
+	oidIfEntry  = ".1.3.6.1.2.1.2.2.1"
+	oidIfXEntry = ".1.3.6.1.2.1.31.1.1.1"
+
+	ifTypeEthernet = 6
+)
+
+// ifCounters are the counter columns collected for every port.
+var ifCounters = map[string]string{
+	"ifInDiscards":        oidIfEntry + ".13",
+	"ifInErrors":          oidIfEntry + ".14",
+	"ifOutDiscards":       oidIfEntry + ".19",
+	"ifOutErrors":         oidIfEntry + ".20",
+	"ifHCInOctets":        oidIfXEntry + ".6",
+	"ifHCInUcastPkts":     oidIfXEntry + ".7",
+	"ifHCInMulticastPkts": oidIfXEntry + ".8",
+	"ifHCInBroadcastPkts": oidIfXEntry + ".9",
+	"ifHCOutOctets":       oidIfXEntry + ".10",
+	"ifHCOutUcastPkts":    oidIfXEntry + ".11",
+}
+
+// dialSNMP prepares an SNMP client for host from the ZYXEL_SNMP_*
+// variables. Version 2c uses ZYXEL_SNMP_COMMUNITY (default "public");
+// version 3 uses the user and optional auth/priv settings.
+func dialSNMP(host string) (*gosnmp.GoSNMP, error) {
+	return dialSNMPVersion(host, os.Getenv("ZYXEL_SNMP_VERSION"))
+}
+
+// dialSNMPVersion is dialSNMP with the version given instead of taken
+// from ZYXEL_SNMP_VERSION.
+func dialSNMPVersion(host, version string) (*gosnmp.GoSNMP, error) {
+	g := &gosnmp.GoSNMP{
+		Target:         host,
+		Port:           161,
+		Timeout:        5 * time.Second,
+		Retries:        2,
+		MaxOids:        gosnmp.MaxOids,
+		MaxRepetitions: 25,
+	}
+	if p := os.Getenv("ZYXEL_SNMP_PORT"); p != "" {
+		n, err := strconv.ParseUint(p, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ZYXEL_SNMP_PORT %q", p)
+		}
+		g.Port = uint16(n)
+	}
+
+	switch version {
+	case "", "2c":
+		g.Version = gosnmp.Version2c
+		g.Community = os.Getenv("ZYXEL_SNMP_COMMUNITY")
+		if g.Community == "" {
+			g.Community = "public"
+		}
+	case "3":
+		usm, flags, err := snmpV3Security()
+		if err != nil {
+			return nil, err
+		}
+		g.Version = gosnmp.Version3
+		g.SecurityModel = gosnmp.UserSecurityModel
+		g.MsgFlags = flags
+		g.SecurityParameters = usm
+	default:
+		return nil, fmt.Errorf("unknown ZYXEL_SNMP_VERSION %q (want 2c or 3)", version)
+	}
+
+	if err := g.Connect(); err != nil {
+		return nil, fmt.Errorf("snmp %s: %w", host, err)
+	}
+	return g, nil
+}
+
+var (
+	snmpAuthProtocols = map[string]gosnmp.SnmpV3AuthProtocol{
+		"MD5": gosnmp.MD5, "SHA": gosnmp.SHA, "SHA224": gosnmp.SHA224,
+		"SHA256": gosnmp.SHA256, "SHA384": gosnmp.SHA384, "SHA512": gosnmp.SHA512,
+	}
+	snmpPrivProtocols = map[string]gosnmp.SnmpV3PrivProtocol{
+		"DES": gosnmp.DES, "AES": gosnmp.AES, "AES192": gosnmp.AES192, "AES256": gosnmp.AES256,
+	}
+)
+
+func snmpV3Security() (*gosnmp.UsmSecurityParameters, gosnmp.SnmpV3MsgFlags, error) {
+	usm := &gosnmp.UsmSecurityParameters{
+		UserName:                 os.Getenv("ZYXEL_SNMP_USER"),
+		AuthenticationProtocol:   gosnmp.NoAuth,
+		AuthenticationPassphrase: os.Getenv("ZYXEL_SNMP_AUTH_PASSWORD"),
+		PrivacyProtocol:          gosnmp.NoPriv,
+		PrivacyPassphrase:        os.Getenv("ZYXEL_SNMP_PRIV_PASSWORD"),
+	}
+	if usm.UserName == "" {
+		return nil, 0, fmt.Errorf("ZYXEL_SNMP_USER is required for SNMPv3")
+	}
+
+	flags := gosnmp.NoAuthNoPriv
+	if name := strings.ToUpper(os.Getenv("ZYXEL_SNMP_AUTH")); name != "" {
+		p, ok := snmpAuthProtocols[name]
+		if !ok {
+			return nil, 0, fmt.Errorf("unknown ZYXEL_SNMP_AUTH %q", name)
+		}
+		usm.AuthenticationProtocol = p
+		flags = gosnmp.AuthNoPriv
+	}
+	if name := strings.ToUpper(os.Getenv("ZYXEL_SNMP_PRIV")); name != "" {
+		p, ok := snmpPrivProtocols[name]
+		if !ok {
+			return nil, 0, fmt.Errorf("unknown ZYXEL_SNMP_PRIV %q", name)
+		}
+		if flags == gosnmp.NoAuthNoPriv {
+			return nil, 0, fmt.Errorf("ZYXEL_SNMP_PRIV needs ZYXEL_SNMP_AUTH")
+		}
+		usm.PrivacyProtocol = p
+		flags = gosnmp.AuthPriv
+	}
+	return usm, flags, nil
+}
+
+// snmpColumn walks one table column and returns its values by row index.
+func snmpColumn(g *gosnmp.GoSNMP, oid string) (map[int]gosnmp.SnmpPDU, error) {
+	pdus, err := g.BulkWalkAll(oid)
+	if err != nil {
+		return nil, fmt.Errorf("snmp walk %s: %w", oid, err)
+	}
+	rows := make(map[int]gosnmp.SnmpPDU, len(pdus))
+	for _, p := range pdus {
+		idx, err := strconv.Atoi(p.Name[strings.LastIndex(p.Name, ".")+1:])
+		if err == nil {
+			rows[idx] = p
+		}
+	}
+	return rows, nil
+}
+
+func pduString(p gosnmp.SnmpPDU) string {
+	switch v := p.Value.(type) {
+	case []byte:
+		return strings.TrimRight(string(v), "\x00")
+	case string:
+		return v
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(p.Value)
+}
+
+// snmpSysInfo reads the system group.
+func snmpSysInfo(g *gosnmp.GoSNMP) (SysInfo, error) {
+	oids := []string{".1.0", ".2.0", ".3.0", ".4.0", ".5.0", ".6.0"}
+	for i := range oids {
+		oids[i] = oidSystem + oids[i]
+	}
+	res, err := g.Get(oids)
+	if err != nil {
+		return SysInfo{}, fmt.Errorf("snmp get system: %w", err)
+	}
+
+	var info SysInfo
+	for _, p := range res.Variables {
+		switch p.Name {
+		case oids[0]:
+			info.Descr = pduString(p)
+		case oids[1]:
+			info.ObjectID = pduString(p)
+		case oids[2]:
+			// sysUpTime is in hundredths of a second.
+			info.UpTime = time.Duration(gosnmp.ToBigInt(p.Value).Int64()) * 10 * time.Millisecond
+		case oids[3]:
+			info.Contact = pduString(p)
+		case oids[4]:
+			info.Name = pduString(p)
+		case oids[5]:
+			info.Location = pduString(p)
+		}
+	}
+	return info, nil
+}
+
+// snmpPorts reads status and counters of the ethernet ports, ordered by
+// interface index.
+func snmpPorts(g *gosnmp.GoSNMP) ([]SNMPPort, error) {
+	columns := map[string]string{
+		"type":  oidIfEntry + ".3",
+		"descr": oidIfEntry + ".2",
+		"admin": oidIfEntry + ".7",
+		"oper":  oidIfEntry + ".8",
+		"name":  oidIfXEntry + ".1",
+		"speed": oidIfXEntry + ".15",
+	}
+	for name, oid := range ifCounters {
+		columns[name] = oid
+	}
+
+	values := make(map[string]map[int]gosnmp.SnmpPDU)
+	for _, name := range sortedStringKeys(columns) {
+		col, err := snmpColumn(g, columns[name])
+		if err != nil {
+			return nil, err
+		}
+		values[name] = col
+	}
+
+	var ports []SNMPPort
+	for _, idx := range sortedKeys(values["type"]) {
+		if gosnmp.ToBigInt(values["type"][idx].Value).Int64() != ifTypeEthernet {
+			continue
+		}
+		p := SNMPPort{
+			Index:     idx,
+			Name:      pduString(values["name"][idx]),
+			Descr:     pduString(values["descr"][idx]),
+			AdminUp:   gosnmp.ToBigInt(values["admin"][idx].Value).Int64() == 1,
+			OperUp:    gosnmp.ToBigInt(values["oper"][idx].Value).Int64() == 1,
+			SpeedMbps: gosnmp.ToBigInt(values["speed"][idx].Value).Uint64(),
+			Counters:  make(map[string]uint64),
+		}
+		for name := range ifCounters {
+			if v, ok := values[name][idx]; ok {
+				p.Counters[name] = gosnmp.ToBigInt(v.Value).Uint64()
+			}
+		}
+		ports = append(ports, p)
+	}
+	return ports, nil
+}
+
+// label is the port name shown to the user.
+func (p SNMPPort) label() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	if p.Descr != "" {
+		return p.Descr
+	}
+	return strconv.Itoa(p.Index)
+}
+
+func upDown(up bool) string {
+	if up {
+		return "up"
+	}
+	return "down"
+}
+
+func runSNMP(fs *flag.FlagSet) func() {
+	host := fs.String("host", "", tr("Switch to poll (default: ZYXEL_HOST)"))
+	return func() {
+		if *host == "" {
+			*host = envConfig().Host
+		}
+		if *host == "" {
+			fatal("missing required environment variables: %s", "ZYXEL_HOST")
+		}
+
+		g, err := dialSNMP(*host)
+		if err != nil {
+			fatal("%v", err)
+		}
+		defer g.Conn.Close()
+
+		info, err := snmpSysInfo(g)
+		if err != nil {
+			fatal("%v", err)
+		}
+		ports, err := snmpPorts(g)
+		if err != nil {
+			fatal("%v", err)
+		}
+
+		fmt.Printf("Name:     %s\n", info.Name)
+		fmt.Printf("System:   %s\n", info.Descr)
+		fmt.Printf("Uptime:   %s\n", info.UpTime.Truncate(time.Second))
+		fmt.Printf("Location: %s\n", info.Location)
+		fmt.Printf("Contact:  %s\n", info.Contact)
+		fmt.Println()
+		fmt.Printf("%-8s %-5s %-5s %7s %16s %16s %8s %8s\n", "Port", "Admin", "Oper", "Mbps", "In octets", "Out octets", "In err", "Out err")
+		for _, p := range ports {
+			fmt.Printf("%-8s %-5s %-5s %7d %16d %16d %8d %8d\n", p.label(), upDown(p.AdminUp), upDown(p.OperUp), p.SpeedMbps,
+				p.Counters["ifHCInOctets"], p.Counters["ifHCOutOctets"], p.Counters["ifInErrors"], p.Counters["ifOutErrors"])
+		}
+	}
+}
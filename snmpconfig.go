@@ -0,0 +1,244 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// The SNMP agent of a switch is set with "snmp-server version v2c|v3|v3v2c",
+// "snmp-server get-community <community>" (likewise set-community), "snmp-server engine-id <id>" and SNMPv3 users,
+// "snmp-server username <name> sec-level noauth|auth|priv auth md5|sha
+// authpassword <password> priv des|aes privpassword <password>". The
+// running-config leaves out settings at their defaults: version v2c and the
+// communities "public" and "private".
+
+// snmpConfig is the SNMP agent configuration of a switch.
+type snmpConfig struct {
+	Version      string
+	GetCommunity string
+	SetCommunity string
+	EngineID     string
+	Users        map[string]string // name to security level
+}
+
+func parseSNMPConfig(rc *RunningConfig) snmpConfig {
+	sc := snmpConfig{
+		Version:      "v2c",
+		GetCommunity: "public",
+		SetCommunity: "private",
+		Users:        make(map[string]string),
+	}
+	for _, c := range rc.Global {
+		f := strings.Fields(c)
+		if len(f) < 3 || f[0] != "snmp-server" {
+			continue
+		}
+		switch f[1] {
+		case "version":
+			sc.Version = f[2]
+		case "get-community":
+			sc.GetCommunity = f[2]
+		case "set-community":
+			sc.SetCommunity = f[2]
+		case "engine-id":
+			sc.EngineID = f[2]
+		case "username":
+			level := ""
+			for i := 3; i+1 < len(f); i++ {
+				if f[i] == "sec-level" {
+					level = f[i+1]
+				}
+			}
+			sc.Users[f[2]] = level
+		}
+	}
+	return sc
+}
+
+// v2c reports whether the agent answers SNMPv1/v2c requests.
+func (sc snmpConfig) v2c() bool {
+	return sc.Version != "v3"
+}
+
+func runAuditSNMP(fs *flag.FlagSet) func() {
+	ff := addFleetFlags(fs)
+	requireV3 := fs.Bool("require-v3", false, tr("Also report switches without an SNMPv3 user with privacy, or still answering v2c"))
+	return func() {
+		_, hosts := ff.load()
+		results := runFleet(hosts, ff, func(h Host, s *Session) (snmpConfig, error) {
+			rc, err := runningConfig(s)
+			if err != nil {
+				return snmpConfig{}, err
+			}
+			return parseSNMPConfig(rc), nil
+		})
+
+		reportFindings(results, auditSNMP(results, *requireV3))
+	}
+}
+
+// auditSNMP reports default communities on switches answering v2c, engine
+// IDs shared by several switches (which breaks SNMPv3) and, with
+// requireV3, switches still on v2c or without a v3 user with privacy.
+func auditSNMP(results []fleetResult[snmpConfig], requireV3 bool) []finding {
+	var findings []finding
+	engines := make(map[string][]string)
+	for _, r := range results {
+		if r.Err != nil {
+			continue
+		}
+		add := func(format string, args ...any) {
+			findings = append(findings, finding{r.Host.Name, fmt.Sprintf(format, args...)})
+		}
+		sc := r.Value
+		if sc.v2c() {
+			for _, c := range []struct{ kind, community, def string }{
+				{"get", sc.GetCommunity, "public"},
+				{"set", sc.SetCommunity, "private"},
+			} {
+				if c.community == c.def {
+					add("SNMP %s community is the default %q", c.kind, c.def)
+				}
+			}
+		}
+		if requireV3 {
+			if sc.v2c() {
+				add("SNMP answers v1/v2c (version %s)", sc.Version)
+			}
+			private := false
+			for _, level := range sc.Users {
+				private = private || level == "priv"
+			}
+			if !private {
+				add("no SNMPv3 user with privacy")
+			}
+		}
+		if sc.EngineID != "" {
+			engines[sc.EngineID] = append(engines[sc.EngineID], r.Host.Name)
+		}
+	}
+
+	var ids []string
+	for id, names := range engines {
+		if len(names) > 1 {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		for _, name := range engines[id] {
+			findings = append(findings, finding{name, fmt.Sprintf("SNMP engine ID %s is shared by %s", id, strings.Join(engines[id], ", "))})
+		}
+	}
+	return findings
+}
+
+// snmpV3Commands configure the SNMPv3 user of the ZYXEL_SNMP_* variables,
+// which the switches only take with MD5 or SHA and DES or AES. With v3Only
+// the agent stops answering v1/v2c.
+func snmpV3Commands(v3Only bool) ([]string, string, error) {
+	user := os.Getenv("ZYXEL_SNMP_USER")
+	if user == "" {
+		return nil, "", fmt.Errorf("ZYXEL_SNMP_USER is required for SNMPv3")
+	}
+	auth := strings.ToLower(os.Getenv("ZYXEL_SNMP_AUTH"))
+	priv := strings.ToLower(os.Getenv("ZYXEL_SNMP_PRIV"))
+	authPW, privPW := os.Getenv("ZYXEL_SNMP_AUTH_PASSWORD"), os.Getenv("ZYXEL_SNMP_PRIV_PASSWORD")
+	if auth != "" && auth != "md5" && auth != "sha" {
+		return nil, "", fmt.Errorf("switches take ZYXEL_SNMP_AUTH MD5 or SHA, not %q", auth)
+	}
+	if priv != "" && priv != "des" && priv != "aes" {
+		return nil, "", fmt.Errorf("switches take ZYXEL_SNMP_PRIV DES or AES, not %q", priv)
+	}
+	if priv != "" && auth == "" {
+		return nil, "", fmt.Errorf("ZYXEL_SNMP_PRIV needs ZYXEL_SNMP_AUTH")
+	}
+	if auth != "" && len(authPW) < 8 || priv != "" && len(privPW) < 8 {
+		return nil, "", fmt.Errorf("SNMPv3 passwords need at least 8 characters")
+	}
+
+	level := "noauth"
+	switch {
+	case priv != "":
+		level = "priv"
+	case auth != "":
+		level = "auth"
+	}
+	c := fmt.Sprintf("snmp-server username %s sec-level %s", user, level)
+	if auth != "" {
+		c += fmt.Sprintf(" auth %s authpassword %s", auth, authPW)
+	}
+	if priv != "" {
+		c += fmt.Sprintf(" priv %s privpassword %s", priv, privPW)
+	}
+	version := "v3v2c"
+	if v3Only {
+		version = "v3"
+	}
+	return []string{"snmp-server version " + version, c}, level, nil
+}
+
+func runSNMPv3(fs *flag.FlagSet) func() {
+	fleet := fs.Bool("fleet", false, tr("Configure every inventory switch instead of one"))
+	v3Only := fs.Bool("v3-only", false, tr("Stop answering SNMPv1/v2c requests"))
+	save := fs.Bool("save", false, tr("Write memory after the change is verified"))
+	cf := addConnFlags(fs)
+	ff := addFleetFlags(fs)
+	return func() {
+		commands, level, err := snmpV3Commands(*v3Only)
+		if err != nil {
+			fatal("%v", err)
+		}
+		user := os.Getenv("ZYXEL_SNMP_USER")
+		secrets := []string{os.Getenv("ZYXEL_SNMP_AUTH_PASSWORD"), os.Getenv("ZYXEL_SNMP_PRIV_PASSWORD")}
+
+		results := hostOrFleet(*fleet, cf, ff, func(h Host, s *Session) (struct{}, error) {
+			if err := s.Configure(commands, io.Discard); err != nil {
+				for _, pw := range secrets {
+					err = hidePassword(err, pw)
+				}
+				return struct{}{}, err
+			}
+			rc, err := runningConfig(s)
+			if err != nil {
+				return struct{}{}, err
+			}
+			sc := parseSNMPConfig(rc)
+			if got, ok := sc.Users[user]; !ok || got != level {
+				return struct{}{}, fmt.Errorf("SNMPv3 user %s with sec-level %s is not in the running-config", user, level)
+			}
+			// Ask the agent with the new user, as the monitoring will.
+			g, err := dialSNMPVersion(h.Address, "3")
+			if err != nil {
+				return struct{}{}, err
+			}
+			defer g.Conn.Close()
+			if _, err := snmpSysInfo(g); err != nil {
+				return struct{}{}, fmt.Errorf("SNMPv3 as %s does not work: %w", user, err)
+			}
+			if *save {
+				if _, err := s.Save(); err != nil {
+					return struct{}{}, fmt.Errorf("configuration NOT saved: %w", err)
+				}
+			}
+			return struct{}{}, nil
+		})
+
+		failed := false
+		for _, r := range results {
+			if r.Err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", r.Host.Name, r.Err)
+				failed = true
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "%s: SNMPv3 user %s (%s) verified\n", r.Host.Name, user, level)
+		}
+		if failed {
+			os.Exit(1)
+		}
+	}
+}
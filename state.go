@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// stateDir is where observations from earlier runs are kept:
+// ZYXEL_STATE_DIR, or ~/.local/state/zyxel.
+func stateDir() (string, error) {
+	if dir := os.Getenv("ZYXEL_STATE_DIR"); dir != "" {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", errorf("failed to locate state directory: %w", err)
+	}
+	return filepath.Join(home, ".local", "state", "zyxel"), nil
+}
+
+// macSighting records that a MAC address was learned on a port.
+type macSighting struct {
+	Time time.Time `json:"time"`
+	Host string    `json:"host"`
+	Port string    `json:"port"`
+	VLAN int       `json:"vlan"`
+	MAC  string    `json:"mac"`
+}
+
+// ipSighting records an IP address bound to a MAC address, from the ARP
+// table or the DHCP snooping bindings.
+type ipSighting struct {
+	Time   time.Time `json:"time"`
+	Host   string    `json:"host"`
+	MAC    string    `json:"mac"`
+	IP     string    `json:"ip"`
+	Source string    `json:"source"`
+}
+
+func recordMACSightings(sightings []macSighting) error {
+	return appendState("macs.jsonl", sightings)
+}
+
+// loadMACSightings returns the recorded MAC sightings since the given time,
+// in the order they were recorded.
+func loadMACSightings(since time.Time) ([]macSighting, error) {
+	return loadState("macs.jsonl", func(s macSighting) bool { return !s.Time.Before(since) })
+}
+
+func recordIPSightings(sightings []ipSighting) error {
+	return appendState("ips.jsonl", sightings)
+}
+
+func loadIPSightings(since time.Time) ([]ipSighting, error) {
+	return loadState("ips.jsonl", func(s ipSighting) bool { return !s.Time.Before(since) })
+}
+
+// appendState appends records as JSON lines to the named state file.
+func appendState[T any](name string, records []T) error {
+	dir, err := stateDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return errorf("failed to create state directory: %w", err)
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return errorf("failed to open %s: %w", name, err)
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return errorf("failed to write %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// writeState replaces the named state file with records. They are written
+// to a temporary file first, so that a failed write keeps the old ones.
+func writeState[T any](name string, records []T) error {
+	dir, err := stateDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return errorf("failed to create state directory: %w", err)
+	}
+
+	f, err := os.CreateTemp(dir, name+".*")
+	if err != nil {
+		return errorf("failed to write %s: %w", name, err)
+	}
+	defer os.Remove(f.Name())
+	enc := json.NewEncoder(f)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			f.Close()
+			return errorf("failed to write %s: %w", name, err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		return errorf("failed to write %s: %w", name, err)
+	}
+	return os.Rename(f.Name(), filepath.Join(dir, name))
+}
+
+// maxStateLine is the longest record loadState reads. The counters of
+// every port of a stack make a line of several hundred kilobytes, far
+// beyond the scanner's default of 64 KiB.
+const maxStateLine = 16 << 20
+
+// loadState reads the records of the named state file that satisfy keep.
+// A missing file is not an error.
+func loadState[T any](name string, keep func(T) bool) ([]T, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Join(dir, name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errorf("failed to open %s: %w", name, err)
+	}
+	defer f.Close()
+
+	var records []T
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), maxStateLine)
+	for sc.Scan() {
+		var r T
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			continue
+		}
+		if keep(r) {
+			records = append(records, r)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, errorf("failed to read %s: %w", name, err)
+	}
+	return records, nil
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Storm control and loop guard are switched on globally ("storm-control",
+// "loopguard") and set per port: "bstorm-control <pps>", "mstorm-control
+// <pps>" and "dlf-control <pps>" limit broadcast, multicast and unknown
+// unicast packets per second, and "loopguard" shuts a port down when its
+// own probes come back.
+
+// portProtection is the storm control and loop guard of a port as set in
+// the running-config; a rate of 0 means no limit.
+type portProtection struct {
+	Port      string `json:"port"`
+	Broadcast int    `json:"broadcast_pps"`
+	Multicast int    `json:"multicast_pps"`
+	DLF       int    `json:"dlf_pps"`
+	LoopGuard bool   `json:"loop_guard"`
+}
+
+func parseProtection(p Port, commands []string) portProtection {
+	return portProtection{
+		Port:      p.String(),
+		Broadcast: atoiOr(setting(commands, "bstorm-control"), 0),
+		Multicast: atoiOr(setting(commands, "mstorm-control"), 0),
+		DLF:       atoiOr(setting(commands, "dlf-control"), 0),
+		LoopGuard: slices.Contains(commands, "loopguard"),
+	}
+}
+
+// protectionGlobals reports whether storm control and loop guard are
+// switched on for the whole switch; the port settings do nothing without.
+func protectionGlobals(rc *RunningConfig) (storm, loopGuard bool) {
+	return slices.Contains(rc.Global, "storm-control"), slices.Contains(rc.Global, "loopguard")
+}
+
+// allPorts returns every port of the switch in order.
+func allPorts(s *Session) ([]Port, error) {
+	ifaces, err := interfaces(s, "*")
+	if err != nil {
+		return nil, err
+	}
+	var ports []Port
+	for _, i := range ifaces {
+		if p, err := parsePort(i.Port); err == nil {
+			ports = append(ports, p)
+		}
+	}
+	sort.Slice(ports, func(i, j int) bool { return ports[i].less(ports[j]) })
+	return ports, nil
+}
+
+var stormCommandList = []subcommand{
+	{"show", "Show the storm control rates and loop guard of ports", runStormShow,
+		"\"Storm control: on|off, loop guard: on|off\", then a table: Port, Broadcast, Multicast, DLF (packets/s, - when unlimited), Loop guard; with --format json an object with storm_control, loop_guard and ports", nil},
+	{"set", "Set storm control rates and loop guard on ports", runStormSet, "nothing; status goes to stderr", nil},
+}
+
+func runStorm(fs *flag.FlagSet) func() {
+	return func() {
+		args := fs.Args()
+		if len(args) == 0 {
+			fmt.Println("Usage: zyxel storm <command> [flags]")
+			fmt.Println()
+			fmt.Println("Commands:")
+			for _, c := range stormCommandList {
+				fmt.Printf("  %-10s %s\n", c.name, c.summary)
+			}
+			os.Exit(1)
+		}
+		for _, c := range stormCommandList {
+			if c.name == args[0] {
+				c.invoke("storm "+c.name, args[1:])
+				return
+			}
+		}
+		fatal("Unknown storm command %q", args[0])
+	}
+}
+
+func runStormShow(fs *flag.FlagSet) func() {
+	cf := addConnFlags(fs)
+	format := fs.String("format", "text", tr("Output format: text or json"))
+	return func() {
+		if *format != "text" && *format != "json" {
+			fatal("--format must be text or json, not %q", *format)
+		}
+		_, s := cf.connect()
+		defer s.Close()
+		rc, err := runningConfig(s)
+		if err != nil {
+			fatal("%v", err)
+		}
+		var ports []Port
+		if fs.NArg() > 0 {
+			list, err := s.portList(fs.Arg(0))
+			if err != nil {
+				fatal("%v", err)
+			}
+			ports, _ = ParsePortList(list)
+		} else if ports, err = allPorts(s); err != nil {
+			fatal("%v", err)
+		}
+		rows := make([]portProtection, len(ports))
+		for i, p := range ports {
+			rows[i] = parseProtection(p, rc.Ports[p])
+		}
+		storm, loopGuard := protectionGlobals(rc)
+
+		if *format == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			err := enc.Encode(struct {
+				StormControl bool             `json:"storm_control"`
+				LoopGuard    bool             `json:"loop_guard"`
+				Ports        []portProtection `json:"ports"`
+			}{storm, loopGuard, rows})
+			if err != nil {
+				fatal("%v", err)
+			}
+			return
+		}
+		onOff := map[bool]string{true: "on", false: "off"}
+		rate := func(pps int) string {
+			if pps == 0 {
+				return "-"
+			}
+			return strconv.Itoa(pps)
+		}
+		fmt.Printf("Storm control: %s, loop guard: %s\n", onOff[storm], onOff[loopGuard])
+		fmt.Printf("%-8s %-10s %-10s %-10s %s\n", "Port", "Broadcast", "Multicast", "DLF", "Loop guard")
+		for _, r := range rows {
+			fmt.Printf("%-8s %-10s %-10s %-10s %s\n", r.Port, rate(r.Broadcast), rate(r.Multicast), rate(r.DLF), onOff[r.LoopGuard])
+		}
+	}
+}
+
+// stormRate parses a --broadcast, --multicast or --dlf value: a rate in
+// packets per second, or "off". It returns 0 for off and -1 for unset.
+func stormRate(name, v string) int {
+	switch v {
+	case "":
+		return -1
+	case "off":
+		return 0
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		fatal("--%s must be a rate in packets/s or off, not %q", name, v)
+	}
+	return n
+}
+
+func runStormSet(fs *flag.FlagSet) func() {
+	pf := addPortFlags(fs)
+	broadcast := fs.String("broadcast", "", tr("Broadcast limit in packets/s, or off"))
+	multicast := fs.String("multicast", "", tr("Multicast limit in packets/s, or off"))
+	dlf := fs.String("dlf", "", tr("Unknown unicast (DLF) limit in packets/s, or off"))
+	loopGuard := fs.String("loop-guard", "", tr("Loop guard on or off"))
+	return func() {
+		if fs.NArg() != 1 {
+			fatal("Usage: zyxel storm set <ports> [--broadcast pps] [--multicast pps] [--dlf pps] [--loop-guard on|off]")
+		}
+		if *loopGuard != "" && *loopGuard != "on" && *loopGuard != "off" {
+			fatal("--loop-guard must be on or off, not %q", *loopGuard)
+		}
+		rates := []struct {
+			command string
+			pps     int
+		}{
+			{"bstorm-control", stormRate("broadcast", *broadcast)},
+			{"mstorm-control", stormRate("multicast", *multicast)},
+			{"dlf-control", stormRate("dlf", *dlf)},
+		}
+
+		var commands, global []string
+		for _, r := range rates {
+			switch {
+			case r.pps > 0:
+				commands = append(commands, fmt.Sprintf("%s %d", r.command, r.pps))
+				global = append(global, "storm-control")
+			case r.pps == 0:
+				commands = append(commands, "no "+r.command)
+			}
+		}
+		switch *loopGuard {
+		case "on":
+			commands = append(commands, "loopguard")
+			global = append(global, "loopguard")
+		case "off":
+			commands = append(commands, "no loopguard")
+		}
+		if len(commands) == 0 {
+			fatal("Nothing to set; give --broadcast, --multicast, --dlf or --loop-guard")
+		}
+		// The global switches follow the port block.
+		if len(global) > 0 {
+			commands = append(append(commands, "exit"), slices.Compact(global)...)
+		}
+
+		// A low limit starves an uplink, and loop guard shuts one down when
+		// the network has a loop elsewhere.
+		pf.change(fs.Arg(0), true, func(func(string) string) []string { return commands }, "storm control and loop guard set", func(p Port, commands []string) error {
+			for _, r := range rates {
+				if have := atoiOr(setting(commands, r.command), 0); r.pps >= 0 && have != r.pps {
+					return fmt.Errorf("port %s has %s %d in the running-config, not %d", p, r.command, have, r.pps)
+				}
+			}
+			if *loopGuard != "" && slices.Contains(commands, "loopguard") != (*loopGuard == "on") {
+				return fmt.Errorf("loop guard of port %s is not %s in the running-config", p, *loopGuard)
+			}
+			return nil
+		})
+	}
+}
+
+// protectionData is what the protection audit reads from a switch.
+type protectionData struct {
+	rc      *RunningConfig
+	ports   []Port
+	uplinks map[Port]bool
+}
+
+func runAuditProtection(fs *flag.FlagSet) func() {
+	ff := addFleetFlags(fs)
+	require := fs.String("require", "broadcast,multicast,loop-guard", tr("Protections every access port needs: broadcast, multicast, dlf and loop-guard"))
+	return func() {
+		var required []string
+		for _, r := range strings.Split(*require, ",") {
+			r = strings.TrimSpace(r)
+			switch r {
+			case "":
+				continue
+			case "broadcast", "multicast", "dlf", "loop-guard":
+				required = append(required, r)
+			default:
+				fatal("Unknown protection %q; use broadcast, multicast, dlf or loop-guard", r)
+			}
+		}
+		_, hosts := ff.load()
+		results := runFleet(hosts, ff, func(h Host, s *Session) (protectionData, error) {
+			rc, err := runningConfig(s)
+			if err != nil {
+				return protectionData{}, err
+			}
+			ports, err := allPorts(s)
+			if err != nil {
+				return protectionData{}, err
+			}
+			neighbors, err := lldpNeighbors(s)
+			if err != nil {
+				return protectionData{}, err
+			}
+			return protectionData{rc, ports, switchPorts(h, neighbors)}, nil
+		})
+
+		reportFindings(results, auditProtection(required, results))
+	}
+}
+
+// auditProtection reports access ports lacking the required protections,
+// grouped by what they lack, protections that are off for the whole
+// switch, and uplinks with loop guard on.
+func auditProtection(required []string, results []fleetResult[protectionData]) []finding {
+	var findings []finding
+	for _, r := range results {
+		if r.Err != nil {
+			continue
+		}
+		add := func(format string, args ...any) {
+			findings = append(findings, finding{r.Host.Name, fmt.Sprintf(format, args...)})
+		}
+		d := r.Value
+		storm, loopGuard := protectionGlobals(d.rc)
+		needStorm := slices.ContainsFunc(required, func(r string) bool { return r != "loop-guard" })
+		if needStorm && !storm {
+			add("storm control is off globally")
+		}
+		if slices.Contains(required, "loop-guard") && !loopGuard {
+			add("loop guard is off globally")
+		}
+
+		lacking := make(map[string][]Port)
+		var order []string
+		var loopedUplinks []Port
+		for _, p := range d.ports {
+			pp := parseProtection(p, d.rc.Ports[p])
+			if d.uplinks[p] {
+				if pp.LoopGuard {
+					loopedUplinks = append(loopedUplinks, p)
+				}
+				continue
+			}
+			var missing []string
+			for _, req := range required {
+				switch {
+				case req == "broadcast" && pp.Broadcast == 0:
+					missing = append(missing, "broadcast storm control")
+				case req == "multicast" && pp.Multicast == 0:
+					missing = append(missing, "multicast storm control")
+				case req == "dlf" && pp.DLF == 0:
+					missing = append(missing, "DLF storm control")
+				case req == "loop-guard" && !pp.LoopGuard:
+					missing = append(missing, "loop guard")
+				}
+			}
+			if len(missing) == 0 {
+				continue
+			}
+			key := strings.Join(missing, ", ")
+			if _, ok := lacking[key]; !ok {
+				order = append(order, key)
+			}
+			lacking[key] = append(lacking[key], p)
+		}
+
+		dialect := dialectOf(d.rc)
+		for _, key := range order {
+			list, err := FormatPortList(lacking[key], dialect)
+			if err != nil {
+				list = fmt.Sprint(lacking[key])
+			}
+			add("port %s has no %s", list, key)
+		}
+		if len(loopedUplinks) > 0 {
+			list, err := FormatPortList(loopedUplinks, dialect)
+			if err != nil {
+				list = fmt.Sprint(loopedUplinks)
+			}
+			add("uplink port %s has loop guard on and may be shut down", list)
+		}
+	}
+	return findings
+}
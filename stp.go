@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// STPStatus is the spanning-tree state of a switch: the bridge, the root
+// it sees and its ports.
+type STPStatus struct {
+	Mode     string `json:"mode"`
+	Priority int    `json:"priority"`
+	BridgeID string `json:"bridge_id"`
+	RootID   string `json:"root_id"`
+	RootPort string `json:"root_port"`
+	// RootCost is the path cost to the root, 0 on the root itself.
+	RootCost int       `json:"root_cost"`
+	IsRoot   bool      `json:"is_root"`
+	Ports    []STPPort `json:"ports"`
+}
+
+// STPPort is one row of the port table of the spanning-tree status.
+type STPPort struct {
+	Port     string `json:"port"`
+	Role     string `json:"role"`
+	State    string `json:"state"`
+	Cost     int    `json:"cost"`
+	Priority int    `json:"priority"`
+}
+
+// STPIntent is the declared spanning-tree design. At fleet level Root names
+// the inventory host meant to be root; per host it is ignored.
+type STPIntent struct {
+	Mode     string `yaml:"mode"`
+	Root     string `yaml:"root"`
+	Priority int    `yaml:"priority"`
+}
+
+// parseSTPStatus parses the "Key : value" summary of the spanning-tree
+// status and the port table below it. Bridge IDs look like
+// "8000-00:19:cb:00:00:01"; when no explicit priority is shown it is taken
+// from the ID prefix.
+func parseSTPStatus(output string) STPStatus {
+	var st STPStatus
+	rootPortSeen := false
+
+	for _, line := range strings.Split(strings.ReplaceAll(output, "\r", ""), "\n") {
+		key, value, ok := splitKeyValue(line)
+		if !ok || value == "" {
+			continue
+		}
+		lv := strings.ToLower(value)
+
+		switch strings.ToLower(key) {
+		case "spanning tree protocol", "spanning tree mode", "mode", "protocol":
+			if st.Mode == "" {
+				st.Mode = value
+			}
+		case "bridge priority", "priority":
+			if st.Priority == 0 {
+				st.Priority, _ = strconv.Atoi(value)
+			}
+		case "bridge id", "our bridge id", "bridge identifier":
+			if st.BridgeID == "" {
+				st.BridgeID = bridgeID(value)
+			}
+		case "root bridge id", "root bridge", "root id", "designated root":
+			if st.RootID == "" {
+				st.RootID = bridgeID(value)
+			}
+			if strings.Contains(lv, "our bridge") || strings.Contains(lv, "this bridge") {
+				st.IsRoot = true
+			}
+		case "root port":
+			rootPortSeen = true
+			st.RootPort = value
+		case "root path cost", "root cost", "path cost to root":
+			st.RootCost = atoiOr(value, 0)
+		}
+	}
+
+	for _, row := range parseTable(output, "Port") {
+		if _, err := parsePort(row["Port"]); err != nil {
+			continue
+		}
+		st.Ports = append(st.Ports, STPPort{
+			Port:     row["Port"],
+			Role:     firstOf(row, "Role", "Port Role"),
+			State:    firstOf(row, "State", "Port State", "Status"),
+			Cost:     atoiOr(firstOf(row, "Path Cost", "Cost", "Port Path Cost"), 0),
+			Priority: atoiOr(firstOf(row, "Priority", "Port Priority"), 0),
+		})
+	}
+
+	if st.Priority == 0 && st.BridgeID != "" {
+		if prefix, _, ok := strings.Cut(st.BridgeID, "-"); ok {
+			if n, err := strconv.ParseInt(prefix, 16, 32); err == nil {
+				st.Priority = int(n)
+			}
+		}
+	}
+	if st.BridgeID != "" && st.BridgeID == st.RootID {
+		st.IsRoot = true
+	}
+	if rootPortSeen {
+		switch strings.ToLower(st.RootPort) {
+		case "0", "-", "none", "n/a":
+			st.IsRoot = true
+		}
+	}
+	return st
+}
+
+// bridgeID normalizes a bridge ID value by dropping any trailing remark
+// such as "(Our Bridge)".
+func bridgeID(value string) string {
+	if f := strings.Fields(value); len(f) > 0 {
+		return strings.ToLower(f[0])
+	}
+	return ""
+}
+
+func stpStatus(s *Session) (STPStatus, error) {
+	out, err := s.Output(s.command("spanning-tree"))
+	if err != nil {
+		return STPStatus{}, err
+	}
+	return parseSTPStatus(out), nil
+}
+
+func runSTP(fs *flag.FlagSet) func() {
+	cf := addConnFlags(fs)
+	format := fs.String("format", "text", tr("Output format: text or json"))
+	return func() {
+		if *format != "text" && *format != "json" {
+			fatal("--format must be text or json, not %q", *format)
+		}
+		_, s := cf.connect()
+		defer s.Close()
+		st, err := stpStatus(s)
+		if err != nil {
+			fatal("%v", err)
+		}
+
+		if *format == "json" {
+			st.Ports = nonNil(st.Ports)
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(st); err != nil {
+				fatal("%v", err)
+			}
+			return
+		}
+		fmt.Printf("Mode: %s\n", st.Mode)
+		fmt.Printf("Bridge: %s (priority %d)\n", st.BridgeID, st.Priority)
+		if st.IsRoot {
+			fmt.Printf("Root: %s (this switch)\n", st.RootID)
+		} else {
+			fmt.Printf("Root: %s via port %s, cost %d\n", st.RootID, st.RootPort, st.RootCost)
+		}
+		if len(st.Ports) == 0 {
+			return
+		}
+		fmt.Println()
+		fmt.Printf("%-8s %-12s %-12s %10s %8s\n", "Port", "Role", "State", "Cost", "Priority")
+		for _, p := range st.Ports {
+			fmt.Printf("%-8s %-12s %-12s %10d %8d\n", p.Port, p.Role, p.State, p.Cost, p.Priority)
+		}
+	}
+}
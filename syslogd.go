@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	syslogFacilities = []string{"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+		"uucp", "cron", "authpriv", "ftp", "ntp", "audit", "alert", "clock",
+		"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7"}
+	syslogSeverities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+)
+
+// syslogRecord is one received message as written to the log.
+type syslogRecord struct {
+	// Time is when the message arrived; switch clocks are often off.
+	Time time.Time `json:"time"`
+	// Switch is the inventory name of the sender, empty for others.
+	Switch   string `json:"switch,omitempty"`
+	Address  string `json:"address"`
+	Facility string `json:"facility,omitempty"`
+	Severity string `json:"severity,omitempty"`
+	Hostname string `json:"hostname,omitempty"`
+	App      string `json:"app,omitempty"`
+	Message  string `json:"message"`
+}
+
+// parseSyslog parses a message in RFC 5424 or the older BSD format of
+// RFC 3164 ("<190>Oct 14 06:30:11 sw1 INTERFACE: Port 5 link down").
+// Whatever does not parse is kept in Message.
+func parseSyslog(msg string) syslogRecord {
+	var r syslogRecord
+	msg = strings.TrimRight(msg, "\r\n\x00")
+	if strings.HasPrefix(msg, "<") {
+		if end := strings.IndexByte(msg, '>'); end > 1 && end <= 4 {
+			if pri, err := strconv.Atoi(msg[1:end]); err == nil && pri < 8*len(syslogFacilities) {
+				r.Facility, r.Severity = syslogFacilities[pri/8], syslogSeverities[pri%8]
+				msg = msg[end+1:]
+			}
+		}
+	}
+
+	if rest, ok := strings.CutPrefix(msg, "1 "); ok {
+		// VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID SD MSG
+		f := strings.SplitN(rest, " ", 6)
+		if len(f) == 6 {
+			r.Hostname, r.App = nilValue(f[1]), nilValue(f[2])
+			r.Message = f[5]
+			if strings.HasPrefix(r.Message, "- ") {
+				r.Message = r.Message[2:]
+			} else if r.Message == "-" {
+				r.Message = ""
+			}
+			return r
+		}
+	}
+
+	if len(msg) > 16 && msg[15] == ' ' {
+		if _, err := time.Parse(time.Stamp, msg[:15]); err == nil {
+			msg = msg[16:]
+			if host, rest, ok := strings.Cut(msg, " "); ok && !strings.HasSuffix(host, ":") && !strings.Contains(host, "[") {
+				r.Hostname, msg = host, rest
+			}
+		}
+	}
+	if tag, rest, ok := strings.Cut(msg, ": "); ok && !strings.Contains(tag, " ") {
+		r.App, msg = tag, rest
+		if i := strings.IndexByte(r.App, '['); i > 0 {
+			r.App = r.App[:i]
+		}
+	}
+	r.Message = strings.TrimSpace(msg)
+	return r
+}
+
+// nilValue maps the RFC 5424 NILVALUE "-" to "".
+func nilValue(s string) string {
+	if s == "-" {
+		return ""
+	}
+	return s
+}
+
+// senderNames maps the IP addresses of hosts to their names, resolving
+// host names once; the first host with an address wins. Hosts that do not
+// resolve are reported and skipped.
+func senderNames(hosts []Host) map[string]string {
+	names := make(map[string]string)
+	for _, h := range hosts {
+		addrs := []string{h.Address}
+		if net.ParseIP(h.Address) == nil {
+			var err error
+			if addrs, err = net.LookupHost(h.Address); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %s: %v\n", h.Name, err)
+				continue
+			}
+		}
+		for _, a := range addrs {
+			ip := net.ParseIP(a).String()
+			if _, ok := names[ip]; !ok {
+				names[ip] = h.Name
+			}
+		}
+	}
+	return names
+}
+
+func runSyslogd(fs *flag.FlagSet) func() {
+	ff := addFleetFlags(fs)
+	listen := fs.String("listen", ":514", tr("UDP address to receive syslog on"))
+	output := fs.String("o", "", tr("Append the JSON log to this `file` instead of printing it"))
+	known := fs.Bool("inventory-only", false, tr("Drop messages from senders not in the inventory"))
+	return func() {
+		_, hosts := ff.load()
+		names := senderNames(hosts)
+
+		var w io.Writer = os.Stdout
+		if *output != "" {
+			f, err := os.OpenFile(*output, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+			if err != nil {
+				fatal("%v", err)
+			}
+			defer f.Close()
+			w = f
+		}
+		conn, err := net.ListenPacket("udp", *listen)
+		if err != nil {
+			fatal("%v", err)
+		}
+		defer conn.Close()
+		fmt.Fprintf(os.Stderr, "Receiving syslog on %s\n", conn.LocalAddr())
+
+		enc := json.NewEncoder(w)
+		buf := make([]byte, 8192)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				fatal("%v", err)
+			}
+			ip := addr.(*net.UDPAddr).IP.String()
+			r := parseSyslog(string(buf[:n]))
+			r.Time, r.Address, r.Switch = time.Now(), ip, names[ip]
+			if r.Switch == "" && *known {
+				continue
+			}
+			if err := enc.Encode(r); err != nil {
+				fatal("%v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ANSI colors of --format table.
+const (
+	colorReset = "\x1b[0m"
+	colorBold  = "\x1b[1m"
+	colorRed   = "\x1b[31m"
+	colorGreen = "\x1b[32m"
+)
+
+// errorCounter matches the names of counters that should stay at zero.
+var errorCounter = regexp.MustCompile(`(?i)error|crc|collis|drop|discard|oversize|undersize|fragment|jabber`)
+
+// tableWriter renders parsed command output as aligned tables: a list of
+// records as rows, a single record as its fields and values, with the
+// record lists among them as tables of their own.
+type tableWriter struct {
+	w     io.Writer
+	color bool
+}
+
+// write renders v, the result of a parser, under the heading title.
+func (tw tableWriter) write(title string, v any) {
+	tw.heading(title)
+	rv := indirect(reflect.ValueOf(v))
+	if !rv.IsValid() {
+		return
+	}
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		tw.rows(rv)
+		return
+	}
+
+	// A single record: its plain fields first, then its record lists.
+	var fields [][]string
+	var lists []string
+	names, values := recordFields(rv)
+	for i, name := range names {
+		if isRecordList(values[i]) {
+			lists = append(lists, name)
+			continue
+		}
+		fields = append(fields, []string{name, cellText(values[i])})
+	}
+	tw.table([]string{"Field", "Value"}, fields)
+	for _, name := range lists {
+		fmt.Fprintln(tw.w)
+		tw.heading(name)
+		tw.rows(indirect(values[slicesIndex(names, name)]))
+	}
+}
+
+func (tw tableWriter) heading(title string) {
+	if tw.color {
+		title = colorBold + title + colorReset
+	}
+	fmt.Fprintln(tw.w, title)
+}
+
+// rows renders a list of records, one per row. A map of counters in a
+// record adds a column for each error counter in it; the other counters
+// are left to --format json.
+func (tw tableWriter) rows(list reflect.Value) {
+	var columns []string
+	seen := make(map[string]bool)
+	records := make([]map[string]string, list.Len())
+	for i := range records {
+		records[i] = make(map[string]string)
+		rec := indirect(list.Index(i))
+		if rec.Kind() != reflect.Struct && rec.Kind() != reflect.Map {
+			records[i]["Value"] = cellText(rec)
+			if !seen["Value"] {
+				seen["Value"], columns = true, append(columns, "Value")
+			}
+			continue
+		}
+		names, values := recordFields(rec)
+		for j, name := range names {
+			v := indirect(values[j])
+			if v.Kind() == reflect.Map && isNumber(v.Type().Elem().Kind()) {
+				for _, k := range sortedMapKeys(v) {
+					if errorCounter.MatchString(k) {
+						records[i][k] = cellText(v.MapIndex(reflect.ValueOf(k)))
+						if !seen[k] {
+							seen[k], columns = true, append(columns, k)
+						}
+					}
+				}
+				continue
+			}
+			if isRecordList(v) {
+				continue
+			}
+			records[i][name] = cellText(v)
+			if !seen[name] {
+				seen[name], columns = true, append(columns, name)
+			}
+		}
+	}
+	var cells [][]string
+	for _, r := range records {
+		row := make([]string, len(columns))
+		for j, c := range columns {
+			row[j] = r[c]
+		}
+		cells = append(cells, row)
+	}
+	tw.table(columns, cells)
+}
+
+// table prints header and rows in columns as wide as their widest cell,
+// colored by what the cells say.
+func (tw tableWriter) table(header []string, rows [][]string) {
+	widths := make([]int, len(header))
+	for _, r := range append([][]string{header}, rows...) {
+		for i, c := range r {
+			widths[i] = max(widths[i], utf8.RuneCountInString(c))
+		}
+	}
+	line := func(cells []string, color func(col int, cell string) string) {
+		var b strings.Builder
+		for i, c := range cells {
+			pad := strings.Repeat(" ", widths[i]-utf8.RuneCountInString(c))
+			if i == len(cells)-1 {
+				pad = ""
+			}
+			if code := color(i, c); code != "" {
+				c = code + c + colorReset
+			}
+			b.WriteString(c + pad)
+			if i < len(cells)-1 {
+				b.WriteString("  ")
+			}
+		}
+		fmt.Fprintln(tw.w, strings.TrimRight(b.String(), " "))
+	}
+	line(header, func(int, string) string {
+		if tw.color {
+			return colorBold
+		}
+		return ""
+	})
+	for _, r := range rows {
+		line(r, func(col int, cell string) string {
+			if !tw.color {
+				return ""
+			}
+			return cellColor(header[col], cell)
+		})
+	}
+}
+
+// cellColor returns the color of a cell in column: red for a link that is
+// down, a state that is bad or an error counter above zero, green for a
+// link that is up or a state that is good.
+func cellColor(column, cell string) string {
+	if errorCounter.MatchString(column) {
+		if n, err := strconv.ParseFloat(cell, 64); err == nil && n > 0 {
+			return colorRed
+		}
+		return ""
+	}
+	c := strings.ToLower(strings.TrimSpace(cell))
+	switch c {
+	case "up", "forwarding", "ok", "active", "online", "pass", "passed", "authorized":
+		return colorGreen
+	case "down", "blocking", "discarding", "err-disabled", "fail", "failed", "error", "offline", "open", "short", "unauthorized":
+		return colorRed
+	}
+	if strings.EqualFold(column, "link") && c != "" {
+		// "1000M/F" and the like: the port has link.
+		return colorGreen
+	}
+	return ""
+}
+
+// recordFields returns the names and values of the fields of a struct,
+// in order, or of the keys of a map, sorted. Fields named in a json tag
+// go by that name.
+func recordFields(rv reflect.Value) ([]string, []reflect.Value) {
+	var names []string
+	var values []reflect.Value
+	if r, ok := rv.Interface().(record); ok {
+		for _, v := range r.values {
+			values = append(values, reflect.ValueOf(v))
+		}
+		return r.names, values
+	}
+	switch rv.Kind() {
+	case reflect.Struct:
+		t := rv.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			names = append(names, name)
+			values = append(values, rv.Field(i))
+		}
+	case reflect.Map:
+		for _, k := range sortedMapKeys(rv) {
+			names = append(names, k)
+			values = append(values, rv.MapIndex(reflect.ValueOf(k)))
+		}
+	}
+	return names, values
+}
+
+func sortedMapKeys(rv reflect.Value) []string {
+	var keys []string
+	for _, k := range rv.MapKeys() {
+		if k.Kind() == reflect.String {
+			keys = append(keys, k.String())
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// isRecordList reports whether v is a list of records rather than of
+// values that fit in a cell.
+func isRecordList(v reflect.Value) bool {
+	v = indirect(v)
+	if v.Kind() != reflect.Slice || v.Len() == 0 {
+		return false
+	}
+	e := indirect(v.Index(0))
+	if _, ok := e.Interface().(fmt.Stringer); ok {
+		return false
+	}
+	return e.Kind() == reflect.Struct || e.Kind() == reflect.Map
+}
+
+func isNumber(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Float64
+}
+
+// cellText writes a value as it goes in a cell; lists are joined with
+// commas, port lists collapsed into ranges.
+func cellText(v reflect.Value) string {
+	v = indirect(v)
+	if !v.IsValid() {
+		return ""
+	}
+	if ports, ok := v.Interface().([]Port); ok {
+		return portRanges(ports)
+	}
+	if s, ok := v.Interface().(fmt.Stringer); ok {
+		return s.String()
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return "yes"
+		}
+		return "no"
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	case reflect.Slice, reflect.Array:
+		parts := make([]string, v.Len())
+		for i := range parts {
+			parts[i] = cellText(v.Index(i))
+		}
+		return strings.Join(parts, ",")
+	case reflect.Map, reflect.Struct:
+		names, values := recordFields(v)
+		parts := make([]string, len(names))
+		for i, name := range names {
+			parts[i] = name + "=" + cellText(values[i])
+		}
+		return strings.Join(parts, " ")
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// portRanges writes ports in the notation they were read in.
+func portRanges(ports []Port) string {
+	d := DialectFlat
+	for _, p := range ports {
+		switch {
+		case p.Slot != 0:
+			d = DialectUnitSlot
+		case p.Unit != 0 && d == DialectFlat:
+			d = DialectSlot
+		}
+	}
+	s, err := FormatPortList(ports, d)
+	if err != nil {
+		return fmt.Sprint(ports)
+	}
+	return s
+}
+
+// indirect follows pointers and interfaces to the value they hold.
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func slicesIndex(names []string, name string) int {
+	for i, n := range names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Telnet protocol bytes (RFC 854).
+const (
+	telnetIAC  = 255
+	telnetDONT = 254
+	telnetDO   = 253
+	telnetWONT = 252
+	telnetWILL = 251
+	telnetSB   = 250
+	telnetSE   = 240
+	telnetNOP  = 241
+
+	telnetOptEcho = 1
+	telnetOptSGA  = 3
+	telnetOptNAWS = 31
+)
+
+// telnetConn strips option negotiation from the data stream, answering it
+// so the server runs a plain character stream: the server may echo and
+// suppress go-ahead, the window size is sent when asked for (RFC 1073),
+// everything else is refused.
+type telnetConn struct {
+	conn   net.Conn
+	r      *bufio.Reader
+	mu     sync.Mutex
+	width  int
+	height int
+}
+
+func (t *telnetConn) reply(cmd, opt byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.conn.Write([]byte{telnetIAC, cmd, opt})
+}
+
+// sendWindowSize answers DO NAWS with the terminal size.
+func (t *telnetConn) sendWindowSize() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := []byte{telnetIAC, telnetWILL, telnetOptNAWS, telnetIAC, telnetSB, telnetOptNAWS}
+	for _, b := range []byte{byte(t.width >> 8), byte(t.width), byte(t.height >> 8), byte(t.height)} {
+		if out = append(out, b); b == telnetIAC {
+			out = append(out, telnetIAC)
+		}
+	}
+	t.conn.Write(append(out, telnetIAC, telnetSE))
+}
+
+// keepAlive sends a NOP every interval until the connection fails. Telnet
+// servers discard it, so it is safe in the middle of a command.
+func (t *telnetConn) keepAlive(interval time.Duration) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for range tick.C {
+		t.mu.Lock()
+		_, err := t.conn.Write([]byte{telnetIAC, telnetNOP})
+		t.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (t *telnetConn) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if n > 0 && t.r.Buffered() == 0 {
+			break
+		}
+		b, err := t.r.ReadByte()
+		if err != nil {
+			if n > 0 {
+				return n, nil
+			}
+			return 0, err
+		}
+		if b != telnetIAC {
+			p[n] = b
+			n++
+			continue
+		}
+
+		cmd, err := t.r.ReadByte()
+		if err != nil {
+			return n, err
+		}
+		switch cmd {
+		case telnetIAC:
+			p[n] = telnetIAC
+			n++
+		case telnetDO, telnetDONT, telnetWILL, telnetWONT:
+			opt, err := t.r.ReadByte()
+			if err != nil {
+				return n, err
+			}
+			switch {
+			case cmd == telnetDO && opt == telnetOptNAWS:
+				t.sendWindowSize()
+			case cmd == telnetDO:
+				t.reply(telnetWONT, opt)
+			case cmd == telnetWILL && (opt == telnetOptEcho || opt == telnetOptSGA):
+				t.reply(telnetDO, opt)
+			case cmd == telnetWILL:
+				t.reply(telnetDONT, opt)
+			}
+		case telnetSB:
+			// Skip subnegotiation up to IAC SE.
+			for {
+				c, err := t.r.ReadByte()
+				if err != nil {
+					return n, err
+				}
+				if c == telnetIAC {
+					if c, _ = t.r.ReadByte(); c == telnetSE {
+						break
+					}
+				}
+			}
+		}
+	}
+	return n, nil
+}
+
+func (t *telnetConn) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// Telnet wants CR LF line endings and doubled IAC bytes.
+	out := make([]byte, 0, len(p)+8)
+	for _, b := range p {
+		switch b {
+		case '\n':
+			out = append(out, '\r', '\n')
+		case telnetIAC:
+			out = append(out, telnetIAC, telnetIAC)
+		default:
+			out = append(out, b)
+		}
+	}
+	if _, err := t.conn.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+var loginPrompt = regexp.MustCompile(`(?i)(user\s*name|login|user)\s*:\s*$`)
+
+// dialTelnet logs in over Telnet and returns a session at the first
+// prompt, for older units without SSH.
+func dialTelnet(ctx context.Context, cfg Config, pc *passwordChange) (*Session, error) {
+	address := cfg.address()
+	d := net.Dialer{Timeout: cfg.connectTimeout()}
+	conn, err := d.DialContext(ctx, "tcp", cfg.dialAddress())
+	if err != nil {
+		return nil, errorf("failed to connect to %s: %w", address, err)
+	}
+
+	tc := &telnetConn{conn: conn, r: bufio.NewReader(conn), width: cfg.termWidth(), height: cfg.termHeight()}
+	if interval := cfg.keepAlive(); interval > 0 {
+		go tc.keepAlive(interval)
+	}
+	s := newSession(recording(cfg.RecordFile, cfg.Host, tc, tc, conn.Close))
+
+	if _, _, err := s.waitFor(ctx, loginPrompt.MatchString, cfg.connectTimeout()); err != nil {
+		s.shutdown()
+		return nil, fmt.Errorf("telnet login: %w", err)
+	}
+	fmt.Fprintf(s.stdin, "%s\n", cfg.User)
+
+	if _, _, err := s.waitFor(ctx, passwordPrompt.MatchString, cfg.connectTimeout()); err != nil {
+		s.shutdown()
+		return nil, fmt.Errorf("telnet login: %w", err)
+	}
+	fmt.Fprintf(s.stdin, "%s\n", cfg.Password)
+
+	if err := s.waitPrompt(ctx, cfg.PromptRegex, cfg.connectTimeout(), pc); err != nil {
+		s.shutdown()
+		return nil, err
+	}
+	return s, nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"golang.org/x/term"
+	"gopkg.in/yaml.v3"
+)
+
+// templateFuncs are available in configuration templates besides the
+// text/template builtins.
+var templateFuncs = template.FuncMap{
+	// ports expands a port list, for ranging over single ports.
+	"ports": ParsePortList,
+	"join": func(sep string, items []any) string {
+		s := make([]string, len(items))
+		for i, v := range items {
+			s[i] = fmt.Sprint(v)
+		}
+		return strings.Join(s, sep)
+	},
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// renderTemplate executes the Go template at path with the variables from
+// the YAML file at varsPath. Referring to a variable the file does not
+// define is an error rather than an empty string.
+func renderTemplate(path, varsPath string) (string, error) {
+	data, err := os.ReadFile(varsPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read variables: %w", err)
+	}
+	vars := make(map[string]any)
+	if err := yaml.Unmarshal(data, &vars); err != nil {
+		return "", fmt.Errorf("failed to parse %s: %w", varsPath, err)
+	}
+
+	t, err := template.New(filepath.Base(path)).Option("missingkey=error").Funcs(templateFuncs).ParseFiles(path)
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, vars); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// configLines returns the commands of a rendered configuration, skipping
+// blank lines and "!" comments.
+func configLines(config string) []string {
+	var commands []string
+	for _, l := range strings.Split(strings.ReplaceAll(config, "\r", ""), "\n") {
+		if l = strings.TrimSpace(l); l != "" && !strings.HasPrefix(l, "!") {
+			commands = append(commands, l)
+		}
+	}
+	return commands
+}
+
+// confirmApply asks whether to go ahead with n commands on host and exits
+// unless the answer is yes.
+func confirmApply(n int, host string) {
+	fmt.Fprintf(os.Stderr, tr("Apply %d commands to %s? [y/N] "), n, host)
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if a := strings.ToLower(strings.TrimSpace(line)); a != "y" && a != "yes" {
+		fatal("not applied")
+	}
+}
+
+// templateArgs checks for the template and variables file arguments.
+func templateArgs(fs *flag.FlagSet, name string) (string, string) {
+	if fs.NArg() != 2 {
+		fatal("Usage: zyxel %s <template> <vars.yaml>", name)
+	}
+	return fs.Arg(0), fs.Arg(1)
+}
+
+func runRender(fs *flag.FlagSet) func() {
+	return func() {
+		config, err := renderTemplate(templateArgs(fs, "render"))
+		if err != nil {
+			fatal("%v", err)
+		}
+		fmt.Print(config)
+	}
+}
+
+func runApply(fs *flag.FlagSet) func() {
+	save := fs.Bool("save", false, tr("Write memory after applying"))
+	yes := fs.Bool("yes", false, tr("Apply without showing the commands and asking first"))
+	var verify stringList
+	fs.Var(&verify, "verify", tr("Command to run afterwards to check the change (repeat for several)"))
+	runbookPath := fs.String("runbook", "", tr("Write a Markdown runbook of the change to `file` ({host} expands)"))
+	dryRun := addDryRunFlag(fs)
+	cf := addConnFlags(fs)
+	return func() {
+		config, err := renderTemplate(templateArgs(fs, "apply"))
+		if err != nil {
+			fatal("%v", err)
+		}
+		commands := configLines(config)
+		if len(commands) == 0 {
+			fatal("the template rendered no commands")
+		}
+		cfg := cf.config()
+
+		if !*yes && !*dryRun {
+			if !term.IsTerminal(int(os.Stdin.Fd())) {
+				fatal("stdin is not a terminal; review with zyxel render and pass --yes to apply")
+			}
+			for _, c := range commands {
+				fmt.Fprintf(os.Stderr, "  %s\n", c)
+			}
+			confirmApply(len(commands), cfg.Host)
+		}
+
+		s, err := Dial(cfg)
+		if err != nil {
+			fatal("%v", err)
+		}
+		defer s.Close()
+		s.dryRun = *dryRun
+		if commands, err = s.expandPorts(commands); err != nil {
+			fatal("%v", err)
+		}
+		if verify, err = s.expandPorts(verify); err != nil {
+			fatal("%v", err)
+		}
+		applyChange(s, cfg, commands, verify, *save, *runbookPath, os.Stdout)
+	}
+}
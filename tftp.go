@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A minimal TFTP server (RFC 1350) for the transfers the switch starts
+// itself: it fetches firmware images and uploads its configuration. It
+// only serves files it was told about and runs for as long as the command
+// that started it.
+
+const (
+	tftpRRQ   = 1
+	tftpWRQ   = 2
+	tftpData  = 3
+	tftpAck   = 4
+	tftpError = 5
+
+	tftpBlockSize = 512
+	tftpTimeout   = 2 * time.Second
+	tftpRetries   = 5
+)
+
+// tftpListenAddr resolves --tftp-listen, an IP address or the name of a
+// network interface, optionally with a port, to the address to bind. The
+// switch is told the IP, so it has to be a specific one.
+func tftpListenAddr(listen string) (*net.UDPAddr, error) {
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		host, port = listen, "69"
+	}
+	if iface, err := net.InterfaceByName(host); err == nil {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		host = ""
+		for _, a := range addrs {
+			if ipn, ok := a.(*net.IPNet); ok && ipn.IP.To4() != nil {
+				host = ipn.IP.String()
+				break
+			}
+		}
+		if host == "" {
+			return nil, fmt.Errorf("interface %s has no IPv4 address", iface.Name)
+		}
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsUnspecified() {
+		return nil, fmt.Errorf("--tftp-listen needs an address the switch can reach, not %q", listen)
+	}
+	return net.ResolveUDPAddr("udp", net.JoinHostPort(ip.String(), port))
+}
+
+// tftpServer serves the files offered to it and receives the files it
+// expects.
+type tftpServer struct {
+	conn *net.UDPConn
+	// IP is the address the switch reaches the server at.
+	IP string
+
+	mu       sync.Mutex
+	offered  map[string]string
+	expected map[string]chan tftpUpload
+}
+
+// tftpUpload is a file a switch wrote to the server.
+type tftpUpload struct {
+	Data []byte
+	Err  error
+}
+
+// startTFTP listens on --tftp-listen and serves requests until Close.
+func startTFTP(listen string) (*tftpServer, error) {
+	addr, err := tftpListenAddr(listen)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start the TFTP server: %w", err)
+	}
+	t := &tftpServer{
+		conn:     conn,
+		IP:       addr.IP.String(),
+		offered:  make(map[string]string),
+		expected: make(map[string]chan tftpUpload),
+	}
+	go t.serve()
+	return t, nil
+}
+
+func (t *tftpServer) Close() error {
+	return t.conn.Close()
+}
+
+// offer lets switches read the local file at path as name.
+func (t *tftpServer) offer(name, path string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.offered[name] = path
+}
+
+// expect accepts one upload of name; the returned channel gets it once
+// the transfer is complete.
+func (t *tftpServer) expect(name string) <-chan tftpUpload {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ch := make(chan tftpUpload, 1)
+	t.expected[name] = ch
+	return ch
+}
+
+func (t *tftpServer) serve() {
+	buf := make([]byte, 1024)
+	for {
+		n, peer, err := t.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		if n < 4 {
+			continue
+		}
+		op := binary.BigEndian.Uint16(buf)
+		name, _, _ := strings.Cut(string(buf[2:n]), "\x00")
+		go t.transfer(op, name, peer)
+	}
+}
+
+// transfer handles one request from its own port, as TFTP does.
+func (t *tftpServer) transfer(op uint16, name string, peer *net.UDPAddr) {
+	c, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP(t.IP)})
+	if err != nil {
+		return
+	}
+	defer c.Close()
+
+	t.mu.Lock()
+	path, offered := t.offered[name]
+	ch, expected := t.expected[name]
+	if op == tftpWRQ && expected {
+		delete(t.expected, name)
+	}
+	t.mu.Unlock()
+
+	switch {
+	case op == tftpRRQ && offered:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			tftpSendError(c, peer, 1, "file not found")
+			return
+		}
+		tftpSend(c, peer, data)
+	case op == tftpWRQ && expected:
+		data, err := tftpReceive(c, peer)
+		ch <- tftpUpload{data, err}
+	case op == tftpRRQ:
+		tftpSendError(c, peer, 1, "file not found")
+	default:
+		tftpSendError(c, peer, 2, "access violation")
+	}
+}
+
+func tftpSendError(c *net.UDPConn, peer *net.UDPAddr, code uint16, msg string) {
+	pkt := binary.BigEndian.AppendUint16(nil, tftpError)
+	pkt = binary.BigEndian.AppendUint16(pkt, code)
+	c.WriteToUDP(append(append(pkt, msg...), 0), peer)
+}
+
+// tftpExchange sends pkt to peer until a reply from it satisfies done,
+// retrying on timeout. An ERROR packet from the peer ends the transfer.
+func tftpExchange(c *net.UDPConn, peer *net.UDPAddr, pkt []byte, done func(op uint16, reply []byte) bool) error {
+	buf := make([]byte, 4+tftpBlockSize)
+	for try := 0; try < tftpRetries; try++ {
+		if _, err := c.WriteToUDP(pkt, peer); err != nil {
+			return err
+		}
+		c.SetReadDeadline(time.Now().Add(tftpTimeout))
+		for {
+			n, from, err := c.ReadFromUDP(buf)
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if n < 4 || !from.IP.Equal(peer.IP) || from.Port != peer.Port {
+				continue
+			}
+			op := binary.BigEndian.Uint16(buf)
+			if op == tftpError {
+				msg, _, _ := strings.Cut(string(buf[4:n]), "\x00")
+				return fmt.Errorf("switch aborted the transfer: %s", msg)
+			}
+			if done(op, buf[2:n]) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("TFTP transfer timed out")
+}
+
+// tftpSend sends data in blocks, each acknowledged before the next. Block
+// numbers wrap around for files over 32 MB.
+func tftpSend(c *net.UDPConn, peer *net.UDPAddr, data []byte) error {
+	for block, off := uint16(1), 0; ; block, off = block+1, off+tftpBlockSize {
+		chunk := data[off:min(off+tftpBlockSize, len(data))]
+		pkt := binary.BigEndian.AppendUint16(nil, tftpData)
+		pkt = binary.BigEndian.AppendUint16(pkt, block)
+		err := tftpExchange(c, peer, append(pkt, chunk...), func(op uint16, reply []byte) bool {
+			return op == tftpAck && binary.BigEndian.Uint16(reply) == block
+		})
+		if err != nil {
+			return err
+		}
+		if len(chunk) < tftpBlockSize {
+			return nil
+		}
+	}
+}
+
+// tftpReceive acknowledges the request and collects the blocks that
+// follow, up to the first short one.
+func tftpReceive(c *net.UDPConn, peer *net.UDPAddr) ([]byte, error) {
+	var data bytes.Buffer
+	for block := uint16(0); ; block++ {
+		ack := binary.BigEndian.AppendUint16(nil, tftpAck)
+		ack = binary.BigEndian.AppendUint16(ack, block)
+		last := false
+		err := tftpExchange(c, peer, ack, func(op uint16, reply []byte) bool {
+			if op != tftpData || binary.BigEndian.Uint16(reply) != block+1 {
+				return false
+			}
+			data.Write(reply[2:])
+			last = len(reply)-2 < tftpBlockSize
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+		if last {
+			ack = binary.BigEndian.AppendUint16(nil, tftpAck)
+			c.WriteToUDP(binary.BigEndian.AppendUint16(ack, block+1), peer)
+			return data.Bytes(), nil
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Topology is the graph of switch interconnections learned over LLDP.
+type Topology struct {
+	Nodes []TopologyNode `json:"nodes"`
+	Links []TopologyLink `json:"links"`
+}
+
+// TopologyNode is an inventory host or a neighbor seen from one.
+type TopologyNode struct {
+	Name      string `json:"name"`
+	Address   string `json:"address,omitempty"`
+	Inventory bool   `json:"inventory"`
+	// Error is set when the host could not be queried.
+	Error string `json:"error,omitempty"`
+}
+
+// TopologyLink is a cable between two ports. A and APort are always the
+// inventory host the link was learned from.
+type TopologyLink struct {
+	A     string `json:"a"`
+	APort string `json:"a_port"`
+	B     string `json:"b"`
+	BPort string `json:"b_port"`
+}
+
+// samePort compares port names from different sources, e.g. "25" from the
+// local table and "swp25" or "Port 25" from a neighbor's port ID.
+func samePort(a, b string) bool {
+	if a == b {
+		return true
+	}
+	pa, err1 := parsePort(strings.TrimPrefix(strings.ToLower(a), "port "))
+	pb, err2 := parsePort(strings.TrimPrefix(strings.ToLower(b), "port "))
+	return err1 == nil && err2 == nil && pa == pb
+}
+
+// buildTopology turns the LLDP tables of the hosts into a graph. A link
+// seen from both ends is listed once. Unless all is set, only neighbors
+// that advertise bridging or routing are included.
+func buildTopology(inv *Inventory, results []fleetResult[[]LLDPNeighbor], all bool) Topology {
+	t := Topology{Links: []TopologyLink{}}
+	known := make(map[string]bool)
+	addNode := func(n TopologyNode) {
+		if !known[n.Name] {
+			known[n.Name] = true
+			t.Nodes = append(t.Nodes, n)
+		}
+	}
+	for _, r := range results {
+		n := TopologyNode{Name: r.Host.Name, Address: r.Host.Address, Inventory: true}
+		if r.Err != nil {
+			n.Error = r.Err.Error()
+		}
+		addNode(n)
+	}
+
+	for _, r := range results {
+		for _, n := range r.Value {
+			if !all && !n.IsSwitch() {
+				continue
+			}
+			link := TopologyLink{A: r.Host.Name, APort: n.LocalPort, BPort: n.PortID}
+			if h, ok := inv.byNeighbor(n); ok {
+				link.B = h.Name
+			} else {
+				link.B = firstNonEmpty(n.SystemName, n.MgmtAddress, n.ChassisID)
+				addNode(TopologyNode{Name: link.B, Address: n.MgmtAddress})
+			}
+			if link.B == "" || link.B == link.A {
+				continue
+			}
+
+			duplicate := false
+			for _, l := range t.Links {
+				if l.A == link.B && l.B == link.A && samePort(l.APort, link.BPort) && samePort(l.BPort, link.APort) {
+					duplicate = true
+					break
+				}
+			}
+			if !duplicate {
+				t.Links = append(t.Links, link)
+			}
+		}
+	}
+
+	sort.SliceStable(t.Nodes, func(i, j int) bool { return t.Nodes[i].Name < t.Nodes[j].Name })
+	return t
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// writeDOT writes t as a Graphviz graph. Hosts outside the inventory are
+// drawn as ellipses, unreachable inventory hosts dashed.
+func (t Topology) writeDOT(w io.Writer) {
+	fmt.Fprintln(w, "graph topology {")
+	fmt.Fprintln(w, "  node [shape=box];")
+	for _, n := range t.Nodes {
+		var attrs []string
+		label := n.Name
+		if n.Address != "" && n.Address != n.Name {
+			label += "\\n" + n.Address
+		}
+		attrs = append(attrs, fmt.Sprintf("label=%q", label))
+		if !n.Inventory {
+			attrs = append(attrs, "shape=ellipse")
+		}
+		if n.Error != "" {
+			attrs = append(attrs, "style=dashed")
+		}
+		fmt.Fprintf(w, "  %q [%s];\n", n.Name, strings.Join(attrs, ", "))
+	}
+	for _, l := range t.Links {
+		fmt.Fprintf(w, "  %q -- %q [taillabel=%q, headlabel=%q];\n", l.A, l.B, l.APort, l.BPort)
+	}
+	fmt.Fprintln(w, "}")
+}
+
+func runTopology(fs *flag.FlagSet) func() {
+	ff := addFleetFlags(fs)
+	format := fs.String("format", "dot", tr("Output format: dot or json"))
+	all := fs.Bool("all", false, tr("Include every LLDP neighbor, not just switches and routers"))
+	return func() {
+		if *format != "dot" && *format != "json" {
+			fatal("--format must be dot or json, not %q", *format)
+		}
+		inv, hosts := ff.load()
+		results := runFleet(hosts, ff, func(h Host, s *Session) ([]LLDPNeighbor, error) {
+			return lldpNeighbors(s)
+		})
+		for _, r := range results {
+			if r.Err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", r.Host.Name, r.Err)
+			}
+		}
+
+		t := buildTopology(inv, results, *all)
+		if *format == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(t); err != nil {
+				fatal("%v", err)
+			}
+			return
+		}
+		t.writeDOT(os.Stdout)
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Trunk is one link aggregation group from "show trunk".
+type Trunk struct {
+	ID      string `json:"id"`
+	State   string `json:"state"`
+	Mode    string `json:"mode"`
+	Members []Port `json:"members"`
+	// Synchronized lists the members currently carrying traffic (LACP).
+	Synchronized []Port `json:"synchronized"`
+}
+
+var trunkGroup = regexp.MustCompile(`(?i)^\s*(?:group\s*id|trunk\s*(?:id)?)\s*:?\s*(T?\d+)\s*:?\s*(.*)$`)
+
+// parseTrunks parses "show trunk". Each group starts with a
+// "Group ID 1: <state>" line followed by "Key: value" lines; member lists
+// use the usual port list notation.
+func parseTrunks(output string) []Trunk {
+	var trunks []Trunk
+	var cur *Trunk
+
+	for _, line := range strings.Split(strings.ReplaceAll(output, "\r", ""), "\n") {
+		if m := trunkGroup.FindStringSubmatch(line); m != nil {
+			trunks = append(trunks, Trunk{ID: m[1], State: strings.TrimSpace(m[2])})
+			cur = &trunks[len(trunks)-1]
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+
+		key, value, ok := splitKeyValue(line)
+		if !ok || value == "" {
+			continue
+		}
+		switch strings.ToLower(key) {
+		case "status", "mode":
+			cur.Mode = value
+		case "members", "member":
+			cur.Members, _ = ParsePortList(value)
+		case "synchronized members", "sync members":
+			cur.Synchronized, _ = ParsePortList(value)
+		}
+	}
+	return trunks
+}
+
+// trunks collects the link aggregation groups from the switch.
+func trunks(s *Session) ([]Trunk, error) {
+	out, err := s.Output(s.command("trunks"))
+	if err != nil {
+		return nil, err
+	}
+	return parseTrunks(out), nil
+}
+
+// trunkHealth is a trunk with the state of its members.
+type trunkHealth struct {
+	ID    string `json:"id"`
+	State string `json:"state"`
+	Mode  string `json:"mode"`
+	// Members are all member ports, Up those with link.
+	Members []string `json:"members"`
+	Up      []string `json:"up"`
+	// Down are members without link and Unsynced members with link that
+	// LACP does not use.
+	Down     []string `json:"down"`
+	Unsynced []string `json:"unsynced"`
+}
+
+// isLACP reports whether the trunk negotiates with LACP, so its
+// synchronized members are known.
+func (t Trunk) isLACP() bool {
+	return strings.Contains(strings.ToLower(t.Mode), "lacp") || len(t.Synchronized) > 0
+}
+
+// trunkStates rates the members of trunks by the link state of the
+// interfaces and, for LACP trunks, whether they are synchronized.
+func trunkStates(trunks []Trunk, ifaces []Interface) []trunkHealth {
+	links := make(map[Port]bool)
+	for _, i := range ifaces {
+		if p, err := parsePort(i.Port); err == nil {
+			links[p] = i.LinkUp()
+		}
+	}
+	var states []trunkHealth
+	for _, t := range trunks {
+		if len(t.Members) == 0 {
+			continue
+		}
+		th := trunkHealth{ID: t.ID, State: t.State, Mode: t.Mode,
+			Members: []string{}, Up: []string{}, Down: []string{}, Unsynced: []string{}}
+		for _, p := range t.Members {
+			th.Members = append(th.Members, p.String())
+			switch {
+			case !links[p]:
+				th.Down = append(th.Down, p.String())
+			case t.isLACP() && !slices.Contains(t.Synchronized, p):
+				th.Unsynced = append(th.Unsynced, p.String())
+				th.Up = append(th.Up, p.String())
+			default:
+				th.Up = append(th.Up, p.String())
+			}
+		}
+		states = append(states, th)
+	}
+	return states
+}
+
+// name is the trunk as the switch names it in commands, e.g. "T1".
+func (th trunkHealth) name() string {
+	if strings.HasPrefix(strings.ToUpper(th.ID), "T") {
+		return strings.ToUpper(th.ID)
+	}
+	return "T" + th.ID
+}
+
+// status fails a trunk without working members and warns when some are
+// down or not synchronized.
+func (th trunkHealth) status() healthStatus {
+	switch {
+	case len(th.Up) == len(th.Unsynced):
+		return healthFail
+	case len(th.Down) > 0 || len(th.Unsynced) > 0:
+		return healthWarn
+	}
+	return healthOK
+}
+
+func (th trunkHealth) String() string {
+	s := fmt.Sprintf("%d/%d up", len(th.Up)-len(th.Unsynced), len(th.Members))
+	var notes []string
+	if len(th.Down) > 0 {
+		notes = append(notes, strings.Join(th.Down, ",")+" down")
+	}
+	if len(th.Unsynced) > 0 {
+		notes = append(notes, strings.Join(th.Unsynced, ",")+" not synchronized")
+	}
+	if len(notes) > 0 {
+		s += " (" + strings.Join(notes, ", ") + ")"
+	}
+	return s
+}
+
+// trunkCheck sums up the trunks as a health check.
+func trunkCheck(states []trunkHealth) healthCheck {
+	c := healthCheck{Name: "Trunks"}
+	var parts []string
+	for _, th := range states {
+		c.Status = max(c.Status, th.status())
+		parts = append(parts, th.name()+" "+th.String())
+		c.Perf = append(c.Perf, perfValue{Label: strings.ToLower(th.name()) + "_up", Value: float64(len(th.Up) - len(th.Unsynced))})
+	}
+	c.Detail = strings.Join(parts, ", ")
+	return c
+}
+
+// trunkHealthStates reads the trunks and the link state of the switch.
+func trunkHealthStates(s *Session) ([]trunkHealth, error) {
+	groups, err := trunks(s)
+	if err != nil || len(groups) == 0 {
+		return nil, err
+	}
+	ifaces, err := interfaces(s, "*")
+	if err != nil {
+		return nil, err
+	}
+	return trunkStates(groups, ifaces), nil
+}
+
+func runTrunks(fs *flag.FlagSet) func() {
+	cf := addConnFlags(fs)
+	format := fs.String("format", "text", tr("Output format: text or json"))
+	return func() {
+		if *format != "text" && *format != "json" {
+			fatal("--format must be text or json, not %q", *format)
+		}
+		_, s := cf.connect()
+		defer s.Close()
+		states, err := trunkHealthStates(s)
+		if err != nil {
+			fatal("%v", err)
+		}
+
+		if *format == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(nonNil(states)); err != nil {
+				fatal("%v", err)
+			}
+		} else {
+			fmt.Printf("%-6s %-10s %-8s %-16s %s\n", "Trunk", "State", "Mode", "Members", "Status")
+			for _, th := range states {
+				fmt.Printf("%-6s %-10s %-8s %-16s %s\n", th.ID, th.State, th.Mode, strings.Join(th.Members, ","), th)
+			}
+		}
+		if trunkCheck(states).Status != healthOK {
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// defaultUplinkUtilization is the utilization above which a port is
+// assumed to carry aggregated traffic.
+const defaultUplinkUtilization = 0.5
+
+// detectUplinks classifies ports as uplinks. A port is an uplink when its
+// LLDP neighbor is a switch, when it is a trunk member, or when its
+// utilization exceeds utilThreshold. The map values explain why.
+func detectUplinks(s *Session, utilThreshold float64) (map[Port][]string, error) {
+	neighbors, err := lldpNeighbors(s)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := trunks(s)
+	if err != nil {
+		return nil, err
+	}
+	ifaces, err := interfaces(s, "*")
+	if err != nil {
+		return nil, err
+	}
+	return classifyUplinks(neighbors, groups, ifaces, utilThreshold), nil
+}
+
+// classifyUplinks is detectUplinks on the parsed LLDP neighbors, trunks
+// and interfaces. Ports are keyed as the switch printed them.
+func classifyUplinks(neighbors []LLDPNeighbor, groups []Trunk, ifaces []Interface, utilThreshold float64) map[Port][]string {
+	uplinks := make(map[Port][]string)
+	for _, n := range neighbors {
+		if !n.IsSwitch() {
+			continue
+		}
+		p, err := parsePort(n.LocalPort)
+		if err != nil {
+			continue
+		}
+		name := n.SystemName
+		if name == "" {
+			name = n.ChassisID
+		}
+		uplinks[p] = append(uplinks[p], fmt.Sprintf("LLDP neighbor %s is a switch", name))
+	}
+
+	for _, t := range groups {
+		for _, p := range t.Members {
+			uplinks[p] = append(uplinks[p], fmt.Sprintf("member of trunk %s", t.ID))
+		}
+	}
+
+	for _, iface := range ifaces {
+		u := iface.Utilization()
+		if u <= utilThreshold {
+			continue
+		}
+		p, err := parsePort(iface.Port)
+		if err != nil {
+			continue
+		}
+		uplinks[p] = append(uplinks[p], fmt.Sprintf("utilization %.0f%%", u*100))
+	}
+	return uplinks
+}
+
+// guardUplinks refuses to touch any of ports that looks like an uplink
+// unless allow is set, so a port operation cannot cut off the switch.
+func guardUplinks(s *Session, ports []Port, allow bool) error {
+	if allow {
+		return nil
+	}
+
+	uplinks, err := detectUplinks(s, defaultUplinkUtilization)
+	if err != nil {
+		return errorf("failed to detect uplinks: %w", err)
+	}
+	d, err := s.portDialect()
+	if err != nil {
+		return err
+	}
+	if hits := uplinkHits(uplinks, ports, d); len(hits) > 0 {
+		return errorf("refusing to change uplink ports: %s; use --allow-uplink to override", strings.Join(hits, "; "))
+	}
+	return nil
+}
+
+// uplinkHits describes the ports of ports that are in uplinks. Both are
+// compared in dialect d: a stacked switch prints "1/24" for the port that
+// may have been asked for as "24".
+func uplinkHits(uplinks map[Port][]string, ports []Port, d Dialect) []string {
+	inDialect := func(p Port) Port {
+		if np, err := p.normalize(d); err == nil {
+			return np
+		}
+		return p
+	}
+	known := make(map[Port][]string, len(uplinks))
+	for p, reasons := range uplinks {
+		np := inDialect(p)
+		known[np] = append(known[np], reasons...)
+	}
+
+	var hits []string
+	for _, p := range ports {
+		if reasons, ok := known[inDialect(p)]; ok {
+			hits = append(hits, fmt.Sprintf("%s (%s)", p, strings.Join(reasons, ", ")))
+		}
+	}
+	return hits
+}
+
+func runUplinks(fs *flag.FlagSet) func() {
+	threshold := fs.Float64("util-threshold", defaultUplinkUtilization*100, tr("Utilization percentage above which a port counts as an uplink"))
+	cf := addConnFlags(fs)
+	return func() {
+		_, s := cf.connect()
+		defer s.Close()
+
+		uplinks, err := detectUplinks(s, *threshold/100)
+		if err != nil {
+			fatal("%v", err)
+		}
+
+		ports := make([]Port, 0, len(uplinks))
+		for p := range uplinks {
+			ports = append(ports, p)
+		}
+		sort.Slice(ports, func(i, j int) bool { return ports[i].less(ports[j]) })
+
+		if len(ports) == 0 {
+			fmt.Fprintln(os.Stderr, tr("No uplinks detected"))
+			return
+		}
+		for _, p := range ports {
+			fmt.Printf("%-8s %s\n", p, strings.Join(uplinks[p], ", "))
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUplinkHitsStacked(t *testing.T) {
+	lldp := `
+  Local Port                   : 1/24
+  Chassis ID                   : bc:99:11:00:00:01
+  Port ID                      : 25
+  System Name                  : core-sw
+  System Capabilities Enabled  : Bridge
+
+  Local Port                   : 2/3
+  Chassis ID                   : 00:11:32:aa:bb:cc
+  System Name                  : ap-lobby
+  System Capabilities Enabled  : WLAN Access Point
+`
+	trunk := `
+Group ID T1: active
+  Status: LACP
+  Members: 2/25-2/26
+`
+	uplinks := classifyUplinks(parseLLDPRemote(lldp), parseTrunks(trunk), nil, defaultUplinkUtilization)
+
+	tests := []struct {
+		name    string
+		ports   string
+		dialect Dialect
+		want    []string
+	}{
+		{"slot notation", "1/23-1/24,2/3,2/26", DialectSlot, []string{
+			"1/24 (LLDP neighbor core-sw is a switch)",
+			"2/26 (member of trunk T1)",
+		}},
+		{"unit-slot notation", "1/1/24,2/1/25", DialectUnitSlot, []string{
+			"1/1/24 (LLDP neighbor core-sw is a switch)",
+			"2/1/25 (member of trunk T1)",
+		}},
+		{"flat notation on the first unit", "23-24", DialectFlat, []string{
+			"24 (LLDP neighbor core-sw is a switch)",
+		}},
+		{"other ports", "1/1-1/22,2/1-2/4", DialectSlot, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ports, err := ParsePortList(tt.ports)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := uplinkHits(uplinks, ports, tt.dialect); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("uplinkHits(%s) = %q, want %q", tt.ports, got, tt.want)
+			}
+		})
+	}
+}
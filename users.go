@@ -0,0 +1,349 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// The built-in "admin" account has its password set with "admin-password
+// <password> <confirm>"; other accounts are "logins username <name>
+// password <password> privilege <0-14>" and are removed with "no logins
+// username <name>".
+
+const adminUser = "admin"
+
+// SwitchUser is a login account of the switch.
+type SwitchUser struct {
+	Name      string `json:"name"`
+	Privilege int    `json:"privilege"`
+}
+
+// parseUsers reads the accounts from the global commands of a
+// running-config, the built-in admin first.
+func parseUsers(rc *RunningConfig) []SwitchUser {
+	users := []SwitchUser{{adminUser, 14}}
+	for _, c := range rc.Global {
+		f := strings.Fields(c)
+		if len(f) < 3 || f[0] != "logins" || f[1] != "username" {
+			continue
+		}
+		u := SwitchUser{Name: f[2]}
+		if i := slices.Index(f, "privilege"); i >= 0 && i+1 < len(f) {
+			u.Privilege = atoiOr(f[i+1], 0)
+		}
+		users = append(users, u)
+	}
+	return users
+}
+
+func findUser(users []SwitchUser, name string) (SwitchUser, bool) {
+	for _, u := range users {
+		if u.Name == name {
+			return u, true
+		}
+	}
+	return SwitchUser{}, false
+}
+
+var userName = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,32}$`)
+
+// passwordCommand sets the password of u.
+func passwordCommand(u SwitchUser, password string) string {
+	if u.Name == adminUser {
+		return fmt.Sprintf("admin-password %s %s", password, password)
+	}
+	return fmt.Sprintf("logins username %s password %s privilege %d", u.Name, password, u.Privilege)
+}
+
+// hidePassword replaces password in the error of a command that contained
+// it, such as "switch rejected ...".
+func hidePassword(err error, password string) error {
+	if err == nil || password == "" || !strings.Contains(err.Error(), password) {
+		return err
+	}
+	return errors.New(strings.ReplaceAll(err.Error(), password, "********"))
+}
+
+// newPassword reads the password to set from file, or asks for it twice on
+// the terminal. The switch's CLI takes no spaces in it.
+func newPassword(file string) string {
+	var pw string
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			fatal("%v", err)
+		}
+		pw = strings.TrimRight(string(data), "\r\n")
+	} else {
+		var err error
+		if pw, err = readPassword(tr("New password: ")); err != nil {
+			fatal("%v", err)
+		}
+		if pw == "" {
+			fatal("No password given; use --new-password-file when stdin is not a terminal")
+		}
+		again, err := readPassword(tr("Retype new password: "))
+		if err != nil {
+			fatal("%v", err)
+		}
+		if again != pw {
+			fatal("The passwords do not match")
+		}
+	}
+	if pw == "" || strings.ContainsAny(pw, " \t\"'?") {
+		fatal("The password must not be empty or contain spaces, quotes or \"?\"")
+	}
+	return pw
+}
+
+var userCommandList = []subcommand{
+	{"list", "List the login accounts and their privilege levels", runUsersList,
+		"a table: Name, Privilege; with --format json an array of objects with name and privilege", nil},
+	{"add", "Add a login account", runUsersAdd, "nothing; status goes to stderr", nil},
+	{"remove", "Remove a login account", runUsersRemove, "nothing; status goes to stderr", nil},
+	{"set-password", "Change the password of an account, on one switch or the inventory in turn", runUsersSetPassword,
+		"nothing; status goes to stderr", nil},
+}
+
+func runUsers(fs *flag.FlagSet) func() {
+	return func() {
+		args := fs.Args()
+		if len(args) == 0 {
+			fmt.Println("Usage: zyxel users <command> [flags]")
+			fmt.Println()
+			fmt.Println("Commands:")
+			for _, c := range userCommandList {
+				fmt.Printf("  %-13s %s\n", c.name, c.summary)
+			}
+			os.Exit(1)
+		}
+		for _, c := range userCommandList {
+			if c.name == args[0] {
+				c.invoke("users "+c.name, args[1:])
+				return
+			}
+		}
+		fatal("Unknown users command %q", args[0])
+	}
+}
+
+func runUsersList(fs *flag.FlagSet) func() {
+	cf := addConnFlags(fs)
+	format := fs.String("format", "text", tr("Output format: text or json"))
+	return func() {
+		if *format != "text" && *format != "json" {
+			fatal("--format must be text or json, not %q", *format)
+		}
+		_, s := cf.connect()
+		defer s.Close()
+		rc, err := runningConfig(s)
+		if err != nil {
+			fatal("%v", err)
+		}
+		users := parseUsers(rc)
+		if *format == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(users); err != nil {
+				fatal("%v", err)
+			}
+			return
+		}
+		fmt.Printf("%-20s %s\n", "Name", "Privilege")
+		for _, u := range users {
+			fmt.Printf("%-20s %d\n", u.Name, u.Privilege)
+		}
+	}
+}
+
+func runUsersAdd(fs *flag.FlagSet) func() {
+	cf := addConnFlags(fs)
+	privilege := fs.Int("privilege", 14, tr("Privilege level, 0 (read-only) to 14 (full access)"))
+	passwordFile := fs.String("new-password-file", "", tr("Read the account's password from `file` instead of asking"))
+	save := fs.Bool("save", false, tr("Write memory after the change is verified"))
+	return func() {
+		if fs.NArg() != 1 {
+			fatal("Usage: zyxel users add <name> [--privilege 0-14]")
+		}
+		name := fs.Arg(0)
+		if !userName.MatchString(name) || name == adminUser {
+			fatal("Invalid user name %q", name)
+		}
+		if *privilege < 0 || *privilege > 14 {
+			fatal("--privilege must be 0 to 14, not %d", *privilege)
+		}
+		pw := newPassword(*passwordFile)
+
+		_, s := cf.connect()
+		defer s.Close()
+		rc, err := runningConfig(s)
+		if err != nil {
+			fatal("%v", err)
+		}
+		if _, ok := findUser(parseUsers(rc), name); ok {
+			fatal("User %s already exists; use users set-password", name)
+		}
+		u := SwitchUser{name, *privilege}
+		if err := s.Configure([]string{passwordCommand(u, pw)}, io.Discard); err != nil {
+			fatal("%v", hidePassword(err, pw))
+		}
+		if rc, err = runningConfig(s); err != nil {
+			fatal("%v", err)
+		}
+		if got, ok := findUser(parseUsers(rc), name); !ok || got.Privilege != u.Privilege {
+			fatal("User %s with privilege %d is not in the running-config", name, u.Privilege)
+		}
+		fmt.Fprintf(os.Stderr, "User %s added with privilege %d\n", name, u.Privilege)
+		if *save {
+			saveConfig(s)
+		}
+	}
+}
+
+func runUsersRemove(fs *flag.FlagSet) func() {
+	cf := addConnFlags(fs)
+	save := fs.Bool("save", false, tr("Write memory after the change is verified"))
+	dryRun := addDryRunFlag(fs)
+	return func() {
+		if fs.NArg() != 1 {
+			fatal("Usage: zyxel users remove <name>")
+		}
+		name := fs.Arg(0)
+		cfg, s := cf.connect()
+		defer s.Close()
+		s.dryRun = *dryRun
+		if name == adminUser || name == cfg.User {
+			fatal("Refusing to remove %s, which this tool or the switch needs to log in", name)
+		}
+		rc, err := runningConfig(s)
+		if err != nil {
+			fatal("%v", err)
+		}
+		if _, ok := findUser(parseUsers(rc), name); !ok {
+			fatal("No user named %s", name)
+		}
+		if err := s.Configure([]string{"no logins username " + name}, io.Discard); err != nil {
+			fatal("%v", err)
+		}
+		if s.dryRun {
+			if *save {
+				saveConfig(s)
+			}
+			return
+		}
+
+		if rc, err = runningConfig(s); err != nil {
+			fatal("%v", err)
+		}
+		if _, ok := findUser(parseUsers(rc), name); ok {
+			fatal("User %s is still in the running-config", name)
+		}
+		fmt.Fprintf(os.Stderr, "User %s removed\n", name)
+		if *save {
+			saveConfig(s)
+		}
+	}
+}
+
+// setPassword changes the password of user on the switch of cfg, then logs
+// in with it to check it works. If the login fails and the account is the
+// one cfg logs in with, the old password is put back. The change is saved
+// only when it was verified.
+func setPassword(cfg Config, user, pw string, save bool) error {
+	s, err := Dial(cfg)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	rc, err := runningConfig(s)
+	if err != nil {
+		return err
+	}
+	u, ok := findUser(parseUsers(rc), user)
+	if !ok {
+		return fmt.Errorf("no user named %s", user)
+	}
+	if err := s.Configure([]string{passwordCommand(u, pw)}, io.Discard); err != nil {
+		return hidePassword(err, pw)
+	}
+
+	check := cfg
+	check.User, check.Password, check.EnablePassword = user, pw, ""
+	vs, err := Dial(check)
+	if err != nil {
+		err = fmt.Errorf("logging in as %s with the new password failed: %w", user, err)
+		if user == cfg.User {
+			if rerr := s.Configure([]string{passwordCommand(u, cfg.Password)}, io.Discard); rerr != nil {
+				return fmt.Errorf("%w; putting the old password back failed too: %v", err, hidePassword(rerr, cfg.Password))
+			}
+			return fmt.Errorf("%w; the old password was put back", err)
+		}
+		return err
+	}
+	vs.Close()
+	if save {
+		if _, err := s.Save(); err != nil {
+			return fmt.Errorf("configuration NOT saved: %w", err)
+		}
+	}
+	return nil
+}
+
+func runUsersSetPassword(fs *flag.FlagSet) func() {
+	passwordFile := fs.String("new-password-file", "", tr("Read the new password from `file` instead of asking"))
+	rolling := fs.Bool("rolling", false, tr("Change the password on the inventory switches one at a time, stopping at the first that fails"))
+	save := fs.Bool("save", false, tr("Write memory after the new password is verified"))
+	cf := addConnFlags(fs)
+	ff := addFleetFlags(fs)
+	return func() {
+		if fs.NArg() != 1 {
+			fatal("Usage: zyxel users set-password <name> [--rolling]")
+		}
+		user := fs.Arg(0)
+		pw := newPassword(*passwordFile)
+
+		var names []string
+		var cfgs []Config
+		if *rolling {
+			_, hosts := ff.load()
+			var errs []error
+			var err error
+			if cfgs, errs, err = hostConfigs(hosts); err != nil {
+				fatal("%v", err)
+			}
+			for i, h := range hosts {
+				if errs[i] != nil {
+					fatal("%s: %v", h.Name, errs[i])
+				}
+				names = append(names, h.Name)
+			}
+		} else {
+			cfg := cf.config()
+			cfgs, names = []Config{cfg}, []string{cfg.Host}
+		}
+
+		for i, cfg := range cfgs {
+			err := cfg.validate()
+			if err == nil {
+				err = setPassword(cfg, user, pw, *save)
+			}
+			if err != nil {
+				if left := len(cfgs) - i - 1; left > 0 {
+					fatal("%s: %v; stopping, %d switches not changed", names[i], err, left)
+				}
+				fatal("%s: %v", names[i], err)
+			}
+			fmt.Fprintf(os.Stderr, "%s: password of %s changed and verified\n", names[i], user)
+		}
+		if slices.ContainsFunc(cfgs, func(c Config) bool { return c.User == user }) {
+			fmt.Fprintln(os.Stderr, "Update the stored password (ZYXEL_PASSWORD, inventory, keychain or Vault) to match")
+		}
+	}
+}
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// VLAN is one VLAN from the running-config. Members are the "fixed"
+// ports; the untagged ones are a subset of them.
+type VLAN struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	Members   []Port `json:"members"`
+	Untagged  []Port `json:"untagged"`
+	Forbidden []Port `json:"forbidden"`
+}
+
+func (v VLAN) isMember(p Port) bool {
+	return slices.Contains(v.Members, p)
+}
+
+func (v VLAN) isUntagged(p Port) bool {
+	return slices.Contains(v.Untagged, p)
+}
+
+// vlans extracts the VLAN definitions from the configuration blocks.
+func (rc *RunningConfig) vlans() map[int]VLAN {
+	vlans := make(map[int]VLAN, len(rc.VLANs))
+	for id, commands := range rc.VLANs {
+		v := VLAN{ID: id}
+		for _, c := range commands {
+			f := strings.Fields(c)
+			if len(f) < 2 {
+				continue
+			}
+			switch f[0] {
+			case "name":
+				v.Name = strings.Join(f[1:], " ")
+			case "fixed":
+				v.Members, _ = ParsePortList(f[1])
+			case "untagged":
+				v.Untagged, _ = ParsePortList(f[1])
+			case "forbidden":
+				v.Forbidden, _ = ParsePortList(f[1])
+			}
+		}
+		vlans[id] = v
+	}
+	return vlans
+}
+
+var vlanTagList = regexp.MustCompile(`(?i)\b(untagged|tagged|forbidden)\s*:\s*(\S*)`)
+
+// parseShowVLAN parses "show vlan". Each VLAN row starts with the index and
+// VID and carries an "Untagged :<ports>" list, with "Tagged :<ports>" on
+// the continuation line below:
+//
+//	Idx.  VID   Status     Elap-Time  TagCtl
+//	   1     1  Static     0:37:54    Untagged :1-24
+//	                                    Tagged :25-28
+func parseShowVLAN(output string) map[int]VLAN {
+	vlans := make(map[int]VLAN)
+	cur := 0
+	for _, line := range strings.Split(strings.ReplaceAll(output, "\r", ""), "\n") {
+		lists := vlanTagList.FindAllStringSubmatchIndex(line, -1)
+		end := len(line)
+		if len(lists) > 0 {
+			end = lists[0][0]
+		}
+		var nums []int
+		for _, f := range strings.Fields(line[:end]) {
+			n, err := strconv.Atoi(strings.TrimSuffix(f, "."))
+			if err != nil {
+				break
+			}
+			nums = append(nums, n)
+		}
+		switch {
+		case len(nums) >= 2:
+			cur = nums[1]
+		case len(nums) == 1:
+			cur = nums[0]
+		case len(lists) == 0:
+			continue
+		}
+		if cur == 0 {
+			continue
+		}
+
+		v, ok := vlans[cur]
+		if !ok {
+			v = VLAN{ID: cur}
+		}
+		for _, m := range lists {
+			ports, _ := ParsePortList(line[m[4]:m[5]])
+			switch strings.ToLower(line[m[2]:m[3]]) {
+			case "untagged":
+				v.Untagged = append(v.Untagged, ports...)
+				v.Members = append(v.Members, ports...)
+			case "tagged":
+				v.Members = append(v.Members, ports...)
+			case "forbidden":
+				v.Forbidden = append(v.Forbidden, ports...)
+			}
+		}
+		vlans[cur] = v
+	}
+	return vlans
+}
+
+// showVLAN collects the VLAN table from the switch.
+func showVLAN(s *Session) (map[int]VLAN, error) {
+	out, err := s.Output(s.command("vlans"))
+	if err != nil {
+		return nil, err
+	}
+	return parseShowVLAN(out), nil
+}
+
+// vlanMembership is the wanted membership of ports in one VLAN.
+type vlanMembership struct {
+	Tagged   []Port
+	Untagged []Port
+	Removed  []Port
+}
+
+// check returns how v differs from m, or nil when it matches.
+func (m vlanMembership) check(v VLAN) error {
+	contains := func(list []Port, p Port) bool {
+		for _, q := range list {
+			if sameFlatPort(p, q) {
+				return true
+			}
+		}
+		return false
+	}
+	var wrong []string
+	for _, p := range m.Tagged {
+		if !contains(v.Members, p) || contains(v.Untagged, p) {
+			wrong = append(wrong, fmt.Sprintf("port %s is not tagged", p))
+		}
+	}
+	for _, p := range m.Untagged {
+		if !contains(v.Untagged, p) {
+			wrong = append(wrong, fmt.Sprintf("port %s is not untagged", p))
+		}
+	}
+	for _, p := range m.Removed {
+		if contains(v.Members, p) {
+			wrong = append(wrong, fmt.Sprintf("port %s is still a member", p))
+		}
+	}
+	if len(wrong) > 0 {
+		return fmt.Errorf("show vlan does not reflect the change to VLAN %d: %s", v.ID, strings.Join(wrong, ", "))
+	}
+	return nil
+}
+
+// sameFlatPort compares ports written in different notations.
+func sameFlatPort(a, b Port) bool {
+	na, err1 := a.normalize(DialectFlat)
+	nb, err2 := b.normalize(DialectFlat)
+	if err1 != nil || err2 != nil {
+		return a == b
+	}
+	return na == nb
+}
+
+// vlanPortCommands returns the configuration commands that add tagged and
+// untagged ports to VLAN id or remove ports from it. Untagged ports also
+// get id as their PVID. Session.expandPorts puts the port lists into the
+// notation of the switch, and cmd gives the commands of its syntax.
+func vlanPortCommands(cmd func(operation string) string, id int, tagged, untagged, removed string) []string {
+	commands := vlanMemberCommands(cmd, id, tagged, untagged, removed)
+	if untagged != "" {
+		commands = append(commands, cmd("port-config")+" "+untagged, fmt.Sprintf("%s %d", cmd("pvid"), id), "exit")
+	}
+	return commands
+}
+
+// vlanMemberCommands is the "vlan" block of vlanPortCommands, leaving the
+// PVID alone.
+func vlanMemberCommands(cmd func(operation string) string, id int, tagged, untagged, removed string) []string {
+	fixed, untag := cmd("vlan-fixed"), cmd("vlan-untagged")
+	commands := []string{fmt.Sprintf("%s %d", cmd("vlan-config"), id)}
+	if tagged != "" {
+		commands = append(commands, fixed+" "+tagged, "no "+untag+" "+tagged)
+	}
+	if untagged != "" {
+		commands = append(commands, fixed+" "+untagged, untag+" "+untagged)
+	}
+	if removed != "" {
+		commands = append(commands, "no "+fixed+" "+removed, "no "+untag+" "+removed)
+	}
+	return append(commands, "exit")
+}
+
+var vlanCommandList = []subcommand{
+	{"create", "Create a VLAN", runVLANCreate, "nothing; status goes to stderr", nil},
+	{"delete", "Delete a VLAN", runVLANDelete, "nothing; status goes to stderr", nil},
+	{"add-port", "Add tagged or untagged ports to a VLAN", runVLANAddPort, "nothing; status goes to stderr", nil},
+	{"remove-port", "Remove ports from a VLAN", runVLANRemovePort, "nothing; status goes to stderr", nil},
+}
+
+func runVLAN(fs *flag.FlagSet) func() {
+	return func() {
+		args := fs.Args()
+		if len(args) == 0 {
+			fmt.Println("Usage: zyxel vlan <command> [flags]")
+			fmt.Println()
+			fmt.Println("Commands:")
+			for _, c := range vlanCommandList {
+				fmt.Printf("  %-12s %s\n", c.name, c.summary)
+			}
+			os.Exit(1)
+		}
+		for _, c := range vlanCommandList {
+			if c.name == args[0] {
+				c.invoke("vlan "+c.name, args[1:])
+				return
+			}
+		}
+		fatal("Unknown vlan command %q", args[0])
+	}
+}
+
+// vlanFlags are shared by the vlan subcommands.
+type vlanFlags struct {
+	conn        *connFlags
+	save        *bool
+	allowUplink *bool
+	dryRun      *bool
+}
+
+func addVLANFlags(fs *flag.FlagSet) *vlanFlags {
+	return &vlanFlags{
+		conn:        addConnFlags(fs),
+		save:        fs.Bool("save", false, tr("Write memory after the change is verified")),
+		allowUplink: fs.Bool("allow-uplink", false, tr("Change the untagged VLAN of ports even if they look like uplinks")),
+		dryRun:      addDryRunFlag(fs),
+	}
+}
+
+// connect parses the VLAN ID argument and opens a session with the VLAN
+// table read, exiting on failure or when the configuration syntax of the
+// switch is not known.
+func (vf *vlanFlags) connect(fs *flag.FlagSet, usage string) (int, *Session, map[int]VLAN) {
+	if fs.NArg() < 1 {
+		fatal("Usage: zyxel vlan %s", usage)
+	}
+	id, err := strconv.Atoi(fs.Arg(0))
+	if err != nil || id < 1 || id > 4094 {
+		fatal("invalid VLAN ID %q (want 1-4094)", fs.Arg(0))
+	}
+	_, s := vf.conn.connect()
+	s.dryRun = *vf.dryRun
+	if err := s.checkConfigSyntax(); err != nil {
+		s.Close()
+		fatal("%v", err)
+	}
+	vlans, err := showVLAN(s)
+	if err != nil {
+		s.Close()
+		fatal("%v", err)
+	}
+	return id, s, vlans
+}
+
+// apply runs commands, reads show vlan back and hands the VLAN to verify.
+// When all is well it reports done and saves if asked.
+func (vf *vlanFlags) apply(s *Session, id int, commands []string, done string, verify func(v VLAN, ok bool) error) {
+	commands, err := s.expandPorts(commands)
+	if err != nil {
+		fatal("%v", err)
+	}
+	if err := s.Configure(commands, io.Discard); err != nil {
+		fatal("%v", err)
+	}
+	if s.dryRun {
+		if *vf.save {
+			saveConfig(s)
+		}
+		return
+	}
+	vlans, err := showVLAN(s)
+	if err != nil {
+		fatal("%v", err)
+	}
+	v, ok := vlans[id]
+	if err := verify(v, ok); err != nil {
+		fatal("%v", err)
+	}
+	fmt.Fprintln(os.Stderr, done)
+	if *vf.save {
+		saveConfig(s)
+	}
+}
+
+func runVLANCreate(fs *flag.FlagSet) func() {
+	vf := addVLANFlags(fs)
+	name := fs.String("name", "", tr("VLAN name"))
+	return func() {
+		id, s, vlans := vf.connect(fs, "create <id> [--name <name>]")
+		defer s.Close()
+		if _, ok := vlans[id]; ok {
+			fatal("VLAN %d already exists", id)
+		}
+
+		cmd := s.command
+		commands := []string{fmt.Sprintf("%s %d", cmd("vlan-config"), id)}
+		if *name != "" {
+			commands = append(commands, cmd("vlan-name")+" "+*name)
+		}
+		commands = append(commands, "exit")
+		vf.apply(s, id, commands, fmt.Sprintf("VLAN %d created", id), func(_ VLAN, ok bool) error {
+			if !ok {
+				return fmt.Errorf("VLAN %d is missing from show vlan after creating it", id)
+			}
+			return nil
+		})
+	}
+}
+
+func runVLANDelete(fs *flag.FlagSet) func() {
+	vf := addVLANFlags(fs)
+	return func() {
+		id, s, vlans := vf.connect(fs, "delete <id>")
+		defer s.Close()
+		if id == 1 {
+			fatal("VLAN 1 is the default VLAN and cannot be deleted")
+		}
+		if _, ok := vlans[id]; !ok {
+			fatal("VLAN %d does not exist", id)
+		}
+
+		vf.apply(s, id, []string{fmt.Sprintf("no %s %d", s.command("vlan-config"), id)}, fmt.Sprintf("VLAN %d deleted", id), func(_ VLAN, ok bool) error {
+			if ok {
+				return fmt.Errorf("VLAN %d is still in show vlan after deleting it", id)
+			}
+			return nil
+		})
+	}
+}
+
+func runVLANAddPort(fs *flag.FlagSet) func() {
+	vf := addVLANFlags(fs)
+	tagged := fs.String("tagged", "", tr("Ports to add as tagged members"))
+	untagged := fs.String("untagged", "", tr("Ports to add as untagged members, with the VLAN as their PVID"))
+	return func() {
+		if *tagged == "" && *untagged == "" {
+			fatal("Usage: zyxel vlan add-port <id> --tagged <ports> | --untagged <ports>")
+		}
+		var m vlanMembership
+		var err error
+		if *tagged != "" {
+			if m.Tagged, err = ParsePortList(*tagged); err != nil {
+				fatal("%v", err)
+			}
+		}
+		if *untagged != "" {
+			if m.Untagged, err = ParsePortList(*untagged); err != nil {
+				fatal("%v", err)
+			}
+		}
+
+		id, s, vlans := vf.connect(fs, "add-port <id> --tagged <ports> | --untagged <ports>")
+		defer s.Close()
+		if _, ok := vlans[id]; !ok {
+			fatal("VLAN %d does not exist; create it with zyxel vlan create %d", id, id)
+		}
+		if err := guardUplinks(s, m.Untagged, *vf.allowUplink); err != nil {
+			fatal("%v", err)
+		}
+
+		vf.apply(s, id, vlanPortCommands(s.command, id, *tagged, *untagged, ""), fmt.Sprintf("VLAN %d: ports added", id), func(v VLAN, _ bool) error {
+			return m.check(v)
+		})
+	}
+}
+
+func runVLANRemovePort(fs *flag.FlagSet) func() {
+	vf := addVLANFlags(fs)
+	return func() {
+		if fs.NArg() != 2 {
+			fatal("Usage: zyxel vlan remove-port <id> <ports>")
+		}
+		ports, err := ParsePortList(fs.Arg(1))
+		if err != nil {
+			fatal("%v", err)
+		}
+
+		id, s, vlans := vf.connect(fs, "remove-port <id> <ports>")
+		defer s.Close()
+		if _, ok := vlans[id]; !ok {
+			fatal("VLAN %d does not exist", id)
+		}
+		if err := guardUplinks(s, ports, *vf.allowUplink); err != nil {
+			fatal("%v", err)
+		}
+
+		m := vlanMembership{Removed: ports}
+		vf.apply(s, id, vlanPortCommands(s.command, id, "", "", fs.Arg(1)), fmt.Sprintf("VLAN %d: ports removed", id), func(v VLAN, _ bool) error {
+			return m.check(v)
+		})
+	}
+}
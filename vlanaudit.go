@@ -0,0 +1,158 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+)
+
+type vlanData struct {
+	vlans     map[int]VLAN
+	neighbors []LLDPNeighbor
+}
+
+// link is an LLDP adjacency between two inventory hosts.
+type link struct {
+	A, B   string
+	PA, PB Port
+}
+
+func runAuditVLAN(fs *flag.FlagSet) func() {
+	ff := addFleetFlags(fs)
+	return func() {
+		inv, hosts := ff.load()
+		results := runFleet(hosts, ff, func(h Host, s *Session) (vlanData, error) {
+			rc, err := runningConfig(s)
+			if err != nil {
+				return vlanData{}, err
+			}
+			neighbors, err := lldpNeighbors(s)
+			return vlanData{rc.vlans(), neighbors}, err
+		})
+
+		reportFindings(results, auditVLAN(inv, results))
+	}
+}
+
+// fleetLinks returns every LLDP link between two audited inventory hosts,
+// each once.
+func fleetLinks(inv *Inventory, neighbors map[string][]LLDPNeighbor) []link {
+	var links []link
+	seen := make(map[string]bool)
+	for name, list := range neighbors {
+		for _, n := range list {
+			remote, ok := inv.byNeighbor(n)
+			if !ok || remote.Name == name {
+				continue
+			}
+			if _, ok := neighbors[remote.Name]; !ok {
+				continue
+			}
+			pa, err1 := parsePort(n.LocalPort)
+			pb, err2 := parsePort(n.PortID)
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			l := link{name, remote.Name, pa, pb}
+			if l.B < l.A {
+				l = link{l.B, l.A, l.PB, l.PA}
+			}
+			key := fmt.Sprintf("%s|%s|%s|%s", l.A, l.PA, l.B, l.PB)
+			if !seen[key] {
+				seen[key] = true
+				links = append(links, l)
+			}
+		}
+	}
+	sort.Slice(links, func(i, j int) bool {
+		if links[i].A != links[j].A {
+			return links[i].A < links[j].A
+		}
+		return links[i].PA.less(links[j].PA)
+	})
+	return links
+}
+
+// auditVLAN reports VLANs carried on only one end of a link, tagging
+// mismatches across a link, and VLANs that cannot reach the switch named
+// as their gateway in the inventory.
+func auditVLAN(inv *Inventory, results []fleetResult[vlanData]) []finding {
+	data := make(map[string]vlanData)
+	neighbors := make(map[string][]LLDPNeighbor)
+	for _, r := range results {
+		if r.Err == nil {
+			data[r.Host.Name] = r.Value
+			neighbors[r.Host.Name] = r.Value.neighbors
+		}
+	}
+	links := fleetLinks(inv, neighbors)
+
+	var findings []finding
+	for _, l := range links {
+		a, b := data[l.A].vlans, data[l.B].vlans
+		ids := make(map[int]bool)
+		for id := range a {
+			ids[id] = true
+		}
+		for id := range b {
+			ids[id] = true
+		}
+		for _, id := range sortedKeys(ids) {
+			va, vb := a[id], b[id]
+			ma, mb := va.isMember(l.PA), vb.isMember(l.PB)
+			switch {
+			case ma && !mb:
+				findings = append(findings, finding{l.A, fmt.Sprintf(
+					"VLAN %d is on %s port %s but missing on %s port %s", id, l.A, l.PA, l.B, l.PB)})
+			case mb && !ma:
+				findings = append(findings, finding{l.B, fmt.Sprintf(
+					"VLAN %d is on %s port %s but missing on %s port %s", id, l.B, l.PB, l.A, l.PA)})
+			case ma && mb && va.isUntagged(l.PA) != vb.isUntagged(l.PB):
+				findings = append(findings, finding{l.A, fmt.Sprintf(
+					"VLAN %d is tagged on one end only of link %s %s <-> %s %s", id, l.A, l.PA, l.B, l.PB)})
+			}
+		}
+	}
+
+	for _, id := range sortedKeys(inv.VLANGateways) {
+		gw := inv.VLANGateways[id]
+		if _, ok := data[gw]; !ok {
+			continue
+		}
+		reached := map[string]bool{gw: true}
+		queue := []string{gw}
+		for len(queue) > 0 {
+			cur := queue[0]
+			queue = queue[1:]
+			for _, l := range links {
+				next, pCur, pNext := "", Port{}, Port{}
+				switch cur {
+				case l.A:
+					next, pCur, pNext = l.B, l.PA, l.PB
+				case l.B:
+					next, pCur, pNext = l.A, l.PB, l.PA
+				default:
+					continue
+				}
+				if reached[next] {
+					continue
+				}
+				if data[cur].vlans[id].isMember(pCur) && data[next].vlans[id].isMember(pNext) {
+					reached[next] = true
+					queue = append(queue, next)
+				}
+			}
+		}
+
+		for _, r := range results {
+			if r.Err != nil || reached[r.Host.Name] {
+				continue
+			}
+			if v, ok := r.Value.vlans[id]; ok && len(v.Members) > 0 {
+				findings = append(findings, finding{r.Host.Name, fmt.Sprintf(
+					"VLAN %d is defined here but has no tagged path to its gateway %s", id, gw)})
+			}
+		}
+	}
+	return findings
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// watchCommands re-runs commands on s every interval, redrawing the screen
+// and highlighting the lines that changed since the previous run. It runs
+// until interrupted, or the connection is lost for good.
+func watchCommands(s *Session, commands []string, interval time.Duration, raw bool, annotate func(string) string, wrap int) {
+	var prev string
+	for first := true; ; first = false {
+		var b strings.Builder
+		for _, c := range commands {
+			// A dropped connection is reconnected, and the command run
+			// again without the output of the lost attempt.
+			var cb strings.Builder
+			err := s.retry(c, nil, func() error {
+				cb.Reset()
+				out := newLineStreamer(&cb, raw)
+				out.annotate = annotate
+				out.wrap = wrap
+				return s.Run(c, out.Write)
+			})
+			if err != nil {
+				fatal("%v", err)
+			}
+			b.WriteString(cb.String())
+		}
+		cur := b.String()
+		writeWatchFrame(os.Stdout, commands, interval, lineDiff(prev, cur), first)
+		prev = cur
+		time.Sleep(interval)
+	}
+}
+
+// writeWatchFrame clears the screen and prints one run. Added or changed
+// lines are shown in reverse video, or marked with "*" in plain mode.
+func writeWatchFrame(w io.Writer, commands []string, interval time.Duration, d []diffLine, first bool) {
+	if plain {
+		fmt.Fprintln(w)
+	} else {
+		fmt.Fprint(w, "\x1b[H\x1b[2J")
+	}
+	fmt.Fprintf(w, "Every %s: %s    %s\n\n", interval, strings.Join(commands, "; "), time.Now().Format("15:04:05"))
+	for _, l := range d {
+		changed := l.Op == '+' && !first
+		switch {
+		case l.Op == '-':
+		case plain && changed:
+			fmt.Fprintf(w, "* %s\n", l.Text)
+		case plain:
+			fmt.Fprintf(w, "  %s\n", l.Text)
+		case changed:
+			fmt.Fprintf(w, "\x1b[7m%s\x1b[0m\n", l.Text)
+		default:
+			fmt.Fprintln(w, l.Text)
+		}
+	}
+}
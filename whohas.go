@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// whohasData is what whohas reads from a switch: where MACs are learned
+// on access ports and the IP addresses bound to MACs.
+type whohasData struct {
+	macs []macSighting
+	ips  []ipSighting
+}
+
+func readWhohas(h Host, s *Session) (whohasData, error) {
+	now := time.Now()
+	entries, err := macTable(s)
+	if err != nil {
+		return whohasData{}, err
+	}
+	neighbors, err := lldpNeighbors(s)
+	if err != nil {
+		return whohasData{}, err
+	}
+	d := whohasData{macs: edgeSightings(h, macData{entries, neighbors}, now)}
+
+	arp, err := arpTable(s)
+	if err != nil {
+		return whohasData{}, err
+	}
+	for _, e := range arp {
+		d.ips = append(d.ips, ipSighting{now, h.Name, e.MAC, e.IP, "arp"})
+	}
+	bindings, err := dhcpBindings(s)
+	if err != nil {
+		return whohasData{}, err
+	}
+	for _, b := range bindings {
+		d.ips = append(d.ips, ipSighting{now, h.Name, b.MAC, b.IP, "dhcp-snooping"})
+	}
+	return d, nil
+}
+
+func runWhohas(fs *flag.FlagSet) func() {
+	fleet := fs.Bool("fleet", false, tr("Ask every inventory switch instead of one"))
+	cf := addConnFlags(fs)
+	ff := addFleetFlags(fs)
+	return func() {
+		if fs.NArg() != 1 {
+			fatal("Usage: zyxel whohas <ip> [--fleet]")
+		}
+		ip := net.ParseIP(fs.Arg(0))
+		if ip == nil {
+			fatal("Invalid IP address %q", fs.Arg(0))
+		}
+
+		var results []fleetResult[whohasData]
+		if *fleet {
+			_, hosts := ff.load()
+			results = runFleet(hosts, ff, readWhohas)
+		} else {
+			cfg, s := cf.connect()
+			h := Host{Name: cfg.Host, Address: cfg.Host}
+			d, err := readWhohas(h, s)
+			s.Close()
+			results = []fleetResult[whohasData]{{h, d, err}}
+		}
+
+		// The MACs the IP is bound to, by any switch's ARP table or DHCP
+		// snooping bindings.
+		macs := make(map[string][]string)
+		for _, r := range results {
+			if r.Err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", r.Host.Name, r.Err)
+				continue
+			}
+			for _, b := range r.Value.ips {
+				src := b.Source + " on " + b.Host
+				if parsed := net.ParseIP(b.IP); parsed != nil && parsed.Equal(ip) && !slices.Contains(macs[b.MAC], src) {
+					macs[b.MAC] = append(macs[b.MAC], src)
+				}
+			}
+		}
+		if len(macs) == 0 {
+			fatal("%s is in no ARP table or DHCP snooping binding", ip)
+		}
+
+		found := 0
+		for _, mac := range sortedStringKeys(macs) {
+			fmt.Printf("%s is %s (%s)\n", ip, mac, strings.Join(macs[mac], ", "))
+			for _, r := range results {
+				for _, s := range r.Value.macs {
+					if s.MAC == mac {
+						found++
+						fmt.Printf("%s port %s VLAN %d\n", r.Host.Name, s.Port, s.VLAN)
+					}
+				}
+			}
+		}
+		if found == 0 {
+			os.Exit(1)
+		}
+	}
+}